@@ -34,6 +34,7 @@ type ABI struct {
 	Constructor Method
 	Methods     map[string]Method
 	Events      map[string]Event
+	Errors      map[string]Error
 
 	// Additional "special" functions introduced in solidity v0.6.0.
 	// It's separated from the original default fallback. Each contract
@@ -157,6 +158,7 @@ func (abi *ABI) UnmarshalJSON(data []byte) error {
 	}
 	abi.Methods = make(map[string]Method)
 	abi.Events = make(map[string]Event)
+	abi.Errors = make(map[string]Error)
 	for _, field := range fields {
 		switch field.Type {
 		case "constructor":
@@ -184,6 +186,11 @@ func (abi *ABI) UnmarshalJSON(data []byte) error {
 		case "event":
 			name := abi.overloadedEventName(field.Name)
 			abi.Events[name] = NewEvent(name, field.Name, field.Anonymous, field.Inputs)
+		case "error":
+			// Custom errors introduced in v0.8.4, check more detail
+			// here https://docs.soliditylang.org/en/v0.8.4/contracts.html#errors-and-the-revert-statement
+			name := abi.overloadedErrorName(field.Name)
+			abi.Errors[name] = NewError(name, field.Name, field.Inputs)
 		default:
 			return fmt.Errorf("abi: could not recognize type %v of field %v", field.Type, field.Name)
 		}
@@ -221,6 +228,21 @@ func (abi *ABI) overloadedEventName(rawName string) string {
 	return name
 }
 
+// overloadedErrorName returns the next available name for a given error.
+// Needed since solidity allows for error overload.
+//
+// e.g. if the abi contains errors failed, failed1
+// overloadedErrorName would return failed2 for input failed.
+func (abi *ABI) overloadedErrorName(rawName string) string {
+	name := rawName
+	_, ok := abi.Errors[name]
+	for idx := 0; ok; idx++ {
+		name = fmt.Sprintf("%s%d", rawName, idx)
+		_, ok = abi.Errors[name]
+	}
+	return name
+}
+
 // MethodById looks up a method by the 4-byte id,
 // returns nil if none found.
 func (abi *ABI) MethodById(sigdata []byte) (*Method, error) {
@@ -246,6 +268,20 @@ func (abi *ABI) EventByID(topic common.Hash) (*Event, error) {
 	return nil, fmt.Errorf("no event with id: %#x", topic.Hex())
 }
 
+// ErrorByID looks up a custom error by the 4-byte selector of its revert data,
+// returns nil if none found.
+func (abi *ABI) ErrorByID(sigdata []byte) (*Error, error) {
+	if len(sigdata) < 4 {
+		return nil, fmt.Errorf("data too short (%d bytes) for abi error lookup", len(sigdata))
+	}
+	for _, errABI := range abi.Errors {
+		if bytes.Equal(errABI.Selector(), sigdata[:4]) {
+			return &errABI, nil
+		}
+	}
+	return nil, fmt.Errorf("no error with id: %#x", sigdata[:4])
+}
+
 // HasFallback returns an indicator whether a fallback function is included.
 func (abi *ABI) HasFallback() bool {
 	return abi.Fallback.Type == Fallback
@@ -277,3 +313,18 @@ func UnpackRevert(data []byte) (string, error) {
 	}
 	return unpacked[0].(string), nil
 }
+
+// UnpackError decodes revert data into one of the custom errors declared in the
+// ABI. Reverts with a plain reason string are not custom errors and fail, use
+// UnpackRevert for those.
+func (abi *ABI) UnpackError(data []byte) (*CustomError, error) {
+	errABI, err := abi.ErrorByID(data)
+	if err != nil {
+		return nil, err
+	}
+	values, err := errABI.Unpack(data)
+	if err != nil {
+		return nil, err
+	}
+	return &CustomError{Decl: errABI, Values: values}, nil
+}
@@ -1146,3 +1146,53 @@ func TestUnpackRevert(t *testing.T) {
 		})
 	}
 }
+
+func TestUnpackCustomError(t *testing.T) {
+	t.Parallel()
+
+	const definition = `[
+	{"type":"error","name":"InsufficientBalance","inputs":[{"name":"available","type":"uint256"},{"name":"required","type":"uint256"}]},
+	{"type":"error","name":"Unauthorized","inputs":[]},
+	{"type":"error","name":"Unauthorized","inputs":[{"name":"","type":"address"}]}
+	]`
+	abi, err := JSON(strings.NewReader(definition))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(abi.Errors) != 3 {
+		t.Fatalf("error count mismatch: have %d, want %d", len(abi.Errors), 3)
+	}
+	if sig := abi.Errors["Unauthorized0"].Sig; sig != "Unauthorized(address)" {
+		t.Fatalf("overloaded error signature mismatch: have %s, want %s", sig, "Unauthorized(address)")
+	}
+	if name := abi.Errors["Unauthorized0"].Inputs[0].Name; name != "arg0" {
+		t.Fatalf("unnamed input not sanitized: have %q", name)
+	}
+	// Decode a revert with the custom error parameters
+	insufficient := abi.Errors["InsufficientBalance"]
+	if have, want := common.Bytes2Hex(insufficient.Selector()), "cf479181"; have != want {
+		t.Fatalf("selector mismatch: have %s, want %s", have, want)
+	}
+	params, err := insufficient.Inputs.Pack(big.NewInt(1), big.NewInt(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	custom, err := abi.UnpackError(append(insufficient.Selector(), params...))
+	if err != nil {
+		t.Fatalf("failed to unpack custom error: %v", err)
+	}
+	if custom.Decl.Name != "InsufficientBalance" {
+		t.Fatalf("error mismatch: have %s, want %s", custom.Decl.Name, "InsufficientBalance")
+	}
+	if have, want := custom.Error(), "InsufficientBalance(available: 1, required: 2)"; have != want {
+		t.Fatalf("error string mismatch: have %s, want %s", have, want)
+	}
+	// Plain revert reasons and malformed data must be rejected
+	reason := common.Hex2Bytes("08c379a00000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000000d72657665727420726561736f6e00000000000000000000000000000000000000")
+	if _, err := abi.UnpackError(reason); err == nil {
+		t.Fatalf("revert reason decoded as custom error")
+	}
+	if _, err := abi.UnpackError(insufficient.Selector()); err == nil {
+		t.Fatalf("truncated custom error decoded")
+	}
+}
@@ -36,6 +36,10 @@ var (
 	// on a backend that doesn't implement PendingContractCaller.
 	ErrNoPendingState = errors.New("backend does not support pending state")
 
+	// This error is raised when attempting to create an access list automatically
+	// on a backend that doesn't implement AccessListCreator.
+	ErrNoAccessListCreator = errors.New("backend does not support access list creation")
+
 	// This error is returned by WaitDeployed if contract creation leaves an
 	// empty contract behind.
 	ErrNoCodeAfterDeploy = errors.New("no contract code after deployment")
@@ -84,6 +88,17 @@ type ContractTransactor interface {
 	SendTransaction(ctx context.Context, tx *types.Transaction) error
 }
 
+// AccessListCreator defines the methods needed to let the backend compute the
+// EIP-2930 access list of a transaction. Transact will try to discover this interface
+// when the access list is requested to be created automatically. If the backend does
+// not support it, Transact returns ErrNoAccessListCreator.
+type AccessListCreator interface {
+	// CreateAccessList executes the transaction against the pending state and returns
+	// the accounts and storage slots it touches, the gas it used with the access list
+	// attached and the execution error if it failed.
+	CreateAccessList(ctx context.Context, call ethereum.CallMsg) (*types.AccessList, uint64, string, error)
+}
+
 // ContractFilterer defines the methods needed to access log events using one-off
 // queries or continuous event subscriptions.
 type ContractFilterer interface {
@@ -37,6 +37,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/eth/filters"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
@@ -45,8 +46,12 @@ import (
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
-// This nil assignment ensures at compile time that SimulatedBackend implements bind.ContractBackend.
-var _ bind.ContractBackend = (*SimulatedBackend)(nil)
+// These nil assignments ensure at compile time that SimulatedBackend implements
+// bind.ContractBackend and bind.AccessListCreator.
+var (
+	_ bind.ContractBackend   = (*SimulatedBackend)(nil)
+	_ bind.AccessListCreator = (*SimulatedBackend)(nil)
+)
 
 var (
 	errBlockNumberUnsupported  = errors.New("simulatedBackend cannot access blocks other than the latest block")
@@ -388,7 +393,7 @@ func (b *SimulatedBackend) CallContract(ctx context.Context, call ethereum.CallM
 	if err != nil {
 		return nil, err
 	}
-	res, err := b.callContract(ctx, call, b.blockchain.CurrentBlock(), stateDB)
+	res, err := b.callContract(ctx, call, b.blockchain.CurrentBlock(), stateDB, vm.Config{})
 	if err != nil {
 		return nil, err
 	}
@@ -405,7 +410,7 @@ func (b *SimulatedBackend) PendingCallContract(ctx context.Context, call ethereu
 	defer b.mu.Unlock()
 	defer b.pendingState.RevertToSnapshot(b.pendingState.Snapshot())
 
-	res, err := b.callContract(ctx, call, b.pendingBlock, b.pendingState)
+	res, err := b.callContract(ctx, call, b.pendingBlock, b.pendingState, vm.Config{})
 	if err != nil {
 		return nil, err
 	}
@@ -476,7 +481,7 @@ func (b *SimulatedBackend) EstimateGas(ctx context.Context, call ethereum.CallMs
 		call.Gas = gas
 
 		snapshot := b.pendingState.Snapshot()
-		res, err := b.callContract(ctx, call, b.pendingBlock, b.pendingState)
+		res, err := b.callContract(ctx, call, b.pendingBlock, b.pendingState, vm.Config{})
 		b.pendingState.RevertToSnapshot(snapshot)
 
 		if err != nil {
@@ -526,7 +531,7 @@ func (b *SimulatedBackend) EstimateGas(ctx context.Context, call ethereum.CallMs
 
 // callContract implements common code between normal and pending contract calls.
 // state is modified during execution, make sure to copy it if necessary.
-func (b *SimulatedBackend) callContract(ctx context.Context, call ethereum.CallMsg, block *types.Block, stateDB *state.StateDB) (*core.ExecutionResult, error) {
+func (b *SimulatedBackend) callContract(ctx context.Context, call ethereum.CallMsg, block *types.Block, stateDB *state.StateDB, vmConfig vm.Config) (*core.ExecutionResult, error) {
 	// Ensure message is initialized properly.
 	if call.GasPrice == nil {
 		call.GasPrice = big.NewInt(1)
@@ -547,12 +552,50 @@ func (b *SimulatedBackend) callContract(ctx context.Context, call ethereum.CallM
 	evmContext := core.NewEVMBlockContext(block.Header(), b.blockchain, nil)
 	// Create a new environment which holds all relevant information
 	// about the transaction and calling mechanisms.
-	vmEnv := vm.NewEVM(evmContext, txContext, stateDB, b.config, vm.Config{})
+	vmEnv := vm.NewEVM(evmContext, txContext, stateDB, b.config, vmConfig)
 	gasPool := new(core.GasPool).AddGas(math.MaxUint64)
 
 	return core.NewStateTransition(vmEnv, msg, gasPool).TransitionDb()
 }
 
+// CreateAccessList executes the requested transaction against the pending state
+// and returns the accounts and storage slots it touches as an EIP-2930 access list.
+// Since the access list may alter the execution path, the transaction is repeated
+// until the list stabilizes.
+func (b *SimulatedBackend) CreateAccessList(ctx context.Context, call ethereum.CallMsg) (*types.AccessList, uint64, string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// The sender, recipient and precompiles are warm already, exclude them
+	to := crypto.CreateAddress(call.From, b.pendingState.GetNonce(call.From))
+	if call.To != nil {
+		to = *call.To
+	}
+	precompiles := vm.ActivePrecompiles(b.config.Rules(b.pendingBlock.Number()))
+
+	prevTracer := vm.NewAccessListTracer(call.AccessList, call.From, to, precompiles)
+	for {
+		call.AccessList = prevTracer.AccessList()
+		tracer := vm.NewAccessListTracer(call.AccessList, call.From, to, precompiles)
+
+		snapshot := b.pendingState.Snapshot()
+		res, err := b.callContract(ctx, call, b.pendingBlock, b.pendingState, vm.Config{Debug: true, Tracer: tracer})
+		b.pendingState.RevertToSnapshot(snapshot)
+
+		if err != nil {
+			return nil, 0, "", err
+		}
+		if tracer.Equal(prevTracer) {
+			var vmerr string
+			if res.Err != nil {
+				vmerr = res.Err.Error()
+			}
+			return &call.AccessList, res.UsedGas, vmerr, nil
+		}
+		prevTracer = tracer
+	}
+}
+
 // SendTransaction updates the pending block to include the given transaction.
 // It panics if the transaction is invalid.
 func (b *SimulatedBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
@@ -1114,3 +1114,73 @@ func TestSimulatedBackend_CallContractRevert(t *testing.T) {
 		sim.Commit()
 	}
 }
+
+func TestSimulatedBackend_CreateAccessList(t *testing.T) {
+	testAddr := crypto.PubkeyToAddress(testKey.PublicKey)
+	sim := simTestBackend(testAddr)
+	defer sim.Close()
+	bgCtx := context.Background()
+
+	// Deploy a contract reading storage slot 0x2a and the balance of 0xdeadbeef:
+	//   PUSH1 0x2a SLOAD POP PUSH20 0xdeadbeef BALANCE POP STOP
+	var (
+		other   = common.HexToAddress("deadbeef")
+		runtime = "602a545073" + common.Bytes2Hex(other[:]) + "315000"
+		deploy  = "601c600c600039601c6000f3" + runtime
+	)
+	auth, _ := bind.NewKeyedTransactorWithChainID(testKey, big.NewInt(1337))
+	addr, _, _, err := bind.DeployContract(auth, abi.ABI{}, common.FromHex(deploy), sim)
+	if err != nil {
+		t.Fatalf("could not deploy contract: %v", err)
+	}
+	sim.Commit()
+
+	// Ensure the backend reports the touched account and slot
+	acl, gas, vmerr, err := sim.CreateAccessList(bgCtx, ethereum.CallMsg{From: testAddr, To: &addr})
+	if err != nil || vmerr != "" {
+		t.Fatalf("failed to create access list: %v %v", err, vmerr)
+	}
+	if len(*acl) != 2 {
+		t.Fatalf("access list length mismatch: have %d, want 2", len(*acl))
+	}
+	for _, tuple := range *acl {
+		switch tuple.Address {
+		case addr:
+			if len(tuple.StorageKeys) != 1 || tuple.StorageKeys[0] != common.BigToHash(big.NewInt(0x2a)) {
+				t.Errorf("contract storage keys mismatch: have %v", tuple.StorageKeys)
+			}
+		case other:
+			if len(tuple.StorageKeys) != 0 {
+				t.Errorf("account storage keys mismatch: have %v", tuple.StorageKeys)
+			}
+		default:
+			t.Errorf("unexpected account in access list: %x", tuple.Address)
+		}
+	}
+	// Ensure bound contracts can send the access list along with the transaction
+	contract := bind.NewBoundContract(addr, abi.ABI{}, sim, sim, sim)
+	auth.CreateAccessList = true
+
+	tx, err := contract.RawTransact(auth, nil)
+	if err != nil {
+		t.Fatalf("failed to transact: %v", err)
+	}
+	sim.Commit()
+
+	if tx.Type() != types.AccessListTxType {
+		t.Errorf("transaction type mismatch: have %d, want %d", tx.Type(), types.AccessListTxType)
+	}
+	if len(tx.AccessList()) != 2 {
+		t.Errorf("transaction access list length mismatch: have %d, want 2", len(tx.AccessList()))
+	}
+	receipt, err := sim.TransactionReceipt(bgCtx, tx.Hash())
+	if err != nil {
+		t.Fatalf("failed to retrieve receipt: %v", err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		t.Errorf("transaction failed")
+	}
+	if receipt.GasUsed != gas {
+		t.Errorf("gas used mismatch: have %d, want %d", receipt.GasUsed, gas)
+	}
+}
@@ -25,9 +25,11 @@ import (
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
 // SignerFn is a signer function callback when a contract requires a method to
@@ -53,6 +55,10 @@ type TransactOpts struct {
 	GasPrice *big.Int // Gas price to use for the transaction execution (nil = gas price oracle)
 	GasLimit uint64   // Gas limit to set for the transaction execution (0 = estimate)
 
+	TxType           uint8            // Transaction type to create (0 = legacy, unless an access list is requested)
+	AccessList       types.AccessList // Optional EIP-2930 access list to attach to the transaction
+	CreateAccessList bool             // Whether to let the backend compute the access list (extending AccessList)
+
 	Context context.Context // Network context to support cancellation and timeouts (nil = no timeout)
 }
 
@@ -142,7 +148,10 @@ func (c *BoundContract) Call(opts *CallOpts, results *[]interface{}, method stri
 			return ErrNoPendingState
 		}
 		output, err = pb.PendingCallContract(ctx, msg)
-		if err == nil && len(output) == 0 {
+		if err != nil {
+			return c.unpackCustomError(err)
+		}
+		if len(output) == 0 {
 			// Make sure we have a contract to operate on, and bail out otherwise.
 			if code, err = pb.PendingCodeAt(ctx, c.address); err != nil {
 				return err
@@ -153,7 +162,7 @@ func (c *BoundContract) Call(opts *CallOpts, results *[]interface{}, method stri
 	} else {
 		output, err = c.caller.CallContract(ctx, msg, opts.BlockNumber)
 		if err != nil {
-			return err
+			return c.unpackCustomError(err)
 		}
 		if len(output) == 0 {
 			// Make sure we have a contract to operate on, and bail out otherwise.
@@ -229,6 +238,32 @@ func (c *BoundContract) transact(opts *TransactOpts, contract *common.Address, i
 			return nil, fmt.Errorf("failed to suggest gas price: %v", err)
 		}
 	}
+	// Figure out the transaction type and assemble the access list if requested
+	txType := opts.TxType
+	if txType == types.LegacyTxType && (opts.AccessList != nil || opts.CreateAccessList) {
+		txType = types.AccessListTxType
+	}
+	if txType != types.LegacyTxType && txType != types.AccessListTxType {
+		return nil, fmt.Errorf("unsupported transaction type %d", txType)
+	}
+	accessList := opts.AccessList
+	if opts.CreateAccessList {
+		creator, ok := c.transactor.(AccessListCreator)
+		if !ok {
+			return nil, ErrNoAccessListCreator
+		}
+		msg := ethereum.CallMsg{From: opts.From, To: contract, GasPrice: gasPrice, Value: value, Data: input, AccessList: accessList}
+		acl, _, vmerr, err := creator.CreateAccessList(ensureContext(opts.Context), msg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create access list: %v", err)
+		}
+		if vmerr != "" {
+			return nil, fmt.Errorf("failed to create access list: %v", vmerr)
+		}
+		if acl != nil {
+			accessList = *acl
+		}
+	}
 	gasLimit := opts.GasLimit
 	if gasLimit == 0 {
 		// Gas estimation cannot succeed without code for method invocations
@@ -240,17 +275,34 @@ func (c *BoundContract) transact(opts *TransactOpts, contract *common.Address, i
 			}
 		}
 		// If the contract surely has code (or code is not needed), estimate the transaction
-		msg := ethereum.CallMsg{From: opts.From, To: contract, GasPrice: gasPrice, Value: value, Data: input}
+		msg := ethereum.CallMsg{From: opts.From, To: contract, GasPrice: gasPrice, Value: value, Data: input, AccessList: accessList}
 		gasLimit, err = c.transactor.EstimateGas(ensureContext(opts.Context), msg)
 		if err != nil {
+			if cerr, ok := c.unpackCustomError(err).(*ContractError); ok {
+				cerr.err = fmt.Errorf("failed to estimate gas needed: %v", err)
+				return nil, cerr
+			}
 			return nil, fmt.Errorf("failed to estimate gas needed: %v", err)
 		}
 	}
 	// Create the transaction, sign it and schedule it for execution
 	var rawTx *types.Transaction
-	if contract == nil {
+	switch {
+	case txType == types.AccessListTxType:
+		// The chain id is left unspecified, the signer fills it in
+		rawTx = types.NewTx(&types.AccessListTx{
+			ChainID:    new(big.Int),
+			Nonce:      nonce,
+			GasPrice:   gasPrice,
+			Gas:        gasLimit,
+			To:         contract,
+			Value:      value,
+			Data:       input,
+			AccessList: accessList,
+		})
+	case contract == nil:
 		rawTx = types.NewContractCreation(nonce, value, gasLimit, gasPrice, input)
-	} else {
+	default:
 		rawTx = types.NewTransaction(nonce, c.address, value, gasLimit, gasPrice, input)
 	}
 	if opts.Signer == nil {
@@ -378,6 +430,52 @@ func (c *BoundContract) UnpackLogIntoMap(out map[string]interface{}, event strin
 	return abi.ParseTopicsIntoMap(out, indexed, log.Topics[1:])
 }
 
+// ContractError is returned by contract calls and gas estimations reverting with
+// a custom error declared in the contract ABI. It implements rpc.DataError, the
+// error data being the decoded custom error instead of the raw revert data.
+type ContractError struct {
+	err    error
+	Custom *abi.CustomError // Custom error the contract reverted with
+}
+
+// Error implements error, appending the decoded custom error to the failure.
+func (e *ContractError) Error() string {
+	return fmt.Sprintf("%v: %v", e.err, e.Custom)
+}
+
+// ErrorData implements rpc.DataError, returning the decoded custom error.
+func (e *ContractError) ErrorData() interface{} {
+	return e.Custom
+}
+
+// Unwrap returns the original error of the failed call or gas estimation.
+func (e *ContractError) Unwrap() error {
+	return e.err
+}
+
+// unpackCustomError decodes the revert data of a failed call or gas estimation
+// into a custom error of the contract, wrapping the failure into a ContractError
+// if successful. Any other errors are returned as is.
+func (c *BoundContract) unpackCustomError(err error) error {
+	var dataErr rpc.DataError
+	if !errors.As(err, &dataErr) {
+		return err
+	}
+	hexdata, ok := dataErr.ErrorData().(string)
+	if !ok {
+		return err
+	}
+	data, decErr := hexutil.Decode(hexdata)
+	if decErr != nil {
+		return err
+	}
+	custom, decErr := c.abi.UnpackError(data)
+	if decErr != nil {
+		return err
+	}
+	return &ContractError{err: err, Custom: custom}
+}
+
 // ensureContext is a helper method to ensure a context is not nil, even if the
 // user specified it as such.
 func ensureContext(ctx context.Context) context.Context {
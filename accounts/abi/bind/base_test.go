@@ -255,3 +255,57 @@ func newMockLog(topics []common.Hash, txHash common.Hash) types.Log {
 		Removed:     false,
 	}
 }
+
+// revertCaller is a contract caller which fails every call with the configured
+// revert data, mimicking the error returned by eth_call over RPC.
+type revertCaller struct {
+	mockCaller
+	data string
+}
+
+type revertError struct{ data string }
+
+func (e *revertError) Error() string          { return "execution reverted" }
+func (e *revertError) ErrorCode() int         { return 3 }
+func (e *revertError) ErrorData() interface{} { return e.data }
+
+func (rc *revertCaller) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return nil, &revertError{data: rc.data}
+}
+
+func TestCallCustomError(t *testing.T) {
+	const abiString = `[{"inputs":[],"name":"withdraw","outputs":[],"stateMutability":"view","type":"function"},{"inputs":[{"name":"available","type":"uint256"},{"name":"required","type":"uint256"}],"name":"InsufficientBalance","type":"error"}]`
+
+	parsedAbi, err := abi.JSON(strings.NewReader(abiString))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Revert data for InsufficientBalance(1, 2)
+	data := "0xcf479181" +
+		"0000000000000000000000000000000000000000000000000000000000000001" +
+		"0000000000000000000000000000000000000000000000000000000000000002"
+
+	bc := bind.NewBoundContract(common.HexToAddress("0x0"), parsedAbi, &revertCaller{data: data}, nil, nil)
+	err = bc.Call(nil, nil, "withdraw")
+
+	cerr, ok := err.(*bind.ContractError)
+	if !ok {
+		t.Fatalf("error type mismatch: have %T (%v), want *bind.ContractError", err, err)
+	}
+	if cerr.Custom.Decl.Name != "InsufficientBalance" {
+		t.Fatalf("decoded error mismatch: have %s, want InsufficientBalance", cerr.Custom.Decl.Name)
+	}
+	if len(cerr.Custom.Values) != 2 || cerr.Custom.Values[0].(*big.Int).Int64() != 1 || cerr.Custom.Values[1].(*big.Int).Int64() != 2 {
+		t.Fatalf("decoded values mismatch: have %v", cerr.Custom.Values)
+	}
+	if cerr.ErrorData() != cerr.Custom {
+		t.Fatalf("error data mismatch: have %v, want %v", cerr.ErrorData(), cerr.Custom)
+	}
+	// Revert data not matching any declared error should be passed through
+	bc = bind.NewBoundContract(common.HexToAddress("0x0"), parsedAbi, &revertCaller{data: "0xdeadbeef"}, nil, nil)
+	if err := bc.Call(nil, nil, "withdraw"); err == nil {
+		t.Fatal("expected call to fail")
+	} else if _, ok := err.(*bind.ContractError); ok {
+		t.Fatalf("unknown revert data decoded as custom error: %v", err)
+	}
+}
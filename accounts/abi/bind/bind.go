@@ -22,7 +22,6 @@ package bind
 
 import (
 	"bytes"
-	"errors"
 	"fmt"
 	"go/format"
 	"regexp"
@@ -77,6 +76,7 @@ func Bind(types []string, abis []string, bytecodes []string, fsigs []map[string]
 			calls     = make(map[string]*tmplMethod)
 			transacts = make(map[string]*tmplMethod)
 			events    = make(map[string]*tmplEvent)
+			errs      = make(map[string]*tmplError)
 			fallback  *tmplMethod
 			receive   *tmplMethod
 
@@ -87,6 +87,7 @@ func Bind(types []string, abis []string, bytecodes []string, fsigs []map[string]
 			callIdentifiers     = make(map[string]bool)
 			transactIdentifiers = make(map[string]bool)
 			eventIdentifiers    = make(map[string]bool)
+			errorIdentifiers    = make(map[string]bool)
 		)
 		for _, original := range evmABI.Methods {
 			// Normalize the method for capital cases and non-anonymous inputs/outputs
@@ -158,6 +159,31 @@ func Bind(types []string, abis []string, bytecodes []string, fsigs []map[string]
 			// Append the event to the accumulator list
 			events[original.Name] = &tmplEvent{Original: original, Normalized: normalized}
 		}
+		for _, original := range evmABI.Errors {
+			// Normalize the error for capital cases and non-anonymous inputs
+			normalized := original
+
+			// Ensure there is no duplicated identifier
+			normalizedName := methodNormalizer[lang](alias(aliases, original.Name))
+			if errorIdentifiers[normalizedName] || eventIdentifiers[normalizedName] {
+				return "", fmt.Errorf("duplicated identifier \"%s\"(normalized \"%s\"), use --alias for renaming", original.Name, normalizedName)
+			}
+			errorIdentifiers[normalizedName] = true
+			normalized.Name = normalizedName
+
+			normalized.Inputs = make([]abi.Argument, len(original.Inputs))
+			copy(normalized.Inputs, original.Inputs)
+			for j, input := range normalized.Inputs {
+				if input.Name == "" {
+					normalized.Inputs[j].Name = fmt.Sprintf("arg%d", j)
+				}
+				if hasStruct(input.Type) {
+					bindStructType[lang](input.Type, structs)
+				}
+			}
+			// Append the error to the accumulator list
+			errs[original.Name] = &tmplError{Original: original, Normalized: normalized}
+		}
 		// Add two special fallback functions if they exist
 		if evmABI.HasFallback() {
 			fallback = &tmplMethod{Original: evmABI.Fallback}
@@ -165,11 +191,6 @@ func Bind(types []string, abis []string, bytecodes []string, fsigs []map[string]
 		if evmABI.HasReceive() {
 			receive = &tmplMethod{Original: evmABI.Receive}
 		}
-		// There is no easy way to pass arbitrary java objects to the Go side.
-		if len(structs) > 0 && lang == LangJava {
-			return "", errors.New("java binding for tuple arguments is not supported yet")
-		}
-
 		contracts[types[i]] = &tmplContract{
 			Type:        capitalise(types[i]),
 			InputABI:    strings.Replace(strippedABI, "\"", "\\\"", -1),
@@ -180,6 +201,7 @@ func Bind(types []string, abis []string, bytecodes []string, fsigs []map[string]
 			Fallback:    fallback,
 			Receive:     receive,
 			Events:      events,
+			Errors:      errs,
 			Libraries:   make(map[string]string),
 		}
 		// Function 4-byte signatures are stored in the same sequence
@@ -222,6 +244,14 @@ func Bind(types []string, abis []string, bytecodes []string, fsigs []map[string]
 		"namedtype":     namedType[lang],
 		"capitalise":    capitalise,
 		"decapitalise":  decapitalise,
+		"hasstruct":     hasStruct,
+		"indent":        indent,
+		"javapack": func(name, value string, kind abi.Type) string {
+			return javaPack(name, value, kind, structs)
+		},
+		"javaunpack": func(name, target, source string, kind abi.Type) string {
+			return javaUnpack(name, target, source, kind, structs)
+		},
 	}
 	tmpl := template.Must(template.New("").Funcs(funcs).Parse(tmplSource[lang]))
 	if err := tmpl.Execute(buffer, data); err != nil {
@@ -469,7 +499,8 @@ func bindStructTypeJava(kind abi.Type, structs map[string]*tmplStruct) string {
 			field := bindStructTypeJava(*elem, structs)
 			fields = append(fields, &tmplField{Type: field, Name: decapitalise(kind.TupleRawNames[i]), SolKind: *elem})
 		}
-		name := kind.TupleRawName
+		// Java classes are conventionally capitalised, unlike raw Solidity names
+		name := capitalise(kind.TupleRawName)
 		if name == "" {
 			name = fmt.Sprintf("Class%d", len(structs))
 		}
@@ -495,6 +526,13 @@ var namedType = map[Lang]func(string, abi.Type) string{
 // namedTypeJava converts some primitive data types to named variants that can
 // be used as parts of method names.
 func namedTypeJava(javaKind string, solKind abi.Type) string {
+	// Structs are passed across as wrapped field lists
+	if hasStruct(solKind) {
+		if solKind.T == abi.TupleTy {
+			return "Tuple"
+		}
+		return "Tuples"
+	}
 	switch javaKind {
 	case "byte[]":
 		return "Binary"
@@ -518,6 +556,65 @@ func namedTypeJava(javaKind string, solKind abi.Type) string {
 	}
 }
 
+// javaPack generates the Java statements storing value of the given Solidity
+// type into the Interface called name. Structs are disassembled into wrapped
+// field lists, arrays of them element by element.
+func javaPack(name, value string, kind abi.Type, structs map[string]*tmplStruct) string {
+	switch {
+	case kind.T == abi.TupleTy:
+		return fmt.Sprintf("%s.setTuple(%s.toInterfaces());", name, value)
+
+	case hasStruct(kind):
+		var (
+			items = name + "Items"
+			item  = name + "Item"
+			index = name + "I"
+		)
+		return fmt.Sprintf("Interfaces %s = Geth.newInterfaces(%s.length);\n", items, value) +
+			fmt.Sprintf("for (int %s = 0; %s < %s.length; %s++) {\n", index, index, value, index) +
+			fmt.Sprintf("\tInterface %s = Geth.newInterface();\n", item) +
+			"\t" + indent(1, javaPack(item, fmt.Sprintf("%s[%s]", value, index), *kind.Elem, structs)) + "\n" +
+			fmt.Sprintf("\t%s.set(%s, %s);\n", items, index, item) +
+			"}\n" +
+			fmt.Sprintf("%s.setTuples(%s);", name, items)
+
+	default:
+		return fmt.Sprintf("%s.set%s(%s);", name, namedTypeJava(bindTypeJava(kind, structs), kind), value)
+	}
+}
+
+// javaUnpack generates the Java statements assigning the value of the given
+// Solidity type held by the Interface expression source to target. Temporary
+// variables are prefixed by name to keep nested conversions apart.
+func javaUnpack(name, target, source string, kind abi.Type, structs map[string]*tmplStruct) string {
+	switch {
+	case kind.T == abi.TupleTy:
+		return fmt.Sprintf("%s = %s.fromInterfaces(%s.getTuple());", target, bindTypeJava(kind, structs), source)
+
+	case hasStruct(kind):
+		var (
+			items = name + "Items"
+			index = name + "I"
+			typ   = bindTypeJava(kind, structs)
+			dim   = strings.Index(typ, "[")
+		)
+		return fmt.Sprintf("Interfaces %s = %s.getTuples();\n", items, source) +
+			fmt.Sprintf("%s = new %s[(int) %s.size()]%s;\n", target, typ[:dim], items, typ[dim+2:]) +
+			fmt.Sprintf("for (int %s = 0; %s < %s.size(); %s++) {\n", index, index, items, index) +
+			"\t" + indent(1, javaUnpack(name+"Item", fmt.Sprintf("%s[%s]", target, index), fmt.Sprintf("%s.get(%s)", items, index), *kind.Elem, structs)) + "\n" +
+			"}"
+
+	default:
+		return fmt.Sprintf("%s = %s.get%s();", target, source, namedTypeJava(bindTypeJava(kind, structs), kind))
+	}
+}
+
+// indent prefixes all but the first line of a generated code snippet with the
+// given number of tabs, so it lines up with the template position it's placed at.
+func indent(depth int, code string) string {
+	return strings.Replace(code, "\n", "\n"+strings.Repeat("\t", depth), -1)
+}
+
 // alias returns an alias of the given string based on the aliasing rules
 // or returns itself if no rule is matched.
 func alias(aliases map[string]string, n string) string {
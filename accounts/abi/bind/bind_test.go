@@ -971,6 +971,24 @@ var bindTests = []struct {
 			if err = sit.Error(); err != nil {
 				t.Fatalf("simple event iteration failed: %v", err)
 			}
+			// Test streaming the same events durably from the start of the chain
+			sch := make(chan *EventerSimpleEvent, 16)
+			ssub, err := eventer.StreamSimpleEvent(&bind.StreamOpts{Start: 1}, sch, []common.Address{common.Address{1}, common.Address{3}}, nil, []bool{true})
+			if err != nil {
+				t.Fatalf("failed to stream simple events: %v", err)
+			}
+			for _, want := range []uint64{11, 21, 31, 33} {
+				select {
+				case event := <-sch:
+					if event.Value.Uint64() != want || event.Raw.Removed {
+						t.Errorf("streamed log content mismatch: have %v, want {%d, true}", event, want)
+					}
+				case <-time.After(time.Second):
+					t.Fatalf("streamed log %d not delivered", want)
+				}
+			}
+			ssub.Unsubscribe()
+
 			// Test raising and filtering for an event with no data component
 			if _, err := eventer.RaiseNodataEvent(auth, big.NewInt(314), 141, 271); err != nil {
 				t.Fatalf("failed to raise nodata event: %v", err)
@@ -1785,6 +1803,68 @@ var bindTests = []struct {
 		nil,
 		nil,
 	},
+	// Test that custom errors are bound into typed Go errors
+	{
+		`CustomErrors`,
+		`
+			pragma solidity ^0.8.4;
+
+			error InsufficientBalance(uint256 available, uint256 required);
+			error Unauthorized();
+
+			contract CustomErrors {
+				function balance() public pure returns (uint256) {
+					revert InsufficientBalance(1, 2);
+				}
+				function withdraw() public {
+					revert InsufficientBalance(1, 2);
+				}
+			}
+		`,
+		[]string{`601a600c600039601a6000f363cf47918160e01b6000526001600452600260245260446000fd`},
+		[]string{`[{"inputs":[],"name":"balance","outputs":[{"name":"","type":"uint256"}],"stateMutability":"pure","type":"function"},{"inputs":[],"name":"withdraw","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"name":"available","type":"uint256"},{"name":"required","type":"uint256"}],"name":"InsufficientBalance","type":"error"},{"inputs":[],"name":"Unauthorized","type":"error"}]`},
+		`
+			"math/big"
+
+			"github.com/ethereum/go-ethereum/accounts/abi/bind"
+			"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+			"github.com/ethereum/go-ethereum/core"
+			"github.com/ethereum/go-ethereum/crypto"
+		`,
+		`
+			// Generate a new random account and a funded simulator
+			key, _ := crypto.GenerateKey()
+			auth := bind.NewKeyedTransactor(key)
+
+			sim := backends.NewSimulatedBackend(core.GenesisAlloc{auth.From: {Balance: big.NewInt(10000000000000000)}}, 10000000)
+			defer sim.Close()
+
+			// Deploy a contract reverting with a custom error on every call
+			_, _, contract, err := DeployCustomErrors(auth, sim)
+			if err != nil {
+				t.Fatalf("Failed to deploy contract: %v", err)
+			}
+			sim.Commit()
+
+			// Ensure both calls and gas estimations surface the typed error
+			if _, err := contract.Balance(nil); err == nil {
+				t.Fatalf("Call succeeded on reverting contract")
+			} else if typed, ok := UnpackCustomErrorsError(err).(*CustomErrorsInsufficientBalance); !ok {
+				t.Fatalf("Call error mismatch: have %T (%v), want *CustomErrorsInsufficientBalance", err, err)
+			} else if typed.Available.Cmp(big.NewInt(1)) != 0 || typed.Required.Cmp(big.NewInt(2)) != 0 {
+				t.Fatalf("Call error fields mismatch: have %v", typed)
+			}
+			if _, err := contract.Withdraw(auth); err == nil {
+				t.Fatalf("Transaction succeeded on reverting contract")
+			} else if _, ok := UnpackCustomErrorsError(err).(*CustomErrorsInsufficientBalance); !ok {
+				t.Fatalf("Estimation error mismatch: have %T (%v), want *CustomErrorsInsufficientBalance", err, err)
+			}
+		`,
+		nil,
+		nil,
+		nil,
+		nil,
+	},
 }
 
 // Tests that packages generated by the binder can be successfully compiled and
@@ -2240,6 +2320,171 @@ public class Test {
 		return this.Contract.transact(opts, "setUint8"	, args);
 	}
 }
+`,
+		},
+		{
+			"structs",
+			`
+			pragma experimental ABIEncoderV2;
+			pragma solidity ^0.8.0;
+
+			contract structs {
+				struct Point { int64 x; int64 y; }
+				struct Path { uint256 id; Point[] points; }
+
+				function first(Path[] memory paths) public pure returns(Path memory path, uint256 count) {}
+				function store(Path memory path) public {}
+				function store(Path[] memory paths) public {}
+			}
+			`,
+			`[{"inputs":[{"components":[{"internalType":"uint256","name":"id","type":"uint256"},{"components":[{"internalType":"int64","name":"x","type":"int64"},{"internalType":"int64","name":"y","type":"int64"}],"internalType":"struct structs.Point[]","name":"points","type":"tuple[]"}],"internalType":"struct structs.Path[]","name":"paths","type":"tuple[]"}],"name":"first","outputs":[{"components":[{"internalType":"uint256","name":"id","type":"uint256"},{"components":[{"internalType":"int64","name":"x","type":"int64"},{"internalType":"int64","name":"y","type":"int64"}],"internalType":"struct structs.Point[]","name":"points","type":"tuple[]"}],"internalType":"struct structs.Path","name":"path","type":"tuple"},{"internalType":"uint256","name":"count","type":"uint256"}],"stateMutability":"pure","type":"function"},{"inputs":[{"components":[{"internalType":"uint256","name":"id","type":"uint256"},{"components":[{"internalType":"int64","name":"x","type":"int64"},{"internalType":"int64","name":"y","type":"int64"}],"internalType":"struct structs.Point[]","name":"points","type":"tuple[]"}],"internalType":"struct structs.Path","name":"path","type":"tuple"}],"name":"store","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"components":[{"internalType":"uint256","name":"id","type":"uint256"},{"components":[{"internalType":"int64","name":"x","type":"int64"},{"internalType":"int64","name":"y","type":"int64"}],"internalType":"struct structs.Point[]","name":"points","type":"tuple[]"}],"internalType":"struct structs.Path[]","name":"paths","type":"tuple[]"}],"name":"store","outputs":[],"stateMutability":"nonpayable","type":"function"}]`,
+			"6000",
+			`// This file is an automatically generated Java binding. Do not modify as any
+// change will likely be lost upon the next re-generation!
+package bindtest;
+import org.ethereum.geth.*;
+import java.util.*;
+public class Structs {
+	// ABI is the input ABI used to generate the binding from.
+	public final static String ABI = "[{\"inputs\":[{\"components\":[{\"internalType\":\"uint256\",\"name\":\"id\",\"type\":\"uint256\"},{\"components\":[{\"internalType\":\"int64\",\"name\":\"x\",\"type\":\"int64\"},{\"internalType\":\"int64\",\"name\":\"y\",\"type\":\"int64\"}],\"internalType\":\"structstructs.Point[]\",\"name\":\"points\",\"type\":\"tuple[]\"}],\"internalType\":\"structstructs.Path[]\",\"name\":\"paths\",\"type\":\"tuple[]\"}],\"name\":\"first\",\"outputs\":[{\"components\":[{\"internalType\":\"uint256\",\"name\":\"id\",\"type\":\"uint256\"},{\"components\":[{\"internalType\":\"int64\",\"name\":\"x\",\"type\":\"int64\"},{\"internalType\":\"int64\",\"name\":\"y\",\"type\":\"int64\"}],\"internalType\":\"structstructs.Point[]\",\"name\":\"points\",\"type\":\"tuple[]\"}],\"internalType\":\"structstructs.Path\",\"name\":\"path\",\"type\":\"tuple\"},{\"internalType\":\"uint256\",\"name\":\"count\",\"type\":\"uint256\"}],\"stateMutability\":\"pure\",\"type\":\"function\"},{\"inputs\":[{\"components\":[{\"internalType\":\"uint256\",\"name\":\"id\",\"type\":\"uint256\"},{\"components\":[{\"internalType\":\"int64\",\"name\":\"x\",\"type\":\"int64\"},{\"internalType\":\"int64\",\"name\":\"y\",\"type\":\"int64\"}],\"internalType\":\"structstructs.Point[]\",\"name\":\"points\",\"type\":\"tuple[]\"}],\"internalType\":\"structstructs.Path\",\"name\":\"path\",\"type\":\"tuple\"}],\"name\":\"store\",\"outputs\":[],\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"inputs\":[{\"components\":[{\"internalType\":\"uint256\",\"name\":\"id\",\"type\":\"uint256\"},{\"components\":[{\"internalType\":\"int64\",\"name\":\"x\",\"type\":\"int64\"},{\"internalType\":\"int64\",\"name\":\"y\",\"type\":\"int64\"}],\"internalType\":\"structstructs.Point[]\",\"name\":\"points\",\"type\":\"tuple[]\"}],\"internalType\":\"structstructs.Path[]\",\"name\":\"paths\",\"type\":\"tuple[]\"}],\"name\":\"store\",\"outputs\":[],\"stateMutability\":\"nonpayable\",\"type\":\"function\"}]";
+	// StructsPath is an auto generated low-level Java binding around an user-defined struct.
+	public static class StructsPath {
+		public BigInt id;
+		public StructsPoint[] points;
+		// toInterfaces wraps the fields of the struct in declaration order.
+		Interfaces toInterfaces() throws Exception {
+			Interfaces fields = Geth.newInterfaces(2);
+			Interface field0 = Geth.newInterface();
+			field0.setBigInt(this.id);
+			fields.set(0, field0);
+			Interface field1 = Geth.newInterface();
+			Interfaces field1Items = Geth.newInterfaces(this.points.length);
+			for (int field1I = 0; field1I < this.points.length; field1I++) {
+				Interface field1Item = Geth.newInterface();
+				field1Item.setTuple(this.points[field1I].toInterfaces());
+				field1Items.set(field1I, field1Item);
+			}
+			field1.setTuples(field1Items);
+			fields.set(1, field1);
+			return fields;
+		}
+		// fromInterfaces assembles the struct from its wrapped fields.
+		static StructsPath fromInterfaces(Interfaces fields) throws Exception {
+			StructsPath value = new StructsPath();
+			value.id = fields.get(0).getBigInt();
+			Interfaces field1Items = fields.get(1).getTuples();
+			value.points = new StructsPoint[(int) field1Items.size()];
+			for (int field1I = 0; field1I < field1Items.size(); field1I++) {
+				value.points[field1I] = StructsPoint.fromInterfaces(field1Items.get(field1I).getTuple());
+			}
+			return value;
+		}
+	}
+	// StructsPoint is an auto generated low-level Java binding around an user-defined struct.
+	public static class StructsPoint {
+		public long x;
+		public long y;
+		// toInterfaces wraps the fields of the struct in declaration order.
+		Interfaces toInterfaces() throws Exception {
+			Interfaces fields = Geth.newInterfaces(2);
+			Interface field0 = Geth.newInterface();
+			field0.setInt64(this.x);
+			fields.set(0, field0);
+			Interface field1 = Geth.newInterface();
+			field1.setInt64(this.y);
+			fields.set(1, field1);
+			return fields;
+		}
+		// fromInterfaces assembles the struct from its wrapped fields.
+		static StructsPoint fromInterfaces(Interfaces fields) throws Exception {
+			StructsPoint value = new StructsPoint();
+			value.x = fields.get(0).getInt64();
+			value.y = fields.get(1).getInt64();
+			return value;
+		}
+	}
+	// BYTECODE is the compiled bytecode used for deploying new contracts.
+	public final static String BYTECODE = "0x6000";
+	// deploy deploys a new Ethereum contract, binding an instance of Structs to it.
+	public static Structs deploy(TransactOpts auth, EthereumClient client) throws Exception {
+		Interfaces args = Geth.newInterfaces(0);
+		String bytecode = BYTECODE;
+		return new Structs(Geth.deployContract(auth, ABI, Geth.decodeFromHex(bytecode), client, args));
+	}
+	// Internal constructor used by contract deployment.
+	private Structs(BoundContract deployment) {
+		this.Address  = deployment.getAddress();
+		this.Deployer = deployment.getDeployer();
+		this.Contract = deployment;
+	}
+	// Ethereum address where this contract is located at.
+	public final Address Address;
+	// Ethereum transaction in which this contract was deployed (if known!).
+	public final Transaction Deployer;
+	// Contract instance bound to a blockchain address.
+	private final BoundContract Contract;
+	// Creates a new instance of Structs, bound to a specific deployed contract.
+	public Structs(Address address, EthereumClient client) throws Exception {
+		this(Geth.bindContract(address, ABI, client));
+	}
+	// FirstResults is the output of a call to first.
+	public class FirstResults {
+		public StructsPath Path;
+		public BigInt Count;
+	}
+	// first is a free data retrieval call binding the contract method 0x4bade301.
+	//
+	// Solidity: function first((uint256,(int64,int64)[])[] paths) pure returns((uint256,(int64,int64)[]) path, uint256 count)
+	public FirstResults first(CallOpts opts, StructsPath[] paths) throws Exception {
+		Interfaces args = Geth.newInterfaces(1);
+		Interface arg0 = Geth.newInterface();
+		Interfaces arg0Items = Geth.newInterfaces(paths.length);
+		for (int arg0I = 0; arg0I < paths.length; arg0I++) {
+			Interface arg0Item = Geth.newInterface();
+			arg0Item.setTuple(paths[arg0I].toInterfaces());
+			arg0Items.set(arg0I, arg0Item);
+		}
+		arg0.setTuples(arg0Items);
+		args.set(0,arg0);
+		Interfaces results = Geth.newInterfaces(2);
+		Interface result0 = Geth.newInterface(); result0.setDefaultTuple(); results.set(0, result0);
+		Interface result1 = Geth.newInterface(); result1.setDefaultBigInt(); results.set(1, result1);
+		if (opts == null) {
+			opts = Geth.newCallOpts();
+		}
+		this.Contract.call(opts, results, "first", args);
+			FirstResults result = new FirstResults();
+			result.Path = StructsPath.fromInterfaces(results.get(0).getTuple());
+			result.Count = results.get(1).getBigInt();
+			return result;
+	}
+	// store is a paid mutator transaction binding the contract method 0xfc926414.
+	//
+	// Solidity: function store((uint256,(int64,int64)[]) path) returns()
+	public Transaction store(TransactOpts opts, StructsPath path) throws Exception {
+		Interfaces args = Geth.newInterfaces(1);
+		Interface arg0 = Geth.newInterface();
+		arg0.setTuple(path.toInterfaces());
+		args.set(0,arg0);
+		return this.Contract.transact(opts, "store"	, args);
+	}
+	// store0 is a paid mutator transaction binding the contract method 0xdeed4e0b.
+	//
+	// Solidity: function store((uint256,(int64,int64)[])[] paths) returns()
+	public Transaction store0(TransactOpts opts, StructsPath[] paths) throws Exception {
+		Interfaces args = Geth.newInterfaces(1);
+		Interface arg0 = Geth.newInterface();
+		Interfaces arg0Items = Geth.newInterfaces(paths.length);
+		for (int arg0I = 0; arg0I < paths.length; arg0I++) {
+			Interface arg0Item = Geth.newInterface();
+			arg0Item.setTuple(paths[arg0I].toInterfaces());
+			arg0Items.set(arg0I, arg0Item);
+		}
+		arg0.setTuples(arg0Items);
+		args.set(0,arg0);
+		return this.Contract.transact(opts, "store0"	, args);
+	}
+}
 `,
 		},
 	}
@@ -0,0 +1,305 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bind
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+const (
+	// defaultStreamReorgDepth is the number of blocks for which delivered logs are
+	// tracked to detect chain reorganisations happening while disconnected.
+	defaultStreamReorgDepth = 64
+
+	// defaultStreamBackoff is the time to wait between resubscription attempts.
+	defaultStreamBackoff = 5 * time.Second
+)
+
+var (
+	// errStreamQuit is returned internally when the stream is torn down by the user.
+	errStreamQuit = errors.New("stream closed")
+
+	// errStreamEnded is returned internally when the backend ends a subscription
+	// without reporting a failure.
+	errStreamEnded = errors.New("subscription ended")
+)
+
+// StreamOpts is the collection of options to fine tune a durable event stream
+// within a bound contract.
+type StreamOpts struct {
+	Start      uint64            // Block to start streaming from if no checkpoint is given
+	Checkpoint *StreamCheckpoint // Position to resume a previous stream from (nil = Start)
+
+	ReorgDepth uint64        // Number of blocks to track for reorgs across reconnects (0 = 64)
+	Backoff    time.Duration // Time to wait between resubscription attempts (0 = 5s)
+
+	Context context.Context // Network context to support cancellation and timeouts (nil = no timeout)
+}
+
+// StreamCheckpoint is a position within a durable event stream from which it can
+// be resumed, e.g. after a restart of the consumer.
+type StreamCheckpoint struct {
+	BlockNumber uint64      // Block to resume streaming from
+	BlockHash   common.Hash // Hash of the block, logs are only skipped if it's still canonical
+	Index       uint        // Index of the first log within the block not yet processed
+}
+
+// NewStreamCheckpoint returns the position of a durable event stream right after
+// the given log was processed. For removed logs, the position is rewound to the
+// log itself, so that it's replaced by whatever the new chain contains.
+func NewStreamCheckpoint(log types.Log) *StreamCheckpoint {
+	cp := &StreamCheckpoint{
+		BlockNumber: log.BlockNumber,
+		BlockHash:   log.BlockHash,
+		Index:       log.Index,
+	}
+	if !log.Removed {
+		cp.Index++
+	}
+	return cp
+}
+
+// StreamLogs creates a durable log subscription for the given event. In contrast to
+// WatchLogs, the stream survives subscription failures (e.g. dropped WebSocket
+// connections) by resubscribing and replaying the missed logs via FilterLogs. Logs
+// are delivered at most once, and logs retracted by a chain reorganisation, whether
+// observed live or while disconnected, are delivered again with Removed set.
+func (c *BoundContract) StreamLogs(opts *StreamOpts, name string, query ...[]interface{}) (chan types.Log, event.Subscription, error) {
+	// Don't crash on a lazy user
+	if opts == nil {
+		opts = new(StreamOpts)
+	}
+	// Append the event selector to the query parameters and construct the topic set
+	query = append([][]interface{}{{c.abi.Events[name].ID}}, query...)
+
+	topics, err := abi.MakeTopics(query...)
+	if err != nil {
+		return nil, nil, err
+	}
+	stream := newLogStream(c.filterer, ethereum.FilterQuery{
+		Addresses: []common.Address{c.address},
+		Topics:    topics,
+	}, opts)
+
+	logs := make(chan types.Log, 128)
+	sub := event.NewSubscription(func(quit <-chan struct{}) error {
+		return stream.run(logs, quit)
+	})
+	return logs, sub, nil
+}
+
+// logKey uniquely identifies a log across chain reorganisations.
+type logKey struct {
+	block common.Hash
+	index uint
+}
+
+// logStream is the state of a durable log subscription.
+type logStream struct {
+	filterer ContractFilterer
+	query    ethereum.FilterQuery
+	ctx      context.Context
+	depth    uint64
+	backoff  time.Duration
+
+	resume  *StreamCheckpoint // Checkpoint to skip logs before, if resuming a previous stream
+	from    uint64            // Block to backfill from on the next (re)subscription
+	head    uint64            // Highest block number a log was delivered from
+	history []types.Log       // Recently delivered logs, tracked to detect reorgs
+}
+
+// newLogStream creates a durable log stream for the given filter query.
+func newLogStream(filterer ContractFilterer, query ethereum.FilterQuery, opts *StreamOpts) *logStream {
+	s := &logStream{
+		filterer: filterer,
+		query:    query,
+		ctx:      ensureContext(opts.Context),
+		depth:    opts.ReorgDepth,
+		backoff:  opts.Backoff,
+		resume:   opts.Checkpoint,
+		from:     opts.Start,
+	}
+	if s.depth == 0 {
+		s.depth = defaultStreamReorgDepth
+	}
+	if s.backoff == 0 {
+		s.backoff = defaultStreamBackoff
+	}
+	if s.resume != nil {
+		s.from = s.resume.BlockNumber
+	}
+	return s
+}
+
+// run keeps the stream alive until it's torn down, resubscribing whenever the
+// backend fails.
+func (s *logStream) run(sink chan<- types.Log, quit <-chan struct{}) error {
+	for {
+		err := s.stream(sink, quit)
+		if err == errStreamQuit {
+			return nil
+		}
+		if s.ctx.Err() != nil {
+			return s.ctx.Err()
+		}
+		select {
+		case <-time.After(s.backoff):
+		case <-quit:
+			return nil
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		}
+	}
+}
+
+// stream subscribes to new logs, replays the ones missed since the last delivery
+// and forwards live logs until the subscription fails.
+func (s *logStream) stream(sink chan<- types.Log, quit <-chan struct{}) error {
+	// Subscribe before backfilling, so no logs slip through in between
+	live := make(chan types.Log, 128)
+	sub, err := s.filterer.SubscribeFilterLogs(s.ctx, s.query, live)
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+
+	if err := s.backfill(sink, quit); err != nil {
+		return err
+	}
+	for {
+		select {
+		case log := <-live:
+			if err := s.deliver(sink, quit, log); err != nil {
+				return err
+			}
+		case err := <-sub.Err():
+			if err == nil {
+				err = errStreamEnded
+			}
+			return err
+		case <-quit:
+			return errStreamQuit
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		}
+	}
+}
+
+// backfill retrieves all logs since the oldest tracked one, retracting any
+// delivered logs no longer on the canonical chain and delivering the new ones.
+func (s *logStream) backfill(sink chan<- types.Log, quit <-chan struct{}) error {
+	from := s.from
+	if len(s.history) > 0 && s.history[0].BlockNumber < from {
+		from = s.history[0].BlockNumber
+	}
+	query := s.query
+	query.FromBlock = new(big.Int).SetUint64(from)
+
+	logs, err := s.filterer.FilterLogs(s.ctx, query)
+	if err != nil {
+		return err
+	}
+	// Retract any tracked logs reorged out while disconnected, newest first
+	canonical := make(map[logKey]struct{}, len(logs))
+	for _, log := range logs {
+		canonical[logKey{log.BlockHash, log.Index}] = struct{}{}
+	}
+	tracked := append([]types.Log(nil), s.history...)
+	for i := len(tracked) - 1; i >= 0; i-- {
+		if _, ok := canonical[logKey{tracked[i].BlockHash, tracked[i].Index}]; !ok {
+			removed := tracked[i]
+			removed.Removed = true
+			if err := s.deliver(sink, quit, removed); err != nil {
+				return err
+			}
+		}
+	}
+	// Deliver everything not yet seen
+	for _, log := range logs {
+		if err := s.deliver(sink, quit, log); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deliver forwards a log to the user, unless it was already delivered or lies
+// before the resume checkpoint, and updates the tracked reorg history.
+func (s *logStream) deliver(sink chan<- types.Log, quit <-chan struct{}, log types.Log) error {
+	key := logKey{log.BlockHash, log.Index}
+	idx := -1
+	for i, tracked := range s.history {
+		if (logKey{tracked.BlockHash, tracked.Index}) == key {
+			idx = i
+			break
+		}
+	}
+	if log.Removed {
+		// Only retract logs the user has actually seen
+		if idx < 0 {
+			return nil
+		}
+		s.history = append(s.history[:idx], s.history[idx+1:]...)
+	} else {
+		// Skip duplicates and logs processed before resuming
+		if idx >= 0 || s.skip(log) {
+			return nil
+		}
+		s.history = append(s.history, log)
+		if log.BlockNumber > s.from {
+			s.from = log.BlockNumber
+		}
+		if log.BlockNumber > s.head {
+			s.head = log.BlockNumber
+		}
+		// Stop tracking logs too old to be reorged
+		var drop int
+		for drop < len(s.history) && s.history[drop].BlockNumber+s.depth < s.head {
+			drop++
+		}
+		s.history = s.history[drop:]
+	}
+	select {
+	case sink <- log:
+		return nil
+	case <-quit:
+		return errStreamQuit
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+// skip reports whether a log was already processed before the stream was resumed
+// from a checkpoint.
+func (s *logStream) skip(log types.Log) bool {
+	if s.resume == nil {
+		return false
+	}
+	if log.BlockNumber < s.resume.BlockNumber {
+		return true
+	}
+	return log.BlockNumber == s.resume.BlockNumber && log.BlockHash == s.resume.BlockHash && log.Index < s.resume.Index
+}
@@ -0,0 +1,185 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bind_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// streamFilterer is a contract filterer whose canonical log set can be modified
+// and whose subscriptions can be failed on demand.
+type streamFilterer struct {
+	lock sync.Mutex
+	logs []types.Log     // Canonical logs returned by FilterLogs
+	feed event.Feed      // Feed of live logs
+	subs chan *streamSub // Channel announcing new subscriptions
+}
+
+type streamSub struct {
+	event.Subscription
+	errc chan error
+}
+
+func (s *streamSub) Err() <-chan error { return s.errc }
+
+func (f *streamFilterer) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	var logs []types.Log
+	for _, log := range f.logs {
+		if log.BlockNumber >= query.FromBlock.Uint64() {
+			logs = append(logs, log)
+		}
+	}
+	return logs, nil
+}
+
+func (f *streamFilterer) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	sub := &streamSub{Subscription: f.feed.Subscribe(ch), errc: make(chan error, 1)}
+	f.subs <- sub
+	return sub, nil
+}
+
+func (f *streamFilterer) setLogs(logs ...types.Log) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.logs = logs
+}
+
+func makeStreamLog(number uint64, hash byte, index uint) types.Log {
+	return types.Log{
+		BlockNumber: number,
+		BlockHash:   common.Hash{hash},
+		Index:       index,
+	}
+}
+
+// expectLogs waits for the given logs to be delivered on the stream, in order.
+func expectLogs(t *testing.T, logs chan types.Log, want ...types.Log) {
+	t.Helper()
+
+	for i, w := range want {
+		select {
+		case have := <-logs:
+			if have.BlockHash != w.BlockHash || have.Index != w.Index || have.Removed != w.Removed {
+				t.Fatalf("log %d mismatch: have {%x %d removed=%v}, want {%x %d removed=%v}", i, have.BlockHash[:1], have.Index, have.Removed, w.BlockHash[:1], w.Index, w.Removed)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("log %d not delivered", i)
+		}
+	}
+	select {
+	case have := <-logs:
+		t.Fatalf("unexpected log delivered: {%x %d removed=%v}", have.BlockHash[:1], have.Index, have.Removed)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func removedLog(log types.Log) types.Log {
+	log.Removed = true
+	return log
+}
+
+func newStreamContract(t *testing.T, filterer *streamFilterer) *bind.BoundContract {
+	parsed, err := abi.JSON(strings.NewReader(`[{"anonymous":false,"inputs":[],"name":"Ping","type":"event"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bind.NewBoundContract(common.Address{}, parsed, nil, nil, filterer)
+}
+
+// Tests that durable streams replay missed logs after subscription failures and
+// report logs removed by reorgs, both live ones and ones that happened offline.
+func TestStreamLogs(t *testing.T) {
+	var (
+		a  = makeStreamLog(1, 0xa, 0)
+		b  = makeStreamLog(2, 0xb, 1)
+		c  = makeStreamLog(3, 0xc, 2)
+		c2 = makeStreamLog(3, 0xd, 2)
+		d  = makeStreamLog(4, 0xe, 3)
+	)
+	filterer := &streamFilterer{subs: make(chan *streamSub, 1)}
+	filterer.setLogs(a, b)
+
+	contract := newStreamContract(t, filterer)
+	logs, sub, err := contract.StreamLogs(&bind.StreamOpts{Backoff: time.Millisecond}, "Ping")
+	if err != nil {
+		t.Fatalf("failed to create stream: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	// Historical logs should be backfilled, live ones forwarded
+	live := <-filterer.subs
+	expectLogs(t, logs, a, b)
+
+	filterer.setLogs(a, b, c)
+	filterer.feed.Send(c)
+	expectLogs(t, logs, c)
+
+	// Drop the connection and reorg c out while disconnected
+	filterer.setLogs(a, b, c2, d)
+	live.errc <- errors.New("connection lost")
+
+	live = <-filterer.subs
+	expectLogs(t, logs, removedLog(c), c2, d)
+
+	// Live reorgs and duplicates should be handled too
+	filterer.setLogs(a, b, c2)
+	filterer.feed.Send(removedLog(d))
+	filterer.feed.Send(c2)
+	expectLogs(t, logs, removedLog(d))
+}
+
+// Tests that durable streams can be resumed from a checkpoint.
+func TestStreamLogsCheckpoint(t *testing.T) {
+	var (
+		a = makeStreamLog(1, 0xa, 0)
+		b = makeStreamLog(1, 0xa, 1)
+		c = makeStreamLog(2, 0xb, 2)
+	)
+	filterer := &streamFilterer{subs: make(chan *streamSub, 1)}
+	filterer.setLogs(a, b, c)
+
+	contract := newStreamContract(t, filterer)
+	logs, sub, err := contract.StreamLogs(&bind.StreamOpts{Checkpoint: bind.NewStreamCheckpoint(a)}, "Ping")
+	if err != nil {
+		t.Fatalf("failed to create stream: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	<-filterer.subs
+	expectLogs(t, logs, b, c)
+
+	// Checkpoints of removed logs should rewind onto the log itself
+	if cp := bind.NewStreamCheckpoint(removedLog(c)); cp.BlockNumber != 2 || cp.Index != 2 {
+		t.Fatalf("removed log checkpoint mismatch: have %d/%d, want 2/2", cp.BlockNumber, cp.Index)
+	}
+}
@@ -38,6 +38,7 @@ type tmplContract struct {
 	Fallback    *tmplMethod            // Additional special fallback function
 	Receive     *tmplMethod            // Additional special receive function
 	Events      map[string]*tmplEvent  // Contract events accessors
+	Errors      map[string]*tmplError  // Contract custom errors
 	Libraries   map[string]string      // Same as tmplData, but filtered to only keep what the contract needs
 	Library     bool                   // Indicator whether the contract is a library
 }
@@ -57,6 +58,13 @@ type tmplEvent struct {
 	Normalized abi.Event // Normalized version of the parsed fields
 }
 
+// tmplError is a wrapper around an abi.Error that contains a few preprocessed
+// and cached data fields.
+type tmplError struct {
+	Original   abi.Error // Original error as parsed by the abi package
+	Normalized abi.Error // Normalized version of the parsed fields
+}
+
 // tmplField is a wrapper around a struct field with binding language
 // struct type definition and relative filed name.
 type tmplField struct {
@@ -88,6 +96,8 @@ const tmplSourceGo = `
 package {{.Package}}
 
 import (
+	"errors"
+	"fmt"
 	"math/big"
 	"strings"
 
@@ -101,6 +111,8 @@ import (
 
 // Reference imports to suppress errors if they are not otherwise used.
 var (
+	_ = errors.As
+	_ = fmt.Sprintf
 	_ = big.NewInt
 	_ = strings.NewReader
 	_ = ethereum.NotFound
@@ -536,6 +548,50 @@ var (
 			}), nil
 		}
 
+		// Stream{{.Normalized.Name}} is a durable log subscription binding the contract event 0x{{printf "%x" .Original.ID}}.
+		// It survives connection failures by replaying missed events, and delivers events retracted
+		// by chain reorganisations once more with Raw.Removed set.
+		//
+		// Solidity: {{.Original.String}}
+		func (_{{$contract.Type}} *{{$contract.Type}}Filterer) Stream{{.Normalized.Name}}(opts *bind.StreamOpts, sink chan<- *{{$contract.Type}}{{.Normalized.Name}}{{range .Normalized.Inputs}}{{if .Indexed}}, {{.Name}} []{{bindtype .Type $structs}}{{end}}{{end}}) (event.Subscription, error) {
+			{{range .Normalized.Inputs}}
+			{{if .Indexed}}var {{.Name}}Rule []interface{}
+			for _, {{.Name}}Item := range {{.Name}} {
+				{{.Name}}Rule = append({{.Name}}Rule, {{.Name}}Item)
+			}{{end}}{{end}}
+
+			logs, sub, err := _{{$contract.Type}}.contract.StreamLogs(opts, "{{.Original.Name}}"{{range .Normalized.Inputs}}{{if .Indexed}}, {{.Name}}Rule{{end}}{{end}})
+			if err != nil {
+				return nil, err
+			}
+			return event.NewSubscription(func(quit <-chan struct{}) error {
+				defer sub.Unsubscribe()
+				for {
+					select {
+					case log := <-logs:
+						// New or removed log arrived, parse the event and forward to the user
+						event := new({{$contract.Type}}{{.Normalized.Name}})
+						if err := _{{$contract.Type}}.contract.UnpackLog(event, "{{.Original.Name}}", log); err != nil {
+							return err
+						}
+						event.Raw = log
+
+						select {
+						case sink <- event:
+						case err := <-sub.Err():
+							return err
+						case <-quit:
+							return nil
+						}
+					case err := <-sub.Err():
+						return err
+					case <-quit:
+						return nil
+					}
+				}
+			}), nil
+		}
+
 		// Parse{{.Normalized.Name}} is a log parse operation binding the contract event 0x{{printf "%x" .Original.ID}}.
 		//
 		// Solidity: {{.Original.String}}
@@ -549,6 +605,38 @@ var (
 		}
 
  	{{end}}
+
+	{{range .Errors}}
+		// {{$contract.Type}}{{.Normalized.Name}} represents a {{.Normalized.Name}} custom error raised by the {{$contract.Type}} contract.
+		//
+		// Solidity: {{.Original.String}}
+		type {{$contract.Type}}{{.Normalized.Name}} struct { {{range .Normalized.Inputs}}
+			{{capitalise .Name}} {{bindtype .Type $structs}}; {{end}}
+		}
+
+		// Error implements the error interface.
+		func (e *{{$contract.Type}}{{.Normalized.Name}}) Error() string {
+			return fmt.Sprintf("{{.Original.RawName}}({{range $i, $_ := .Normalized.Inputs}}{{if $i}}, {{end}}{{.Name}}: %v{{end}})"{{range .Normalized.Inputs}}, e.{{capitalise .Name}}{{end}})
+		}
+	{{end}}
+	{{if .Errors}}
+		// Unpack{{$contract.Type}}Error converts a custom error the {{$contract.Type}} contract reverted with in a
+		// call or gas estimation into its typed representation. Other errors are returned as is.
+		func Unpack{{$contract.Type}}Error(err error) error {
+			var cerr *bind.ContractError
+			if !errors.As(err, &cerr) {
+				return err
+			}
+			switch cerr.Custom.Decl.Sig {
+			{{range .Errors}}case "{{.Original.Sig}}":
+				typed := new({{$contract.Type}}{{.Normalized.Name}})
+				{{range $i, $_ := .Normalized.Inputs}}typed.{{capitalise .Name}} = *abi.ConvertType(cerr.Custom.Values[{{$i}}], new({{bindtype .Type $structs}})).(*{{bindtype .Type $structs}})
+				{{end}}return typed
+			{{end}}
+			}
+			return err
+		}
+	{{end}}
 {{end}}
 `
 
@@ -568,6 +656,30 @@ import java.util.*;
 {{if not .Library}}public {{end}}class {{.Type}} {
 	// ABI is the input ABI used to generate the binding from.
 	public final static String ABI = "{{.InputABI}}";
+	{{range $structs}}
+	// {{.Name}} is an auto generated low-level Java binding around an user-defined struct.
+	public static class {{.Name}} {
+		{{range $field := .Fields}}public {{$field.Type}} {{$field.Name}};
+		{{end}}
+		// toInterfaces wraps the fields of the struct in declaration order.
+		Interfaces toInterfaces() throws Exception {
+			Interfaces fields = Geth.newInterfaces({{len .Fields}});
+			{{range $index, $field := .Fields}}Interface field{{$index}} = Geth.newInterface();
+			{{indent 3 (javapack (printf "field%d" $index) (printf "this.%s" $field.Name) $field.SolKind)}}
+			fields.set({{$index}}, field{{$index}});
+			{{end}}
+			return fields;
+		}
+
+		// fromInterfaces assembles the struct from its wrapped fields.
+		static {{.Name}} fromInterfaces(Interfaces fields) throws Exception {
+			{{.Name}} value = new {{.Name}}();
+			{{range $index, $field := .Fields}}{{indent 3 (javaunpack (printf "field%d" $index) (printf "value.%s" $field.Name) (printf "fields.get(%d)" $index) $field.SolKind)}}
+			{{end}}
+			return value;
+		}
+	}
+	{{end}}
 	{{if $contract.FuncSigs}}
 		// {{.Type}}FuncSigs maps the 4-byte function signature to its string representation.
 		public final static Map<String, String> {{.Type}}FuncSigs;
@@ -594,7 +706,9 @@ import java.util.*;
 		bytecode = bytecode.replace("__${{$pattern}}$__", {{decapitalise $name}}Inst.Address.getHex().substring(2));
 		{{end}}
 		{{end}}
-		{{range $index, $element := .Constructor.Inputs}}Interface arg{{$index}} = Geth.newInterface();arg{{$index}}.set{{namedtype (bindtype .Type $structs) .Type}}({{.Name}});args.set({{$index}},arg{{$index}});
+		{{range $index, $element := .Constructor.Inputs}}Interface arg{{$index}} = Geth.newInterface();{{if hasstruct .Type}}
+		{{indent 2 (javapack (printf "arg%d" $index) .Name .Type)}}
+		{{else}}arg{{$index}}.set{{namedtype (bindtype .Type $structs) .Type}}({{.Name}});{{end}}args.set({{$index}},arg{{$index}});
 		{{end}}
 		return new {{.Type}}(Geth.deployContract(auth, ABI, Geth.decodeFromHex(bytecode), client, args));
 	}
@@ -635,7 +749,9 @@ import java.util.*;
 	// Solidity: {{.Original.String}}
 	public {{if gt (len .Normalized.Outputs) 1}}{{capitalise .Normalized.Name}}Results{{else if eq (len .Normalized.Outputs) 0}}void{{else}}{{range .Normalized.Outputs}}{{bindtype .Type $structs}}{{end}}{{end}} {{.Normalized.Name}}(CallOpts opts{{range .Normalized.Inputs}}, {{bindtype .Type $structs}} {{.Name}}{{end}}) throws Exception {
 		Interfaces args = Geth.newInterfaces({{(len .Normalized.Inputs)}});
-		{{range $index, $item := .Normalized.Inputs}}Interface arg{{$index}} = Geth.newInterface();arg{{$index}}.set{{namedtype (bindtype .Type $structs) .Type}}({{.Name}});args.set({{$index}},arg{{$index}});
+		{{range $index, $item := .Normalized.Inputs}}Interface arg{{$index}} = Geth.newInterface();{{if hasstruct .Type}}
+		{{indent 2 (javapack (printf "arg%d" $index) .Name .Type)}}
+		{{else}}arg{{$index}}.set{{namedtype (bindtype .Type $structs) .Type}}({{.Name}});{{end}}args.set({{$index}},arg{{$index}});
 		{{end}}
 
 		Interfaces results = Geth.newInterfaces({{(len .Normalized.Outputs)}});
@@ -648,10 +764,12 @@ import java.util.*;
 		this.Contract.call(opts, results, "{{.Original.Name}}", args);
 		{{if gt (len .Normalized.Outputs) 1}}
 			{{capitalise .Normalized.Name}}Results result = new {{capitalise .Normalized.Name}}Results();
-			{{range $index, $item := .Normalized.Outputs}}result.{{if ne .Name ""}}{{.Name}}{{else}}Return{{$index}}{{end}} = results.get({{$index}}).get{{namedtype (bindtype .Type $structs) .Type}}();
+			{{range $index, $item := .Normalized.Outputs}}{{if hasstruct .Type}}{{indent 3 (javaunpack (printf "result%d" $index) (printf "result.%s" (or .Name (printf "Return%d" $index))) (printf "results.get(%d)" $index) .Type)}}{{else}}result.{{if ne .Name ""}}{{.Name}}{{else}}Return{{$index}}{{end}} = results.get({{$index}}).get{{namedtype (bindtype .Type $structs) .Type}}();{{end}}
 			{{end}}
 			return result;
-		{{else}}{{range .Normalized.Outputs}}return results.get(0).get{{namedtype (bindtype .Type $structs) .Type}}();{{end}}
+		{{else}}{{range .Normalized.Outputs}}{{if hasstruct .Type}}{{bindtype .Type $structs}} result;
+		{{indent 2 (javaunpack "result" "result" "results.get(0)" .Type)}}
+		return result;{{else}}return results.get(0).get{{namedtype (bindtype .Type $structs) .Type}}();{{end}}{{end}}
 		{{end}}
 	}
 	{{end}}
@@ -662,7 +780,9 @@ import java.util.*;
 	// Solidity: {{.Original.String}}
 	public Transaction {{.Normalized.Name}}(TransactOpts opts{{range .Normalized.Inputs}}, {{bindtype .Type $structs}} {{.Name}}{{end}}) throws Exception {
 		Interfaces args = Geth.newInterfaces({{(len .Normalized.Inputs)}});
-		{{range $index, $item := .Normalized.Inputs}}Interface arg{{$index}} = Geth.newInterface();arg{{$index}}.set{{namedtype (bindtype .Type $structs) .Type}}({{.Name}});args.set({{$index}},arg{{$index}});
+		{{range $index, $item := .Normalized.Inputs}}Interface arg{{$index}} = Geth.newInterface();{{if hasstruct .Type}}
+		{{indent 2 (javapack (printf "arg%d" $index) .Name .Type)}}
+		{{else}}arg{{$index}}.set{{namedtype (bindtype .Type $structs) .Type}}({{.Name}});{{end}}args.set({{$index}},arg{{$index}});
 		{{end}}
 		return this.Contract.transact(opts, "{{.Original.Name}}"	, args);
 	}
@@ -17,11 +17,112 @@
 package abi
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"reflect"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 )
 
+// Error is a custom error declared by a contract (Solidity 0.8.4+), which can be
+// raised by reverting with its abi-encoded parameters, prefixed by the first four
+// bytes of the error signature hash.
+type Error struct {
+	// Name is the error name used for internal representation. It's derived from
+	// the raw name and a suffix will be added in the case of an error overload.
+	Name string
+	// RawName is the raw error name parsed from ABI.
+	RawName string
+	Inputs  Arguments
+	str     string
+	// Sig contains the string signature according to the ABI spec.
+	// e.g.	 error foo(uint32 a, int b) = "foo(uint32,int256)"
+	// Please note that "int" is substitute for its canonical representation "int256"
+	Sig string
+	// ID returns the canonical representation of the error's signature used by the
+	// abi definition to identify error names and types. The revert data selector
+	// is its first four bytes.
+	ID common.Hash
+}
+
+// NewError creates a new Error.
+// It sanitizes the input arguments to remove unnamed arguments.
+// It also precomputes the id, signature and string representation
+// of the error.
+func NewError(name, rawName string, inputs Arguments) Error {
+	// sanitize inputs to remove inputs without names
+	// and precompute string and sig representation.
+	names := make([]string, len(inputs))
+	types := make([]string, len(inputs))
+	for i, input := range inputs {
+		if input.Name == "" {
+			inputs[i] = Argument{
+				Name: fmt.Sprintf("arg%d", i),
+				Type: input.Type,
+			}
+		} else {
+			inputs[i] = input
+		}
+		// string representation
+		names[i] = fmt.Sprintf("%v %v", input.Type, inputs[i].Name)
+		// sig representation
+		types[i] = input.Type.String()
+	}
+
+	str := fmt.Sprintf("error %v(%v)", rawName, strings.Join(names, ", "))
+	sig := fmt.Sprintf("%v(%v)", rawName, strings.Join(types, ","))
+	id := common.BytesToHash(crypto.Keccak256([]byte(sig)))
+
+	return Error{
+		Name:    name,
+		RawName: rawName,
+		Inputs:  inputs,
+		str:     str,
+		Sig:     sig,
+		ID:      id,
+	}
+}
+
+func (e Error) String() string {
+	return e.str
+}
+
+// Selector returns the four byte prefix identifying the error in revert data.
+func (e Error) Selector() []byte {
+	return e.ID[:4]
+}
+
+// Unpack decodes the parameters of the error from the given revert data.
+func (e Error) Unpack(data []byte) ([]interface{}, error) {
+	if len(data) < 4 {
+		return nil, errors.New("invalid data for unpacking")
+	}
+	if !bytes.Equal(data[:4], e.Selector()) {
+		return nil, errors.New("invalid data for unpacking")
+	}
+	return e.Inputs.Unpack(data[4:])
+}
+
+// CustomError is a custom error decoded from the revert data of a contract call.
+// It implements the error interface, so it can be passed around as is.
+type CustomError struct {
+	Decl   *Error        // Declaration of the error in the contract ABI
+	Values []interface{} // Decoded error parameters, in declaration order
+}
+
+// Error implements error, returning the error name and its parameters, e.g.
+// InsufficientBalance(available: 1, required: 2).
+func (e *CustomError) Error() string {
+	params := make([]string, len(e.Values))
+	for i, value := range e.Values {
+		params[i] = fmt.Sprintf("%v: %v", e.Decl.Inputs[i].Name, value)
+	}
+	return fmt.Sprintf("%v(%v)", e.Decl.RawName, strings.Join(params, ", "))
+}
+
 var (
 	errBadBool = errors.New("abi: improperly encoded boolean value")
 )
@@ -0,0 +1,135 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package hsm implements an accounts.Backend for secp256k1 keys held by hardware
+// security modules, key management services or software tokens such as SoftHSM.
+//
+// Tokens are accessed through the minimal Token interface, which maps one to one
+// onto the PKCS#11 operations needed for signing. PKCS11Token implements it on
+// top of any PKCS#11 module (only available in cgo enabled builds), other
+// backends may implement it directly:
+//
+//   - Login and Logout correspond to C_Login (CKU_USER) and C_Logout.
+//   - Keys corresponds to C_FindObjects over the CKO_PUBLIC_KEY objects of type
+//     CKK_EC with the secp256k1 CKA_EC_PARAMS, returning their CKA_ID, CKA_LABEL
+//     and CKA_EC_POINT attributes.
+//   - Sign corresponds to C_SignInit and C_Sign with the raw CKM_ECDSA mechanism
+//     on the private key sharing the CKA_ID.
+//
+// The token never reveals the recovery id of a signature nor guarantees that it
+// is canonical, so the wallet recovers the former from the public key and
+// normalizes the latter into the lower half of the curve order as required by
+// Ethereum.
+package hsm
+
+import (
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// Scheme is the URI prefix for HSM wallets.
+const Scheme = "hsm"
+
+// Key is a secp256k1 key pair stored on a token.
+type Key struct {
+	ID    []byte // Identifier of the key pair on the token (CKA_ID)
+	Label string // Human readable label of the key pair (CKA_LABEL)
+	Point []byte // Public key, uncompressed, compressed or DER wrapped (CKA_EC_POINT)
+}
+
+// Token is a PKCS#11-style cryptographic token holding secp256k1 keys.
+type Token interface {
+	// Label returns the unique label of the token, used to build wallet URLs.
+	Label() string
+
+	// Login authenticates a user session with the token using the given PIN.
+	Login(pin string) error
+
+	// Logout terminates the user session with the token.
+	Logout() error
+
+	// Keys lists the secp256k1 key pairs available on the token.
+	Keys() ([]Key, error)
+
+	// Sign signs a 32 byte digest with the private key identified by id. The
+	// signature may be returned either as the raw 64 byte concatenation of r
+	// and s, or as an ASN.1 DER encoded sequence.
+	Sign(id []byte, digest []byte) ([]byte, error)
+}
+
+// Hub is an accounts.Backend exposing a wallet for each of a fixed set of tokens.
+type Hub struct {
+	wallets     []accounts.Wallet       // Wallets of the tokens, sorted by URL
+	updateFeed  event.Feed              // Event feed to notify wallets being opened
+	updateScope event.SubscriptionScope // Subscription scope tracking current live listeners
+}
+
+// NewHub creates an HSM backend for the given tokens. The wallets start out
+// closed and need to be opened with the token PIN before use.
+func NewHub(tokens ...Token) *Hub {
+	hub := new(Hub)
+	for _, token := range tokens {
+		hub.wallets = append(hub.wallets, newWallet(hub, token))
+	}
+	sort.Sort(accounts.WalletsByURL(hub.wallets))
+	return hub
+}
+
+// NewPKCS11HubWithPINFile creates an HSM backend for the token in the given slot
+// of a PKCS#11 module. If a PIN file is given, the wallet of the token is opened
+// with the PIN read from it right away.
+func NewPKCS11HubWithPINFile(module string, slot uint, pinfile string) (*Hub, error) {
+	var pin string
+	if pinfile != "" {
+		blob, err := ioutil.ReadFile(pinfile)
+		if err != nil {
+			return nil, err
+		}
+		pin = strings.TrimRight(string(blob), "\r\n")
+	}
+	return NewPKCS11Hub(module, slot, pin)
+}
+
+// Wallets implements accounts.Backend, returning the wallets of all the tokens.
+func (hub *Hub) Wallets() []accounts.Wallet {
+	cpy := make([]accounts.Wallet, len(hub.wallets))
+	copy(cpy, hub.wallets)
+	return cpy
+}
+
+// Subscribe implements accounts.Backend, creating an async subscription to
+// receive notifications on the opening of HSM wallets.
+func (hub *Hub) Subscribe(sink chan<- accounts.WalletEvent) event.Subscription {
+	return hub.updateScope.Track(hub.updateFeed.Subscribe(sink))
+}
+
+// Close unsubscribes all listeners, logs out of all open tokens and closes the
+// tokens needing it, such as PKCS#11 ones.
+func (hub *Hub) Close() error {
+	hub.updateScope.Close()
+	for _, w := range hub.wallets {
+		w.Close()
+		if closer, ok := w.(*wallet).token.(io.Closer); ok {
+			closer.Close()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build cgo
+// +build cgo
+
+package hsm
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/miekg/pkcs11"
+)
+
+// secp256k1Params is the DER encoded OID of the secp256k1 curve (1.3.132.0.10),
+// as stored in the CKA_EC_PARAMS attribute of its keys.
+var secp256k1Params = []byte{0x06, 0x05, 0x2b, 0x81, 0x04, 0x00, 0x0a}
+
+// errNotLoggedIn is returned if the keys of a token are accessed without a user
+// session logged in.
+var errNotLoggedIn = errors.New("not logged in to token")
+
+// PKCS11Token is a Token backed by a slot of a PKCS#11 module, such as the ones
+// shipped with hardware security modules or SoftHSM.
+type PKCS11Token struct {
+	ctx   *pkcs11.Ctx // Loaded and initialized PKCS#11 module
+	slot  uint        // Slot of the token within the module
+	label string      // Label of the token in the slot
+
+	session  pkcs11.SessionHandle // User session logged in to the token
+	loggedIn bool                 // Whether the session is open and logged in
+}
+
+// NewPKCS11Token loads the PKCS#11 module from the given shared library and
+// creates a token for the given slot of it. The token needs to be closed when
+// not used anymore to unload the module.
+func NewPKCS11Token(module string, slot uint) (*PKCS11Token, error) {
+	ctx := pkcs11.New(module)
+	if ctx == nil {
+		return nil, fmt.Errorf("failed to load PKCS#11 module %s", module)
+	}
+	if err := ctx.Initialize(); err != nil {
+		ctx.Destroy()
+		return nil, fmt.Errorf("failed to initialize PKCS#11 module %s: %v", module, err)
+	}
+	info, err := ctx.GetTokenInfo(slot)
+	if err != nil {
+		ctx.Finalize()
+		ctx.Destroy()
+		return nil, fmt.Errorf("no token in slot %d: %v", slot, err)
+	}
+	label := info.Label
+	if label == "" {
+		label = fmt.Sprintf("slot%d", slot)
+	}
+	return &PKCS11Token{ctx: ctx, slot: slot, label: label}, nil
+}
+
+// Label implements Token, returning the label of the token in the slot.
+func (t *PKCS11Token) Label() string {
+	return t.label
+}
+
+// Login implements Token, opening a session with the token and logging the user
+// in with the given PIN.
+func (t *PKCS11Token) Login(pin string) error {
+	if t.loggedIn {
+		return nil
+	}
+	session, err := t.ctx.OpenSession(t.slot, pkcs11.CKF_SERIAL_SESSION)
+	if err != nil {
+		return err
+	}
+	// Login state is shared by all sessions of the application, it's fine if a
+	// session was logged in already
+	if err := t.ctx.Login(session, pkcs11.CKU_USER, pin); err != nil && err != pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN) {
+		t.ctx.CloseSession(session)
+		return err
+	}
+	t.session, t.loggedIn = session, true
+	return nil
+}
+
+// Logout implements Token, logging the user out and closing the session.
+func (t *PKCS11Token) Logout() error {
+	if !t.loggedIn {
+		return nil
+	}
+	t.loggedIn = false
+
+	err := t.ctx.Logout(t.session)
+	if cerr := t.ctx.CloseSession(t.session); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Keys implements Token, listing the secp256k1 public keys on the token.
+func (t *PKCS11Token) Keys() ([]Key, error) {
+	if !t.loggedIn {
+		return nil, errNotLoggedIn
+	}
+	objects, err := t.findObjects([]*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC),
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, secp256k1Params),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var keys []Key
+	for _, object := range objects {
+		attrs, err := t.ctx.GetAttributeValue(t.session, object, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_ID, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+		})
+		if err != nil {
+			return nil, err
+		}
+		key := Key{}
+		for _, attr := range attrs {
+			switch attr.Type {
+			case pkcs11.CKA_ID:
+				key.ID = attr.Value
+			case pkcs11.CKA_LABEL:
+				key.Label = string(attr.Value)
+			case pkcs11.CKA_EC_POINT:
+				key.Point = attr.Value
+			}
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Sign implements Token, signing the digest using raw ECDSA with the private key
+// sharing the given identifier.
+func (t *PKCS11Token) Sign(id []byte, digest []byte) ([]byte, error) {
+	if !t.loggedIn {
+		return nil, errNotLoggedIn
+	}
+	objects, err := t.findObjects([]*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, id),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) != 1 {
+		return nil, fmt.Errorf("found %d private keys with id %x", len(objects), id)
+	}
+	mechanism := []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil)}
+	if err := t.ctx.SignInit(t.session, mechanism, objects[0]); err != nil {
+		return nil, err
+	}
+	return t.ctx.Sign(t.session, digest)
+}
+
+// NewPKCS11Hub creates an HSM backend for the token in the given slot of a PKCS#11
+// module. If a PIN is given, the wallet of the token is opened with it right away.
+func NewPKCS11Hub(module string, slot uint, pin string) (*Hub, error) {
+	token, err := NewPKCS11Token(module, slot)
+	if err != nil {
+		return nil, err
+	}
+	hub := NewHub(token)
+	if pin != "" {
+		if err := hub.wallets[0].Open(pin); err != nil {
+			token.Close()
+			return nil, err
+		}
+	}
+	return hub, nil
+}
+
+// findObjects returns all objects of the token matching the template.
+func (t *PKCS11Token) findObjects(template []*pkcs11.Attribute) ([]pkcs11.ObjectHandle, error) {
+	if err := t.ctx.FindObjectsInit(t.session, template); err != nil {
+		return nil, err
+	}
+	var objects []pkcs11.ObjectHandle
+	for {
+		found, _, err := t.ctx.FindObjects(t.session, 64)
+		if err != nil {
+			t.ctx.FindObjectsFinal(t.session)
+			return nil, err
+		}
+		if len(found) == 0 {
+			break
+		}
+		objects = append(objects, found...)
+	}
+	return objects, t.ctx.FindObjectsFinal(t.session)
+}
+
+// Close logs out of the token and unloads the PKCS#11 module.
+func (t *PKCS11Token) Close() error {
+	t.Logout()
+	err := t.ctx.Finalize()
+	t.ctx.Destroy()
+	return err
+}
@@ -0,0 +1,60 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build !cgo
+// +build !cgo
+
+// This is the fallback implementation of PKCS#11 tokens, used if cgo is disabled
+// and the PKCS#11 modules can't be loaded.
+
+package hsm
+
+import "errors"
+
+// errNoCgo is returned when accessing PKCS#11 modules without cgo support.
+var errNoCgo = errors.New("HSM support requires cgo")
+
+// PKCS11Token is a Token backed by a slot of a PKCS#11 module. Without cgo it
+// can't be created.
+type PKCS11Token struct{}
+
+// NewPKCS11Token always fails, PKCS#11 modules can't be loaded without cgo.
+func NewPKCS11Token(module string, slot uint) (*PKCS11Token, error) {
+	return nil, errNoCgo
+}
+
+// Label implements Token.
+func (t *PKCS11Token) Label() string { return "" }
+
+// Login implements Token.
+func (t *PKCS11Token) Login(pin string) error { return errNoCgo }
+
+// Logout implements Token.
+func (t *PKCS11Token) Logout() error { return nil }
+
+// Keys implements Token.
+func (t *PKCS11Token) Keys() ([]Key, error) { return nil, errNoCgo }
+
+// Sign implements Token.
+func (t *PKCS11Token) Sign(id []byte, digest []byte) ([]byte, error) { return nil, errNoCgo }
+
+// Close implements io.Closer.
+func (t *PKCS11Token) Close() error { return nil }
+
+// NewPKCS11Hub always fails, PKCS#11 modules can't be loaded without cgo.
+func NewPKCS11Hub(module string, slot uint, pin string) (*Hub, error) {
+	return nil, errNoCgo
+}
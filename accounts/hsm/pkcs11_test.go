@@ -0,0 +1,177 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build cgo
+// +build cgo
+
+package hsm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/miekg/pkcs11"
+)
+
+// softHSMPaths are the usual install locations of the SoftHSM v2 module, the
+// SOFTHSM2_MODULE environment variable takes precedence over them.
+var softHSMPaths = []string{
+	"/usr/lib/softhsm/libsofthsm2.so",
+	"/usr/lib/x86_64-linux-gnu/softhsm/libsofthsm2.so",
+	"/usr/local/lib/softhsm/libsofthsm2.so",
+	"/usr/lib64/pkcs11/libsofthsm2.so",
+	"/usr/local/opt/softhsm/lib/softhsm/libsofthsm2.so",
+}
+
+// newSoftHSM initializes a fresh SoftHSM token with a secp256k1 key pair in a
+// temporary token directory, returning the module and slot of the token. The
+// test is skipped if SoftHSM is not installed.
+func newSoftHSM(t *testing.T, label string, pin string) (string, uint) {
+	module := os.Getenv("SOFTHSM2_MODULE")
+	if module == "" {
+		for _, path := range softHSMPaths {
+			if _, err := os.Stat(path); err == nil {
+				module = path
+				break
+			}
+		}
+	}
+	if module == "" {
+		t.Skip("SoftHSM module not found, set SOFTHSM2_MODULE to run")
+	}
+	// Keep the tokens of the test out of the system token directory
+	dir, err := ioutil.TempDir("", "softhsm")
+	if err != nil {
+		t.Fatalf("failed to create token directory: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	// Point SoftHSM at the test tokens until the test is done
+	config := filepath.Join(dir, "softhsm2.conf")
+	if err := ioutil.WriteFile(config, []byte(fmt.Sprintf("directories.tokendir = %s\nobjectstore.backend = file\n", dir)), 0600); err != nil {
+		t.Fatalf("failed to write SoftHSM config: %v", err)
+	}
+	conf := os.Getenv("SOFTHSM2_CONF")
+	t.Cleanup(func() { os.Setenv("SOFTHSM2_CONF", conf) })
+	os.Setenv("SOFTHSM2_CONF", config)
+
+	ctx := pkcs11.New(module)
+	if ctx == nil {
+		t.Skipf("failed to load SoftHSM module %s", module)
+	}
+	defer ctx.Destroy()
+	if err := ctx.Initialize(); err != nil {
+		t.Fatalf("failed to initialize SoftHSM: %v", err)
+	}
+	defer ctx.Finalize()
+
+	// Initialize the token in the free slot, SoftHSM moves it to a new slot
+	slots, err := ctx.GetSlotList(false)
+	if err != nil || len(slots) == 0 {
+		t.Fatalf("failed to list SoftHSM slots: %v", err)
+	}
+	if err := ctx.InitToken(slots[0], "so-"+pin, label); err != nil {
+		t.Fatalf("failed to initialize token: %v", err)
+	}
+	if slots, err = ctx.GetSlotList(true); err != nil {
+		t.Fatalf("failed to list SoftHSM slots: %v", err)
+	}
+	var slot uint
+	for _, id := range slots {
+		if info, err := ctx.GetTokenInfo(id); err == nil && info.Label == label {
+			slot = id
+		}
+	}
+	session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	if err != nil {
+		t.Fatalf("failed to open session: %v", err)
+	}
+	if err := ctx.Login(session, pkcs11.CKU_SO, "so-"+pin); err != nil {
+		t.Fatalf("failed to log in as security officer: %v", err)
+	}
+	if err := ctx.InitPIN(session, pin); err != nil {
+		t.Fatalf("failed to set user PIN: %v", err)
+	}
+	ctx.Logout(session)
+	if err := ctx.Login(session, pkcs11.CKU_USER, pin); err != nil {
+		t.Fatalf("failed to log in as user: %v", err)
+	}
+	_, _, err = ctx.GenerateKeyPair(session,
+		[]*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_EC_KEY_PAIR_GEN, nil)},
+		[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, secp256k1Params),
+			pkcs11.NewAttribute(pkcs11.CKA_ID, []byte{0x01}),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, "key"),
+		},
+		[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_ID, []byte{0x01}),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, "key"),
+		},
+	)
+	if err != nil {
+		t.Skipf("SoftHSM doesn't support secp256k1 keys: %v", err)
+	}
+	ctx.Logout(session)
+	ctx.CloseSession(session)
+
+	return module, slot
+}
+
+// Tests that keys generated on a SoftHSM token can sign through the wallet.
+func TestPKCS11Signing(t *testing.T) {
+	module, slot := newSoftHSM(t, "geth", "1234")
+
+	if _, err := NewPKCS11Hub(module, slot, "4321"); err == nil {
+		t.Fatalf("opened token with wrong PIN")
+	}
+	hub, err := NewPKCS11Hub(module, slot, "1234")
+	if err != nil {
+		t.Fatalf("failed to open token: %v", err)
+	}
+	defer hub.Close()
+
+	wallets := hub.Wallets()
+	if len(wallets) != 1 || wallets[0].URL() != (accounts.URL{Scheme: Scheme, Path: "geth"}) {
+		t.Fatalf("unexpected wallets: %v", wallets)
+	}
+	accs := wallets[0].Accounts()
+	if len(accs) != 1 {
+		t.Fatalf("account count mismatch: have %d, want 1", len(accs))
+	}
+	text := []byte("hello world")
+	sig, err := wallets[0].SignText(accs[0], text)
+	if err != nil {
+		t.Fatalf("failed to sign text: %v", err)
+	}
+	pubkey, err := crypto.SigToPub(accounts.TextHash(text), sig)
+	if err != nil {
+		t.Fatalf("failed to recover signer: %v", err)
+	}
+	if addr := crypto.PubkeyToAddress(*pubkey); addr != accs[0].Address {
+		t.Fatalf("signer mismatch: have %x, want %x", addr, accs[0].Address)
+	}
+}
@@ -0,0 +1,320 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hsm
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var (
+	// secp256k1N is the order of the secp256k1 curve.
+	secp256k1N = crypto.S256().Params().N
+
+	// secp256k1halfN is half the order of the secp256k1 curve, the upper bound
+	// of canonical signature s values.
+	secp256k1halfN = new(big.Int).Rsh(secp256k1N, 1)
+)
+
+// errInvalidSignature is returned if the token produced a signature that does
+// not verify against the public key of the signing key.
+var errInvalidSignature = errors.New("invalid signature from token")
+
+// wallet implements accounts.Wallet for the keys held by a single token.
+type wallet struct {
+	hub   *Hub         // Backend the wallet belongs to, for event notifications
+	token Token        // Token holding the keys and signing
+	url   accounts.URL // Textual URL uniquely identifying this wallet
+
+	open     bool                    // Whether a session is logged in to the token
+	keys     map[common.Address]*key // Keys available on the token, by address
+	accounts []accounts.Account      // Accounts of the keys, in token order
+	lock     sync.RWMutex            // Protects the wallet state from racey access
+}
+
+// key is a token key pair with its decoded public key.
+type key struct {
+	id     []byte
+	label  string
+	pubkey *ecdsa.PublicKey
+}
+
+// newWallet creates a closed wallet for a token.
+func newWallet(hub *Hub, token Token) *wallet {
+	return &wallet{
+		hub:   hub,
+		token: token,
+		url:   accounts.URL{Scheme: Scheme, Path: token.Label()},
+	}
+}
+
+// URL implements accounts.Wallet, returning the URL of the token.
+func (w *wallet) URL() accounts.URL {
+	return w.url // Immutable, no need for a lock
+}
+
+// Status implements accounts.Wallet, returning whether a session is logged in
+// to the token and the number of keys found on it.
+func (w *wallet) Status() (string, error) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	if !w.open {
+		return "Closed", nil
+	}
+	return fmt.Sprintf("Online, %d keys", len(w.accounts)), nil
+}
+
+// Open implements accounts.Wallet, logging in to the token with the passphrase
+// as the user PIN and loading the keys available on it.
+func (w *wallet) Open(passphrase string) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.open {
+		return accounts.ErrWalletAlreadyOpen
+	}
+	if err := w.token.Login(passphrase); err != nil {
+		return err
+	}
+	if err := w.loadKeys(); err != nil {
+		w.token.Logout()
+		return err
+	}
+	w.open = true
+	go w.hub.updateFeed.Send(accounts.WalletEvent{Wallet: w, Kind: accounts.WalletOpened})
+
+	return nil
+}
+
+// loadKeys retrieves the key pairs from the token and derives their addresses.
+// Keys with unusable public keys are skipped.
+func (w *wallet) loadKeys() error {
+	found, err := w.token.Keys()
+	if err != nil {
+		return err
+	}
+	w.keys = make(map[common.Address]*key)
+	w.accounts = nil
+
+	for _, k := range found {
+		pubkey, err := decodePoint(k.Point)
+		if err != nil {
+			log.Warn("Skipping unusable HSM key", "token", w.url, "id", fmt.Sprintf("%x", k.ID), "label", k.Label, "err", err)
+			continue
+		}
+		address := crypto.PubkeyToAddress(*pubkey)
+		if _, ok := w.keys[address]; ok {
+			continue
+		}
+		w.keys[address] = &key{id: k.ID, label: k.Label, pubkey: pubkey}
+		w.accounts = append(w.accounts, accounts.Account{
+			Address: address,
+			URL:     accounts.URL{Scheme: Scheme, Path: fmt.Sprintf("%s/%x", w.url.Path, k.ID)},
+		})
+	}
+	return nil
+}
+
+// Close implements accounts.Wallet, logging out of the token.
+func (w *wallet) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if !w.open {
+		return nil
+	}
+	w.open, w.keys, w.accounts = false, nil, nil
+	return w.token.Logout()
+}
+
+// Accounts implements accounts.Wallet, returning the accounts of the keys on the
+// token. The list is empty until the wallet is opened.
+func (w *wallet) Accounts() []accounts.Account {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	cpy := make([]accounts.Account, len(w.accounts))
+	copy(cpy, w.accounts)
+	return cpy
+}
+
+// Contains implements accounts.Wallet, returning whether a particular account is
+// or is not held by the token.
+func (w *wallet) Contains(account accounts.Account) bool {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	_, ok := w.keys[account.Address]
+	return ok
+}
+
+// Derive implements accounts.Wallet, but is a noop for HSM wallets since the
+// keys are generated and managed by the token itself.
+func (w *wallet) Derive(path accounts.DerivationPath, pin bool) (accounts.Account, error) {
+	return accounts.Account{}, accounts.ErrNotSupported
+}
+
+// SelfDerive implements accounts.Wallet, but is a noop for HSM wallets since
+// there is no notion of hierarchical account derivation for tokens.
+func (w *wallet) SelfDerive(bases []accounts.DerivationPath, chain ethereum.ChainStateReader) {
+}
+
+// signHash requests the token to sign the hash with the key of the account,
+// returning the signature in the [R || S || V] format where V is 0 or 1.
+func (w *wallet) signHash(account accounts.Account, hash []byte) ([]byte, error) {
+	// Token sessions are not safe for concurrent use, serialize all signing
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if !w.open {
+		return nil, accounts.ErrWalletClosed
+	}
+	k, ok := w.keys[account.Address]
+	if !ok {
+		return nil, accounts.ErrUnknownAccount
+	}
+	sig, err := w.token.Sign(k.id, hash)
+	if err != nil {
+		return nil, err
+	}
+	return recoverableSignature(hash, sig, k.pubkey)
+}
+
+// SignData implements accounts.Wallet, signing the keccak256 hash of the data.
+func (w *wallet) SignData(account accounts.Account, mimeType string, data []byte) ([]byte, error) {
+	return w.signHash(account, crypto.Keccak256(data))
+}
+
+// SignDataWithPassphrase implements accounts.Wallet, attempting to sign the given
+// data with the given account using passphrase as extra authentication.
+// Since tokens are authenticated on opening, these are silently ignored.
+func (w *wallet) SignDataWithPassphrase(account accounts.Account, passphrase, mimeType string, data []byte) ([]byte, error) {
+	return w.SignData(account, mimeType, data)
+}
+
+// SignText implements accounts.Wallet, signing the hash of the given text with
+// the given account.
+func (w *wallet) SignText(account accounts.Account, text []byte) ([]byte, error) {
+	return w.signHash(account, accounts.TextHash(text))
+}
+
+// SignTextWithPassphrase implements accounts.Wallet, attempting to sign the
+// given text with the given account using passphrase as extra authentication.
+// Since tokens are authenticated on opening, these are silently ignored.
+func (w *wallet) SignTextWithPassphrase(account accounts.Account, passphrase string, text []byte) ([]byte, error) {
+	return w.SignText(account, text)
+}
+
+// SignTx implements accounts.Wallet, signing the given transaction with the
+// given account on the token.
+func (w *wallet) SignTx(account accounts.Account, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	// Depending on the presence of the chain ID, sign with 2718 or homestead
+	signer := types.LatestSignerForChainID(chainID)
+
+	sig, err := w.signHash(account, signer.Hash(tx).Bytes())
+	if err != nil {
+		return nil, err
+	}
+	signed, err := tx.WithSignature(signer, sig)
+	if err != nil {
+		return nil, err
+	}
+	// Sanity check that the token signed with the expected key
+	sender, err := types.Sender(signer, signed)
+	if err != nil {
+		return nil, err
+	}
+	if sender != account.Address {
+		return nil, fmt.Errorf("signer mismatch: expected %s, got %s", account.Address.Hex(), sender.Hex())
+	}
+	return signed, nil
+}
+
+// SignTxWithPassphrase implements accounts.Wallet, attempting to sign the given
+// transaction with the given account using passphrase as extra authentication.
+// Since tokens are authenticated on opening, these are silently ignored.
+func (w *wallet) SignTxWithPassphrase(account accounts.Account, passphrase string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return w.SignTx(account, tx, chainID)
+}
+
+// decodePoint decodes a secp256k1 public key in the formats tokens are known to
+// return it in: the uncompressed or compressed SEC1 point, either raw or wrapped
+// into a DER octet string as mandated by PKCS#11 for CKA_EC_POINT.
+func decodePoint(point []byte) (*ecdsa.PublicKey, error) {
+	// Raw points are 33 or 65 bytes long, DER wrapping adds a two byte header
+	if (len(point) == 35 || len(point) == 67) && point[0] == 0x04 && int(point[1]) == len(point)-2 {
+		var raw []byte
+		if _, err := asn1.Unmarshal(point, &raw); err == nil {
+			point = raw
+		}
+	}
+	switch len(point) {
+	case 65:
+		return crypto.UnmarshalPubkey(point)
+	case 33:
+		return crypto.DecompressPubkey(point)
+	}
+	return nil, fmt.Errorf("invalid public key length %d", len(point))
+}
+
+// recoverableSignature converts an ECDSA signature produced by a token into the
+// canonical [R || S || V] format, normalizing S into the lower half of the curve
+// order and finding the recovery id V matching the public key.
+func recoverableSignature(hash []byte, sig []byte, pubkey *ecdsa.PublicKey) ([]byte, error) {
+	var r, s *big.Int
+	switch {
+	case len(sig) == 64:
+		r, s = new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+	default:
+		var der struct{ R, S *big.Int }
+		if rest, err := asn1.Unmarshal(sig, &der); err != nil || len(rest) > 0 {
+			return nil, errors.New("invalid signature encoding from token")
+		}
+		r, s = der.R, der.S
+	}
+	if r.Sign() <= 0 || s.Sign() <= 0 || r.Cmp(secp256k1N) >= 0 || s.Cmp(secp256k1N) >= 0 {
+		return nil, errInvalidSignature
+	}
+	if s.Cmp(secp256k1halfN) > 0 {
+		s = new(big.Int).Sub(secp256k1N, s)
+	}
+	out := make([]byte, crypto.SignatureLength)
+	copy(out[32-len(r.Bytes()):32], r.Bytes())
+	copy(out[64-len(s.Bytes()):64], s.Bytes())
+
+	want := crypto.FromECDSAPub(pubkey)
+	for v := byte(0); v < 2; v++ {
+		out[crypto.RecoveryIDOffset] = v
+		if recovered, err := crypto.Ecrecover(hash, out); err == nil && bytes.Equal(recovered, want) {
+			return out, nil
+		}
+	}
+	return nil, errInvalidSignature
+}
@@ -0,0 +1,257 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hsm
+
+import (
+	"crypto/ecdsa"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// softToken is an in-memory software token mimicking the behaviour of PKCS#11
+// modules such as SoftHSM: points are DER wrapped, signatures carry no recovery
+// id and are not guaranteed to be canonical.
+type softToken struct {
+	label  string
+	pin    string
+	keys   []*ecdsa.PrivateKey
+	der    bool // Whether to return DER encoded signatures instead of raw ones
+	highS  bool // Whether to return signatures with the S value in the upper half
+	logged bool
+	closed bool // Whether the token was closed, releasing its module
+}
+
+func newSoftToken(t *testing.T, label string, keys int) *softToken {
+	token := &softToken{label: label, pin: "1234"}
+	for i := 0; i < keys; i++ {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+		token.keys = append(token.keys, key)
+	}
+	return token
+}
+
+func (t *softToken) Label() string { return t.label }
+
+func (t *softToken) Login(pin string) error {
+	if pin != t.pin {
+		return errors.New("CKR_PIN_INCORRECT")
+	}
+	t.logged = true
+	return nil
+}
+
+func (t *softToken) Logout() error {
+	t.logged = false
+	return nil
+}
+
+func (t *softToken) Keys() ([]Key, error) {
+	var keys []Key
+	for i, key := range t.keys {
+		point, _ := asn1.Marshal(crypto.FromECDSAPub(&key.PublicKey))
+		keys = append(keys, Key{ID: []byte{byte(i)}, Label: "key", Point: point})
+	}
+	return keys, nil
+}
+
+func (t *softToken) Sign(id []byte, digest []byte) ([]byte, error) {
+	if !t.logged {
+		return nil, errors.New("CKR_USER_NOT_LOGGED_IN")
+	}
+	sig, err := crypto.Sign(digest, t.keys[id[0]])
+	if err != nil {
+		return nil, err
+	}
+	r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:64])
+	if t.highS {
+		s.Sub(secp256k1N, s)
+	}
+	if t.der {
+		return asn1.Marshal(struct{ R, S *big.Int }{r, s})
+	}
+	raw := make([]byte, 64)
+	copy(raw[32-len(r.Bytes()):32], r.Bytes())
+	copy(raw[64-len(s.Bytes()):], s.Bytes())
+	return raw, nil
+}
+
+func (t *softToken) Close() error {
+	t.closed = true
+	return nil
+}
+
+// Tests that wallets only expose accounts after logging in to the token, and
+// that the addresses are derived from the public keys on the token.
+func TestWalletAccounts(t *testing.T) {
+	token := newSoftToken(t, "softhsm", 2)
+	hub := NewHub(token)
+	defer hub.Close()
+
+	wallets := hub.Wallets()
+	if len(wallets) != 1 {
+		t.Fatalf("wallet count mismatch: have %d, want 1", len(wallets))
+	}
+	wallet := wallets[0]
+	if url := wallet.URL().String(); url != "hsm://softhsm" {
+		t.Errorf("wallet URL mismatch: have %s, want hsm://softhsm", url)
+	}
+	if accs := wallet.Accounts(); len(accs) != 0 {
+		t.Fatalf("accounts exposed before opening: %v", accs)
+	}
+	if err := wallet.Open("0000"); err == nil {
+		t.Fatalf("wallet opened with invalid PIN")
+	}
+	events := make(chan accounts.WalletEvent, 1)
+	sub := hub.Subscribe(events)
+	defer sub.Unsubscribe()
+
+	if err := wallet.Open("1234"); err != nil {
+		t.Fatalf("failed to open wallet: %v", err)
+	}
+	select {
+	case ev := <-events:
+		if ev.Kind != accounts.WalletOpened || ev.Wallet != wallet {
+			t.Errorf("wallet event mismatch: have %v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("wallet opening not announced")
+	}
+	accs := wallet.Accounts()
+	if len(accs) != len(token.keys) {
+		t.Fatalf("account count mismatch: have %d, want %d", len(accs), len(token.keys))
+	}
+	for i, acc := range accs {
+		if want := crypto.PubkeyToAddress(token.keys[i].PublicKey); acc.Address != want {
+			t.Errorf("account %d: address mismatch: have %x, want %x", i, acc.Address, want)
+		}
+		if !wallet.Contains(acc) {
+			t.Errorf("account %d: not contained in wallet", i)
+		}
+	}
+	if err := wallet.Close(); err != nil {
+		t.Fatalf("failed to close wallet: %v", err)
+	}
+	if _, err := wallet.SignText(accs[0], []byte("hello")); err != accounts.ErrWalletClosed {
+		t.Errorf("signing on closed wallet: have %v, want %v", err, accounts.ErrWalletClosed)
+	}
+}
+
+// Tests that closing the account manager logs out of and closes the tokens of
+// the hub, so PKCS#11 modules are finalized on shutdown.
+func TestManagerCloseTokens(t *testing.T) {
+	token := newSoftToken(t, "softhsm", 1)
+	hub := NewHub(token)
+	if err := hub.Wallets()[0].Open("1234"); err != nil {
+		t.Fatalf("failed to open wallet: %v", err)
+	}
+	am := accounts.NewManager(&accounts.Config{}, hub)
+	if err := am.Close(); err != nil {
+		t.Fatalf("failed to close account manager: %v", err)
+	}
+	if token.logged {
+		t.Errorf("token still logged in")
+	}
+	if !token.closed {
+		t.Errorf("token not closed")
+	}
+}
+
+// Tests that signatures in all the formats tokens produce are converted into
+// canonical recoverable signatures for both transactions and messages.
+func TestWalletSigning(t *testing.T) {
+	for _, format := range []struct{ der, highS bool }{{false, false}, {true, false}, {false, true}, {true, true}} {
+		token := newSoftToken(t, "softhsm", 1)
+		token.der, token.highS = format.der, format.highS
+
+		wallet := NewHub(token).Wallets()[0]
+		if err := wallet.Open("1234"); err != nil {
+			t.Fatalf("failed to open wallet: %v", err)
+		}
+		account := wallet.Accounts()[0]
+
+		// Sign a few messages and check they recover to the account
+		for i := 0; i < 8; i++ {
+			text := []byte{byte(i)}
+			sig, err := wallet.SignText(account, text)
+			if err != nil {
+				t.Fatalf("%+v: failed to sign text: %v", format, err)
+			}
+			if s := new(big.Int).SetBytes(sig[32:64]); s.Cmp(secp256k1halfN) > 0 {
+				t.Errorf("%+v: signature not canonical", format)
+			}
+			pubkey, err := crypto.SigToPub(accounts.TextHash(text), sig)
+			if err != nil {
+				t.Fatalf("%+v: failed to recover signer: %v", format, err)
+			}
+			if addr := crypto.PubkeyToAddress(*pubkey); addr != account.Address {
+				t.Errorf("%+v: signer mismatch: have %x, want %x", format, addr, account.Address)
+			}
+		}
+		// Sign legacy and typed transactions and check the sender
+		to := common.HexToAddress("0xdeadbeef")
+		txs := []*types.Transaction{
+			types.NewTransaction(0, to, big.NewInt(1), 21000, big.NewInt(1), nil),
+			types.NewTx(&types.AccessListTx{ChainID: big.NewInt(1), Nonce: 1, To: &to, Gas: 21000, GasPrice: big.NewInt(1)}),
+		}
+		for i, tx := range txs {
+			signed, err := wallet.SignTx(account, tx, big.NewInt(1))
+			if err != nil {
+				t.Fatalf("%+v: tx %d: failed to sign: %v", format, i, err)
+			}
+			sender, err := types.Sender(types.LatestSignerForChainID(big.NewInt(1)), signed)
+			if err != nil {
+				t.Fatalf("%+v: tx %d: failed to recover sender: %v", format, i, err)
+			}
+			if sender != account.Address {
+				t.Errorf("%+v: tx %d: sender mismatch: have %x, want %x", format, i, sender, account.Address)
+			}
+		}
+	}
+}
+
+// Tests that the public key encodings used by tokens are all understood.
+func TestDecodePoint(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	uncompressed := crypto.FromECDSAPub(&key.PublicKey)
+	compressed := crypto.CompressPubkey(&key.PublicKey)
+	wrapped, _ := asn1.Marshal(uncompressed)
+	wrappedCompressed, _ := asn1.Marshal(compressed)
+
+	for i, point := range [][]byte{uncompressed, compressed, wrapped, wrappedCompressed} {
+		pubkey, err := decodePoint(point)
+		if err != nil {
+			t.Fatalf("point %d: failed to decode: %v", i, err)
+		}
+		if crypto.PubkeyToAddress(*pubkey) != crypto.PubkeyToAddress(key.PublicKey) {
+			t.Errorf("point %d: public key mismatch", i)
+		}
+	}
+	if _, err := decodePoint(uncompressed[:40]); err == nil {
+		t.Errorf("truncated point accepted")
+	}
+}
@@ -486,6 +486,19 @@ func (ks *KeyStore) Update(a accounts.Account, passphrase, newPassphrase string)
 	return ks.storage.StoreKey(a.URL.Path, key, newPassphrase)
 }
 
+// Rekey re-encrypts an existing account with a new passphrase and key derivation
+// function, e.g. to upgrade it to a stronger one. The original keyfile is only
+// replaced once the re-encrypted one is verified to decrypt to the same key.
+func (ks *KeyStore) Rekey(a accounts.Account, passphrase, newPassphrase string, kdf KDF) error {
+	a, key, err := ks.getDecryptedKey(a, passphrase)
+	if err != nil {
+		return err
+	}
+	defer zeroKey(key.PrivateKey)
+
+	return rekeyKey(a.URL.Path, key, newPassphrase, kdf)
+}
+
 // ImportPreSaleKey decrypts the given Ethereum presale wallet and stores
 // a key file in the key directory. The key file is encrypted with the same passphrase.
 func (ks *KeyStore) ImportPreSaleKey(keyJSON []byte, passphrase string) (accounts.Account, error) {
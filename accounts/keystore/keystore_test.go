@@ -60,7 +60,10 @@ func TestKeyStore(t *testing.T) {
 	if err := ks.Update(a, "foo", "bar"); err != nil {
 		t.Errorf("Update error: %v", err)
 	}
-	if err := ks.Delete(a, "bar"); err != nil {
+	if err := ks.Rekey(a, "bar", "baz", veryLightArgon2id); err != nil {
+		t.Errorf("Rekey error: %v", err)
+	}
+	if err := ks.Delete(a, "baz"); err != nil {
 		t.Errorf("Delete error: %v", err)
 	}
 	if common.FileExist(a.URL.Path) {
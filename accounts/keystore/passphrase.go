@@ -32,6 +32,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -43,6 +44,7 @@ import (
 	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/google/uuid"
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/pbkdf2"
 	"golang.org/x/crypto/scrypt"
 )
@@ -68,8 +70,137 @@ const (
 
 	scryptR     = 8
 	scryptDKLen = 32
+
+	keyHeaderKDFArgon2id = "argon2id"
+
+	// StandardArgon2idTime is the number of passes of Argon2id encryption, using
+	// 256MB memory and taking approximately 1s CPU time on a modern processor.
+	StandardArgon2idTime = 3
+
+	// StandardArgon2idMemory is the memory in KiB used by Argon2id encryption,
+	// using 256MB memory and taking approximately 1s CPU time on a modern processor.
+	StandardArgon2idMemory = 256 * 1024
+
+	// StandardArgon2idThreads is the parallelism of Argon2id encryption, using
+	// 256MB memory and taking approximately 1s CPU time on a modern processor.
+	StandardArgon2idThreads = 4
+
+	// LightArgon2idTime is the number of passes of Argon2id encryption, using
+	// 8MB memory and taking approximately 100ms CPU time on a modern processor.
+	LightArgon2idTime = 4
+
+	// LightArgon2idMemory is the memory in KiB used by Argon2id encryption,
+	// using 8MB memory and taking approximately 100ms CPU time on a modern processor.
+	LightArgon2idMemory = 8 * 1024
+
+	// LightArgon2idThreads is the parallelism of Argon2id encryption, using 8MB
+	// memory and taking approximately 100ms CPU time on a modern processor.
+	LightArgon2idThreads = 1
+
+	argon2idDKLen = 32
+
+	// maxArgon2idMemory is the largest argon2id memory in KiB (4GB) accepted,
+	// capping the allocation a crafted keyfile can make us do.
+	maxArgon2idMemory = 4 * 1024 * 1024
 )
 
+// KDF is the configuration of the key derivation function used to turn the
+// passphrase into the encryption key of a keyfile.
+type KDF struct {
+	Name string // Name of the key derivation function (scrypt or argon2id)
+
+	ScryptN int // CPU/memory cost parameter of scrypt
+	ScryptP int // Parallelization parameter of scrypt
+
+	Argon2idTime    uint32 // Number of passes over the memory of argon2id
+	Argon2idMemory  uint32 // Memory used by argon2id in KiB
+	Argon2idThreads uint8  // Number of threads used by argon2id
+}
+
+// ScryptKDF returns a scrypt key derivation configuration.
+func ScryptKDF(n, p int) KDF {
+	return KDF{Name: keyHeaderKDF, ScryptN: n, ScryptP: p}
+}
+
+// Argon2idKDF returns an argon2id key derivation configuration.
+func Argon2idKDF(time, memory uint32, threads uint8) KDF {
+	return KDF{Name: keyHeaderKDFArgon2id, Argon2idTime: time, Argon2idMemory: memory, Argon2idThreads: threads}
+}
+
+// NewKDF returns the standard or light configuration of the key derivation
+// function with the given name.
+func NewKDF(name string, light bool) (KDF, error) {
+	switch name {
+	case keyHeaderKDF:
+		if light {
+			return ScryptKDF(LightScryptN, LightScryptP), nil
+		}
+		return ScryptKDF(StandardScryptN, StandardScryptP), nil
+	case keyHeaderKDFArgon2id:
+		if light {
+			return Argon2idKDF(LightArgon2idTime, LightArgon2idMemory, LightArgon2idThreads), nil
+		}
+		return Argon2idKDF(StandardArgon2idTime, StandardArgon2idMemory, StandardArgon2idThreads), nil
+	}
+	return KDF{}, fmt.Errorf("unsupported KDF: %s", name)
+}
+
+// derive generates a new random salt and derives the encryption key from the
+// passphrase, returning the key along with the parameters to store.
+func (kdf KDF) derive(auth []byte) ([]byte, map[string]interface{}, error) {
+	salt := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		panic("reading from crypto/rand failed: " + err.Error())
+	}
+	switch kdf.Name {
+	case keyHeaderKDF:
+		derivedKey, err := scrypt.Key(auth, salt, kdf.ScryptN, scryptR, kdf.ScryptP, scryptDKLen)
+		if err != nil {
+			return nil, nil, err
+		}
+		params := make(map[string]interface{}, 5)
+		params["n"] = kdf.ScryptN
+		params["r"] = scryptR
+		params["p"] = kdf.ScryptP
+		params["dklen"] = scryptDKLen
+		params["salt"] = hex.EncodeToString(salt)
+		return derivedKey, params, nil
+
+	case keyHeaderKDFArgon2id:
+		if err := checkArgon2idParams(kdf.Argon2idTime, kdf.Argon2idMemory, kdf.Argon2idThreads); err != nil {
+			return nil, nil, err
+		}
+		derivedKey := argon2.IDKey(auth, salt, kdf.Argon2idTime, kdf.Argon2idMemory, kdf.Argon2idThreads, argon2idDKLen)
+
+		params := make(map[string]interface{}, 5)
+		params["t"] = kdf.Argon2idTime
+		params["m"] = kdf.Argon2idMemory
+		params["p"] = kdf.Argon2idThreads
+		params["dklen"] = argon2idDKLen
+		params["salt"] = hex.EncodeToString(salt)
+		return derivedKey, params, nil
+	}
+	return nil, nil, fmt.Errorf("unsupported KDF: %s", kdf.Name)
+}
+
+// checkArgon2idParams validates the argon2id parameters, which would otherwise
+// make the derivation panic.
+func checkArgon2idParams(time, memory uint32, threads uint8) error {
+	if time < 1 {
+		return errors.New("argon2id time must be at least 1")
+	}
+	if threads < 1 {
+		return errors.New("argon2id parallelism must be at least 1")
+	}
+	if memory < 8*uint32(threads) {
+		return fmt.Errorf("argon2id memory must be at least %d KiB", 8*uint32(threads))
+	}
+	if memory > maxArgon2idMemory {
+		return fmt.Errorf("argon2id memory must be at most %d KiB", maxArgon2idMemory)
+	}
+	return nil
+}
+
 type keyStorePassphrase struct {
 	keysDirPath string
 	scryptN     int
@@ -130,6 +261,61 @@ func (ks keyStorePassphrase) StoreKey(filename string, key *Key, auth string) er
 	return os.Rename(tmpName, filename)
 }
 
+// RekeyKeyFile re-encrypts the keyfile at the given path with a new passphrase
+// and key derivation function, returning the address of the key.
+func RekeyKeyFile(filename, auth, newAuth string, kdf KDF) (common.Address, error) {
+	keyjson, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return common.Address{}, err
+	}
+	key, err := DecryptKey(keyjson, auth)
+	if err != nil {
+		return common.Address{}, err
+	}
+	defer zeroKey(key.PrivateKey)
+
+	return key.Address, rekeyKey(filename, key, newAuth, kdf)
+}
+
+// rekeyKey encrypts a decrypted key with a new passphrase and key derivation
+// function and replaces the keyfile with it. The new keyfile is written next to
+// the original one and is only moved into its place after it has been verified
+// to decrypt to the same key, so the original is retained if anything fails.
+func rekeyKey(filename string, key *Key, newAuth string, kdf KDF) error {
+	keyjson, err := EncryptKeyWithKDF(key, newAuth, kdf)
+	if err != nil {
+		return err
+	}
+	tmpName, err := writeTemporaryKeyFile(filename, keyjson)
+	if err != nil {
+		return err
+	}
+	if err := verifyKeyFile(tmpName, key, newAuth); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("failed to verify re-encrypted key, original retained: %v", err)
+	}
+	return os.Rename(tmpName, filename)
+}
+
+// verifyKeyFile checks that the keyfile at the given path decrypts to the given
+// key with the given passphrase.
+func verifyKeyFile(filename string, key *Key, auth string) error {
+	keyjson, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	stored, err := DecryptKey(keyjson, auth)
+	if err != nil {
+		return err
+	}
+	defer zeroKey(stored.PrivateKey)
+
+	if stored.Address != key.Address || stored.Id != key.Id || stored.PrivateKey.D.Cmp(key.PrivateKey.D) != 0 {
+		return errors.New("key content mismatch")
+	}
+	return nil
+}
+
 func (ks keyStorePassphrase) JoinPath(filename string) string {
 	if filepath.IsAbs(filename) {
 		return filename
@@ -139,12 +325,13 @@ func (ks keyStorePassphrase) JoinPath(filename string) string {
 
 // Encryptdata encrypts the data given as 'data' with the password 'auth'.
 func EncryptDataV3(data, auth []byte, scryptN, scryptP int) (CryptoJSON, error) {
+	return EncryptDataV3WithKDF(data, auth, ScryptKDF(scryptN, scryptP))
+}
 
-	salt := make([]byte, 32)
-	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
-		panic("reading from crypto/rand failed: " + err.Error())
-	}
-	derivedKey, err := scrypt.Key(auth, salt, scryptN, scryptR, scryptP, scryptDKLen)
+// EncryptDataV3WithKDF encrypts the data given as 'data' with the password 'auth',
+// deriving the encryption key with the given key derivation function.
+func EncryptDataV3WithKDF(data, auth []byte, kdf KDF) (CryptoJSON, error) {
+	derivedKey, kdfParamsJSON, err := kdf.derive(auth)
 	if err != nil {
 		return CryptoJSON{}, err
 	}
@@ -160,12 +347,6 @@ func EncryptDataV3(data, auth []byte, scryptN, scryptP int) (CryptoJSON, error)
 	}
 	mac := crypto.Keccak256(derivedKey[16:32], cipherText)
 
-	scryptParamsJSON := make(map[string]interface{}, 5)
-	scryptParamsJSON["n"] = scryptN
-	scryptParamsJSON["r"] = scryptR
-	scryptParamsJSON["p"] = scryptP
-	scryptParamsJSON["dklen"] = scryptDKLen
-	scryptParamsJSON["salt"] = hex.EncodeToString(salt)
 	cipherParamsJSON := cipherparamsJSON{
 		IV: hex.EncodeToString(iv),
 	}
@@ -174,8 +355,8 @@ func EncryptDataV3(data, auth []byte, scryptN, scryptP int) (CryptoJSON, error)
 		Cipher:       "aes-128-ctr",
 		CipherText:   hex.EncodeToString(cipherText),
 		CipherParams: cipherParamsJSON,
-		KDF:          keyHeaderKDF,
-		KDFParams:    scryptParamsJSON,
+		KDF:          kdf.Name,
+		KDFParams:    kdfParamsJSON,
 		MAC:          hex.EncodeToString(mac),
 	}
 	return cryptoStruct, nil
@@ -184,8 +365,14 @@ func EncryptDataV3(data, auth []byte, scryptN, scryptP int) (CryptoJSON, error)
 // EncryptKey encrypts a key using the specified scrypt parameters into a json
 // blob that can be decrypted later on.
 func EncryptKey(key *Key, auth string, scryptN, scryptP int) ([]byte, error) {
+	return EncryptKeyWithKDF(key, auth, ScryptKDF(scryptN, scryptP))
+}
+
+// EncryptKeyWithKDF encrypts a key using the specified key derivation function
+// into a json blob that can be decrypted later on.
+func EncryptKeyWithKDF(key *Key, auth string, kdf KDF) ([]byte, error) {
 	keyBytes := math.PaddedBigBytes(key.PrivateKey.D, 32)
-	cryptoStruct, err := EncryptDataV3(keyBytes, []byte(auth), scryptN, scryptP)
+	cryptoStruct, err := EncryptDataV3WithKDF(keyBytes, []byte(auth), kdf)
 	if err != nil {
 		return nil, err
 	}
@@ -351,6 +538,18 @@ func getKDFKey(cryptoJSON CryptoJSON, auth string) ([]byte, error) {
 		}
 		key := pbkdf2.Key(authArray, salt, c, dkLen, sha256.New)
 		return key, nil
+
+	} else if cryptoJSON.KDF == keyHeaderKDFArgon2id {
+		t := ensureInt(cryptoJSON.KDFParams["t"])
+		m := ensureInt(cryptoJSON.KDFParams["m"])
+		p := ensureInt(cryptoJSON.KDFParams["p"])
+		if t < 0 || uint64(t) > math.MaxUint32 || m < 0 || uint64(m) > math.MaxUint32 || p < 0 || p > math.MaxUint8 {
+			return nil, errors.New("argon2id parameters out of range")
+		}
+		if err := checkArgon2idParams(uint32(t), uint32(m), uint8(p)); err != nil {
+			return nil, err
+		}
+		return argon2.IDKey(authArray, salt, uint32(t), uint32(m), uint8(p), uint32(dkLen)), nil
 	}
 
 	return nil, fmt.Errorf("unsupported KDF: %s", cryptoJSON.KDF)
@@ -17,7 +17,10 @@
 package keystore
 
 import (
+	"encoding/json"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -28,6 +31,9 @@ const (
 	veryLightScryptP = 1
 )
 
+// veryLightArgon2id is the cheapest valid argon2id configuration, for tests.
+var veryLightArgon2id = Argon2idKDF(1, 8, 1)
+
 // Tests that a json key file can be decrypted and encrypted in multiple rounds.
 func TestKeyEncryptDecrypt(t *testing.T) {
 	keyjson, err := ioutil.ReadFile("testdata/very-light-scrypt.json")
@@ -58,3 +64,104 @@ func TestKeyEncryptDecrypt(t *testing.T) {
 		}
 	}
 }
+
+// Tests that keys can be encrypted with argon2id and decrypted again.
+func TestKeyEncryptDecryptArgon2id(t *testing.T) {
+	keyjson, err := ioutil.ReadFile("testdata/very-light-scrypt.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := DecryptKey(keyjson, "")
+	if err != nil {
+		t.Fatalf("json key failed to decrypt: %v", err)
+	}
+	if keyjson, err = EncryptKeyWithKDF(key, "argon", veryLightArgon2id); err != nil {
+		t.Fatalf("failed to encrypt key with argon2id: %v", err)
+	}
+	var stored encryptedKeyJSONV3
+	if err := json.Unmarshal(keyjson, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Crypto.KDF != "argon2id" {
+		t.Errorf("kdf mismatch: have %s, want argon2id", stored.Crypto.KDF)
+	}
+	if _, err := DecryptKey(keyjson, "bad"); err != ErrDecrypt {
+		t.Errorf("json key decrypted with bad password: %v", err)
+	}
+	decrypted, err := DecryptKey(keyjson, "argon")
+	if err != nil {
+		t.Fatalf("json key failed to decrypt: %v", err)
+	}
+	if decrypted.Address != key.Address {
+		t.Errorf("key address mismatch: have %x, want %x", decrypted.Address, key.Address)
+	}
+	// Invalid parameters must be rejected instead of crashing the derivation
+	if _, err := EncryptKeyWithKDF(key, "argon", Argon2idKDF(1, 8, 0)); err == nil {
+		t.Errorf("argon2id with zero parallelism accepted")
+	}
+	if _, err := EncryptKeyWithKDF(key, "argon", Argon2idKDF(1, maxArgon2idMemory+1, 1)); err == nil {
+		t.Errorf("argon2id with excessive memory accepted")
+	}
+	stored.Crypto.KDFParams["m"] = maxArgon2idMemory + 1
+	if keyjson, err = json.Marshal(stored); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecryptKey(keyjson, "argon"); err == nil || err == ErrDecrypt {
+		t.Errorf("keyfile with excessive argon2id memory not rejected: %v", err)
+	}
+	if _, err := NewKDF("bcrypt", false); err == nil {
+		t.Errorf("unsupported kdf accepted")
+	}
+}
+
+// Tests that keyfiles can be re-encrypted with a new passphrase and KDF, and that
+// the original is retained if that fails.
+func TestRekeyKeyFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keystore-rekey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	keyjson, err := ioutil.ReadFile("testdata/very-light-scrypt.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "key.json")
+	if err := ioutil.WriteFile(path, keyjson, 0600); err != nil {
+		t.Fatal(err)
+	}
+	// Failing to decrypt must leave the original in place
+	if _, err := RekeyKeyFile(path, "bad", "new", veryLightArgon2id); err == nil {
+		t.Fatalf("keyfile rekeyed with bad password")
+	}
+	if blob, _ := ioutil.ReadFile(path); string(blob) != string(keyjson) {
+		t.Fatalf("original keyfile modified on failure")
+	}
+	// Rekeying should upgrade the KDF and change the password
+	addr, err := RekeyKeyFile(path, "", "new", veryLightArgon2id)
+	if err != nil {
+		t.Fatalf("failed to rekey keyfile: %v", err)
+	}
+	if want := common.HexToAddress("45dea0fb0bba44f4fcf290bba71fd57d7117cbb8"); addr != want {
+		t.Errorf("address mismatch: have %x, want %x", addr, want)
+	}
+	rekeyed, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecryptKey(rekeyed, ""); err == nil {
+		t.Errorf("rekeyed keyfile decrypted with old password")
+	}
+	if key, err := DecryptKey(rekeyed, "new"); err != nil || key.Address != addr {
+		t.Errorf("rekeyed keyfile failed to decrypt: %v", err)
+	}
+	// No temporary files should be left behind
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("leftover files in keystore: have %d, want 1", len(files))
+	}
+}
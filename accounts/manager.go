@@ -17,6 +17,7 @@
 package accounts
 
 import (
+	"io"
 	"reflect"
 	"sort"
 	"sync"
@@ -81,11 +82,26 @@ func NewManager(config *Config, backends ...Backend) *Manager {
 	return am
 }
 
-// Close terminates the account manager's internal notification processes.
+// Close terminates the account manager's internal notification processes and
+// closes the backends holding on to resources, such as loaded HSM modules.
 func (am *Manager) Close() error {
 	errc := make(chan error)
 	am.quit <- errc
-	return <-errc
+	err := <-errc
+
+	am.lock.RLock()
+	defer am.lock.RUnlock()
+
+	for _, backends := range am.backends {
+		for _, backend := range backends {
+			if closer, ok := backend.(io.Closer); ok {
+				if cerr := closer.Close(); err == nil {
+					err = cerr
+				}
+			}
+		}
+	}
+	return err
 }
 
 // Config returns the configuration of account manager.
@@ -18,29 +18,33 @@ package main
 
 import (
 	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
 	"strings"
 
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/signer/core"
 	"github.com/ethereum/go-ethereum/signer/fourbyte"
 )
 
+var (
+	jsonFlag   = flag.Bool("json", false, "Output all candidate decodings as ranked JSON, decoding nested calls recursively")
+	txFlag     = flag.Bool("tx", false, "Interpret the input as a signed transaction instead of raw call data")
+	customFlag = flag.String("4bytedb-custom", "", "File containing additional 4byte-identifiers to decode with")
+)
+
 func init() {
 	flag.Usage = func() {
-		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "<hexdata>")
+		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "[options] <hexdata>")
 		flag.PrintDefaults()
 		fmt.Fprintln(os.Stderr, `
 Parses the given ABI data and tries to interpret it from the fourbyte database.`)
 	}
 }
 
-func parse(data []byte) {
-	db, err := fourbyte.New()
-	if err != nil {
-		die(err)
-	}
+func parse(db *fourbyte.Database, data []byte) {
 	messages := core.ValidationMessages{}
 	db.ValidateCallData(nil, data, &messages)
 	for _, m := range messages.Messages {
@@ -48,6 +52,20 @@ func parse(data []byte) {
 	}
 }
 
+// dump decodes the given call data with all the candidate signatures and prints
+// the ranked results as JSON.
+func dump(db *fourbyte.Database, data []byte) {
+	decodings, err := db.DecodeCallData(data)
+	if err != nil {
+		die(err)
+	}
+	out, err := json.MarshalIndent(decodings, "", "  ")
+	if err != nil {
+		die(err)
+	}
+	fmt.Println(string(out))
+}
+
 // Example
 // ./abidump a9059cbb000000000000000000000000ea0e2dc7d65a50e77fc7e84bff3fd2a9e781ff5c0000000000000000000000000000000000000000000000015af1d78b58c40000
 func main() {
@@ -60,7 +78,25 @@ func main() {
 		if err != nil {
 			die(err)
 		}
-		parse(data)
+		db, err := fourbyte.NewWithFile(*customFlag)
+		if err != nil {
+			die(err)
+		}
+		if *txFlag {
+			tx := new(types.Transaction)
+			if err := tx.UnmarshalBinary(data); err != nil {
+				die(err)
+			}
+			if tx.To() == nil {
+				die("Contract creation has no call data to decode")
+			}
+			data = tx.Data()
+		}
+		if *jsonFlag {
+			dump(db, data)
+		} else {
+			parse(db, data)
+		}
 	default:
 		fmt.Fprintln(os.Stderr, "Error: one argument needed")
 		flag.Usage()
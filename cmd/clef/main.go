@@ -36,6 +36,7 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/hsm"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/cmd/utils"
 	"github.com/ethereum/go-ethereum/common"
@@ -51,6 +52,7 @@ import (
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/ethereum/go-ethereum/signer/core"
 	"github.com/ethereum/go-ethereum/signer/fourbyte"
+	"github.com/ethereum/go-ethereum/signer/policy"
 	"github.com/ethereum/go-ethereum/signer/rules"
 	"github.com/ethereum/go-ethereum/signer/storage"
 
@@ -125,6 +127,14 @@ var (
 		Name:  "rules",
 		Usage: "Path to the rule file to auto-authorize requests with",
 	}
+	policyFlag = cli.StringFlag{
+		Name:  "policy",
+		Usage: "Path to the declarative (TOML) policy file to auto-authorize requests with, instead of rules",
+	}
+	safeCosignersFlag = cli.StringFlag{
+		Name:  "safe.cosigners",
+		Usage: "Comma separated list of owner=URL pairs of remote Clef instances signing Gnosis Safe proposals for the owner",
+	}
 	stdiouiFlag = cli.BoolFlag{
 		Name: "stdio-ui",
 		Usage: "Use STDIN/STDOUT as a channel for an external UI. " +
@@ -135,6 +145,10 @@ var (
 		Name:  "stdio-ui-test",
 		Usage: "Mechanism to test interface between Clef and UI. Requires 'stdio-ui'.",
 	}
+	decodeTxFlag = cli.BoolFlag{
+		Name:  "tx",
+		Usage: "Interpret the inputs as signed transactions instead of raw call data",
+	}
 	app         = cli.NewApp()
 	initCommand = cli.Command{
 		Action:    utils.MigrateFlags(initializeSecrets),
@@ -160,8 +174,8 @@ the rule-engine to work.`,
 			signerSecretFlag,
 		},
 		Description: `
-The attest command stores the sha256 of the rule.js-file that you want to use for automatic processing of
-incoming requests.
+The attest command stores the sha256 of the rule.js-file (or the declarative policy file) that you
+want to use for automatic processing of incoming requests.
 
 Whenever you make an edit to the rule file, you need to use attestation to tell
 Clef that the file is 'safe' to execute.`,
@@ -208,6 +222,25 @@ The newaccount command creates a new keystore-backed account. It is a convenienc
 which can be used in lieu of an external UI.`,
 	}
 
+	decodeCommand = cli.Command{
+		Action:    utils.MigrateFlags(decodeCallData),
+		Name:      "decode",
+		Usage:     "Decode call data using the 4byte database",
+		ArgsUsage: "[<hexdata>...]",
+		Flags: []cli.Flag{
+			customDBFlag,
+			decodeTxFlag,
+		},
+		Description: `
+The decode command resolves the candidate method signatures of call data from the
+4byte database and decodes it with each of them, without starting the signer. Calls
+nested in multisig transactions, multicalls and multisends are decoded recursively.
+
+The call data is taken from the arguments, or read from standard input one per line
+if none are given. For every input, a line of JSON is printed with the decodings
+ranked by how well they match.`,
+	}
+
 	gendocCommand = cli.Command{
 		Action: GenDoc,
 		Name:   "gendoc",
@@ -229,6 +262,9 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.LightKDFFlag,
 			utils.NoUSBFlag,
 			utils.SmartCardDaemonPathFlag,
+			utils.HSMModuleFlag,
+			utils.HSMSlotFlag,
+			utils.HSMPINFileFlag,
 			utils.HTTPListenAddrFlag,
 			utils.HTTPVirtualHostsFlag,
 			utils.IPCDisabledFlag,
@@ -239,6 +275,8 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			customDBFlag,
 			auditLogFlag,
 			ruleFlag,
+			policyFlag,
+			safeCosignersFlag,
 			stdiouiFlag,
 			testFlag,
 			advancedMode,
@@ -258,6 +296,9 @@ func init() {
 		utils.LightKDFFlag,
 		utils.NoUSBFlag,
 		utils.SmartCardDaemonPathFlag,
+		utils.HSMModuleFlag,
+		utils.HSMSlotFlag,
+		utils.HSMPINFileFlag,
 		utils.HTTPListenAddrFlag,
 		utils.HTTPVirtualHostsFlag,
 		utils.IPCDisabledFlag,
@@ -268,6 +309,8 @@ func init() {
 		customDBFlag,
 		auditLogFlag,
 		ruleFlag,
+		policyFlag,
+		safeCosignersFlag,
 		stdiouiFlag,
 		testFlag,
 		advancedMode,
@@ -279,6 +322,7 @@ func init() {
 		setCredentialCommand,
 		delCredentialCommand,
 		newAccountCommand,
+		decodeCommand,
 		gendocCommand}
 	cli.CommandHelpTemplate = flags.CommandHelpTemplate
 	// Override the default app help template
@@ -473,6 +517,58 @@ func removeCredential(ctx *cli.Context) error {
 	return nil
 }
 
+// decodeResult is the outcome of decoding a single input of the decode command.
+type decodeResult struct {
+	Input     string               `json:"input"`
+	Decodings []*fourbyte.Decoding `json:"decodings,omitempty"`
+	Error     string               `json:"error,omitempty"`
+}
+
+func decodeCallData(c *cli.Context) error {
+	db, err := fourbyte.NewWithFile(c.GlobalString(customDBFlag.Name))
+	if err != nil {
+		utils.Fatalf("Failed to open 4byte database: %v", err)
+	}
+	decode := func(input string) *decodeResult {
+		result := &decodeResult{Input: input}
+
+		data, err := hex.DecodeString(strings.TrimPrefix(input, "0x"))
+		if err == nil {
+			if c.Bool(decodeTxFlag.Name) {
+				tx := new(types.Transaction)
+				if err = tx.UnmarshalBinary(data); err == nil {
+					result.Decodings, err = db.DecodeTransaction(tx)
+				}
+			} else {
+				result.Decodings, err = db.DecodeCallData(data)
+			}
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		return result
+	}
+	out := json.NewEncoder(os.Stdout)
+	if c.NArg() > 0 {
+		for _, input := range c.Args() {
+			if err := out.Encode(decode(input)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		if input := strings.TrimSpace(scanner.Text()); input != "" {
+			if err := out.Encode(decode(input)); err != nil {
+				return err
+			}
+		}
+	}
+	return scanner.Err()
+}
+
 func newAccount(c *cli.Context) error {
 	if err := initialize(c); err != nil {
 		return err
@@ -523,6 +619,25 @@ func initialize(c *cli.Context) error {
 	return nil
 }
 
+// makeSafeCosigners parses the owner=URL pairs of the remote Clef instances
+// signing Safe proposals and connects to them.
+func makeSafeCosigners(spec string) (map[common.Address]core.SafeCosigner, error) {
+	cosigners := make(map[common.Address]core.SafeCosigner)
+	for _, pair := range utils.SplitAndTrim(spec) {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || !common.IsHexAddress(parts[0]) {
+			return nil, fmt.Errorf("invalid cosigner %q, want owner=URL", pair)
+		}
+		client, err := rpc.Dial(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to cosigner %s: %v", parts[1], err)
+		}
+		cosigners[common.HexToAddress(parts[0])] = core.NewRemoteCosigner(client)
+		log.Info("Configured Safe cosigner", "owner", common.HexToAddress(parts[0]), "url", parts[1])
+	}
+	return cosigners, nil
+}
+
 // ipcEndpoint resolves an IPC endpoint based on a configured value, taking into
 // account the set data folders as well as the designated platform we're currently
 // running on.
@@ -574,7 +689,14 @@ func signer(c *cli.Context) error {
 	var (
 		api       core.ExternalAPI
 		pwStorage storage.Storage = &storage.NoStorage{}
+		msStorage                 = storage.NewEphemeralStorage()
+		evaluator interface {
+			SetAuditor(auditor core.DecisionAuditor)
+		}
 	)
+	if c.GlobalString(ruleFlag.Name) != "" && c.GlobalString(policyFlag.Name) != "" {
+		utils.Fatalf("Only one of --%s and --%s may be used", ruleFlag.Name, policyFlag.Name)
+	}
 	configDir := c.GlobalString(configdirFlag.Name)
 	if stretchedKey, err := readMasterKey(c, ui); err != nil {
 		log.Warn("Failed to open master, rules disabled", "err", err)
@@ -585,11 +707,14 @@ func signer(c *cli.Context) error {
 		pwkey := crypto.Keccak256([]byte("credentials"), stretchedKey)
 		jskey := crypto.Keccak256([]byte("jsstorage"), stretchedKey)
 		confkey := crypto.Keccak256([]byte("config"), stretchedKey)
+		mskey := crypto.Keccak256([]byte("multisig"), stretchedKey)
+		policykey := crypto.Keccak256([]byte("policy"), stretchedKey)
 
 		// Initialize the encrypted storages
 		pwStorage = storage.NewAESEncryptedStorage(filepath.Join(vaultLocation, "credentials.json"), pwkey)
 		jsStorage := storage.NewAESEncryptedStorage(filepath.Join(vaultLocation, "jsstorage.json"), jskey)
 		configStorage := storage.NewAESEncryptedStorage(filepath.Join(vaultLocation, "config.json"), confkey)
+		msStorage = storage.NewAESEncryptedStorage(filepath.Join(vaultLocation, "multisig.json"), mskey)
 
 		// Do we have a rule-file?
 		if ruleFile := c.GlobalString(ruleFlag.Name); ruleFile != "" {
@@ -614,6 +739,32 @@ func signer(c *cli.Context) error {
 				}
 			}
 		}
+		// Do we have a policy-file?
+		if policyFile := c.GlobalString(policyFlag.Name); policyFile != "" {
+			policyTOML, err := ioutil.ReadFile(policyFile)
+			if err != nil {
+				log.Warn("Could not load policy, disabling", "file", policyFile, "err", err)
+			} else {
+				shasum := sha256.Sum256(policyTOML)
+				foundShaSum := hex.EncodeToString(shasum[:])
+				storedShasum, _ := configStorage.Get("ruleset_sha256")
+				if storedShasum != foundShaSum {
+					log.Warn("Policy hash not attested, disabling", "hash", foundShaSum, "attested", storedShasum)
+				} else {
+					parsed, err := policy.Parse(policyTOML)
+					if err != nil {
+						utils.Fatalf("Invalid policy %s: %v", policyFile, err)
+					}
+					policyStorage := storage.NewAESEncryptedStorage(filepath.Join(vaultLocation, "policy.json"), policykey)
+					policyEngine, err := policy.NewPolicyEvaluator(ui, policyStorage, parsed)
+					if err != nil {
+						utils.Fatalf(err.Error())
+					}
+					ui, evaluator = policyEngine, policyEngine
+					log.Info("Policy engine configured", "file", policyFile)
+				}
+			}
+		}
 	}
 	var (
 		chainId  = c.GlobalInt64(chainIdFlag.Name)
@@ -625,19 +776,42 @@ func signer(c *cli.Context) error {
 	)
 	log.Info("Starting signer", "chainid", chainId, "keystore", ksLoc,
 		"light-kdf", lightKdf, "advanced", advanced)
-	am := core.StartClefAccountManager(ksLoc, nousb, lightKdf, scpath)
+	var backends []accounts.Backend
+	if c.GlobalString(utils.HSMModuleFlag.Name) != "" {
+		hub, err := hsm.NewPKCS11HubWithPINFile(c.GlobalString(utils.HSMModuleFlag.Name), c.GlobalUint(utils.HSMSlotFlag.Name), c.GlobalString(utils.HSMPINFileFlag.Name))
+		if err != nil {
+			utils.Fatalf("Failed to start HSM hub: %v", err)
+		}
+		backends = append(backends, hub)
+	}
+	am := core.StartClefAccountManager(ksLoc, nousb, lightKdf, scpath, backends...)
+	defer am.Close()
 	apiImpl := core.NewSignerAPI(am, chainId, nousb, ui, db, advanced, pwStorage)
 
 	// Establish the bidirectional communication, by creating a new UI backend and registering
 	// it with the UI.
 	ui.RegisterUIServer(core.NewUIServerAPI(apiImpl))
 	api = apiImpl
+
+	// Coordinate multisig proposals with the local accounts and remote cosigners
+	cosigners, err := makeSafeCosigners(c.GlobalString(safeCosignersFlag.Name))
+	if err != nil {
+		utils.Fatalf("Invalid Safe cosigners: %v", err)
+	}
+	multisigAPI := core.NewMultisigAPI(ui, msStorage, apiImpl, cosigners)
+	var safeAPI interface{} = multisigAPI
+
 	// Audit logging
 	if logfile := c.GlobalString(auditLogFlag.Name); logfile != "" {
-		api, err = core.NewAuditLogger(logfile, api)
+		auditLogger, err := core.NewAuditLogger(logfile, api)
 		if err != nil {
 			utils.Fatalf(err.Error())
 		}
+		if evaluator != nil {
+			evaluator.SetAuditor(auditLogger)
+		}
+		api = auditLogger
+		safeAPI = auditLogger.Multisig(multisigAPI)
 		log.Info("Audit logs configured", "file", logfile)
 	}
 	// register signer API with server
@@ -651,13 +825,18 @@ func signer(c *cli.Context) error {
 			Public:    true,
 			Service:   api,
 			Version:   "1.0"},
+		{
+			Namespace: "safe",
+			Public:    true,
+			Service:   safeAPI,
+			Version:   "1.0"},
 	}
 	if c.GlobalBool(utils.HTTPEnabledFlag.Name) {
 		vhosts := utils.SplitAndTrim(c.GlobalString(utils.HTTPVirtualHostsFlag.Name))
 		cors := utils.SplitAndTrim(c.GlobalString(utils.HTTPCORSDomainFlag.Name))
 
 		srv := rpc.NewServer()
-		err := node.RegisterApisFromWhitelist(rpcAPI, []string{"account"}, srv, false)
+		err := node.RegisterApisFromWhitelist(rpcAPI, []string{"account", "safe"}, srv, false)
 		if err != nil {
 			utils.Fatalf("Could not register API: %w", err)
 		}
@@ -0,0 +1,87 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var commandRekey = cli.Command{
+	Name:      "rekey",
+	Usage:     "re-encrypt keyfiles with a new key-derivation function",
+	ArgsUsage: "<keyfile> [<keyfile> ...]",
+	Description: `
+Re-encrypt the given keyfiles with a new key-derivation function (argon2id by
+default), e.g. to upgrade them to stronger settings.
+
+The keyfiles keep their current password, unless a new one is given with the
+--newpasswordfile flag. Each re-encrypted keyfile is verified to decrypt to the
+same key before it atomically replaces the original one.`,
+	Flags: []cli.Flag{
+		passphraseFlag,
+		newPassphraseFlag,
+		utils.KDFFlag,
+		utils.LightKDFFlag,
+		jsonFlag,
+	},
+	Action: func(ctx *cli.Context) error {
+		if ctx.NArg() == 0 {
+			utils.Fatalf("No keyfiles specified to rekey")
+		}
+		kdf, err := keystore.NewKDF(ctx.String(utils.KDFFlag.Name), ctx.Bool(utils.LightKDFFlag.Name))
+		if err != nil {
+			utils.Fatalf("%v", err)
+		}
+		passphrase := getPassphrase(ctx, false)
+
+		newPhrase := passphrase
+		if passFile := ctx.String(newPassphraseFlag.Name); passFile != "" {
+			content, err := ioutil.ReadFile(passFile)
+			if err != nil {
+				utils.Fatalf("Failed to read new password file '%s': %v", passFile, err)
+			}
+			newPhrase = strings.TrimRight(string(content), "\r\n")
+		}
+		type outputRekey struct {
+			Keyfile string
+			Address string
+			KDF     string
+		}
+		var out []outputRekey
+		for _, keyfilepath := range ctx.Args() {
+			addr, err := keystore.RekeyKeyFile(keyfilepath, passphrase, newPhrase, kdf)
+			if err != nil {
+				utils.Fatalf("Failed to rekey '%s': %v", keyfilepath, err)
+			}
+			out = append(out, outputRekey{Keyfile: keyfilepath, Address: addr.Hex(), KDF: kdf.Name})
+		}
+		if ctx.Bool(jsonFlag.Name) {
+			mustPrintJSON(out)
+		} else {
+			for _, rekeyed := range out {
+				fmt.Printf("Rekeyed %s (%s) with %s\n", rekeyed.Keyfile, rekeyed.Address, rekeyed.KDF)
+			}
+		}
+		return nil
+	},
+}
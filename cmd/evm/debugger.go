@@ -0,0 +1,167 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/eth/tracers/debugger"
+)
+
+// debuggerUsage lists the commands handled by the interactive prompt on top
+// of the ones understood by the debugger itself.
+const debuggerUsage = `  stack                    show the full stack of the current instruction
+  memory                   show the memory of the current instruction
+  help, h                  show this help
+  quit, q                  abort the execution and exit
+An empty line repeats the last command.`
+
+// runDebugger executes the given function with the debugger attached, reading
+// commands from in and printing the machine state to out until the execution
+// finishes or is aborted.
+func runDebugger(dbg *debugger.Debugger, execFunc func() ([]byte, uint64, error), in io.Reader, out io.Writer) ([]byte, uint64, error) {
+	var (
+		output  []byte
+		gasLeft uint64
+		err     error
+	)
+	step, _ := dbg.Start(func() error {
+		output, gasLeft, err = execFunc()
+		return err
+	})
+	fmt.Fprintln(out, "Type 'help' for the list of commands.")
+	if step != nil {
+		printStep(out, step)
+	}
+	var (
+		scanner = bufio.NewScanner(in)
+		last    string
+	)
+	for !dbg.Finished() {
+		fmt.Fprint(out, "evm> ")
+		if !scanner.Scan() {
+			dbg.Stop()
+			break
+		}
+		command := strings.TrimSpace(scanner.Text())
+		if command == "" {
+			command = last
+		}
+		if command == "" {
+			continue
+		}
+		last = command
+
+		switch command {
+		case "help", "h":
+			fmt.Fprintln(out, debugger.Usage)
+			fmt.Fprintln(out, debuggerUsage)
+			continue
+		case "quit", "q":
+			dbg.Stop()
+			continue
+		case "stack":
+			if step := dbg.Current(); step != nil {
+				printStack(out, step, len(step.Stack))
+			}
+			continue
+		case "memory":
+			if step := dbg.Current(); step != nil {
+				printMemory(out, step.Memory)
+			}
+			continue
+		}
+		res, err := dbg.Execute(command)
+		if err != nil {
+			fmt.Fprintf(out, "error: %v\n", err)
+			continue
+		}
+		printResult(out, res)
+	}
+	if result := dbg.Result(); result != nil {
+		printResult(out, result)
+	}
+	return output, gasLeft, err
+}
+
+// printResult prints the outcome of a debugger command.
+func printResult(out io.Writer, res interface{}) {
+	switch res := res.(type) {
+	case *debugger.Step:
+		printStep(out, res)
+	case *debugger.Result:
+		if res == nil {
+			return
+		}
+		fmt.Fprintf(out, "finished: gas used %d, output 0x%x\n", res.GasUsed, []byte(res.Output))
+		if res.Error != "" {
+			fmt.Fprintf(out, "  error: %v\n", res.Error)
+		}
+	case *debugger.Breakpoint:
+		fmt.Fprintf(out, "breakpoint %d: %v\n", res.ID, res)
+	case []*debugger.Breakpoint:
+		if len(res) == 0 {
+			fmt.Fprintln(out, "no breakpoints")
+		}
+		for _, bp := range res {
+			fmt.Fprintf(out, "breakpoint %d: %v\n", bp.ID, bp)
+		}
+	case common.Hash:
+		fmt.Fprintln(out, res.Hex())
+	case *big.Int:
+		fmt.Fprintln(out, res)
+	}
+}
+
+// printStep prints the instruction the execution is paused at along with the
+// top of the stack.
+func printStep(out io.Writer, step *debugger.Step) {
+	fmt.Fprintf(out, "[%d] %s pc=%d %s gas=%d cost=%d (%s)\n", step.Depth, step.Address.Hex(), step.PC, step.Op, step.Gas, step.Cost, step.Reason)
+	if step.Error != "" {
+		fmt.Fprintf(out, "  error: %v\n", step.Error)
+	}
+	printStack(out, step, 4)
+}
+
+// printStack prints the top items of the stack, top first.
+func printStack(out io.Writer, step *debugger.Step, items int) {
+	if items > len(step.Stack) {
+		items = len(step.Stack)
+	}
+	for i := 0; i < items; i++ {
+		fmt.Fprintf(out, "  %02d: %v\n", i, step.Stack[len(step.Stack)-1-i])
+	}
+	if rest := len(step.Stack) - items; rest > 0 {
+		fmt.Fprintf(out, "  ... %d more\n", rest)
+	}
+}
+
+// printMemory prints the memory in 32 byte rows.
+func printMemory(out io.Writer, memory []byte) {
+	for i := 0; i < len(memory); i += 32 {
+		end := i + 32
+		if end > len(memory) {
+			end = len(memory)
+		}
+		fmt.Fprintf(out, "  %04x: %x\n", i, memory[i:end])
+	}
+}
@@ -50,6 +50,6 @@ func disasmCmd(ctx *cli.Context) error {
 	}
 
 	code := strings.TrimSpace(in)
-	fmt.Printf("%v\n", code)
+	fmt.Printf(";; %v\n", code)
 	return asm.PrintDisassembled(code)
 }
@@ -19,12 +19,21 @@ package compiler
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"path/filepath"
 
 	"github.com/ethereum/go-ethereum/core/asm"
 )
 
 func Compile(fn string, src []byte, debug bool) (string, error) {
 	compiler := asm.NewCompiler(debug)
+	compiler.SetIncludeLoader(func(name string) ([]byte, error) {
+		// Includes are relative to the compiled file
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(filepath.Dir(fn), name)
+		}
+		return ioutil.ReadFile(name)
+	})
 	compiler.Feed(asm.Lex(src, debug))
 
 	bin, compileErrors := compiler.Compile()
@@ -0,0 +1,249 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package t8nfuzz
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"math/rand"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	maxSenders   = 3  // Maximum number of externally owned accounts sending txs
+	maxContracts = 4  // Maximum number of contracts in the pre-state
+	maxTxs       = 3  // Maximum number of transactions in a block
+	maxProgram   = 96 // Maximum size of a generated program in bytes
+)
+
+// Env is the block environment of a case, in the t8n input format.
+type Env struct {
+	Coinbase    common.UnprefixedAddress            `json:"currentCoinbase"`
+	Difficulty  *math.HexOrDecimal256               `json:"currentDifficulty"`
+	GasLimit    math.HexOrDecimal64                 `json:"currentGasLimit"`
+	Number      math.HexOrDecimal64                 `json:"currentNumber"`
+	Timestamp   math.HexOrDecimal64                 `json:"currentTimestamp"`
+	BlockHashes map[math.HexOrDecimal64]common.Hash `json:"blockHashes,omitempty"`
+}
+
+// Tx is an unsigned legacy transaction of a case, in the t8n input format.
+// The tool signs it with the secret key.
+type Tx struct {
+	Nonce     hexutil.Uint64  `json:"nonce"`
+	GasPrice  *hexutil.Big    `json:"gasPrice"`
+	Gas       hexutil.Uint64  `json:"gas"`
+	To        *common.Address `json:"to"`
+	Value     *hexutil.Big    `json:"value"`
+	Input     hexutil.Bytes   `json:"input"`
+	V         *hexutil.Big    `json:"v"`
+	R         *hexutil.Big    `json:"r"`
+	S         *hexutil.Big    `json:"s"`
+	SecretKey common.Hash     `json:"secretKey"`
+}
+
+// Case is a state transition to run through the tools.
+type Case struct {
+	Alloc core.GenesisAlloc `json:"alloc"`
+	Env   *Env              `json:"env"`
+	Txs   []*Tx             `json:"txs"`
+}
+
+// Write saves the case into the given directory as the alloc.json, env.json
+// and txs.json inputs of t8n.
+func (c *Case) Write(dir string) error {
+	files := map[string]interface{}{
+		"alloc.json": c.Alloc,
+		"env.json":   c.Env,
+		"txs.json":   c.Txs,
+	}
+	for name, obj := range files {
+		blob, err := json.MarshalIndent(obj, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), blob, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copy returns a deep copy of the case, which minimization can modify.
+func (c *Case) copy() *Case {
+	blob, _ := json.Marshal(c)
+	cpy := new(Case)
+	json.Unmarshal(blob, cpy)
+
+	// The transaction list is nil if empty, keep it an array for the tools
+	if cpy.Txs == nil {
+		cpy.Txs = []*Tx{}
+	}
+	return cpy
+}
+
+// renumber assigns the nonces of the transactions in order of appearance,
+// keeping them valid once some were removed.
+func (c *Case) renumber() {
+	nonces := make(map[common.Hash]uint64)
+	for _, tx := range c.Txs {
+		tx.Nonce = hexutil.Uint64(nonces[tx.SecretKey])
+		nonces[tx.SecretKey]++
+	}
+}
+
+// senderKey derives the key of the i-th sender account.
+func senderKey(i int) *ecdsa.PrivateKey {
+	key, _ := crypto.ToECDSA(crypto.Keccak256([]byte("t8nfuzz"), []byte{byte(i)}))
+	return key
+}
+
+// generator creates random cases.
+type generator struct {
+	rand *rand.Rand
+}
+
+// generate creates a random case: a pre-state of senders and contracts running
+// random programs, a block environment and transactions calling the contracts
+// or creating new ones.
+func (g *generator) generate() *Case {
+	c := &Case{
+		Alloc: make(core.GenesisAlloc),
+		Env: &Env{
+			Difficulty: (*math.HexOrDecimal256)(big.NewInt(0x20000)),
+			GasLimit:   math.HexOrDecimal64(30000000),
+			Number:     math.HexOrDecimal64(1 + g.rand.Intn(300)),
+			Timestamp:  math.HexOrDecimal64(1000 + g.rand.Intn(1000)),
+		},
+		Txs: []*Tx{},
+	}
+	g.rand.Read(c.Env.Coinbase[:])
+
+	// Supply the hashes of all blocks accessible via BLOCKHASH
+	c.Env.BlockHashes = make(map[math.HexOrDecimal64]common.Hash)
+	for n := int(c.Env.Number) - 1; n >= 0 && n >= int(c.Env.Number)-256; n-- {
+		c.Env.BlockHashes[math.HexOrDecimal64(n)] = crypto.Keccak256Hash(big.NewInt(int64(n)).Bytes())
+	}
+	// Create the senders and the contracts they interact with
+	var keys []*ecdsa.PrivateKey
+	for i := 0; i < 1+g.rand.Intn(maxSenders); i++ {
+		key := senderKey(i)
+		keys = append(keys, key)
+		c.Alloc[crypto.PubkeyToAddress(key.PublicKey)] = core.GenesisAccount{
+			Balance: new(big.Int).Exp(big.NewInt(10), big.NewInt(20), nil),
+		}
+	}
+	var contracts []common.Address
+	for i := 0; i < 1+g.rand.Intn(maxContracts); i++ {
+		contracts = append(contracts, common.BigToAddress(big.NewInt(int64(0xc0de00+i))))
+	}
+	for _, addr := range contracts {
+		account := core.GenesisAccount{
+			Code:    g.program(contracts),
+			Balance: big.NewInt(g.rand.Int63n(1000)),
+			Storage: make(map[common.Hash]common.Hash),
+		}
+		for i := g.rand.Intn(4); i > 0; i-- {
+			account.Storage[common.BigToHash(big.NewInt(g.rand.Int63n(8)))] = common.BigToHash(big.NewInt(1 + g.rand.Int63n(1000)))
+		}
+		c.Alloc[addr] = account
+	}
+	// Create the transactions, calling contracts or deploying programs
+	for i := 0; i < 1+g.rand.Intn(maxTxs); i++ {
+		tx := &Tx{
+			GasPrice:  (*hexutil.Big)(big.NewInt(1 + g.rand.Int63n(10))),
+			Gas:       hexutil.Uint64(21000 + g.rand.Intn(300000)),
+			Value:     (*hexutil.Big)(big.NewInt(g.rand.Int63n(1000))),
+			V:         new(hexutil.Big),
+			R:         new(hexutil.Big),
+			S:         new(hexutil.Big),
+			SecretKey: common.BytesToHash(crypto.FromECDSA(keys[g.rand.Intn(len(keys))])),
+		}
+		if g.rand.Intn(4) == 0 {
+			tx.Input = g.program(contracts)
+		} else {
+			to := contracts[g.rand.Intn(len(contracts))]
+			tx.To = &to
+			tx.Input = make([]byte, g.rand.Intn(68))
+			g.rand.Read(tx.Input)
+		}
+		c.Txs = append(c.Txs, tx)
+	}
+	c.renumber()
+	return c
+}
+
+// program creates random code. Besides arbitrary instructions it favours
+// pushes of small values, which keep the stack filled with sensible offsets,
+// and calls to the other contracts.
+func (g *generator) program(contracts []common.Address) []byte {
+	var (
+		code []byte
+		size = 1 + g.rand.Intn(maxProgram)
+	)
+	for len(code) < size {
+		switch r := g.rand.Intn(16); {
+		case r < 5:
+			code = append(code, byte(vm.PUSH1), byte(g.rand.Intn(64)))
+		case r < 6:
+			value := make([]byte, 1+g.rand.Intn(32))
+			g.rand.Read(value)
+			code = append(append(code, byte(vm.PUSH1)+byte(len(value)-1)), value...)
+		case r < 7:
+			code = append(code, g.call(contracts[g.rand.Intn(len(contracts))])...)
+		default:
+			code = append(code, g.instruction())
+		}
+	}
+	return code
+}
+
+// instruction returns a random defined instruction other than a push.
+func (g *generator) instruction() byte {
+	for {
+		op := vm.OpCode(g.rand.Intn(256))
+		if !op.IsPush() && !strings.Contains(op.String(), "not defined") {
+			return byte(op)
+		}
+	}
+}
+
+// call returns the code calling the given contract with one of the call
+// instructions, random arguments and all the available gas.
+func (g *generator) call(addr common.Address) []byte {
+	ops := []vm.OpCode{vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL}
+	op := ops[g.rand.Intn(len(ops))]
+
+	var code []byte
+	for i := 0; i < 4; i++ { // return and argument ranges
+		code = append(code, byte(vm.PUSH1), byte(g.rand.Intn(64)))
+	}
+	if op == vm.CALL || op == vm.CALLCODE {
+		code = append(code, byte(vm.PUSH1), byte(g.rand.Intn(2)))
+	}
+	code = append(append(code, byte(vm.PUSH20)), addr.Bytes()...)
+	return append(code, byte(vm.GAS), byte(op))
+}
@@ -0,0 +1,297 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package t8nfuzz
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+)
+
+// traceFields are the fields of the struct logs compared between the tools,
+// omitting those implementations are known to format differently, such as
+// the error messages.
+var traceFields = []string{"pc", "op", "gas", "gasCost", "depth", "stack", "output", "gasUsed"}
+
+// receipt is the part of a t8n receipt compared between the tools.
+type receipt struct {
+	TxHash  common.Hash    `json:"transactionHash"`
+	Status  hexutil.Uint64 `json:"status"`
+	GasUsed hexutil.Uint64 `json:"gasUsed"`
+}
+
+// result is the part of the t8n result compared between the tools.
+type result struct {
+	StateRoot   common.Hash       `json:"stateRoot"`
+	ReceiptRoot common.Hash       `json:"receiptRoot"`
+	LogsHash    common.Hash       `json:"logsHash"`
+	Receipts    []receipt         `json:"receipts"`
+	Rejected    []json.RawMessage `json:"rejected"`
+}
+
+// outcome is the output of a tool running a case.
+type outcome struct {
+	code   int    // Exit code of the tool
+	stderr string // Error output of the tool
+
+	result *result
+	alloc  core.GenesisAlloc
+	traces map[int][]map[string]interface{} // Normalized struct logs by transaction index
+}
+
+// loadOutcome reads the output files a tool wrote into a directory.
+func loadOutcome(dir string, code int, stderr string) (*outcome, error) {
+	out := &outcome{code: code, stderr: stderr, traces: make(map[int][]map[string]interface{})}
+	if code != 0 {
+		return out, nil
+	}
+	blob, err := ioutil.ReadFile(filepath.Join(dir, "result.json"))
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(blob, &out.result); err != nil {
+		return nil, fmt.Errorf("invalid result: %v", err)
+	}
+	if blob, err = ioutil.ReadFile(filepath.Join(dir, "alloc.json")); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(blob, &out.alloc); err != nil {
+		return nil, fmt.Errorf("invalid alloc: %v", err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "trace-*.jsonl"))
+	for _, file := range files {
+		index, err := strconv.Atoi(strings.Split(filepath.Base(file), "-")[1])
+		if err != nil {
+			continue
+		}
+		if out.traces[index], err = loadTrace(file); err != nil {
+			return nil, fmt.Errorf("invalid trace %s: %v", filepath.Base(file), err)
+		}
+	}
+	return out, nil
+}
+
+// loadTrace reads the struct logs of a trace file, keeping the compared fields.
+func loadTrace(file string) ([]map[string]interface{}, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var (
+		steps   []map[string]interface{}
+		scanner = bufio.NewScanner(f)
+	)
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		var fields map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &fields); err != nil {
+			return nil, err
+		}
+		step := make(map[string]interface{})
+		for _, name := range traceFields {
+			if value, ok := fields[name]; ok {
+				step[name] = normalize(value)
+			}
+		}
+		steps = append(steps, step)
+	}
+	return steps, scanner.Err()
+}
+
+// normalize converts hex and decimal quantities into a canonical decimal form,
+// so the formatting choices of the tools don't cause differences.
+func normalize(value interface{}) interface{} {
+	switch value := value.(type) {
+	case string:
+		if n, ok := new(big.Int).SetString(strings.TrimPrefix(value, "0x"), 16); ok && strings.HasPrefix(value, "0x") {
+			return n.String()
+		}
+		return value
+	case float64:
+		return new(big.Float).SetFloat64(value).Text('f', 0)
+	case []interface{}:
+		for i := range value {
+			value[i] = normalize(value[i])
+		}
+		return value
+	default:
+		return value
+	}
+}
+
+// Divergence is a case the tools disagree on.
+type Divergence struct {
+	Case    *Case
+	Reasons []string // Differences found in the outputs
+	Step    string   // Struct logs around the first diverging step, if any
+}
+
+// String implements fmt.Stringer, formatting the divergence as a report.
+func (d *Divergence) String() string {
+	report := "Divergence:\n"
+	for _, reason := range d.Reasons {
+		report += "  " + reason + "\n"
+	}
+	if d.Step != "" {
+		report += d.Step
+	}
+	return report
+}
+
+// compare returns the differences between the local and reference outcome,
+// or nil if they agree.
+func compare(c *Case, local, ref *outcome) *Divergence {
+	d := &Divergence{Case: c}
+	if local.code != ref.code {
+		d.Reasons = append(d.Reasons, fmt.Sprintf("exit code: local %d, reference %d: %s", local.code, ref.code, strings.TrimSpace(local.stderr+" "+ref.stderr)))
+		return d
+	}
+	if local.code != 0 {
+		return nil // Both failed the same way
+	}
+	// Compare the block results and the receipts of each transaction
+	lr, rr := local.result, ref.result
+	if lr.StateRoot != rr.StateRoot {
+		d.Reasons = append(d.Reasons, fmt.Sprintf("stateRoot: local %x, reference %x", lr.StateRoot, rr.StateRoot))
+	}
+	if lr.ReceiptRoot != rr.ReceiptRoot {
+		d.Reasons = append(d.Reasons, fmt.Sprintf("receiptRoot: local %x, reference %x", lr.ReceiptRoot, rr.ReceiptRoot))
+	}
+	if lr.LogsHash != rr.LogsHash {
+		d.Reasons = append(d.Reasons, fmt.Sprintf("logsHash: local %x, reference %x", lr.LogsHash, rr.LogsHash))
+	}
+	if len(lr.Rejected) != len(rr.Rejected) {
+		d.Reasons = append(d.Reasons, fmt.Sprintf("rejected txs: local %d, reference %d", len(lr.Rejected), len(rr.Rejected)))
+	}
+	for i := 0; i < len(lr.Receipts) && i < len(rr.Receipts); i++ {
+		if l, r := lr.Receipts[i], rr.Receipts[i]; l != r {
+			d.Reasons = append(d.Reasons, fmt.Sprintf("receipt %d: local status %d gas %d, reference status %d gas %d", i, l.Status, l.GasUsed, r.Status, r.GasUsed))
+		}
+	}
+	d.Reasons = append(d.Reasons, compareAlloc(local.alloc, ref.alloc)...)
+
+	// Locate the first diverging struct log
+	var indices []int
+	for index := range local.traces {
+		indices = append(indices, index)
+	}
+	for index := range ref.traces {
+		if _, ok := local.traces[index]; !ok {
+			indices = append(indices, index)
+		}
+	}
+	sort.Ints(indices)
+	for _, index := range indices {
+		if step := compareTrace(index, local.traces[index], ref.traces[index]); step != "" {
+			d.Step = step
+			if len(d.Reasons) == 0 {
+				d.Reasons = append(d.Reasons, fmt.Sprintf("trace of tx %d", index))
+			}
+			break
+		}
+	}
+	if len(d.Reasons) == 0 {
+		return nil
+	}
+	return d
+}
+
+// compareAlloc returns the differences between two post-states.
+func compareAlloc(local, ref core.GenesisAlloc) []string {
+	addrs := make(map[common.Address]struct{})
+	for addr := range local {
+		addrs[addr] = struct{}{}
+	}
+	for addr := range ref {
+		addrs[addr] = struct{}{}
+	}
+	var diffs []string
+	for addr := range addrs {
+		l, lok := local[addr]
+		r, rok := ref[addr]
+		switch {
+		case !lok || !rok:
+			diffs = append(diffs, fmt.Sprintf("account %x: local exists %v, reference exists %v", addr, lok, rok))
+		case l.Balance.Cmp(r.Balance) != 0:
+			diffs = append(diffs, fmt.Sprintf("account %x: local balance %v, reference %v", addr, l.Balance, r.Balance))
+		case l.Nonce != r.Nonce:
+			diffs = append(diffs, fmt.Sprintf("account %x: local nonce %d, reference %d", addr, l.Nonce, r.Nonce))
+		case string(l.Code) != string(r.Code):
+			diffs = append(diffs, fmt.Sprintf("account %x: local code %x, reference %x", addr, l.Code, r.Code))
+		case !equalStorage(l.Storage, r.Storage):
+			diffs = append(diffs, fmt.Sprintf("account %x: local storage %v, reference %v", addr, l.Storage, r.Storage))
+		}
+	}
+	sort.Strings(diffs)
+	return diffs
+}
+
+// equalStorage returns whether two storages are equal, treating missing and
+// zero slots alike.
+func equalStorage(a, b map[common.Hash]common.Hash) bool {
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	for k, v := range b {
+		if a[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// compareTrace returns the struct logs around the first step two traces
+// differ at, or an empty string if they agree.
+func compareTrace(index int, local, ref []map[string]interface{}) string {
+	for i := 0; i < len(local) || i < len(ref); i++ {
+		var l, r map[string]interface{}
+		if i < len(local) {
+			l = local[i]
+		}
+		if i < len(ref) {
+			r = ref[i]
+		}
+		if reflect.DeepEqual(l, r) {
+			continue
+		}
+		report := fmt.Sprintf("First diverging step of tx %d at step %d:\n", index, i)
+		if i > 0 && i <= len(local) && i <= len(ref) {
+			prev, _ := json.Marshal(local[i-1])
+			report += fmt.Sprintf("  previous:  %s\n", prev)
+		}
+		lblob, _ := json.Marshal(l)
+		rblob, _ := json.Marshal(r)
+		return report + fmt.Sprintf("  local:     %s\n  reference: %s\n", lblob, rblob)
+	}
+	return ""
+}
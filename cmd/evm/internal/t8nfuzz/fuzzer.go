@@ -0,0 +1,193 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// Package t8nfuzz implements a differential fuzzer of state transition tools.
+//
+// Random pre-states, programs and transactions are run through the local t8n
+// tool and a reference implementation of the same command line interface.
+// Cases the tools disagree on are minimized before being reported along with
+// the first struct log the executions diverge at.
+package t8nfuzz
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// maxMinimizeRuns caps the number of cases run while minimizing a divergence.
+const maxMinimizeRuns = 1000
+
+// Fuzzer runs random cases through two state transition tools.
+type Fuzzer struct {
+	Local     Tool
+	Reference Tool
+	Fork      string // Fork rules to run the cases with
+	Dir       string // Directory to place the tool inputs and outputs in
+
+	runs int // Number of cases run
+}
+
+// Run generates and runs cases from the seed until the tools diverge or the
+// iterations are exhausted. A divergence is minimized before being returned.
+func (f *Fuzzer) Run(seed int64, iterations int) (*Divergence, error) {
+	gen := &generator{rand: rand.New(rand.NewSource(seed))}
+	for i := 0; i < iterations; i++ {
+		c := gen.generate()
+		d, err := f.check(c)
+		if err != nil {
+			return nil, err
+		}
+		if d != nil {
+			log.Info("Found divergence, minimizing", "iteration", i, "txs", len(c.Txs), "accounts", len(c.Alloc))
+			return f.minimize(d)
+		}
+	}
+	return nil, nil
+}
+
+// check runs a case through both tools, returning their differences.
+func (f *Fuzzer) check(c *Case) (*Divergence, error) {
+	f.runs++
+
+	dir, err := ioutil.TempDir(f.Dir, "case")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	if err := c.Write(dir); err != nil {
+		return nil, err
+	}
+	local, err := f.run(f.Local, dir, "local")
+	if err != nil {
+		return nil, fmt.Errorf("local tool: %v", err)
+	}
+	ref, err := f.run(f.Reference, dir, "reference")
+	if err != nil {
+		return nil, fmt.Errorf("reference tool: %v", err)
+	}
+	return compare(c, local, ref), nil
+}
+
+// run runs a tool on the case in the directory, collecting its outputs.
+func (f *Fuzzer) run(tool Tool, dir, name string) (*outcome, error) {
+	out := filepath.Join(dir, name)
+	args := []string{
+		"--input.alloc=" + filepath.Join(dir, "alloc.json"),
+		"--input.env=" + filepath.Join(dir, "env.json"),
+		"--input.txs=" + filepath.Join(dir, "txs.json"),
+		"--output.basedir=" + out,
+		"--output.result=result.json",
+		"--output.alloc=alloc.json",
+		"--trace",
+		"--trace.nomemory",
+		"--trace.noreturndata",
+		"--state.fork=" + f.Fork,
+	}
+	code, stderr := tool.Transition(args)
+	return loadOutcome(out, code, stderr)
+}
+
+// minimize reduces a diverging case, greedily applying simplifications as long
+// as the tools keep diverging.
+func (f *Fuzzer) minimize(d *Divergence) (*Divergence, error) {
+	start := f.runs
+	for reduced := true; reduced && f.runs-start < maxMinimizeRuns; {
+		reduced = false
+		for _, candidate := range reductions(d.Case) {
+			if f.runs-start >= maxMinimizeRuns {
+				break
+			}
+			smaller, err := f.check(candidate)
+			if err != nil {
+				return nil, err
+			}
+			if smaller != nil {
+				d, reduced = smaller, true
+				break
+			}
+		}
+	}
+	return d, nil
+}
+
+// reductions returns the simplifications of a case, most effective first:
+// dropping transactions, accounts and storage slots, then shrinking the code
+// and inputs.
+func reductions(c *Case) []*Case {
+	var cases []*Case
+	for i := range c.Txs {
+		r := c.copy()
+		r.Txs = append(r.Txs[:i], r.Txs[i+1:]...)
+		r.renumber()
+		cases = append(cases, r)
+	}
+	senders := make(map[common.Address]bool)
+	for addr, account := range c.Alloc {
+		if len(account.Code) == 0 && len(account.Storage) == 0 {
+			senders[addr] = true
+		}
+	}
+	for addr, account := range c.Alloc {
+		if !senders[addr] {
+			r := c.copy()
+			delete(r.Alloc, addr)
+			cases = append(cases, r)
+		}
+		for slot := range account.Storage {
+			r := c.copy()
+			delete(r.Alloc[addr].Storage, slot)
+			cases = append(cases, r)
+		}
+		for _, code := range shrink(account.Code) {
+			r := c.copy()
+			account := r.Alloc[addr]
+			account.Code = code
+			r.Alloc[addr] = account
+			cases = append(cases, r)
+		}
+	}
+	for i, tx := range c.Txs {
+		for _, input := range shrink(tx.Input) {
+			r := c.copy()
+			r.Txs[i].Input = input
+			cases = append(cases, r)
+		}
+	}
+	return cases
+}
+
+// shrink returns smaller variants of code or data: its halves, then the data
+// with a single byte removed at a time.
+func shrink(data []byte) [][]byte {
+	if len(data) == 0 {
+		return nil
+	}
+	variants := [][]byte{nil}
+	if len(data) > 1 {
+		variants = append(variants, common.CopyBytes(data[:len(data)/2]), common.CopyBytes(data[len(data)/2:]))
+	}
+	for i := range data {
+		variants = append(variants, append(common.CopyBytes(data[:i]), data[i+1:]...))
+	}
+	return variants
+}
@@ -0,0 +1,114 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package t8nfuzz
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// countingHandler is a log handler counting the records it's given.
+type countingHandler struct{ records int }
+
+func (h *countingHandler) Log(r *log.Record) error {
+	h.records++
+	return nil
+}
+
+// Tests that the local tool agrees with a second instance of itself, standing
+// in for a second build.
+func TestFuzzerAgreement(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "t8nfuzz")
+	defer os.RemoveAll(dir)
+
+	f := &Fuzzer{Local: new(Local), Reference: new(Local), Fork: "Berlin", Dir: dir}
+	d, err := f.Run(1, 20)
+	if err != nil {
+		t.Fatalf("fuzzer failed: %v", err)
+	}
+	if d != nil {
+		t.Fatalf("unexpected divergence: %v", d)
+	}
+	if f.runs != 20 {
+		t.Errorf("run count mismatch: have %d, want 20", f.runs)
+	}
+}
+
+// Tests that running the local tool leaves the logging of the process alone.
+func TestLocalKeepsLogger(t *testing.T) {
+	handler := new(countingHandler)
+	defer log.Root().SetHandler(log.Root().GetHandler())
+	log.Root().SetHandler(handler)
+
+	dir, _ := ioutil.TempDir("", "t8nfuzz")
+	defer os.RemoveAll(dir)
+
+	f := &Fuzzer{Local: new(Local), Reference: new(Local), Fork: "Berlin", Dir: dir}
+	if _, err := f.Run(1, 2); err != nil {
+		t.Fatalf("fuzzer failed: %v", err)
+	}
+	if log.Root().GetHandler() != handler {
+		t.Fatal("local tool replaced the root log handler")
+	}
+	log.Info("Still logging")
+	if handler.records == 0 {
+		t.Error("root logger muted by local tool")
+	}
+}
+
+// Tests that divergences are found and minimized, using a reference running
+// different fork rules as a stand-in for a faulty implementation.
+func TestFuzzerDivergence(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "t8nfuzz")
+	defer os.RemoveAll(dir)
+
+	f := &Fuzzer{
+		Local:     new(Local),
+		Reference: &Local{Args: []string{"--state.fork=Istanbul"}},
+		Fork:      "Berlin",
+		Dir:       dir,
+	}
+	d, err := f.Run(1, 20)
+	if err != nil {
+		t.Fatalf("fuzzer failed: %v", err)
+	}
+	if d == nil {
+		t.Fatal("divergence not found")
+	}
+	if len(d.Case.Txs) != 1 {
+		t.Errorf("case not minimized: %d txs", len(d.Case.Txs))
+	}
+	for addr, account := range d.Case.Alloc {
+		if len(account.Code) > maxProgram/2 {
+			t.Errorf("code of %x not minimized: %x", addr, account.Code)
+		}
+	}
+	if !strings.Contains(d.String(), "First diverging step of tx 0") {
+		t.Errorf("missing struct log diff in report:\n%v", d)
+	}
+	// The minimized case must still diverge when written out and run again
+	if err := d.Case.Write(dir); err != nil {
+		t.Fatalf("failed to write case: %v", err)
+	}
+	if again, err := f.check(d.Case); err != nil || again == nil {
+		t.Errorf("minimized case doesn't diverge: %v", err)
+	}
+}
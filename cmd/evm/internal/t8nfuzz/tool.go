@@ -0,0 +1,105 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package t8nfuzz
+
+import (
+	"bytes"
+	"flag"
+	"os/exec"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/cmd/evm/internal/t8ntool"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// Tool is a state transition tool implementing the t8n command line interface.
+type Tool interface {
+	// Transition runs the tool with the given t8n arguments, returning its
+	// exit code and error output.
+	Transition(args []string) (int, string)
+}
+
+// Binary is an external t8n compatible tool, such as another build of evm or
+// the transition tool of a different client.
+type Binary struct {
+	Command []string // Command running the tool, e.g. "evm t8n"
+}
+
+// NewBinary creates a tool running the given command, split on whitespace.
+func NewBinary(command string) *Binary {
+	return &Binary{Command: strings.Fields(command)}
+}
+
+// Transition implements Tool, executing the command.
+func (b *Binary) Transition(args []string) (int, string) {
+	var stderr bytes.Buffer
+
+	cmd := exec.Command(b.Command[0], append(b.Command[1:], args...)...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if exit, ok := err.(*exec.ExitError); ok {
+			return exit.ExitCode(), stderr.String()
+		}
+		return -1, err.Error()
+	}
+	return 0, stderr.String()
+}
+
+// Local is the t8n tool of this binary, run in-process.
+type Local struct {
+	Args []string // Extra arguments overriding those given to Transition
+}
+
+// localFlags are the flags of the t8n command.
+var localFlags = []cli.Flag{
+	t8ntool.TraceFlag,
+	t8ntool.TraceDisableMemoryFlag,
+	t8ntool.TraceDisableStackFlag,
+	t8ntool.TraceDisableReturnDataFlag,
+	t8ntool.OutputBasedir,
+	t8ntool.OutputAllocFlag,
+	t8ntool.OutputResultFlag,
+	t8ntool.OutputBodyFlag,
+	t8ntool.InputAllocFlag,
+	t8ntool.InputEnvFlag,
+	t8ntool.InputTxsFlag,
+	t8ntool.ForknameFlag,
+	t8ntool.ChainIDFlag,
+	t8ntool.RewardFlag,
+	t8ntool.VerbosityFlag,
+}
+
+// Transition implements Tool, running the t8n command with the arguments
+// parsed as on the command line. The exit code mirrors the one of evm.
+func (l *Local) Transition(args []string) (int, string) {
+	set := flag.NewFlagSet("t8n", flag.ContinueOnError)
+	for _, f := range localFlags {
+		f.Apply(set)
+	}
+	if err := set.Parse(append(args, l.Args...)); err != nil {
+		return 1, err.Error()
+	}
+	// Run the transition without the logger setup of the command, which would
+	// reconfigure the logging of the whole process
+	if err := t8ntool.Transition(cli.NewContext(cli.NewApp(), set, nil)); err != nil {
+		if numbered, ok := err.(*t8ntool.NumberedError); ok {
+			return numbered.Code(), err.Error()
+		}
+		return 1, err.Error()
+	}
+	return 0, ""
+}
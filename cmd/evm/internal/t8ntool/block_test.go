@@ -0,0 +1,160 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package t8ntool
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/clique"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Tests that a block assembled from a state transition and sealed with ethash
+// is accepted on top of the genesis of its prestate.
+func TestBuildBlockEthash(t *testing.T) {
+	var (
+		key, _   = crypto.HexToECDSA("45a915e4d060149eb4365960e6a7a45f334393093061116b197e3240065ff2d8")
+		sender   = crypto.PubkeyToAddress(key.PublicKey)
+		coinbase = common.HexToAddress("0xc0ffee")
+		config   = params.AllEthashProtocolChanges
+		alloc    = core.GenesisAlloc{sender: {Balance: big.NewInt(params.Ether)}}
+		db       = rawdb.NewMemoryDatabase()
+		genesis  = (&core.Genesis{Config: config, Alloc: alloc, GasLimit: 8000000, Difficulty: params.MinimumDifficulty}).MustCommit(db)
+	)
+	// Run a value transfer through the state transition
+	signer := types.MakeSigner(config, big.NewInt(1))
+	tx, _ := types.SignTx(types.NewTransaction(0, common.Address{0xaa}, big.NewInt(1), params.TxGas, big.NewInt(1), nil), signer, key)
+
+	prestate := &Prestate{
+		Env: stEnv{
+			Coinbase:   coinbase,
+			Difficulty: ethash.CalcDifficulty(config, genesis.Time()+13, genesis.Header()),
+			GasLimit:   genesis.GasLimit(),
+			Number:     1,
+			Timestamp:  genesis.Time() + 13,
+		},
+		Pre: alloc,
+	}
+	noTracer := func(int, common.Hash) (vm.Tracer, error) { return nil, nil }
+	_, result, err := prestate.Apply(vm.Config{}, config, types.Transactions{tx}, ethash.ConstantinopleBlockReward.Int64(), noTracer)
+	if err != nil {
+		t.Fatalf("failed to apply transactions: %v", err)
+	}
+	// Build the block from the header fields and the t8n result, passed through
+	// their json formats like the command line tool does
+	input := &bbInput{
+		Header: &header{
+			ParentHash: genesis.Hash(),
+			Coinbase:   &coinbase,
+			Difficulty: prestate.Env.Difficulty,
+			Number:     big.NewInt(1),
+			GasLimit:   prestate.Env.GasLimit,
+			Time:       prestate.Env.Timestamp,
+		},
+		Txs:     types.Transactions{tx},
+		Ethash:  true,
+		PowMode: ethash.ModeTest,
+	}
+	blob, _ := json.Marshal(input.Header)
+	if err := json.Unmarshal(blob, input.Header); err != nil {
+		t.Fatalf("failed to unmarshal header: %v", err)
+	}
+	blob, _ = json.Marshal(result)
+	if err := json.Unmarshal(blob, &input.Result); err != nil {
+		t.Fatalf("failed to unmarshal result: %v", err)
+	}
+	block, err := input.ToBlock()
+	if err != nil {
+		t.Fatalf("failed to build block: %v", err)
+	}
+	if block, err = input.SealBlock(block); err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	// Import the rlp encoded block, verifying the seal and all the roots
+	enc, _ := rlp.EncodeToBytes(block)
+	var decoded types.Block
+	if err := rlp.DecodeBytes(enc, &decoded); err != nil {
+		t.Fatalf("failed to decode block: %v", err)
+	}
+	chain, err := core.NewBlockChain(db, nil, config, ethash.NewTester(nil, false), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(types.Blocks{&decoded}); err != nil {
+		t.Fatalf("failed to import block: %v", err)
+	}
+	if head := chain.CurrentBlock().Hash(); head != block.Hash() {
+		t.Errorf("head mismatch: have %x, want %x", head, block.Hash())
+	}
+}
+
+// Tests that clique sealing signs the block and casts the requested vote.
+func TestBuildBlockClique(t *testing.T) {
+	key, _ := crypto.HexToECDSA("45a915e4d060149eb4365960e6a7a45f334393093061116b197e3240065ff2d8")
+	voted, authorize := common.HexToAddress("0xc0ffee"), true
+
+	input := &bbInput{
+		Header: &header{
+			Root:       &types.EmptyRootHash,
+			Difficulty: big.NewInt(2),
+			Number:     big.NewInt(1),
+			GasLimit:   8000000,
+			Time:       15,
+		},
+		Clique: &cliqueInput{Key: key, Voted: &voted, Authorize: &authorize, Vanity: common.Hash{0x01}},
+	}
+	block, err := input.ToBlock()
+	if err != nil {
+		t.Fatalf("failed to build block: %v", err)
+	}
+	if block, err = input.SealBlock(block); err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	signer, err := clique.New(params.AllCliqueProtocolChanges.Clique, nil).Author(block.Header())
+	if err != nil {
+		t.Fatalf("failed to recover signer: %v", err)
+	}
+	if want := crypto.PubkeyToAddress(key.PublicKey); signer != want {
+		t.Errorf("signer mismatch: have %x, want %x", signer, want)
+	}
+	if block.Coinbase() != voted {
+		t.Errorf("vote mismatch: have %x, want %x", block.Coinbase(), voted)
+	}
+	if block.Nonce() != ^uint64(0) {
+		t.Errorf("authorization mismatch: have nonce %x", block.Nonce())
+	}
+	if block.Extra()[0] != 0x01 {
+		t.Errorf("vanity missing: %x", block.Extra())
+	}
+	// Voting must not silently clobber given header fields
+	input.Header.Coinbase = &voted
+	if _, err := input.SealBlock(block); err == nil {
+		t.Errorf("clobbering coinbase accepted")
+	}
+}
@@ -41,11 +41,12 @@ import (
 
 const (
 	ErrorEVM              = 2
-	ErrorVMConfig         = 3
+	ErrorConfig           = 3
 	ErrorMissingBlockhash = 4
 
 	ErrorJson = 10
 	ErrorIO   = 11
+	ErrorRlp  = 12
 
 	stdinSelector = "stdin"
 )
@@ -79,22 +80,21 @@ func Main(ctx *cli.Context) error {
 	glogger.Verbosity(log.Lvl(ctx.Int(VerbosityFlag.Name)))
 	log.Root().SetHandler(glogger)
 
+	return Transition(ctx)
+}
+
+// Transition runs the state transition as Main does, without touching the
+// configuration of the logger. It's meant for running the transition in-process.
+func Transition(ctx *cli.Context) error {
 	var (
-		err     error
-		tracer  vm.Tracer
-		baseDir = ""
+		err    error
+		tracer vm.Tracer
 	)
 	var getTracer func(txIndex int, txHash common.Hash) (vm.Tracer, error)
 
-	// If user specified a basedir, make sure it exists
-	if ctx.IsSet(OutputBasedir.Name) {
-		if base := ctx.String(OutputBasedir.Name); len(base) > 0 {
-			err := os.MkdirAll(base, 0755) // //rw-r--r--
-			if err != nil {
-				return NewError(ErrorIO, fmt.Errorf("failed creating output basedir: %v", err))
-			}
-			baseDir = base
-		}
+	baseDir, err := createBasedir(ctx)
+	if err != nil {
+		return NewError(ErrorIO, fmt.Errorf("failed creating output basedir: %v", err))
 	}
 	if ctx.Bool(TraceFlag.Name) {
 		// Configure the EVM logger
@@ -180,7 +180,7 @@ func Main(ctx *cli.Context) error {
 	// Construct the chainconfig
 	var chainConfig *params.ChainConfig
 	if cConf, extraEips, err := tests.GetChainConfig(ctx.String(ForknameFlag.Name)); err != nil {
-		return NewError(ErrorVMConfig, fmt.Errorf("Failed constructing chain configuration: %v", err))
+		return NewError(ErrorConfig, fmt.Errorf("Failed constructing chain configuration: %v", err))
 	} else {
 		chainConfig = cConf
 		vmConfig.ExtraEips = extraEips
@@ -188,6 +188,20 @@ func Main(ctx *cli.Context) error {
 	// Set the chain id
 	chainConfig.ChainID = big.NewInt(ctx.Int64(ChainIDFlag.Name))
 
+	// Set the custom precompiles, if any
+	if path := ctx.String(InputPrecompilesFlag.Name); path != "" {
+		var precompiles map[common.Address]*params.PrecompileConfig
+		if err := readFile(path, "precompiles", &precompiles); err != nil {
+			return err
+		}
+		config := *chainConfig
+		config.Precompiles = precompiles
+		if err := vm.CheckPrecompiles(&config); err != nil {
+			return NewError(ErrorConfig, fmt.Errorf("Failed constructing chain configuration: %v", err))
+		}
+		chainConfig = &config
+	}
+
 	var txsWithKeys []*txWithKey
 	if txStr != stdinSelector {
 		inFile, err := os.Open(txStr)
@@ -224,6 +238,21 @@ func Main(ctx *cli.Context) error {
 
 }
 
+// createBasedir makes sure the output basedir exists, if the user specified one.
+func createBasedir(ctx *cli.Context) (string, error) {
+	baseDir := ""
+	if ctx.IsSet(OutputBasedir.Name) {
+		if base := ctx.String(OutputBasedir.Name); len(base) > 0 {
+			err := os.MkdirAll(base, 0755) // //rw-r--r--
+			if err != nil {
+				return "", err
+			}
+			baseDir = base
+		}
+	}
+	return baseDir, nil
+}
+
 // txWithKey is a helper-struct, to allow us to use the types.Transaction along with
 // a `secretKey`-field, for input
 type txWithKey struct {
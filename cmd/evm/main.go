@@ -134,6 +134,22 @@ var (
 		Usage: "External EVM configuration (default = built-in interpreter)",
 		Value: "",
 	}
+	BlockAnalysisFlag = cli.BoolFlag{
+		Name:  "vm.blocks",
+		Usage: "execute code by basic blocks with fused instructions (ignored when tracing)",
+	}
+	DebuggerFlag = cli.BoolFlag{
+		Name:  "debugger",
+		Usage: "step through the execution interactively",
+	}
+	ArtifactsFlag = cli.StringFlag{
+		Name:  "solc.artifacts",
+		Usage: "solc --combined-json output to annotate the trace with source locations",
+	}
+	GasProfileFlag = cli.StringFlag{
+		Name:  "gasprofile",
+		Usage: "profile the gas usage, writing the collapsed stacks for flame graphs to the given file",
+	}
 )
 
 var stateTransitionCommand = cli.Command{
@@ -153,6 +169,7 @@ var stateTransitionCommand = cli.Command{
 		t8ntool.InputAllocFlag,
 		t8ntool.InputEnvFlag,
 		t8ntool.InputTxsFlag,
+		t8ntool.InputPrecompilesFlag,
 		t8ntool.ForknameFlag,
 		t8ntool.ChainIDFlag,
 		t8ntool.RewardFlag,
@@ -160,6 +177,26 @@ var stateTransitionCommand = cli.Command{
 	},
 }
 
+var blockBuilderCommand = cli.Command{
+	Name:    "block-builder",
+	Aliases: []string{"b11r"},
+	Usage:   "builds a block",
+	Action:  t8ntool.BuildBlock,
+	Flags: []cli.Flag{
+		t8ntool.OutputBasedir,
+		t8ntool.OutputBlockFlag,
+		t8ntool.InputHeaderFlag,
+		t8ntool.InputOmmersFlag,
+		t8ntool.InputTxsRlpFlag,
+		t8ntool.InputResultFlag,
+		t8ntool.SealCliqueFlag,
+		t8ntool.SealEthashFlag,
+		t8ntool.SealEthashDirFlag,
+		t8ntool.SealEthashModeFlag,
+		t8ntool.VerbosityFlag,
+	},
+}
+
 func init() {
 	app.Flags = []cli.Flag{
 		BenchFlag,
@@ -186,6 +223,10 @@ func init() {
 		DisableStorageFlag,
 		DisableReturnDataFlag,
 		EVMInterpreterFlag,
+		BlockAnalysisFlag,
+		DebuggerFlag,
+		ArtifactsFlag,
+		GasProfileFlag,
 	}
 	app.Commands = []cli.Command{
 		compileCommand,
@@ -193,6 +234,8 @@ func init() {
 		runCommand,
 		stateTestCommand,
 		stateTransitionCommand,
+		blockBuilderCommand,
+		fuzzCommand,
 	}
 	cli.CommandHelpTemplate = flags.OriginCommandHelpTemplate
 }
@@ -23,6 +23,7 @@ import (
 	"io/ioutil"
 	"math/big"
 	"os"
+	"path/filepath"
 	goruntime "runtime"
 	"runtime/pprof"
 	"testing"
@@ -36,6 +37,9 @@ import (
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/core/vm/runtime"
+	"github.com/ethereum/go-ethereum/eth/tracers/debugger"
+	"github.com/ethereum/go-ethereum/eth/tracers/gasprofile"
+	"github.com/ethereum/go-ethereum/eth/tracers/sourcemap"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
 	"gopkg.in/urfave/cli.v1"
@@ -70,6 +74,34 @@ func readGenesis(genesisPath string) *core.Genesis {
 	return genesis
 }
 
+// readArtifacts reads the solc combined JSON output at the given path, along
+// with the source files listed in it. Sources are looked up relative to the
+// working directory first, and the directory of the artifacts second.
+func readArtifacts(path string) (*sourcemap.Config, error) {
+	combined, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var output struct {
+		SourceList []string `json:"sourceList"`
+	}
+	if err := json.Unmarshal(combined, &output); err != nil {
+		return nil, err
+	}
+	config := &sourcemap.Config{CombinedJSON: combined, Sources: make(map[string]string)}
+	for _, file := range output.SourceList {
+		source, err := ioutil.ReadFile(file)
+		if err != nil {
+			if source, err = ioutil.ReadFile(filepath.Join(filepath.Dir(path), file)); err != nil {
+				log.Warn("Source file unavailable", "file", file)
+				continue
+			}
+		}
+		config.Sources[file] = string(source)
+	}
+	return config, nil
+}
+
 type execStats struct {
 	time           time.Duration // The execution time.
 	allocs         int64         // The number of heap allocations during execution.
@@ -117,6 +149,9 @@ func runCmd(ctx *cli.Context) error {
 
 	var (
 		tracer        vm.Tracer
+		dbg           *debugger.Debugger
+		sourceTracer  *sourcemap.Tracer
+		profiler      *gasprofile.Profiler
 		debugLogger   *vm.StructLogger
 		statedb       *state.StateDB
 		chainConfig   *params.ChainConfig
@@ -124,7 +159,31 @@ func runCmd(ctx *cli.Context) error {
 		receiver      = common.BytesToAddress([]byte("receiver"))
 		genesisConfig *core.Genesis
 	)
-	if ctx.GlobalBool(MachineFlag.Name) {
+	if ctx.GlobalBool(DebuggerFlag.Name) {
+		if ctx.GlobalBool(BenchFlag.Name) || ctx.GlobalString(CodeFileFlag.Name) == "-" {
+			utils.Fatalf("--%s cannot be combined with --%s or code from stdin", DebuggerFlag.Name, BenchFlag.Name)
+		}
+		dbg = debugger.New()
+		tracer = dbg
+	} else if path := ctx.GlobalString(ArtifactsFlag.Name); path != "" {
+		if ctx.GlobalBool(BenchFlag.Name) {
+			utils.Fatalf("--%s cannot be combined with --%s", ArtifactsFlag.Name, BenchFlag.Name)
+		}
+		config, err := readArtifacts(path)
+		if err != nil {
+			utils.Fatalf("Failed to read compiler artifacts: %v", err)
+		}
+		if sourceTracer, err = sourcemap.New(config); err != nil {
+			utils.Fatalf("Failed to load compiler artifacts: %v", err)
+		}
+		tracer = sourceTracer
+	} else if ctx.GlobalString(GasProfileFlag.Name) != "" {
+		if ctx.GlobalBool(BenchFlag.Name) {
+			utils.Fatalf("--%s cannot be combined with --%s", GasProfileFlag.Name, BenchFlag.Name)
+		}
+		profiler = gasprofile.New(nil)
+		tracer = profiler
+	} else if ctx.GlobalBool(MachineFlag.Name) {
 		tracer = vm.NewJSONLogger(logconfig, os.Stdout)
 	} else if ctx.GlobalBool(DebugFlag.Name) {
 		debugLogger = vm.NewStructLogger(logconfig)
@@ -139,6 +198,11 @@ func runCmd(ctx *cli.Context) error {
 		genesis := gen.ToBlock(db)
 		statedb, _ = state.New(genesis.Root(), state.NewDatabase(db), nil)
 		chainConfig = gen.Config
+		if chainConfig != nil {
+			if err := vm.CheckPrecompiles(chainConfig); err != nil {
+				utils.Fatalf("Invalid genesis config: %v", err)
+			}
+		}
 	} else {
 		statedb, _ = state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
 		genesisConfig = new(core.Genesis)
@@ -212,8 +276,9 @@ func runCmd(ctx *cli.Context) error {
 		BlockNumber: new(big.Int).SetUint64(genesisConfig.Number),
 		EVMConfig: vm.Config{
 			Tracer:         tracer,
-			Debug:          ctx.GlobalBool(DebugFlag.Name) || ctx.GlobalBool(MachineFlag.Name),
+			Debug:          ctx.GlobalBool(DebugFlag.Name) || ctx.GlobalBool(MachineFlag.Name) || tracer != nil,
 			EVMInterpreter: ctx.GlobalString(EVMInterpreterFlag.Name),
+			BlockAnalysis:  ctx.GlobalBool(BlockAnalysisFlag.Name),
 		},
 	}
 
@@ -264,8 +329,18 @@ func runCmd(ctx *cli.Context) error {
 		}
 	}
 
-	bench := ctx.GlobalBool(BenchFlag.Name)
-	output, leftOverGas, stats, err := timedExec(bench, execFunc)
+	var (
+		bench       = ctx.GlobalBool(BenchFlag.Name)
+		output      []byte
+		leftOverGas uint64
+		stats       execStats
+		err         error
+	)
+	if dbg != nil {
+		output, leftOverGas, err = runDebugger(dbg, execFunc, os.Stdin, os.Stdout)
+	} else {
+		output, leftOverGas, stats, err = timedExec(bench, execFunc)
+	}
 
 	if ctx.GlobalBool(DumpFlag.Name) {
 		statedb.Commit(true)
@@ -302,6 +377,22 @@ allocations:     %d
 allocated bytes: %d
 `, initialGas-leftOverGas, stats.time, stats.allocs, stats.bytesAllocated)
 	}
+	if sourceTracer != nil {
+		out, _ := json.MarshalIndent(sourceTracer.Result(), "", "  ")
+		fmt.Println(string(out))
+	}
+	if profiler != nil {
+		result := profiler.Result()
+		var collapsed bytes.Buffer
+		for _, line := range result.Flamegraph {
+			fmt.Fprintln(&collapsed, line)
+		}
+		if err := ioutil.WriteFile(ctx.GlobalString(GasProfileFlag.Name), collapsed.Bytes(), 0644); err != nil {
+			utils.Fatalf("Failed to write gas profile: %v", err)
+		}
+		out, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(out))
+	}
 	if tracer == nil {
 		fmt.Printf("0x%x\n", output)
 		if err != nil {
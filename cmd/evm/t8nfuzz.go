@@ -0,0 +1,99 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/cmd/evm/internal/t8nfuzz"
+	"github.com/ethereum/go-ethereum/cmd/evm/internal/t8ntool"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	FuzzSeedFlag = cli.Int64Flag{
+		Name:  "seed",
+		Usage: "seed of the generated cases, defaults to the current time",
+	}
+	FuzzIterationsFlag = cli.IntFlag{
+		Name:  "iterations",
+		Usage: "number of cases to run",
+		Value: 1000,
+	}
+	FuzzOutputFlag = cli.StringFlag{
+		Name:  "output.dir",
+		Usage: "directory to write the minimized diverging case to",
+		Value: "divergence",
+	}
+)
+
+var fuzzCommand = cli.Command{
+	Action:    fuzzCmd,
+	Name:      "t8nfuzz",
+	Usage:     "differentially fuzzes the state transition against another t8n tool",
+	ArgsUsage: "<t8n command, e.g. \"/path/to/evm t8n\">",
+	Flags: []cli.Flag{
+		FuzzSeedFlag,
+		FuzzIterationsFlag,
+		FuzzOutputFlag,
+		t8ntool.ForknameFlag,
+	},
+}
+
+func fuzzCmd(ctx *cli.Context) error {
+	if len(ctx.Args().First()) == 0 {
+		return errors.New("reference t8n command required")
+	}
+	seed := ctx.Int64(FuzzSeedFlag.Name)
+	if !ctx.IsSet(FuzzSeedFlag.Name) {
+		seed = time.Now().UnixNano()
+	}
+	dir, err := ioutil.TempDir("", "t8nfuzz")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	fuzzer := &t8nfuzz.Fuzzer{
+		Local:     new(t8nfuzz.Local),
+		Reference: t8nfuzz.NewBinary(ctx.Args().First()),
+		Fork:      ctx.String(t8ntool.ForknameFlag.Name),
+		Dir:       dir,
+	}
+	fmt.Printf("Fuzzing %d cases from seed %d\n", ctx.Int(FuzzIterationsFlag.Name), seed)
+	divergence, err := fuzzer.Run(seed, ctx.Int(FuzzIterationsFlag.Name))
+	if err != nil {
+		return err
+	}
+	if divergence == nil {
+		fmt.Println("No divergence found")
+		return nil
+	}
+	out := ctx.String(FuzzOutputFlag.Name)
+	if err := os.MkdirAll(out, 0755); err != nil {
+		return err
+	}
+	if err := divergence.Case.Write(out); err != nil {
+		return err
+	}
+	fmt.Print(divergence)
+	return fmt.Errorf("diverging case written to %s", out)
+}
@@ -19,6 +19,7 @@ package main
 import (
 	"fmt"
 	"io/ioutil"
+	"strings"
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
@@ -29,6 +30,11 @@ import (
 )
 
 var (
+	newPasswordFileFlag = cli.StringFlag{
+		Name:  "newpassword",
+		Usage: "Password file to re-encrypt the accounts with (default = keep current passwords)",
+	}
+
 	walletCommand = cli.Command{
 		Name:      "wallet",
 		Usage:     "Manage Ethereum presale wallets",
@@ -152,6 +158,36 @@ For non-interactive use the password can be specified with the --password flag:
 
 Since only one password can be given, only format update can be performed,
 changing your password is only possible interactively.
+`,
+			},
+			{
+				Name:      "rekey",
+				Usage:     "Re-encrypt existing accounts with a new key-derivation function",
+				Action:    utils.MigrateFlags(accountRekey),
+				ArgsUsage: "[<address> ...]",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.KeyStoreDirFlag,
+					utils.PasswordFileFlag,
+					utils.KDFFlag,
+					utils.LightKDFFlag,
+					newPasswordFileFlag,
+				},
+				Description: `
+    geth account rekey [<address> ...]
+
+Re-encrypt the given accounts, or all accounts in the keystore if none are given,
+with a new key-derivation function (argon2id by default), e.g. to upgrade them
+to stronger settings.
+
+The accounts keep their current password, unless a new one is given via the
+--newpassword flag. Each re-encrypted key file is verified to decrypt to the
+same key before it atomically replaces the original one.
+
+For non-interactive use the passwords can be specified with the --password and
+--newpassword flags, containing one password per line for each account:
+
+    geth account rekey [options] [<address> ...]
 `,
 			},
 			{
@@ -310,6 +346,51 @@ func accountUpdate(ctx *cli.Context) error {
 	return nil
 }
 
+// accountRekey re-encrypts accounts with a new key-derivation function and
+// optionally a new password.
+func accountRekey(ctx *cli.Context) error {
+	kdf, err := keystore.NewKDF(ctx.String(utils.KDFFlag.Name), ctx.Bool(utils.LightKDFFlag.Name))
+	if err != nil {
+		utils.Fatalf("%v", err)
+	}
+	stack, _ := makeConfigNode(ctx)
+	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+
+	addrs := ctx.Args()
+	if len(addrs) == 0 {
+		for _, account := range ks.Accounts() {
+			addrs = append(addrs, account.Address.Hex())
+		}
+	}
+	if len(addrs) == 0 {
+		utils.Fatalf("No accounts to rekey")
+	}
+	var (
+		passwords    = utils.MakePasswordList(ctx)
+		newPasswords []string
+	)
+	if path := ctx.String(newPasswordFileFlag.Name); path != "" {
+		text, err := ioutil.ReadFile(path)
+		if err != nil {
+			utils.Fatalf("Failed to read new password file: %v", err)
+		}
+		newPasswords = strings.Split(strings.Replace(string(text), "\r", "", -1), "\n")
+	}
+	for i, addr := range addrs {
+		account, password := unlockAccount(ks, addr, i, passwords)
+
+		newPassword := password
+		if newPasswords != nil {
+			newPassword = utils.GetPassPhraseWithList("", false, i, newPasswords)
+		}
+		if err := ks.Rekey(account, password, newPassword, kdf); err != nil {
+			utils.Fatalf("Could not rekey the account: %v", err)
+		}
+		fmt.Printf("Rekeyed account %s with %s\n", account.Address.Hex(), kdf.Name)
+	}
+	return nil
+}
+
 func importWallet(ctx *cli.Context) error {
 	keyfile := ctx.Args().First()
 	if len(keyfile) == 0 {
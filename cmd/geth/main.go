@@ -69,6 +69,9 @@ var (
 		utils.NoUSBFlag,
 		utils.USBFlag,
 		utils.SmartCardDaemonPathFlag,
+		utils.HSMModuleFlag,
+		utils.HSMSlotFlag,
+		utils.HSMPINFileFlag,
 		utils.OverrideBerlinFlag,
 		utils.EthashCacheDirFlag,
 		utils.EthashCachesInMemoryFlag,
@@ -216,6 +219,8 @@ func init() {
 		removedbCommand,
 		dumpCommand,
 		dumpGenesisCommand,
+		// See replaycmd.go:
+		replayCommand,
 		// See accountcmd.go:
 		accountCommand,
 		walletCommand,
@@ -0,0 +1,195 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/tracers/replay"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	replayReferenceFlag = cli.StringFlag{
+		Name:  "replay.reference",
+		Usage: "Reference to compare against: a replay output or debug_traceBlock result file, or the endpoint of a node to call debug_traceBlock on",
+	}
+	replayOutputFlag = cli.StringFlag{
+		Name:  "replay.output",
+		Usage: "File to write the outcome of the local execution to, usable as a reference elsewhere",
+	}
+	replayTraceFlag = cli.BoolFlag{
+		Name:  "replay.trace",
+		Usage: "Include the struct log traces of all transactions in the output",
+	}
+	replayCommand = cli.Command{
+		Action:    utils.MigrateFlags(replayBlock),
+		Name:      "replay",
+		Usage:     "Re-execute a block and locate where it diverges from a reference",
+		ArgsUsage: "<blockHash | blockFile>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.CacheFlag,
+			utils.SyncModeFlag,
+			utils.RopstenFlag,
+			utils.RinkebyFlag,
+			utils.GoerliFlag,
+			replayReferenceFlag,
+			replayOutputFlag,
+			replayTraceFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The replay command re-executes a block on top of the state of its parent, which
+needs to be available in the database. The block is either given by hash, looked
+up among the bad blocks and the blocks of the chain, or as an RLP encoded file.
+
+The state root and receipt after every transaction are compared against the
+reference, bisecting to the first diverging transaction. If the reference has
+traces of it, the transaction is traced to locate the first diverging opcode.
+Without a reference, the outcome is checked against the block header.`,
+	}
+)
+
+// replayBlock re-executes a block, reporting its divergence from a reference.
+func replayBlock(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chain, db := utils.MakeChain(ctx, stack, true)
+	defer db.Close()
+	defer chain.Stop()
+
+	block, err := loadReplayBlock(db, ctx.Args().First())
+	if err != nil {
+		utils.Fatalf("Failed to load block: %v", err)
+	}
+	ref := replay.FromHeader(block)
+	if path := ctx.String(replayReferenceFlag.Name); path != "" {
+		if ref, err = loadReplayReference(path, block); err != nil {
+			utils.Fatalf("Failed to load reference: %v", err)
+		}
+		if ref.Block != (common.Hash{}) && ref.Block != block.Hash() {
+			log.Warn("Reference is of a different block", "block", block.Hash(), "reference", ref.Block)
+		}
+	}
+	log.Info("Replaying block", "number", block.Number(), "hash", block.Hash(), "txs", len(block.Transactions()))
+	local, divergence, err := replay.Replay(chain, block, ref)
+	if err != nil {
+		utils.Fatalf("Failed to replay block: %v", err)
+	}
+	if path := ctx.String(replayOutputFlag.Name); path != "" {
+		if ctx.Bool(replayTraceFlag.Name) {
+			statedb, err := chain.StateAt(chain.GetHeaderByHash(block.ParentHash()).Root)
+			if err != nil {
+				utils.Fatalf("Failed to retrieve parent state: %v", err)
+			}
+			local = replay.Execute(chain, block, statedb, func(int) bool { return true })
+		}
+		out, err := json.MarshalIndent(local, "", "  ")
+		if err != nil {
+			utils.Fatalf("Failed to encode outcome: %v", err)
+		}
+		if err := ioutil.WriteFile(path, out, 0644); err != nil {
+			utils.Fatalf("Failed to write outcome: %v", err)
+		}
+		log.Info("Wrote replay outcome", "file", path)
+	}
+	if divergence == nil {
+		log.Info("Block matches the reference", "number", block.Number(), "hash", block.Hash())
+		return nil
+	}
+	fmt.Print(divergence)
+	return fmt.Errorf("block %d [%x] diverges from the reference", block.Number(), block.Hash())
+}
+
+// loadReplayBlock retrieves the block to replay: given by hash, it's looked up
+// among the bad blocks and the blocks of the chain, otherwise it's read from
+// a binary or hex encoded RLP file.
+func loadReplayBlock(db ethdb.Database, arg string) (*types.Block, error) {
+	if blob, err := hexutil.Decode(arg); err == nil && len(blob) == common.HashLength {
+		hash := common.BytesToHash(blob)
+		if block := rawdb.ReadBadBlock(db, hash); block != nil {
+			return block, nil
+		}
+		if number := rawdb.ReadHeaderNumber(db, hash); number != nil {
+			if block := rawdb.ReadBlock(db, hash, *number); block != nil {
+				return block, nil
+			}
+		}
+		return nil, fmt.Errorf("block %x not found", hash)
+	}
+	blob, err := ioutil.ReadFile(arg)
+	if err != nil {
+		return nil, err
+	}
+	if text := strings.TrimSpace(string(blob)); strings.HasPrefix(text, "0x") {
+		if blob, err = hexutil.Decode(text); err != nil {
+			return nil, err
+		}
+	}
+	block := new(types.Block)
+	if err := rlp.DecodeBytes(blob, block); err != nil {
+		return nil, fmt.Errorf("invalid block RLP: %v", err)
+	}
+	return block, nil
+}
+
+// loadReplayReference reads the reference outcome of the block from a file, or
+// retrieves it by calling debug_traceBlock on the given endpoint.
+func loadReplayReference(path string, block *types.Block) (*replay.Result, error) {
+	endpoint := strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") ||
+		strings.HasPrefix(path, "ws://") || strings.HasPrefix(path, "wss://") || strings.HasSuffix(path, ".ipc")
+	if !endpoint {
+		blob, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return replay.LoadReference(blob)
+	}
+	client, err := rpc.Dial(path)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	enc, err := rlp.EncodeToBytes(block)
+	if err != nil {
+		return nil, err
+	}
+	var traces json.RawMessage
+	config := map[string]interface{}{"disableMemory": true, "disableStorage": true}
+	if err := client.Call(&traces, "debug_traceBlock", hexutil.Bytes(enc), config); err != nil {
+		return nil, err
+	}
+	return replay.LoadReference(traces)
+}
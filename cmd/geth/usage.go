@@ -127,6 +127,9 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.PasswordFileFlag,
 			utils.ExternalSignerFlag,
 			utils.InsecureUnlockAllowedFlag,
+			utils.HSMModuleFlag,
+			utils.HSMSlotFlag,
+			utils.HSMPINFileFlag,
 		},
 	},
 	{
@@ -30,7 +30,9 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ibft"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
 )
@@ -59,6 +61,7 @@ func (w *wizard) makeGenesis() {
 	fmt.Println("Which consensus engine to use? (default = clique)")
 	fmt.Println(" 1. Ethash - proof-of-work")
 	fmt.Println(" 2. Clique - proof-of-authority")
+	fmt.Println(" 3. IBFT   - byzantine fault tolerant proof-of-authority")
 
 	choice := w.read()
 	switch {
@@ -105,6 +108,39 @@ func (w *wizard) makeGenesis() {
 			copy(genesis.ExtraData[32+i*common.AddressLength:], signer[:])
 		}
 
+	case choice == "3":
+		// In the case of IBFT, configure the consensus parameters
+		genesis.Difficulty = big.NewInt(1)
+		genesis.Mixhash = types.BFTDigest
+		genesis.Config.IBFT = &params.IBFTConfig{
+			Period:         5,
+			Epoch:          30000,
+			RequestTimeout: 10000,
+		}
+		fmt.Println()
+		fmt.Println("How many seconds should blocks take at least? (default = 5)")
+		genesis.Config.IBFT.Period = uint64(w.readDefaultInt(5))
+
+		// We also need the initial list of validators, identified by their node keys
+		fmt.Println()
+		fmt.Println("Which node key addresses are allowed to validate? (mandatory at least one)")
+
+		var validators []common.Address
+		for {
+			if address := w.readAddress(); address != nil {
+				validators = append(validators, *address)
+				continue
+			}
+			if len(validators) > 0 {
+				break
+			}
+		}
+		extra, err := ibft.GenesisExtra(nil, validators)
+		if err != nil {
+			log.Crit("Failed to assemble validator extra-data", "err", err)
+		}
+		genesis.ExtraData = extra
+
 	default:
 		log.Crit("Invalid consensus engine choice", "choice", choice)
 	}
@@ -103,8 +103,9 @@ func (w *wizard) deployNode(boot bool) {
 	}
 	// If the node is a miner/signer, load up needed credentials
 	if !boot {
-		if w.conf.Genesis.Config.Ethash != nil {
-			// Ethash based miners only need an etherbase to mine against
+		if w.conf.Genesis.Config.Ethash != nil || w.conf.Genesis.Config.IBFT != nil {
+			// Ethash based miners and IBFT validators (signing with the node key)
+			// only need an etherbase to mine against
 			fmt.Println()
 			if infos.etherbase == "" {
 				fmt.Printf("What address should the miner use?\n")
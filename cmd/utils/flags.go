@@ -135,6 +135,18 @@ var (
 		Usage: "Path to the smartcard daemon (pcscd) socket file",
 		Value: pcsclite.PCSCDSockName,
 	}
+	HSMModuleFlag = cli.StringFlag{
+		Name:  "hsm.module",
+		Usage: "Path to the PKCS#11 module of a hardware security module holding account keys",
+	}
+	HSMSlotFlag = cli.UintFlag{
+		Name:  "hsm.slot",
+		Usage: "Slot of the token within the PKCS#11 module",
+	}
+	HSMPINFileFlag = cli.StringFlag{
+		Name:  "hsm.pinfile",
+		Usage: "File containing the user PIN to open the HSM token with at startup",
+	}
 	NetworkIdFlag = cli.Uint64Flag{
 		Name:  "networkid",
 		Usage: "Explicitly set network id (integer)(For testnets: use --ropsten, --rinkeby, --goerli instead)",
@@ -221,6 +233,11 @@ var (
 		Name:  "lightkdf",
 		Usage: "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
 	}
+	KDFFlag = cli.StringFlag{
+		Name:  "kdf",
+		Usage: "Key-derivation function to re-encrypt keys with (scrypt, argon2id)",
+		Value: "argon2id",
+	}
 	WhitelistFlag = cli.StringFlag{
 		Name:  "whitelist",
 		Usage: "Comma separated block number-to-hash mappings to enforce (<number>=<hash>)",
@@ -1198,6 +1215,7 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	setNodeUserIdent(ctx, cfg)
 	setDataDir(ctx, cfg)
 	setSmartCard(ctx, cfg)
+	setHSM(ctx, cfg)
 
 	if ctx.GlobalIsSet(ExternalSignerFlag.Name) {
 		cfg.ExternalSigner = ctx.GlobalString(ExternalSignerFlag.Name)
@@ -1240,6 +1258,18 @@ func setSmartCard(ctx *cli.Context, cfg *node.Config) {
 	cfg.SmartCardDaemonPath = path
 }
 
+func setHSM(ctx *cli.Context, cfg *node.Config) {
+	if ctx.GlobalIsSet(HSMModuleFlag.Name) {
+		cfg.HSMModule = ctx.GlobalString(HSMModuleFlag.Name)
+	}
+	if ctx.GlobalIsSet(HSMSlotFlag.Name) {
+		cfg.HSMSlot = ctx.GlobalUint(HSMSlotFlag.Name)
+	}
+	if ctx.GlobalIsSet(HSMPINFileFlag.Name) {
+		cfg.HSMPINFile = ctx.GlobalString(HSMPINFileFlag.Name)
+	}
+}
+
 func setDataDir(ctx *cli.Context, cfg *node.Config) {
 	switch {
 	case ctx.GlobalIsSet(DataDirFlag.Name):
@@ -46,6 +46,7 @@ type ContractInfo struct {
 	CompilerOptions string      `json:"compilerOptions"`
 	SrcMap          interface{} `json:"srcMap"`
 	SrcMapRuntime   string      `json:"srcMapRuntime"`
+	SourceList      []string    `json:"sourceList,omitempty"`
 	AbiDefinition   interface{} `json:"abiDefinition"`
 	UserDoc         interface{} `json:"userDoc"`
 	DeveloperDoc    interface{} `json:"developerDoc"`
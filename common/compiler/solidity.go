@@ -41,7 +41,8 @@ type solcOutput struct {
 		Bin, SrcMap, Abi, Devdoc, Userdoc, Metadata string
 		Hashes                                      map[string]string
 	}
-	Version string
+	SourceList []string `json:"sourceList"`
+	Version    string
 }
 
 // solidity v.0.8 changes the way ABI, Devdoc and Userdoc are serialized
@@ -55,7 +56,8 @@ type solcOutputV8 struct {
 		Userdoc               interface{}
 		Hashes                map[string]string
 	}
-	Version string
+	SourceList []string `json:"sourceList"`
+	Version    string
 }
 
 func (s *Solidity) makeArgs() []string {
@@ -181,6 +183,7 @@ func ParseCombinedJSON(combinedJSON []byte, source string, languageVersion strin
 				CompilerOptions: compilerOptions,
 				SrcMap:          info.SrcMap,
 				SrcMapRuntime:   info.SrcMapRuntime,
+				SourceList:      output.SourceList,
 				AbiDefinition:   abi,
 				UserDoc:         userdoc,
 				DeveloperDoc:    devdoc,
@@ -213,6 +216,7 @@ func parseCombinedJSONV8(combinedJSON []byte, source string, languageVersion str
 				CompilerOptions: compilerOptions,
 				SrcMap:          info.SrcMap,
 				SrcMapRuntime:   info.SrcMapRuntime,
+				SourceList:      output.SourceList,
 				AbiDefinition:   info.Abi,
 				UserDoc:         info.Userdoc,
 				DeveloperDoc:    info.Devdoc,
@@ -0,0 +1,89 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package compiler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Jump types of source map entries, describing how a jump instruction relates
+// to the function call structure of the source.
+const (
+	JumpInto    = 'i' // Jump into a function
+	JumpOutOf   = 'o' // Jump returning from a function
+	JumpRegular = '-' // Regular jump within a function, or no jump at all
+)
+
+// SourceMapEntry is the source range an instruction was generated from.
+type SourceMapEntry struct {
+	Offset        int  // Byte offset of the range start in the source file
+	Length        int  // Length of the range in bytes
+	File          int  // Index of the source file in the source list, -1 if generated
+	Jump          byte // Jump type of the instruction, one of JumpInto, JumpOutOf or JumpRegular
+	ModifierDepth int  // Depth of modifier placeholders the instruction is within
+}
+
+// ParseSourceMap decodes a compressed solc source map, returning the source
+// range of each instruction in the order they appear in the bytecode.
+//
+// Each entry is in the form of s:l:f:j:m, separated by semicolons. Empty or
+// missing fields inherit their value from the previous entry.
+func ParseSourceMap(srcmap string) ([]SourceMapEntry, error) {
+	if srcmap == "" {
+		return nil, nil
+	}
+	var (
+		entries []SourceMapEntry
+		last    = SourceMapEntry{File: -1, Jump: JumpRegular}
+	)
+	for i, item := range strings.Split(srcmap, ";") {
+		entry := last
+		for j, field := range strings.Split(item, ":") {
+			if field == "" {
+				continue
+			}
+			if j == 3 {
+				if len(field) != 1 || (field[0] != JumpInto && field[0] != JumpOutOf && field[0] != JumpRegular) {
+					return nil, fmt.Errorf("entry %d: invalid jump type %q", i, field)
+				}
+				entry.Jump = field[0]
+				continue
+			}
+			n, err := strconv.Atoi(field)
+			if err != nil {
+				return nil, fmt.Errorf("entry %d: invalid field %q", i, field)
+			}
+			switch j {
+			case 0:
+				entry.Offset = n
+			case 1:
+				entry.Length = n
+			case 2:
+				entry.File = n
+			case 4:
+				entry.ModifierDepth = n
+			default:
+				return nil, fmt.Errorf("entry %d: too many fields", i)
+			}
+		}
+		entries = append(entries, entry)
+		last = entry
+	}
+	return entries, nil
+}
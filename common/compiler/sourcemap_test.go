@@ -0,0 +1,45 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package compiler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSourceMap(t *testing.T) {
+	entries, err := ParseSourceMap("1:2:1;:9;2:1:2;;5::0:i;::-1:o:1")
+	if err != nil {
+		t.Fatalf("failed to parse source map: %v", err)
+	}
+	want := []SourceMapEntry{
+		{Offset: 1, Length: 2, File: 1, Jump: JumpRegular},
+		{Offset: 1, Length: 9, File: 1, Jump: JumpRegular},
+		{Offset: 2, Length: 1, File: 2, Jump: JumpRegular},
+		{Offset: 2, Length: 1, File: 2, Jump: JumpRegular},
+		{Offset: 5, Length: 1, File: 0, Jump: JumpInto},
+		{Offset: 5, Length: 1, File: -1, Jump: JumpOutOf, ModifierDepth: 1},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("source map mismatch:\nhave %+v\nwant %+v", entries, want)
+	}
+	for _, invalid := range []string{"1:2:x", "1:2:1:x", "1:2:1:i:0:7"} {
+		if _, err := ParseSourceMap(invalid); err == nil {
+			t.Errorf("invalid source map %q accepted", invalid)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package clique
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	// alertMissedRounds is the number of full signer rotations a signer may stay
+	// silent for before it's considered to have stopped sealing.
+	alertMissedRounds = 2
+
+	// alertRecency is the maximum age of the chain head for raising alerts, to
+	// avoid flooding subscribers with stale alerts while syncing.
+	alertRecency = time.Hour
+)
+
+// SignerAlert is posted on the alert feed when an authorized signer stops sealing
+// blocks, and again when it resumes sealing.
+type SignerAlert struct {
+	Signer     common.Address `json:"signer"`     // Authorized signer the alert is about
+	Stopped    bool           `json:"stopped"`    // Whether the signer stopped (or resumed) sealing
+	LastSealed uint64         `json:"lastSealed"` // Last block sealed by the signer (0 if not found)
+	Number     uint64         `json:"number"`     // Number of the block the alert was raised at
+}
+
+// SubscribeSignerAlerts registers a subscription for alerts about signers that
+// stopped or resumed sealing blocks.
+func (c *Clique) SubscribeSignerAlerts(ch chan<- SignerAlert) event.Subscription {
+	return c.alertScope.Track(c.alertFeed.Subscribe(ch))
+}
+
+// NewChainHead checks the signers for changes in their sealing status on the
+// canonical chain ending in head, raising alerts if any authorized signer stopped
+// or resumed sealing blocks. The status is derived from the canonical chain on
+// every call, so side chains and reorged out blocks are never accounted for.
+func (c *Clique) NewChainHead(chain consensus.ChainHeaderReader, head *types.Header) {
+	// Don't flood the subscribers with stale alerts while syncing
+	if time.Since(time.Unix(int64(head.Time), 0)) > alertRecency {
+		return
+	}
+	number := head.Number.Uint64()
+	snap, err := c.snapshot(chain, number, head.Hash(), nil)
+	if err != nil {
+		log.Debug("Failed to retrieve signer snapshot", "number", number, "err", err)
+		return
+	}
+	threshold := uint64(len(snap.Signers)) * alertMissedRounds
+	if number < threshold {
+		return
+	}
+	// Collect the signers of the last threshold blocks, stopping at the parent
+	// of the oldest one
+	sealed := make(map[common.Address]uint64)
+	base := head
+	for i := uint64(0); i < threshold && base != nil; i++ {
+		if signer, err := ecrecover(base, c.signatures); err == nil {
+			if _, ok := sealed[signer]; !ok {
+				sealed[signer] = base.Number.Uint64()
+			}
+		}
+		base = chain.GetHeader(base.ParentHash, base.Number.Uint64()-1)
+	}
+	if base == nil {
+		return
+	}
+	// Only signers authorized for the entire range can have missed it
+	authorized, err := c.snapshot(chain, base.Number.Uint64(), base.Hash(), nil)
+	if err != nil {
+		log.Debug("Failed to retrieve signer snapshot", "number", base.Number, "err", err)
+		return
+	}
+	var alerts []SignerAlert
+
+	c.activityLock.Lock()
+	for signer := range c.alerted {
+		if _, ok := snap.Signers[signer]; !ok {
+			delete(c.alerted, signer)
+		}
+	}
+	for signer := range snap.Signers {
+		last, ok := sealed[signer]
+		_, old := authorized.Signers[signer]
+		if stopped := !ok && old; stopped != c.alerted[signer] {
+			if stopped {
+				c.alerted[signer] = true
+				last = c.lastSealed(chain, base, signer)
+			} else {
+				delete(c.alerted, signer)
+			}
+			alerts = append(alerts, SignerAlert{Signer: signer, Stopped: stopped, LastSealed: last, Number: number})
+		}
+	}
+	c.activityLock.Unlock()
+
+	// Deliver the alerts outside of the lock, subscribers might be slow
+	for _, alert := range alerts {
+		if alert.Stopped {
+			log.Warn("Signer stopped sealing", "signer", alert.Signer, "last", alert.LastSealed, "number", alert.Number)
+		} else {
+			log.Info("Signer resumed sealing", "signer", alert.Signer, "number", alert.Number)
+		}
+		c.alertFeed.Send(alert)
+	}
+}
+
+// lastSealed searches the canonical chain from header backwards for the last block
+// sealed by the given signer, giving up after maxHistoryRange blocks.
+func (c *Clique) lastSealed(chain consensus.ChainHeaderReader, header *types.Header, signer common.Address) uint64 {
+	for i := 0; i < maxHistoryRange && header != nil && header.Number.Uint64() > 0; i++ {
+		if author, err := ecrecover(header, c.signatures); err == nil && author == signer {
+			return header.Number.Uint64()
+		}
+		header = chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+	}
+	return 0
+}
@@ -17,6 +17,9 @@
 package clique
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -25,6 +28,9 @@ import (
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
+// maxHistoryRange is the maximum number of blocks the history queries iterate over.
+const maxHistoryRange = 16384
+
 // API is a user facing RPC API to allow controlling the signer and voting
 // mechanisms of the proof-of-authority scheme.
 type API struct {
@@ -175,3 +181,178 @@ func (api *API) Status() (*status, error) {
 		NumBlocks:     numBlocks,
 	}, nil
 }
+
+// GetVotes retrieves all the votes cast in the given (inclusive) range of blocks.
+func (api *API) GetVotes(from, to rpc.BlockNumber) ([]*Vote, error) {
+	first, last, err := api.resolveRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	votes := make([]*Vote, 0)
+	for n := first; n <= last; n++ {
+		header := api.chain.GetHeaderByNumber(n)
+		if header == nil {
+			return nil, fmt.Errorf("missing block %d", n)
+		}
+		// Skip blocks without a vote (checkpoints never contain one)
+		if header.Coinbase == (common.Address{}) {
+			continue
+		}
+		signer, err := api.clique.Author(header)
+		if err != nil {
+			return nil, err
+		}
+		votes = append(votes, &Vote{
+			Signer:    signer,
+			Block:     n,
+			Address:   header.Coinbase,
+			Authorize: bytes.Equal(header.Nonce[:], nonceAuthVote),
+		})
+	}
+	return votes, nil
+}
+
+// SignerActivity is the sealing record of a single signer over a range of blocks.
+type SignerActivity struct {
+	Sealed       uint64 `json:"sealed"`       // Number of blocks sealed by the signer
+	MissedInTurn uint64 `json:"missedInTurn"` // Number of in-turn slots sealed by someone else
+	LastSealed   uint64 `json:"lastSealed"`   // Last block sealed by the signer in the range (0 if none)
+}
+
+type activityReport struct {
+	From    uint64                             `json:"from"`
+	To      uint64                             `json:"to"`
+	Signers map[common.Address]*SignerActivity `json:"signers"`
+}
+
+// GetSignerActivity reports the per-signer sealing activity over the last given
+// number of blocks (64 if unspecified).
+func (api *API) GetSignerActivity(blocks *uint64) (*activityReport, error) {
+	numBlocks := uint64(64)
+	if blocks != nil {
+		numBlocks = *blocks
+	}
+	if numBlocks == 0 || numBlocks > maxHistoryRange {
+		return nil, fmt.Errorf("invalid block range %d, must be within 1..%d", numBlocks, maxHistoryRange)
+	}
+	var (
+		head  = api.chain.CurrentHeader()
+		end   = head.Number.Uint64()
+		start = uint64(1)
+	)
+	if end >= numBlocks {
+		start = end - numBlocks + 1
+	}
+	report := &activityReport{
+		From:    start,
+		To:      end,
+		Signers: make(map[common.Address]*SignerActivity),
+	}
+	if end == 0 {
+		return report, nil
+	}
+	// Walk the range, tracking the signer set to know who was in-turn
+	parent := api.chain.GetHeaderByNumber(start - 1)
+	if parent == nil {
+		return nil, fmt.Errorf("missing block %d", start-1)
+	}
+	snap, err := api.clique.snapshot(api.chain, start-1, parent.Hash(), nil)
+	if err != nil {
+		return nil, err
+	}
+	track := func(signer common.Address) *SignerActivity {
+		if report.Signers[signer] == nil {
+			report.Signers[signer] = new(SignerActivity)
+		}
+		return report.Signers[signer]
+	}
+	for n := start; n <= end; n++ {
+		header := api.chain.GetHeaderByNumber(n)
+		if header == nil {
+			return nil, fmt.Errorf("missing block %d", n)
+		}
+		sealer, err := api.clique.Author(header)
+		if err != nil {
+			return nil, err
+		}
+		activity := track(sealer)
+		activity.Sealed++
+		activity.LastSealed = n
+
+		signers := snap.signers()
+		if inturn := signers[n%uint64(len(signers))]; inturn != sealer {
+			track(inturn).MissedInTurn++
+		}
+		if snap, err = snap.apply([]*types.Header{header}); err != nil {
+			return nil, err
+		}
+	}
+	// Report the idle signers too
+	for signer := range snap.Signers {
+		track(signer)
+	}
+	return report, nil
+}
+
+// SignerAlerts creates a subscription that is notified whenever an authorized
+// signer stops sealing blocks, or resumes sealing after an alert.
+func (api *API) SignerAlerts(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		alerts := make(chan SignerAlert, 16)
+		sub := api.clique.SubscribeSignerAlerts(alerts)
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case alert := <-alerts:
+				notifier.Notify(rpcSub.ID, alert)
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			case <-sub.Err():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
+// resolveRange converts an (inclusive) range of block numbers into absolute
+// numbers, ensuring it's not too large to iterate over.
+func (api *API) resolveRange(from, to rpc.BlockNumber) (uint64, uint64, error) {
+	head := api.chain.CurrentHeader().Number.Uint64()
+	resolve := func(number rpc.BlockNumber) (uint64, error) {
+		switch {
+		case number == rpc.LatestBlockNumber || number == rpc.PendingBlockNumber:
+			return head, nil
+		case number < 0:
+			return 0, fmt.Errorf("unsupported block number %d", number)
+		}
+		return uint64(number), nil
+	}
+	first, err := resolve(from)
+	if err != nil {
+		return 0, 0, err
+	}
+	last, err := resolve(to)
+	if err != nil {
+		return 0, 0, err
+	}
+	if last > head {
+		last = head
+	}
+	if first > last {
+		return 0, 0, errors.New("invalid block range")
+	}
+	if last-first+1 > maxHistoryRange {
+		return 0, 0, fmt.Errorf("block range too large, maximum is %d", maxHistoryRange)
+	}
+	return first, last, nil
+}
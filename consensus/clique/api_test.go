@@ -0,0 +1,269 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package clique
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Tests the voting history and signer activity reporting, as well as the alerts
+// raised when a signer stops sealing.
+func TestSignerHistory(t *testing.T) {
+	const blocks = 12
+
+	// Create a chain with three signers, one of which never seals
+	accounts := newTesterAccountPool()
+	signers := []common.Address{accounts.address("A"), accounts.address("B"), accounts.address("C")}
+	for i := 0; i < len(signers); i++ {
+		for j := i + 1; j < len(signers); j++ {
+			if bytes.Compare(signers[i][:], signers[j][:]) > 0 {
+				signers[i], signers[j] = signers[j], signers[i]
+			}
+		}
+	}
+	genesis := &core.Genesis{
+		Timestamp: uint64(time.Now().Unix()) - 10*blocks - 10,
+		ExtraData: make([]byte, extraVanity+common.AddressLength*len(signers)+extraSeal),
+	}
+	for i, signer := range signers {
+		copy(genesis.ExtraData[extraVanity+i*common.AddressLength:], signer[:])
+	}
+	db := rawdb.NewMemoryDatabase()
+	genesis.Commit(db)
+
+	config := *params.TestChainConfig
+	config.Clique = &params.CliqueConfig{Period: 1, Epoch: 30000}
+	engine := New(config.Clique, db)
+	engine.fakeDiff = true
+
+	chain, _ := core.GenerateChain(&config, genesis.ToBlock(db), engine, db, blocks, func(i int, gen *core.BlockGen) {
+		// Cast a single vote in the first block
+		if i > 0 {
+			gen.SetCoinbase(common.Address{})
+			return
+		}
+		gen.SetCoinbase(accounts.address("D"))
+
+		var nonce types.BlockNonce
+		copy(nonce[:], nonceAuthVote)
+		gen.SetNonce(nonce)
+	})
+	sealers := make([]string, blocks)
+	for i := range sealers {
+		sealers[i] = "A"
+		if i%2 == 1 {
+			sealers[i] = "B"
+		}
+	}
+	sealTestChain(accounts, common.Hash{}, chain, sealers)
+
+	alerts := make(chan SignerAlert, 16)
+	sub := engine.SubscribeSignerAlerts(alerts)
+	defer sub.Unsubscribe()
+
+	blockchain, err := core.NewBlockChain(db, nil, &config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create test chain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	engine.NewChainHead(blockchain, blockchain.CurrentHeader())
+
+	api := &API{chain: blockchain, clique: engine}
+
+	// Ensure the voting history is reported correctly
+	votes, err := api.GetVotes(0, rpc.LatestBlockNumber)
+	if err != nil {
+		t.Fatalf("failed to retrieve votes: %v", err)
+	}
+	if len(votes) != 1 {
+		t.Fatalf("vote count mismatch: have %d, want %d", len(votes), 1)
+	}
+	if want := (Vote{Signer: accounts.address("A"), Block: 1, Address: accounts.address("D"), Authorize: true}); *votes[0] != want {
+		t.Errorf("vote mismatch: have %+v, want %+v", *votes[0], want)
+	}
+	if votes, err = api.GetVotes(2, rpc.LatestBlockNumber); err != nil || len(votes) != 0 {
+		t.Errorf("votes after vote block: have %d, %v, want none", len(votes), err)
+	}
+	if _, err := api.GetVotes(5, 2); err == nil {
+		t.Errorf("inverted range accepted")
+	}
+	// Ensure the signer activity is reported correctly
+	report, err := api.GetSignerActivity(nil)
+	if err != nil {
+		t.Fatalf("failed to retrieve signer activity: %v", err)
+	}
+	if report.From != 1 || report.To != blocks {
+		t.Errorf("range mismatch: have %d-%d, want %d-%d", report.From, report.To, 1, blocks)
+	}
+	want := make(map[common.Address]*SignerActivity)
+	for _, signer := range signers {
+		want[signer] = new(SignerActivity)
+	}
+	for i, sealer := range sealers {
+		number := uint64(i + 1)
+
+		want[accounts.address(sealer)].Sealed++
+		want[accounts.address(sealer)].LastSealed = number
+		if inturn := signers[number%uint64(len(signers))]; inturn != accounts.address(sealer) {
+			want[inturn].MissedInTurn++
+		}
+	}
+	for signer, activity := range want {
+		if have := report.Signers[signer]; have == nil || *have != *activity {
+			t.Errorf("signer %x activity mismatch: have %+v, want %+v", signer, have, activity)
+		}
+	}
+	// Ensure the idle signer was reported on the alert feed
+	select {
+	case alert := <-alerts:
+		if alert.Signer != accounts.address("C") || !alert.Stopped {
+			t.Errorf("alert mismatch: have %+v", alert)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("no alert raised for idle signer")
+	}
+	select {
+	case alert := <-alerts:
+		t.Errorf("unexpected alert: %+v", alert)
+	default:
+	}
+}
+
+// Tests that signer alerts only account for blocks on the canonical chain, not
+// for side chains or blocks reorged out.
+func TestSignerAlertsReorg(t *testing.T) {
+	// Create a chain with three signers, one of which never seals
+	accounts := newTesterAccountPool()
+	genesis := &core.Genesis{
+		Timestamp: uint64(time.Now().Unix()) - 200,
+		ExtraData: make([]byte, extraVanity+common.AddressLength*3+extraSeal),
+	}
+	signers := []common.Address{accounts.address("A"), accounts.address("B"), accounts.address("C")}
+	sort.Sort(signersAscending(signers))
+	for i, signer := range signers {
+		copy(genesis.ExtraData[extraVanity+i*common.AddressLength:], signer[:])
+	}
+	db := rawdb.NewMemoryDatabase()
+	genesis.Commit(db)
+
+	config := *params.TestChainConfig
+	config.Clique = &params.CliqueConfig{Period: 1, Epoch: 30000}
+	engine := New(config.Clique, db)
+	engine.fakeDiff = true
+
+	noVote := func(i int, gen *core.BlockGen) { gen.SetCoinbase(common.Address{}) }
+
+	chain, _ := core.GenerateChain(&config, genesis.ToBlock(db), engine, db, 12, noVote)
+	sealTestChain(accounts, common.Hash{}, chain, []string{"A", "B", "A", "B", "A", "B", "A", "B", "A", "B", "A", "B"})
+
+	// Fork off the canonical chain with blocks sealed by the idle signer too
+	fork, _ := core.GenerateChain(&config, chain[5], engine, db, 8, noVote)
+	sealTestChain(accounts, chain[5].Hash(), fork, []string{"C", "A", "C", "A", "C", "A", "C", "A"})
+
+	alerts := make(chan SignerAlert, 16)
+	sub := engine.SubscribeSignerAlerts(alerts)
+	defer sub.Unsubscribe()
+
+	blockchain, err := core.NewBlockChain(db, nil, &config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create test chain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	// Import the canonical chain and a shorter side chain, only the former counts
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	if _, err := blockchain.InsertChain(fork[:5]); err != nil {
+		t.Fatalf("failed to import side chain: %v", err)
+	}
+	if head := blockchain.CurrentBlock(); head.Hash() != chain[len(chain)-1].Hash() {
+		t.Fatalf("side chain became canonical")
+	}
+	engine.NewChainHead(blockchain, blockchain.CurrentHeader())
+
+	want := map[common.Address]bool{accounts.address("C"): true}
+	checkSignerAlerts(t, alerts, want)
+
+	// Reorg to the fork, where the idle signer resumed and the other one stopped
+	if _, err := blockchain.InsertChain(fork[5:]); err != nil {
+		t.Fatalf("failed to import fork: %v", err)
+	}
+	if head := blockchain.CurrentBlock(); head.Hash() != fork[len(fork)-1].Hash() {
+		t.Fatalf("fork didn't become canonical")
+	}
+	engine.NewChainHead(blockchain, blockchain.CurrentHeader())
+
+	want = map[common.Address]bool{accounts.address("B"): true, accounts.address("C"): false}
+	checkSignerAlerts(t, alerts, want)
+}
+
+// sealTestChain re-links the generated blocks onto the given parent (unless it's
+// empty) and seals them in order by the given signers.
+func sealTestChain(accounts *testerAccountPool, parent common.Hash, blocks []*types.Block, sealers []string) {
+	for i, block := range blocks {
+		header := block.Header()
+		if i > 0 {
+			header.ParentHash = blocks[i-1].Hash()
+		} else if parent != (common.Hash{}) {
+			header.ParentHash = parent
+		}
+		header.Extra = make([]byte, extraVanity+extraSeal)
+		header.Difficulty = diffInTurn
+
+		accounts.sign(header, sealers[i])
+		blocks[i] = block.WithSeal(header)
+	}
+}
+
+// checkSignerAlerts ensures exactly the expected stopped (or resumed) alerts were
+// delivered on the feed.
+func checkSignerAlerts(t *testing.T, alerts chan SignerAlert, want map[common.Address]bool) {
+	t.Helper()
+
+	for len(want) > 0 {
+		select {
+		case alert := <-alerts:
+			if stopped, ok := want[alert.Signer]; !ok || stopped != alert.Stopped {
+				t.Fatalf("unexpected alert: %+v", alert)
+			}
+			delete(want, alert.Signer)
+		case <-time.After(time.Second):
+			t.Fatalf("missing alerts: %v", want)
+		}
+	}
+	select {
+	case alert := <-alerts:
+		t.Fatalf("unexpected alert: %+v", alert)
+	default:
+	}
+}
@@ -35,6 +35,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
@@ -181,6 +182,11 @@ type Clique struct {
 	signFn SignerFn       // Signer function to authorize hashes with
 	lock   sync.RWMutex   // Protects the signer fields
 
+	alerted      map[common.Address]bool // Signers currently reported as not sealing
+	activityLock sync.Mutex              // Protects the signer alert status
+	alertFeed    event.Feed              // Feed of signer alerts
+	alertScope   event.SubscriptionScope // Subscription scope tracking the alert subscribers
+
 	// The fields below are for testing only
 	fakeDiff bool // Skip difficulty verifications
 }
@@ -203,6 +209,7 @@ func New(config *params.CliqueConfig, db ethdb.Database) *Clique {
 		recents:    recents,
 		signatures: signatures,
 		proposals:  make(map[common.Address]bool),
+		alerted:    make(map[common.Address]bool),
 	}
 }
 
@@ -668,8 +675,10 @@ func (c *Clique) SealHash(header *types.Header) common.Hash {
 	return SealHash(header)
 }
 
-// Close implements consensus.Engine. It's a noop for clique as there are no background threads.
+// Close implements consensus.Engine. There are no background threads in clique,
+// only the signer alert subscriptions are terminated.
 func (c *Clique) Close() error {
+	c.alertScope.Close()
 	return nil
 }
 
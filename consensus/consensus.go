@@ -23,6 +23,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rpc"
 )
@@ -125,3 +126,22 @@ type PoW interface {
 	// Hashrate returns the current mining hashrate of a PoW consensus engine.
 	Hashrate() float64
 }
+
+// BFT is a consensus engine which agrees on blocks by running a byzantine fault
+// tolerant protocol between the validators, rendering blocks final as soon as
+// they are committed.
+type BFT interface {
+	Engine
+
+	// Protocols returns the p2p sub-protocols used to exchange consensus messages.
+	Protocols() []p2p.Protocol
+
+	// Start launches the agreement protocol. Proposals received from other
+	// validators are fully validated with verify before being voted on, blocks
+	// committed without the local node proposing them are handed to insert.
+	Start(chain ChainHeaderReader, verify func(*types.Block) error, insert func(*types.Block) error) error
+
+	// NewChainHead notifies the engine that the head of the local chain changed,
+	// moving the agreement protocol to the next height.
+	NewChainHead(header *types.Header)
+}
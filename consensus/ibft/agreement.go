@@ -0,0 +1,505 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ibft
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+	maxBacklog      = 1024 // Maximum number of future messages to keep around
+	maxSenderLog    = 64   // Maximum number of future messages to keep around per validator
+	maxRoundBackoff = 6    // Maximum number of times the round timeout is doubled
+)
+
+// Round states of the agreement protocol.
+const (
+	stateAcceptRequest = iota // Waiting for the proposal of the round
+	statePreprepared          // Proposal accepted, collecting prepare votes
+	statePrepared             // Proposal prepared (and locked), collecting commit votes
+	stateCommitted            // Proposal committed, waiting for it to be imported
+)
+
+// request is a block offered by the local miner for proposal.
+type request struct {
+	block   *types.Block
+	results chan<- *types.Block
+}
+
+// agreement is the state machine running the agreement protocol. All its state is
+// owned by the event loop, the outside world interacts with it by posting
+// chain heads, sealing requests and consensus messages.
+type agreement struct {
+	engine *Engine
+	chain  consensus.ChainHeaderReader
+	verify func(*types.Block) error // Full validation of proposals (state transition)
+	insert func(*types.Block) error // Import of blocks committed without our proposal
+
+	events chan interface{}
+	quit   chan struct{}
+	wg     sync.WaitGroup
+
+	parent   *types.Header // Head of the chain the agreement builds on
+	snap     *Snapshot     // Validator set at the parent block
+	sequence uint64        // Block number currently being agreed on
+	round    uint64        // Round of the agreement at the current sequence
+	state    int           // State of the current round
+
+	pending  *request     // Latest block offered by the local miner
+	proposal *types.Block // Proposal accepted in the current round
+	locked   *types.Block // Proposal prepared in an earlier round, must be re-proposed
+
+	prepares        map[common.Address]common.Hash         // Prepare votes of the current round
+	commits         map[common.Address]*message            // Commit votes of the current round
+	roundChanges    map[uint64]map[common.Address]struct{} // Round change requests per future round
+	roundChangeSent uint64                                 // Highest round we requested to move to
+	backlog         []*message                             // Messages received for future sequences or rounds
+	backlogged      map[common.Address]int                 // Number of backlogged messages per sender
+
+	timeout <-chan time.Time // Fires if the current round doesn't complete in time
+}
+
+func newAgreement(engine *Engine, chain consensus.ChainHeaderReader, verify func(*types.Block) error, insert func(*types.Block) error) *agreement {
+	c := &agreement{
+		engine: engine,
+		chain:  chain,
+		verify: verify,
+		insert: insert,
+		events: make(chan interface{}, 256),
+		quit:   make(chan struct{}),
+
+		backlogged: make(map[common.Address]int),
+	}
+	c.wg.Add(1)
+	go c.loop()
+	return c
+}
+
+// post hands an event over to the event loop.
+func (c *agreement) post(ev interface{}) {
+	select {
+	case c.events <- ev:
+	case <-c.quit:
+	}
+}
+
+// stop terminates the event loop and waits for all background imports.
+func (c *agreement) stop() {
+	close(c.quit)
+	c.wg.Wait()
+}
+
+func (c *agreement) loop() {
+	defer c.wg.Done()
+
+	c.newHead(c.chain.CurrentHeader())
+	for {
+		select {
+		case ev := <-c.events:
+			switch ev := ev.(type) {
+			case *types.Header:
+				c.newHead(ev)
+			case *request:
+				c.handleRequest(ev)
+			case *message:
+				c.handleMessage(ev)
+			}
+		case <-c.timeout:
+			c.handleTimeout()
+
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// newHead moves the agreement on to the block following the given header.
+func (c *agreement) newHead(header *types.Header) {
+	number := header.Number.Uint64()
+	if c.parent != nil && number < c.sequence {
+		return
+	}
+	snap, err := c.engine.snapshot(c.chain, number, header.Hash(), nil)
+	if err != nil {
+		log.Warn("Failed to retrieve validator snapshot", "number", number, "hash", header.Hash(), "err", err)
+		return
+	}
+	c.parent, c.snap = header, snap
+	c.sequence = number + 1
+	c.locked = nil
+	c.roundChanges = make(map[uint64]map[common.Address]struct{})
+	c.roundChangeSent = 0
+
+	if c.pending != nil && c.pending.block.ParentHash() != header.Hash() {
+		c.pending = nil
+	}
+	c.startRound(0)
+}
+
+// startRound resets the round state and proposes a block if it's our turn.
+func (c *agreement) startRound(round uint64) {
+	c.round = round
+	c.state = stateAcceptRequest
+	c.proposal = nil
+	c.prepares = make(map[common.Address]common.Hash)
+	c.commits = make(map[common.Address]*message)
+	for r := range c.roundChanges {
+		if r <= round {
+			delete(c.roundChanges, r)
+		}
+	}
+	c.timeout = time.After(c.roundTimeout(round))
+
+	log.Debug("Starting consensus round", "sequence", c.sequence, "round", round, "proposer", c.snap.proposer(round))
+	if c.isProposer() {
+		c.propose()
+	}
+	// Replay any messages received in advance for the new round
+	backlog := c.backlog
+	c.backlog, c.backlogged = nil, make(map[common.Address]int)
+	for _, msg := range backlog {
+		c.handleMessage(msg)
+	}
+}
+
+// roundTimeout calculates how long to wait for a round to complete. Later rounds
+// get exponentially more time, the first one also the time until the block is due.
+func (c *agreement) roundTimeout(round uint64) time.Duration {
+	backoff := round
+	if backoff > maxRoundBackoff {
+		backoff = maxRoundBackoff
+	}
+	timeout := time.Duration(c.engine.config.RequestTimeout) * time.Millisecond << backoff
+	if round == 0 {
+		due := time.Unix(int64(c.parent.Time+c.engine.config.Period), 0)
+		if delay := time.Until(due); delay > 0 {
+			timeout += delay
+		}
+	}
+	return timeout
+}
+
+// isProposer reports whether the local node proposes in the current round.
+func (c *agreement) isProposer() bool {
+	return c.snap.proposer(c.round) == c.engine.address
+}
+
+// propose broadcasts the locked block, or the latest one offered by the miner.
+func (c *agreement) propose() {
+	block := c.locked
+	if block == nil {
+		if c.pending == nil || c.pending.block.ParentHash() != c.parent.Hash() {
+			return
+		}
+		block = c.pending.block
+	}
+	payload, err := rlp.EncodeToBytes(block)
+	if err != nil {
+		log.Error("Failed to encode proposal", "err", err)
+		return
+	}
+	log.Debug("Proposing block", "number", c.sequence, "round", c.round, "hash", block.Hash())
+	c.broadcast(&message{Code: msgPreprepare, Proposal: payload})
+}
+
+// broadcast signs a message for the current view, gossips it to the network
+// and processes it locally.
+func (c *agreement) broadcast(msg *message) {
+	msg.Sequence = c.sequence
+	if msg.Code != msgRoundChange {
+		msg.Round = c.round
+	}
+	if err := msg.sign(c.engine.key); err != nil {
+		log.Error("Failed to sign consensus message", "err", err)
+		return
+	}
+	payload, err := rlp.EncodeToBytes(msg)
+	if err != nil {
+		log.Error("Failed to encode consensus message", "err", err)
+		return
+	}
+	c.engine.gossip(payload)
+	c.handleMessage(msg)
+}
+
+// handleRequest stores a block offered by the miner, proposing it right away if
+// it's our turn.
+func (c *agreement) handleRequest(req *request) {
+	if req.block.ParentHash() != c.parent.Hash() {
+		// The new chain head might not have been processed yet, keep the request
+		// around if it builds on top of a newer block
+		if req.block.NumberU64() > c.sequence {
+			c.pending = req
+		}
+		return
+	}
+	c.pending = req
+	if c.state == stateAcceptRequest && c.isProposer() {
+		c.propose()
+	}
+}
+
+// handleMessage validates the view and sender of a consensus message and routes
+// it to the appropriate handler.
+func (c *agreement) handleMessage(msg *message) {
+	// Messages beyond the next sequence can't be attributed to a known validator
+	// set, drop them along with the stale ones
+	if c.snap == nil || msg.Sequence < c.sequence || msg.Sequence > c.sequence+1 {
+		return
+	}
+	// Messages of the next sequence are checked against the current validator
+	// set, missing those of validators joining with the next block. They'll
+	// catch up via round changes.
+	if _, ok := c.snap.Validators[msg.sender]; !ok {
+		log.Trace("Ignoring message from non-validator", "sender", msg.sender)
+		return
+	}
+	if msg.Sequence > c.sequence || msg.Code != msgRoundChange && msg.Round > c.round {
+		c.backlogMessage(msg)
+		return
+	}
+	switch msg.Code {
+	case msgPreprepare:
+		c.handlePreprepare(msg)
+	case msgPrepare:
+		c.handlePrepare(msg)
+	case msgCommit:
+		c.handleCommit(msg)
+	case msgRoundChange:
+		c.handleRoundChange(msg)
+	}
+}
+
+// backlogMessage keeps a message of a future round or of the next sequence
+// around until the agreement catches up with it. Every validator only gets its
+// share of the backlog, so none can crowd out the messages of the others.
+func (c *agreement) backlogMessage(msg *message) {
+	if len(c.backlog) >= maxBacklog || c.backlogged[msg.sender] >= maxSenderLog {
+		log.Trace("Dropping future message", "sender", msg.sender, "sequence", msg.Sequence, "round", msg.Round)
+		return
+	}
+	c.backlog = append(c.backlog, msg)
+	c.backlogged[msg.sender]++
+}
+
+// handlePreprepare validates the proposal of the round and votes on it.
+func (c *agreement) handlePreprepare(msg *message) {
+	if msg.Round != c.round || c.state != stateAcceptRequest {
+		return
+	}
+	if proposer := c.snap.proposer(c.round); msg.sender != proposer {
+		log.Debug("Ignoring proposal from wrong proposer", "sender", msg.sender, "proposer", proposer)
+		return
+	}
+	block, err := msg.proposal()
+	if err != nil {
+		log.Debug("Ignoring malformed proposal", "sender", msg.sender, "err", err)
+		return
+	}
+	if block.NumberU64() != c.sequence || block.ParentHash() != c.parent.Hash() {
+		log.Debug("Ignoring proposal for other parent", "number", block.Number(), "parent", block.ParentHash())
+		return
+	}
+	// Proposals locked in an earlier round are re-proposed as is, so the seal may
+	// be of an earlier proposer. It must be a validator's all the same.
+	if author, err := c.engine.Author(block.Header()); err != nil {
+		log.Debug("Ignoring unsealed proposal", "sender", msg.sender, "err", err)
+		return
+	} else if _, ok := c.snap.Validators[author]; !ok {
+		log.Debug("Ignoring proposal sealed by non-validator", "sender", msg.sender, "author", author)
+		return
+	}
+	if c.locked != nil {
+		// A block was already prepared at this height, it's the only acceptable one
+		if block.Hash() != c.locked.Hash() {
+			log.Debug("Ignoring proposal conflicting with locked block", "hash", block.Hash(), "locked", c.locked.Hash())
+			return
+		}
+	} else {
+		// Fresh proposals must have a valid header and state
+		if err := c.engine.verifyHeader(c.chain, block.Header(), nil, false); err != nil {
+			log.Warn("Rejecting invalid proposal", "number", block.Number(), "hash", block.Hash(), "err", err)
+			return
+		}
+		if err := c.verify(block); err != nil {
+			log.Warn("Rejecting invalid proposal", "number", block.Number(), "hash", block.Hash(), "err", err)
+			return
+		}
+	}
+	c.proposal = block
+	c.state = statePreprepared
+
+	c.broadcast(&message{Code: msgPrepare, Digest: block.Hash()})
+	c.checkCommitted()
+}
+
+// handlePrepare records a prepare vote of the current round.
+func (c *agreement) handlePrepare(msg *message) {
+	if msg.Round != c.round {
+		return
+	}
+	c.prepares[msg.sender] = msg.Digest
+	c.checkPrepared()
+}
+
+// checkPrepared locks the proposal and commits to it if a qualified majority of
+// the validators accepted it.
+func (c *agreement) checkPrepared() {
+	if c.state != statePreprepared {
+		return
+	}
+	var votes int
+	for _, digest := range c.prepares {
+		if digest == c.proposal.Hash() {
+			votes++
+		}
+	}
+	if votes < c.snap.quorum() {
+		return
+	}
+	c.locked = c.proposal
+	c.state = statePrepared
+
+	seal, err := crypto.Sign(commitHash(c.proposal.Hash()), c.engine.key)
+	if err != nil {
+		log.Error("Failed to sign commit seal", "err", err)
+		return
+	}
+	c.broadcast(&message{Code: msgCommit, Digest: c.proposal.Hash(), CommittedSeal: seal})
+}
+
+// handleCommit records a commit vote of the current round.
+func (c *agreement) handleCommit(msg *message) {
+	if msg.Round != c.round {
+		return
+	}
+	pubkey, err := crypto.SigToPub(commitHash(msg.Digest), msg.CommittedSeal)
+	if err != nil || crypto.PubkeyToAddress(*pubkey) != msg.sender {
+		log.Debug("Ignoring commit with invalid seal", "sender", msg.sender, "err", err)
+		return
+	}
+	c.commits[msg.sender] = msg
+	c.checkCommitted()
+}
+
+// checkCommitted finalizes the proposal if a qualified majority of validators
+// committed to it.
+func (c *agreement) checkCommitted() {
+	if c.state != statePreprepared && c.state != statePrepared {
+		return
+	}
+	var seals []*message
+	for _, msg := range c.commits {
+		if msg.Digest == c.proposal.Hash() {
+			seals = append(seals, msg)
+		}
+	}
+	if len(seals) < c.snap.quorum() {
+		return
+	}
+	c.state = stateCommitted
+
+	// Embed the committed seals into the block in a deterministic order
+	sort.Slice(seals, func(i, j int) bool {
+		return bytes.Compare(seals[i].sender[:], seals[j].sender[:]) < 0
+	})
+	header := c.proposal.Header()
+	extra, err := ExtractExtra(header)
+	if err != nil {
+		log.Error("Failed to decode committed proposal", "err", err)
+		return
+	}
+	for _, msg := range seals {
+		extra.CommittedSeals = append(extra.CommittedSeals, msg.CommittedSeal)
+	}
+	if header.Extra, err = types.EncodeBFTExtra(header.Extra[:extraVanity], extra); err != nil {
+		log.Error("Failed to encode committed proposal", "err", err)
+		return
+	}
+	block := c.proposal.WithSeal(header)
+	log.Info("Committed block", "number", block.Number(), "round", c.round, "hash", block.Hash(), "seals", len(seals))
+
+	// Hand our own proposal back to the miner, import any other block directly
+	if c.pending != nil && SealHash(c.pending.block.Header()) == SealHash(header) {
+		select {
+		case c.pending.results <- block:
+			return
+		default:
+			log.Warn("Sealing result is not read by miner", "sealhash", SealHash(header))
+		}
+	}
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		if err := c.insert(block); err != nil {
+			log.Error("Failed to import committed block", "number", block.Number(), "hash", block.Hash(), "err", err)
+		}
+	}()
+}
+
+// handleRoundChange records a request to move to a later round, following the
+// other validators if enough of them want to move on.
+func (c *agreement) handleRoundChange(msg *message) {
+	if msg.Round <= c.round {
+		return
+	}
+	if c.roundChanges[msg.Round] == nil {
+		c.roundChanges[msg.Round] = make(map[common.Address]struct{})
+	}
+	c.roundChanges[msg.Round][msg.sender] = struct{}{}
+
+	// If at least one honest validator wants to move on, join it
+	if len(c.roundChanges[msg.Round]) > c.snap.faulty() && msg.Round > c.roundChangeSent {
+		c.sendRoundChange(msg.Round)
+	}
+	// If a qualified majority agrees, start the new round
+	if msg.Round > c.round && len(c.roundChanges[msg.Round]) >= c.snap.quorum() {
+		c.startRound(msg.Round)
+	}
+}
+
+// handleTimeout requests a round change if the current round didn't complete.
+func (c *agreement) handleTimeout() {
+	c.timeout = nil
+	if c.state == stateCommitted {
+		return
+	}
+	round := c.round
+	if c.roundChangeSent > round {
+		round = c.roundChangeSent
+	}
+	log.Debug("Consensus round timed out", "sequence", c.sequence, "round", c.round)
+	c.sendRoundChange(round + 1)
+}
+
+// sendRoundChange broadcasts our request to move to the given round.
+func (c *agreement) sendRoundChange(round uint64) {
+	c.roundChangeSent = round
+	c.timeout = time.After(c.roundTimeout(round))
+
+	c.broadcast(&message{Code: msgRoundChange, Round: round})
+}
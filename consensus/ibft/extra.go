@@ -0,0 +1,71 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ibft
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ExtractExtra decodes the consensus data from the extra-data field of a header.
+func ExtractExtra(header *types.Header) (*types.BFTExtra, error) {
+	if len(header.Extra) < extraVanity {
+		return nil, errMissingVanity
+	}
+	extra, err := types.ExtractBFTExtra(header)
+	if err != nil {
+		return nil, errInvalidExtraData
+	}
+	return extra, nil
+}
+
+// GenesisExtra assembles the extra-data field of the genesis block for a chain
+// started with the given validator set.
+func GenesisExtra(vanity []byte, validators []common.Address) ([]byte, error) {
+	sorted := make([]common.Address, len(validators))
+	copy(sorted, validators)
+	sort.Sort(validatorsAscending(sorted))
+
+	return types.EncodeBFTExtra(vanity, &types.BFTExtra{Validators: sorted})
+}
+
+// SealHash returns the hash of a block prior to it being sealed by the proposer,
+// i.e. the hash of the header without any seals in the extra-data.
+func SealHash(header *types.Header) common.Hash {
+	if cpy := types.BFTFilteredHeader(header, false); cpy != nil {
+		return cpy.Hash()
+	}
+	return common.Hash{}
+}
+
+// commitHash returns the hash validators sign when committing to a proposal. The
+// block hash doesn't cover the committed seals, so it's the same for the proposal
+// and the final block.
+func commitHash(proposal common.Hash) []byte {
+	return crypto.Keccak256(proposal[:], []byte{byte(msgCommit)})
+}
+
+// validatorsAscending implements the sort interface to allow sorting a list of addresses
+type validatorsAscending []common.Address
+
+func (s validatorsAscending) Len() int           { return len(s) }
+func (s validatorsAscending) Less(i, j int) bool { return bytes.Compare(s[i][:], s[j][:]) < 0 }
+func (s validatorsAscending) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
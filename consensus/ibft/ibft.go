@@ -0,0 +1,685 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package ibft implements a byzantine fault tolerant proof-of-authority consensus
+// engine in the spirit of Istanbul BFT.
+//
+// A fixed (but votable) set of validators takes turns in proposing blocks. Every
+// proposal goes through a three phase agreement (pre-prepare, prepare, commit)
+// gossiped over a dedicated p2p sub-protocol. Once a qualified majority of the
+// validators committed to a proposal, their signatures are embedded into the
+// block, rendering it final: no two conflicting blocks can ever be committed at
+// the same height, so the chain never reorganizes. If a proposer fails to get a
+// block committed in time, the validators agree to move to a new round with the
+// next proposer in line.
+package ibft
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/misc"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+	checkpointInterval = 1024 // Number of blocks after which to save the vote snapshot to the database
+	inmemorySnapshots  = 128  // Number of recent vote snapshots to keep in memory
+	inmemorySignatures = 4096 // Number of recent proposer seals to keep in memory
+)
+
+// IBFT protocol constants.
+var (
+	epochLength    = uint64(30000) // Default number of blocks after which to checkpoint and reset the pending votes
+	requestTimeout = uint64(10000) // Default milliseconds to wait for a round to complete
+
+	extraVanity = types.BFTExtraVanity // Fixed number of extra-data prefix bytes reserved for proposer vanity
+
+	mixDigest = types.BFTDigest // Fixed mix digest of IBFT blocks, excluding the committed seals from the hash
+
+	uncleHash = types.CalcUncleHash(nil) // Always Keccak256(RLP([])) as uncles are meaningless outside of PoW.
+
+	defaultDifficulty = big.NewInt(1) // Difficulty of all blocks, committed blocks are final anyway
+)
+
+// Various error messages to mark blocks invalid. These should be private to
+// prevent engine specific errors from being referenced in the remainder of the
+// codebase, inherently breaking if the engine is swapped out. Please put common
+// error types into the consensus package.
+var (
+	// errUnknownBlock is returned when the list of validators is requested for a
+	// block that is not part of the local blockchain.
+	errUnknownBlock = errors.New("unknown block")
+
+	// errMissingVanity is returned if a block's extra-data section is shorter than
+	// 32 bytes, which is required to store the proposer vanity.
+	errMissingVanity = errors.New("extra-data 32 byte vanity prefix missing")
+
+	// errInvalidExtraData is returned if the consensus data following the vanity
+	// in a block's extra-data section can't be decoded.
+	errInvalidExtraData = errors.New("invalid consensus extra-data")
+
+	// errInvalidCheckpointVote is returned if a checkpoint/epoch transition block
+	// contains a validator vote.
+	errInvalidCheckpointVote = errors.New("vote in checkpoint block")
+
+	// errExtraValidators is returned if non-checkpoint block contain validator
+	// data in their extra-data fields.
+	errExtraValidators = errors.New("non-checkpoint block contains extra validator list")
+
+	// errMismatchingCheckpointValidators is returned if a checkpoint block contains
+	// a list of validators different than the one the local node calculated.
+	errMismatchingCheckpointValidators = errors.New("mismatching validator list on checkpoint block")
+
+	// errInvalidMixDigest is returned if a block's mix digest is not the IBFT digest.
+	errInvalidMixDigest = errors.New("invalid mix digest")
+
+	// errInvalidNonce is returned if a block's nonce is non-zero.
+	errInvalidNonce = errors.New("non-zero nonce")
+
+	// errInvalidUncleHash is returned if a block contains an non-empty uncle list.
+	errInvalidUncleHash = errors.New("non empty uncle hash")
+
+	// errInvalidDifficulty is returned if the difficulty of a block is not 1.
+	errInvalidDifficulty = errors.New("invalid difficulty")
+
+	// errInvalidTimestamp is returned if the timestamp of a block is lower than
+	// the previous block's timestamp + the minimum block period.
+	errInvalidTimestamp = errors.New("invalid timestamp")
+
+	// errInvalidVotingChain is returned if an authorization list is attempted to
+	// be modified via out-of-range or non-contiguous headers.
+	errInvalidVotingChain = errors.New("invalid voting chain")
+
+	// errMissingSignature is returned if a block's extra-data section doesn't
+	// contain a proposer seal.
+	errMissingSignature = errors.New("proposer seal missing")
+
+	// errUnauthorizedProposer is returned if a header is sealed by a non-validator.
+	errUnauthorizedProposer = errors.New("unauthorized proposer")
+
+	// errInvalidCommittedSeals is returned if a committed seal is not from a
+	// validator or the same validator committed multiple times.
+	errInvalidCommittedSeals = errors.New("invalid committed seals")
+
+	// errInsufficientCommittedSeals is returned if a block is committed by fewer
+	// validators than required for agreement.
+	errInsufficientCommittedSeals = errors.New("insufficient committed seals")
+
+	// errInvalidMessage is returned if a consensus message is malformed.
+	errInvalidMessage = errors.New("invalid consensus message")
+
+	// errNotStarted is returned if blocks are attempted to be sealed before the
+	// agreement protocol was started.
+	errNotStarted = errors.New("agreement protocol not started")
+)
+
+// ecrecover extracts the address of the proposer from a sealed header.
+func ecrecover(header *types.Header, sigcache *lru.ARCCache) (common.Address, error) {
+	// If the signature's already cached, return that
+	hash := header.Hash()
+	if address, known := sigcache.Get(hash); known {
+		return address.(common.Address), nil
+	}
+	// Retrieve the signature from the header extra-data
+	extra, err := ExtractExtra(header)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if len(extra.Seal) != crypto.SignatureLength {
+		return common.Address{}, errMissingSignature
+	}
+	// Recover the public key and the Ethereum address
+	pubkey, err := crypto.Ecrecover(SealHash(header).Bytes(), extra.Seal)
+	if err != nil {
+		return common.Address{}, err
+	}
+	var proposer common.Address
+	copy(proposer[:], crypto.Keccak256(pubkey[1:])[12:])
+
+	sigcache.Add(hash, proposer)
+	return proposer, nil
+}
+
+// Engine is the byzantine fault tolerant proof-of-authority consensus engine.
+type Engine struct {
+	config *params.IBFTConfig // Consensus engine configuration parameters
+	db     ethdb.Database     // Database to store and retrieve snapshot checkpoints
+
+	recents    *lru.ARCCache // Snapshots for recent block to speed up reorgs
+	signatures *lru.ARCCache // Proposer seals of recent blocks to speed up mining
+
+	proposals map[common.Address]bool // Current list of proposals we are pushing
+
+	key     *ecdsa.PrivateKey // Validator key to sign proposals and votes with
+	address common.Address    // Ethereum address of the validator key
+	core    *agreement        // Agreement protocol state machine, nil until started
+	lock    sync.RWMutex      // Protects the proposals and the core
+
+	peers    map[string]*peer // Peers connected on the consensus sub-protocol
+	seen     *lru.Cache       // Hashes of recently seen consensus messages
+	peerLock sync.RWMutex     // Protects the peer set
+}
+
+// New creates an IBFT consensus engine validating with the given key.
+func New(config *params.IBFTConfig, key *ecdsa.PrivateKey, db ethdb.Database) *Engine {
+	// Set any missing consensus parameters to their defaults
+	conf := *config
+	if conf.Epoch == 0 {
+		conf.Epoch = epochLength
+	}
+	if conf.RequestTimeout == 0 {
+		conf.RequestTimeout = requestTimeout
+	}
+	// Allocate the snapshot caches and create the engine
+	recents, _ := lru.NewARC(inmemorySnapshots)
+	signatures, _ := lru.NewARC(inmemorySignatures)
+	seen, _ := lru.New(maxSeenMessages)
+
+	return &Engine{
+		config:     &conf,
+		db:         db,
+		recents:    recents,
+		signatures: signatures,
+		proposals:  make(map[common.Address]bool),
+		key:        key,
+		address:    crypto.PubkeyToAddress(key.PublicKey),
+		peers:      make(map[string]*peer),
+		seen:       seen,
+	}
+}
+
+// Author implements consensus.Engine, returning the address of the proposer
+// recovered from the seal in the header's extra-data section.
+func (e *Engine) Author(header *types.Header) (common.Address, error) {
+	return ecrecover(header, e.signatures)
+}
+
+// VerifyHeader checks whether a header conforms to the consensus rules. The
+// committed seals are only checked if seal is set.
+func (e *Engine) VerifyHeader(chain consensus.ChainHeaderReader, header *types.Header, seal bool) error {
+	return e.verifyHeader(chain, header, nil, seal)
+}
+
+// VerifyHeaders is similar to VerifyHeader, but verifies a batch of headers. The
+// method returns a quit channel to abort the operations and a results channel to
+// retrieve the async verifications (the order is that of the input slice).
+func (e *Engine) VerifyHeaders(chain consensus.ChainHeaderReader, headers []*types.Header, seals []bool) (chan<- struct{}, <-chan error) {
+	abort := make(chan struct{})
+	results := make(chan error, len(headers))
+
+	go func() {
+		for i, header := range headers {
+			err := e.verifyHeader(chain, header, headers[:i], seals[i])
+
+			select {
+			case <-abort:
+				return
+			case results <- err:
+			}
+		}
+	}()
+	return abort, results
+}
+
+// verifyHeader checks whether a header conforms to the consensus rules. The
+// caller may optionally pass in a batch of parents (ascending order) to avoid
+// looking those up from the database. This is useful for concurrently verifying
+// a batch of new headers.
+func (e *Engine) verifyHeader(chain consensus.ChainHeaderReader, header *types.Header, parents []*types.Header, seals bool) error {
+	if header.Number == nil {
+		return errUnknownBlock
+	}
+	number := header.Number.Uint64()
+
+	// Don't waste time checking blocks from the future
+	if header.Time > uint64(time.Now().Unix()) {
+		return consensus.ErrFutureBlock
+	}
+	// Ensure the consensus data is present and well formed
+	extra, err := ExtractExtra(header)
+	if err != nil {
+		return err
+	}
+	checkpoint := (number % e.config.Epoch) == 0
+	if checkpoint && extra.Vote != nil {
+		return errInvalidCheckpointVote
+	}
+	if !checkpoint && len(extra.Validators) != 0 {
+		return errExtraValidators
+	}
+	// Ensure the header fields unused by IBFT have their fixed values
+	if header.MixDigest != mixDigest {
+		return errInvalidMixDigest
+	}
+	if header.Nonce != (types.BlockNonce{}) {
+		return errInvalidNonce
+	}
+	if header.UncleHash != uncleHash {
+		return errInvalidUncleHash
+	}
+	if number > 0 && (header.Difficulty == nil || header.Difficulty.Cmp(defaultDifficulty) != 0) {
+		return errInvalidDifficulty
+	}
+	// If all checks passed, validate any special fields for hard forks
+	if err := misc.VerifyForkHashes(chain.Config(), header, false); err != nil {
+		return err
+	}
+	// All basic checks passed, verify cascading fields
+	return e.verifyCascadingFields(chain, header, parents, seals)
+}
+
+// verifyCascadingFields verifies all the header fields that are not standalone,
+// rather depend on a batch of previous headers. The caller may optionally pass
+// in a batch of parents (ascending order) to avoid looking those up from the
+// database. This is useful for concurrently verifying a batch of new headers.
+func (e *Engine) verifyCascadingFields(chain consensus.ChainHeaderReader, header *types.Header, parents []*types.Header, seals bool) error {
+	// The genesis block is the always valid dead-end
+	number := header.Number.Uint64()
+	if number == 0 {
+		return nil
+	}
+	// Ensure that the block's timestamp isn't too close to its parent
+	var parent *types.Header
+	if len(parents) > 0 {
+		parent = parents[len(parents)-1]
+	} else {
+		parent = chain.GetHeader(header.ParentHash, number-1)
+	}
+	if parent == nil || parent.Number.Uint64() != number-1 || parent.Hash() != header.ParentHash {
+		return consensus.ErrUnknownAncestor
+	}
+	if parent.Time+e.config.Period > header.Time {
+		return errInvalidTimestamp
+	}
+	// Retrieve the snapshot needed to verify this header and cache it
+	snap, err := e.snapshot(chain, number-1, header.ParentHash, parents)
+	if err != nil {
+		return err
+	}
+	// If the block is a checkpoint block, verify the validator list
+	if number%e.config.Epoch == 0 {
+		extra, err := ExtractExtra(header)
+		if err != nil {
+			return err
+		}
+		validators := snap.validators()
+		if len(extra.Validators) != len(validators) {
+			return errMismatchingCheckpointValidators
+		}
+		for i, validator := range validators {
+			if extra.Validators[i] != validator {
+				return errMismatchingCheckpointValidators
+			}
+		}
+	}
+	// All basic checks passed, verify the seals and return
+	if err := e.verifySeal(header, snap); err != nil {
+		return err
+	}
+	if seals {
+		return e.verifyCommittedSeals(header, snap)
+	}
+	return nil
+}
+
+// snapshot retrieves the validator snapshot at a given point in time.
+func (e *Engine) snapshot(chain consensus.ChainHeaderReader, number uint64, hash common.Hash, parents []*types.Header) (*Snapshot, error) {
+	// Search for a snapshot in memory or on disk for checkpoints
+	var (
+		headers []*types.Header
+		snap    *Snapshot
+	)
+	for snap == nil {
+		// If an in-memory snapshot was found, use that
+		if s, ok := e.recents.Get(hash); ok {
+			snap = s.(*Snapshot)
+			break
+		}
+		// If an on-disk checkpoint snapshot can be found, use that
+		if number%checkpointInterval == 0 {
+			if s, err := loadSnapshot(e.config, e.signatures, e.db, hash); err == nil {
+				log.Trace("Loaded validator snapshot from disk", "number", number, "hash", hash)
+				snap = s
+				break
+			}
+		}
+		// If we're at the genesis, snapshot the initial state. Alternatively if we're
+		// at a checkpoint block without a parent (light client CHT), or we have piled
+		// up more headers than allowed to be reorged (chain reinit from a freezer),
+		// consider the checkpoint trusted and snapshot it.
+		if number == 0 || (number%e.config.Epoch == 0 && (len(headers) > params.FullImmutabilityThreshold || chain.GetHeaderByNumber(number-1) == nil)) {
+			checkpoint := chain.GetHeaderByNumber(number)
+			if checkpoint != nil {
+				extra, err := ExtractExtra(checkpoint)
+				if err != nil {
+					return nil, err
+				}
+				hash := checkpoint.Hash()
+
+				snap = newSnapshot(e.config, e.signatures, number, hash, extra.Validators)
+				if number > 0 {
+					if snap.Proposer, err = ecrecover(checkpoint, e.signatures); err != nil {
+						return nil, err
+					}
+				}
+				if err := snap.store(e.db); err != nil {
+					return nil, err
+				}
+				log.Info("Stored checkpoint snapshot to disk", "number", number, "hash", hash)
+				break
+			}
+		}
+		// No snapshot for this header, gather the header and move backward
+		var header *types.Header
+		if len(parents) > 0 {
+			// If we have explicit parents, pick from there (enforced)
+			header = parents[len(parents)-1]
+			if header.Hash() != hash || header.Number.Uint64() != number {
+				return nil, consensus.ErrUnknownAncestor
+			}
+			parents = parents[:len(parents)-1]
+		} else {
+			// No explicit parents (or no more left), reach out to the database
+			header = chain.GetHeader(hash, number)
+			if header == nil {
+				return nil, consensus.ErrUnknownAncestor
+			}
+		}
+		headers = append(headers, header)
+		number, hash = number-1, header.ParentHash
+	}
+	// Previous snapshot found, apply any pending headers on top of it
+	for i := 0; i < len(headers)/2; i++ {
+		headers[i], headers[len(headers)-1-i] = headers[len(headers)-1-i], headers[i]
+	}
+	snap, err := snap.apply(headers)
+	if err != nil {
+		return nil, err
+	}
+	e.recents.Add(snap.Hash, snap)
+
+	// If we've generated a new checkpoint snapshot, save to disk
+	if snap.Number%checkpointInterval == 0 && len(headers) > 0 {
+		if err = snap.store(e.db); err != nil {
+			return nil, err
+		}
+		log.Trace("Stored validator snapshot to disk", "number", snap.Number, "hash", snap.Hash)
+	}
+	return snap, err
+}
+
+// VerifyUncles implements consensus.Engine, always returning an error for any
+// uncles as this consensus mechanism doesn't permit uncles.
+func (e *Engine) VerifyUncles(chain consensus.ChainReader, block *types.Block) error {
+	if len(block.Uncles()) > 0 {
+		return errors.New("uncles not allowed")
+	}
+	return nil
+}
+
+// verifySeal checks whether the proposer seal contained in the header was made
+// by one of the validators.
+func (e *Engine) verifySeal(header *types.Header, snap *Snapshot) error {
+	proposer, err := ecrecover(header, e.signatures)
+	if err != nil {
+		return err
+	}
+	if _, ok := snap.Validators[proposer]; !ok {
+		return errUnauthorizedProposer
+	}
+	return nil
+}
+
+// verifyCommittedSeals checks whether a qualified majority of the validators
+// committed to the block.
+func (e *Engine) verifyCommittedSeals(header *types.Header, snap *Snapshot) error {
+	extra, err := ExtractExtra(header)
+	if err != nil {
+		return err
+	}
+	var (
+		hash      = commitHash(header.Hash())
+		committed = make(map[common.Address]struct{})
+	)
+	for _, seal := range extra.CommittedSeals {
+		pubkey, err := crypto.SigToPub(hash, seal)
+		if err != nil {
+			return errInvalidCommittedSeals
+		}
+		validator := crypto.PubkeyToAddress(*pubkey)
+		if _, ok := snap.Validators[validator]; !ok {
+			return errInvalidCommittedSeals
+		}
+		if _, ok := committed[validator]; ok {
+			return errInvalidCommittedSeals
+		}
+		committed[validator] = struct{}{}
+	}
+	if len(committed) < snap.quorum() {
+		return errInsufficientCommittedSeals
+	}
+	return nil
+}
+
+// Prepare implements consensus.Engine, preparing all the consensus fields of the
+// header for running the transactions on top.
+func (e *Engine) Prepare(chain consensus.ChainHeaderReader, header *types.Header) error {
+	header.Nonce = types.BlockNonce{}
+	header.MixDigest = mixDigest
+	header.Difficulty = new(big.Int).Set(defaultDifficulty)
+
+	number := header.Number.Uint64()
+	// Assemble the voting snapshot to check which votes make sense
+	snap, err := e.snapshot(chain, number-1, header.ParentHash, nil)
+	if err != nil {
+		return err
+	}
+	extra := new(types.BFTExtra)
+	if number%e.config.Epoch == 0 {
+		extra.Validators = snap.validators()
+	} else {
+		e.lock.RLock()
+
+		// Gather all the proposals that make sense voting on
+		addresses := make([]common.Address, 0, len(e.proposals))
+		for address, authorize := range e.proposals {
+			if snap.validVote(address, authorize) {
+				addresses = append(addresses, address)
+			}
+		}
+		// If there's pending proposals, cast a vote on them
+		if len(addresses) > 0 {
+			address := addresses[rand.Intn(len(addresses))]
+			extra.Vote = &types.BFTVote{Address: address, Authorize: e.proposals[address]}
+		}
+		e.lock.RUnlock()
+	}
+	vanity := header.Extra
+	if len(vanity) > extraVanity {
+		vanity = vanity[:extraVanity]
+	}
+	if header.Extra, err = types.EncodeBFTExtra(vanity, extra); err != nil {
+		return err
+	}
+	// Ensure the timestamp has the correct delay
+	parent := chain.GetHeader(header.ParentHash, number-1)
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	header.Time = parent.Time + e.config.Period
+	if header.Time < uint64(time.Now().Unix()) {
+		header.Time = uint64(time.Now().Unix())
+	}
+	return nil
+}
+
+// Finalize implements consensus.Engine, ensuring no uncles are set, nor block
+// rewards given.
+func (e *Engine) Finalize(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header) {
+	// No block rewards in PoA, so the state remains as is and uncles are dropped
+	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
+	header.UncleHash = types.CalcUncleHash(nil)
+}
+
+// FinalizeAndAssemble implements consensus.Engine, ensuring no uncles are set,
+// nor block rewards given, and returns the final block.
+func (e *Engine) FinalizeAndAssemble(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header, receipts []*types.Receipt) (*types.Block, error) {
+	// Finalize block
+	e.Finalize(chain, header, state, txs, uncles)
+
+	// Assemble and return the final block for sealing
+	return types.NewBlock(header, txs, nil, receipts, trie.NewStackTrie(nil)), nil
+}
+
+// Seal implements consensus.Engine, signing the block as its proposer and handing
+// it over to the agreement protocol. The sealed block is delivered on results if
+// it gets committed, otherwise the committed proposal of another validator is
+// imported directly.
+func (e *Engine) Seal(chain consensus.ChainHeaderReader, block *types.Block, results chan<- *types.Block, stop <-chan struct{}) error {
+	header := block.Header()
+
+	// Sealing the genesis block is not supported
+	number := header.Number.Uint64()
+	if number == 0 {
+		return errUnknownBlock
+	}
+	e.lock.RLock()
+	core := e.core
+	e.lock.RUnlock()
+
+	if core == nil {
+		return errNotStarted
+	}
+	// Bail out if we're not a validator
+	snap, err := e.snapshot(chain, number-1, header.ParentHash, nil)
+	if err != nil {
+		return err
+	}
+	if _, authorized := snap.Validators[e.address]; !authorized {
+		return errUnauthorizedProposer
+	}
+	// Sign the proposal
+	extra, err := ExtractExtra(header)
+	if err != nil {
+		return err
+	}
+	if extra.Seal, err = crypto.Sign(SealHash(header).Bytes(), e.key); err != nil {
+		return err
+	}
+	if header.Extra, err = types.EncodeBFTExtra(header.Extra[:extraVanity], extra); err != nil {
+		return err
+	}
+	// Wait for our time and offer the block to the agreement protocol
+	delay := time.Unix(int64(header.Time), 0).Sub(time.Now()) // nolint: gosimple
+
+	log.Trace("Waiting for slot to propose", "delay", common.PrettyDuration(delay))
+	go func() {
+		select {
+		case <-stop:
+			return
+		case <-time.After(delay):
+		}
+		core.post(&request{block: block.WithSeal(header), results: results})
+	}()
+	return nil
+}
+
+// CalcDifficulty is the difficulty adjustment algorithm. Difficulty is meaningless
+// with instant finality, so it's always 1.
+func (e *Engine) CalcDifficulty(chain consensus.ChainHeaderReader, time uint64, parent *types.Header) *big.Int {
+	return new(big.Int).Set(defaultDifficulty)
+}
+
+// SealHash returns the hash of a block prior to it being sealed.
+func (e *Engine) SealHash(header *types.Header) common.Hash {
+	return SealHash(header)
+}
+
+// Protocols implements consensus.BFT, returning the sub-protocol used to gossip
+// consensus messages between the validators.
+func (e *Engine) Protocols() []p2p.Protocol {
+	return []p2p.Protocol{{
+		Name:    protocolName,
+		Version: protocolVersion,
+		Length:  protocolLength,
+		Run:     e.runPeer,
+	}}
+}
+
+// Start implements consensus.BFT, launching the agreement protocol on top of the
+// given chain.
+func (e *Engine) Start(chain consensus.ChainHeaderReader, verify func(*types.Block) error, insert func(*types.Block) error) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.core != nil {
+		return errors.New("already started")
+	}
+	e.core = newAgreement(e, chain, verify, insert)
+	return nil
+}
+
+// NewChainHead implements consensus.BFT, moving the agreement protocol on to the
+// next block.
+func (e *Engine) NewChainHead(header *types.Header) {
+	e.lock.RLock()
+	core := e.core
+	e.lock.RUnlock()
+
+	if core != nil {
+		core.post(header)
+	}
+}
+
+// Close implements consensus.Engine, terminating the agreement protocol.
+func (e *Engine) Close() error {
+	e.lock.Lock()
+	core := e.core
+	e.core = nil
+	e.lock.Unlock()
+
+	if core != nil {
+		core.stop()
+	}
+	return nil
+}
+
+// APIs implements consensus.Engine, returning the user facing RPC API to allow
+// controlling the validator voting.
+func (e *Engine) APIs(chain consensus.ChainHeaderReader) []rpc.API {
+	return []rpc.API{{
+		Namespace: "ibft",
+		Version:   "1.0",
+		Service:   &API{chain: chain, ibft: e},
+		Public:    false,
+	}}
+}
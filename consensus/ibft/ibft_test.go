@@ -0,0 +1,488 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ibft
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// testNode is a validator running the agreement protocol on its own chain.
+type testNode struct {
+	key    *ecdsa.PrivateKey
+	addr   common.Address
+	engine *Engine
+	chain  *core.BlockChain
+	quit   chan struct{}
+	done   chan struct{}
+}
+
+// newTestNetwork creates a set of validators with the given keys, all of them
+// connected to each other via in-memory pipes.
+func newTestNetwork(t *testing.T, keys []*ecdsa.PrivateKey) []*testNode {
+	addrs := make([]common.Address, len(keys))
+	for i, key := range keys {
+		addrs[i] = crypto.PubkeyToAddress(key.PublicKey)
+	}
+	extra, err := GenesisExtra(nil, addrs)
+	if err != nil {
+		t.Fatalf("failed to create genesis extra-data: %v", err)
+	}
+	config := *params.AllCliqueProtocolChanges
+	config.Clique = nil
+	config.IBFT = &params.IBFTConfig{Period: 0, Epoch: 30000, RequestTimeout: 250}
+
+	nodes := make([]*testNode, len(keys))
+	for i, key := range keys {
+		db := rawdb.NewMemoryDatabase()
+		genspec := &core.Genesis{
+			Config:     &config,
+			ExtraData:  extra,
+			GasLimit:   params.GenesisGasLimit,
+			Difficulty: big.NewInt(1),
+			Mixhash:    mixDigest,
+		}
+		genspec.MustCommit(db)
+
+		engine := New(config.IBFT, key, db)
+		chain, err := core.NewBlockChain(db, nil, &config, engine, vm.Config{}, nil, nil)
+		if err != nil {
+			t.Fatalf("failed to create chain: %v", err)
+		}
+		nodes[i] = &testNode{key: key, addr: addrs[i], engine: engine, chain: chain}
+	}
+	// Connect all the validators to each other
+	for i := 0; i < len(nodes); i++ {
+		for j := i + 1; j < len(nodes); j++ {
+			a, b := p2p.MsgPipe()
+			go nodes[i].engine.runPeer(p2p.NewPeer(enode.ID{byte(j)}, "", nil), a)
+			go nodes[j].engine.runPeer(p2p.NewPeer(enode.ID{byte(i)}, "", nil), b)
+		}
+	}
+	return nodes
+}
+
+// verify runs the full state transition of a proposal on top of its parent.
+func (n *testNode) verify(block *types.Block) error {
+	if err := n.chain.Validator().ValidateBody(block); err != nil {
+		return err
+	}
+	parent := n.chain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	statedb, err := n.chain.StateAt(parent.Root())
+	if err != nil {
+		return err
+	}
+	receipts, _, usedGas, err := n.chain.Processor().Process(block, statedb, vm.Config{})
+	if err != nil {
+		return err
+	}
+	return n.chain.Validator().ValidateState(block, statedb, receipts, usedGas)
+}
+
+// insert imports a committed block into the local chain.
+func (n *testNode) insert(block *types.Block) error {
+	_, err := n.chain.InsertChain(types.Blocks{block})
+	return err
+}
+
+// start launches the agreement protocol and, if seal is set, a minimal miner
+// offering a fresh block on top of every new chain head.
+func (n *testNode) start(t *testing.T, seal bool) {
+	if err := n.engine.Start(n.chain, n.verify, n.insert); err != nil {
+		t.Fatalf("failed to start engine: %v", err)
+	}
+	n.quit, n.done = make(chan struct{}), make(chan struct{})
+
+	heads := make(chan core.ChainHeadEvent, 16)
+	sub := n.chain.SubscribeChainHeadEvent(heads)
+
+	go func() {
+		defer close(n.done)
+		defer sub.Unsubscribe()
+
+		var (
+			results = make(chan *types.Block, 16)
+			stop    chan struct{}
+		)
+		propose := func(parent *types.Block) {
+			if stop != nil {
+				close(stop)
+			}
+			stop = make(chan struct{})
+			if !seal {
+				return
+			}
+			header := &types.Header{
+				ParentHash: parent.Hash(),
+				Number:     new(big.Int).Add(parent.Number(), common.Big1),
+				GasLimit:   parent.GasLimit(),
+			}
+			if err := n.engine.Prepare(n.chain, header); err != nil {
+				t.Errorf("failed to prepare header: %v", err)
+				return
+			}
+			statedb, err := n.chain.StateAt(parent.Root())
+			if err != nil {
+				t.Errorf("failed to retrieve parent state: %v", err)
+				return
+			}
+			block, err := n.engine.FinalizeAndAssemble(n.chain, header, statedb, nil, nil, nil)
+			if err != nil {
+				t.Errorf("failed to assemble block: %v", err)
+				return
+			}
+			if err := n.engine.Seal(n.chain, block, results, stop); err != nil {
+				t.Errorf("failed to seal block: %v", err)
+			}
+		}
+		propose(n.chain.CurrentBlock())
+		for {
+			select {
+			case ev := <-heads:
+				n.engine.NewChainHead(ev.Block.Header())
+				propose(ev.Block)
+
+			case block := <-results:
+				n.insert(block)
+
+			case <-n.quit:
+				return
+			}
+		}
+	}()
+}
+
+// stop terminates the node's miner, agreement protocol and chain.
+func (n *testNode) stop() {
+	if n.quit != nil {
+		close(n.quit)
+		<-n.done
+	}
+	n.engine.Close()
+	n.chain.Stop()
+}
+
+// waitHeight waits until all the nodes imported the given block.
+func waitHeight(t *testing.T, nodes []*testNode, number uint64) {
+	deadline := time.Now().Add(20 * time.Second)
+	for _, node := range nodes {
+		for node.chain.CurrentBlock().NumberU64() < number {
+			if time.Now().After(deadline) {
+				t.Fatalf("validator %x stuck at block %d, want %d", node.addr, node.chain.CurrentBlock().NumberU64(), number)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+func newTestKeys(n int) []*ecdsa.PrivateKey {
+	keys := make([]*ecdsa.PrivateKey, n)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+	}
+	// Sort the keys by address for easier proposer order calculation
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := crypto.PubkeyToAddress(keys[i].PublicKey), crypto.PubkeyToAddress(keys[j].PublicKey)
+		return validatorsAscending{a, b}.Less(0, 1)
+	})
+	return keys
+}
+
+// Tests that a network of validators agrees on a common chain, with each block
+// committed by a qualified majority and all of them identical across nodes.
+func TestAgreement(t *testing.T) {
+	nodes := newTestNetwork(t, newTestKeys(4))
+	for _, node := range nodes {
+		node.start(t, true)
+		defer node.stop()
+	}
+	waitHeight(t, nodes, 5)
+
+	for number := uint64(1); number <= 5; number++ {
+		want := nodes[0].chain.GetBlockByNumber(number)
+		for i, node := range nodes[1:] {
+			if have := node.chain.GetBlockByNumber(number); have.Hash() != want.Hash() {
+				t.Fatalf("validator %d block %d mismatch: have %x, want %x", i+1, number, have.Hash(), want.Hash())
+			}
+		}
+		if err := nodes[0].engine.VerifyHeader(nodes[0].chain, want.Header(), true); err != nil {
+			t.Fatalf("block %d committed seals invalid: %v", number, err)
+		}
+		// Proposers should take turns in a round-robin fashion
+		author, _ := nodes[0].engine.Author(want.Header())
+		if expect := nodes[(number-1)%4].addr; author != expect {
+			t.Errorf("block %d author mismatch: have %x, want %x", number, author, expect)
+		}
+	}
+}
+
+// Tests that if a proposer fails to propose, the validators move on to the next
+// round and let the next validator in line propose instead.
+func TestRoundChange(t *testing.T) {
+	nodes := newTestNetwork(t, newTestKeys(4))
+	for i, node := range nodes {
+		node.start(t, i != 0) // first proposer participates, but never proposes
+		defer node.stop()
+	}
+	waitHeight(t, nodes, 1)
+
+	block := nodes[0].chain.GetBlockByNumber(1)
+	author, err := nodes[0].engine.Author(block.Header())
+	if err != nil {
+		t.Fatalf("failed to retrieve block author: %v", err)
+	}
+	if author != nodes[1].addr {
+		t.Fatalf("block author mismatch: have %x, want %x", author, nodes[1].addr)
+	}
+}
+
+// Tests that validators voted in through the block extra-data join the validator
+// set once a majority of the current validators voted for them.
+func TestValidatorVoting(t *testing.T) {
+	nodes := newTestNetwork(t, newTestKeys(4))
+	candidate := common.Address{0xff}
+	for _, node := range nodes {
+		api := &API{chain: node.chain, ibft: node.engine}
+		api.Propose(candidate, true)
+
+		node.start(t, true)
+		defer node.stop()
+	}
+	waitHeight(t, nodes, 3)
+
+	api := &API{chain: nodes[0].chain, ibft: nodes[0].engine}
+	validators, err := api.GetValidators(nil)
+	if err != nil {
+		t.Fatalf("failed to retrieve validators: %v", err)
+	}
+	for _, validator := range validators {
+		if validator == candidate {
+			return
+		}
+	}
+	t.Fatalf("candidate not voted in: have %x", validators)
+}
+
+// Tests that committed blocks without a qualified majority of seals are rejected.
+func TestInsufficientCommittedSeals(t *testing.T) {
+	nodes := newTestNetwork(t, newTestKeys(4))
+	for _, node := range nodes {
+		node.start(t, true)
+		defer node.stop()
+	}
+	waitHeight(t, nodes, 1)
+
+	header := nodes[0].chain.GetHeaderByNumber(1)
+	extra, err := ExtractExtra(header)
+	if err != nil {
+		t.Fatalf("failed to decode extra-data: %v", err)
+	}
+	extra.CommittedSeals = extra.CommittedSeals[:nodes[0].engine.snapshotQuorum(t, nodes[0].chain)-1]
+
+	cpy := types.CopyHeader(header)
+	if cpy.Extra, err = types.EncodeBFTExtra(header.Extra[:extraVanity], extra); err != nil {
+		t.Fatalf("failed to encode extra-data: %v", err)
+	}
+	if err := nodes[0].engine.VerifyHeader(nodes[0].chain, cpy, true); err != errInsufficientCommittedSeals {
+		t.Fatalf("error mismatch: have %v, want %v", err, errInsufficientCommittedSeals)
+	}
+}
+
+// snapshotQuorum returns the agreement quorum at the genesis block.
+func (e *Engine) snapshotQuorum(t *testing.T, chain *core.BlockChain) int {
+	genesis := chain.Genesis()
+	snap, err := e.snapshot(chain, 0, genesis.Hash(), nil)
+	if err != nil {
+		t.Fatalf("failed to retrieve genesis snapshot: %v", err)
+	}
+	return snap.quorum()
+}
+
+// Tests that neither non-validators nor single validators can crowd legitimate
+// messages out of the backlog of future messages.
+func TestBacklogSpam(t *testing.T) {
+	var (
+		keys       = newTestKeys(4)
+		validators = make(map[common.Address]struct{})
+		addrs      []common.Address
+	)
+	for _, key := range keys {
+		addr := crypto.PubkeyToAddress(key.PublicKey)
+		validators[addr] = struct{}{}
+		addrs = append(addrs, addr)
+	}
+	c := &agreement{
+		engine:       &Engine{config: &params.IBFTConfig{RequestTimeout: 1000}},
+		parent:       &types.Header{Number: big.NewInt(4)},
+		snap:         &Snapshot{Validators: validators},
+		sequence:     5,
+		prepares:     make(map[common.Address]common.Hash),
+		commits:      make(map[common.Address]*message),
+		roundChanges: make(map[uint64]map[common.Address]struct{}),
+		backlogged:   make(map[common.Address]int),
+	}
+	// Flood the backlog from a non-validator, with messages of both the next
+	// round and sequences, and from a validator
+	for i := 0; i < 2*maxBacklog; i++ {
+		c.handleMessage(&message{Code: msgPrepare, Sequence: 5, Round: 1, sender: common.Address{0xff}})
+		c.handleMessage(&message{Code: msgPrepare, Sequence: 6, sender: common.Address{0xff}})
+		c.handleMessage(&message{Code: msgPrepare, Sequence: 1000, sender: addrs[1]})
+		c.handleMessage(&message{Code: msgPrepare, Sequence: 5, Round: 1, sender: addrs[1]})
+	}
+	if len(c.backlog) != maxSenderLog {
+		t.Fatalf("backlog size mismatch: have %d, want %d", len(c.backlog), maxSenderLog)
+	}
+	// A legitimate message for the next round must still get through
+	digest := common.Hash{0x01}
+	c.handleMessage(&message{Code: msgPrepare, Sequence: 5, Round: 1, Digest: digest, sender: addrs[0]})
+
+	c.startRound(1)
+	if have := c.prepares[addrs[0]]; have != digest {
+		t.Fatalf("backlogged prepare not replayed: have %x, want %x", have, digest)
+	}
+	if _, ok := c.prepares[common.Address{0xff}]; ok {
+		t.Errorf("prepare of non-validator replayed")
+	}
+	if len(c.backlog) != 0 {
+		t.Errorf("backlog not drained: %d messages left", len(c.backlog))
+	}
+}
+
+// Tests that a block locked in an earlier round and re-proposed after a round
+// change is accepted, even though it carries the seal of the earlier proposer.
+func TestRelockedProposal(t *testing.T) {
+	keys := newTestKeys(4)
+	nodes := newTestNetwork(t, keys)
+	for _, node := range nodes {
+		defer node.stop()
+	}
+	genesis := nodes[0].chain.Genesis()
+	snap, err := nodes[0].engine.snapshot(nodes[0].chain, 0, genesis.Hash(), nil)
+	if err != nil {
+		t.Fatalf("failed to retrieve genesis snapshot: %v", err)
+	}
+	signers := make(map[common.Address]*ecdsa.PrivateKey)
+	for _, key := range keys {
+		signers[crypto.PubkeyToAddress(key.PublicKey)] = key
+	}
+	stranger, _ := crypto.GenerateKey()
+
+	// Create the block of the first round and pick a validator proposing neither
+	// in the first nor in the second round
+	var (
+		first  = signers[snap.proposer(0)]
+		second = signers[snap.proposer(1)]
+		node   *testNode
+	)
+	for _, n := range nodes {
+		if n.addr != snap.proposer(0) && n.addr != snap.proposer(1) {
+			node = n
+		}
+	}
+	locked := sealTestBlock(t, node, genesis, first)
+
+	c := &agreement{
+		engine:       node.engine,
+		chain:        node.chain,
+		verify:       node.verify,
+		parent:       genesis.Header(),
+		snap:         snap,
+		sequence:     1,
+		roundChanges: make(map[uint64]map[common.Address]struct{}),
+		backlogged:   make(map[common.Address]int),
+	}
+	c.startRound(1)
+
+	preprepare := func(block *types.Block, key *ecdsa.PrivateKey) {
+		payload, err := rlp.EncodeToBytes(block)
+		if err != nil {
+			t.Fatalf("failed to encode proposal: %v", err)
+		}
+		msg := &message{Code: msgPreprepare, Sequence: 1, Round: 1, Proposal: payload}
+		if err := msg.sign(key); err != nil {
+			t.Fatalf("failed to sign proposal: %v", err)
+		}
+		c.handleMessage(msg)
+	}
+	// Proposals of the wrong proposer or sealed by non-validators are rejected
+	preprepare(locked, first)
+	if c.state != stateAcceptRequest {
+		t.Fatalf("accepted proposal of the previous round's proposer")
+	}
+	preprepare(sealTestBlock(t, node, genesis, stranger), second)
+	if c.state != stateAcceptRequest {
+		t.Fatalf("accepted proposal sealed by non-validator")
+	}
+	// The locked block re-sent by the proposer of the new round is accepted
+	preprepare(locked, second)
+	if c.state != statePreprepared {
+		t.Fatalf("re-proposed locked block rejected")
+	}
+	if c.proposal.Hash() != locked.Hash() {
+		t.Fatalf("proposal mismatch: have %x, want %x", c.proposal.Hash(), locked.Hash())
+	}
+	if c.prepares[node.addr] != locked.Hash() {
+		t.Errorf("no prepare vote cast for re-proposed block")
+	}
+}
+
+// sealTestBlock assembles an empty block on top of the parent, sealed with the
+// given key.
+func sealTestBlock(t *testing.T, node *testNode, parent *types.Block, key *ecdsa.PrivateKey) *types.Block {
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number(), common.Big1),
+		GasLimit:   parent.GasLimit(),
+	}
+	if err := node.engine.Prepare(node.chain, header); err != nil {
+		t.Fatalf("failed to prepare header: %v", err)
+	}
+	statedb, err := node.chain.StateAt(parent.Root())
+	if err != nil {
+		t.Fatalf("failed to retrieve parent state: %v", err)
+	}
+	block, err := node.engine.FinalizeAndAssemble(node.chain, header, statedb, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to assemble block: %v", err)
+	}
+	header = block.Header()
+	extra, err := ExtractExtra(header)
+	if err != nil {
+		t.Fatalf("failed to decode extra-data: %v", err)
+	}
+	if extra.Seal, err = crypto.Sign(SealHash(header).Bytes(), key); err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	if header.Extra, err = types.EncodeBFTExtra(header.Extra[:extraVanity], extra); err != nil {
+		t.Fatalf("failed to encode extra-data: %v", err)
+	}
+	return block.WithSeal(header)
+}
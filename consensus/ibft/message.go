@@ -0,0 +1,93 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ibft
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Consensus message codes.
+const (
+	msgPreprepare  = 0x00 // Block proposal of the round's proposer
+	msgPrepare     = 0x01 // Vote of a validator accepting the round's proposal
+	msgCommit      = 0x02 // Vote of a validator committing to the prepared proposal
+	msgRoundChange = 0x03 // Request of a validator to move to a new round
+)
+
+// message is a signed consensus message exchanged between the validators.
+type message struct {
+	Code          uint64      // Type of the message
+	Sequence      uint64      // Block number being agreed on
+	Round         uint64      // Round of the agreement at the given sequence
+	Digest        common.Hash // Proposal hash being voted on (prepare and commit)
+	Proposal      []byte      // RLP encoded proposed block (preprepare only)
+	CommittedSeal []byte      // Signature over the commit hash (commit only)
+	Signature     []byte      // Signature of the sender over all the above fields
+
+	sender common.Address // Cached address of the signer
+}
+
+// sigHash returns the hash the sender signs the message with.
+func (m *message) sigHash() common.Hash {
+	blob, err := rlp.EncodeToBytes([]interface{}{m.Code, m.Sequence, m.Round, m.Digest, m.Proposal, m.CommittedSeal})
+	if err != nil {
+		panic("can't encode: " + err.Error())
+	}
+	return crypto.Keccak256Hash(blob)
+}
+
+// sign signs the message with the given key.
+func (m *message) sign(key *ecdsa.PrivateKey) error {
+	sig, err := crypto.Sign(m.sigHash().Bytes(), key)
+	if err != nil {
+		return err
+	}
+	m.Signature, m.sender = sig, crypto.PubkeyToAddress(key.PublicKey)
+	return nil
+}
+
+// decodeMessage parses a signed consensus message and recovers its sender.
+func decodeMessage(payload []byte) (*message, error) {
+	msg := new(message)
+	if err := rlp.DecodeBytes(payload, msg); err != nil {
+		return nil, err
+	}
+	if msg.Code > msgRoundChange {
+		return nil, fmt.Errorf("%w: unknown code %d", errInvalidMessage, msg.Code)
+	}
+	pubkey, err := crypto.SigToPub(msg.sigHash().Bytes(), msg.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidMessage, err)
+	}
+	msg.sender = crypto.PubkeyToAddress(*pubkey)
+	return msg, nil
+}
+
+// proposal decodes the block proposed in a preprepare message.
+func (m *message) proposal() (*types.Block, error) {
+	block := new(types.Block)
+	if err := rlp.DecodeBytes(m.Proposal, block); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidMessage, err)
+	}
+	return block, nil
+}
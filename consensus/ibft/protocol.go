@@ -0,0 +1,162 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ibft
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// Constants to match up protocol versions and messages.
+const (
+	protocolName    = "ibft"
+	protocolVersion = 1
+	protocolLength  = 1
+
+	consensusMsg = 0x00 // Signed consensus message, gossiped to all peers
+)
+
+const (
+	maxMessageSize    = 10 * 1024 * 1024 // Maximum cap on the size of a protocol message (proposals carry full blocks)
+	maxSeenMessages   = 4096             // Maximum number of message hashes to remember for deduplication
+	maxKnownMessages  = 1024             // Maximum number of message hashes to remember per peer
+	maxQueuedMessages = 256              // Maximum number of messages to queue up for a peer before dropping
+)
+
+var (
+	errMsgTooLarge    = errors.New("message too long")
+	errInvalidMsgCode = errors.New("invalid message code")
+	errAlreadyPeered  = errors.New("peer already registered")
+)
+
+// peer is a remote node running the consensus sub-protocol.
+type peer struct {
+	id    string
+	rw    p2p.MsgReadWriter
+	known *lru.Cache    // Hashes of messages the peer is known to have
+	queue chan []byte   // Messages waiting to be sent to the peer
+	term  chan struct{} // Termination channel to stop the broadcaster
+}
+
+// broadcastLoop sends the queued messages to the remote peer until it disconnects.
+func (p *peer) broadcastLoop() {
+	for {
+		select {
+		case payload := <-p.queue:
+			if err := p2p.Send(p.rw, consensusMsg, payload); err != nil {
+				return
+			}
+		case <-p.term:
+			return
+		}
+	}
+}
+
+// runPeer is the p2p protocol run function, registering the peer for gossiping
+// and feeding its messages into the agreement protocol.
+func (e *Engine) runPeer(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+	known, _ := lru.New(maxKnownMessages)
+	peer := &peer{
+		id:    p.ID().String(),
+		rw:    rw,
+		known: known,
+		queue: make(chan []byte, maxQueuedMessages),
+		term:  make(chan struct{}),
+	}
+	e.peerLock.Lock()
+	if _, ok := e.peers[peer.id]; ok {
+		e.peerLock.Unlock()
+		return errAlreadyPeered
+	}
+	e.peers[peer.id] = peer
+	e.peerLock.Unlock()
+
+	defer func() {
+		e.peerLock.Lock()
+		delete(e.peers, peer.id)
+		e.peerLock.Unlock()
+		close(peer.term)
+	}()
+	go peer.broadcastLoop()
+
+	for {
+		msg, err := rw.ReadMsg()
+		if err != nil {
+			return err
+		}
+		if msg.Size > maxMessageSize {
+			msg.Discard()
+			return fmt.Errorf("%w: %v > %v", errMsgTooLarge, msg.Size, maxMessageSize)
+		}
+		if msg.Code != consensusMsg {
+			msg.Discard()
+			return fmt.Errorf("%w: %v", errInvalidMsgCode, msg.Code)
+		}
+		var payload []byte
+		if err := msg.Decode(&payload); err != nil {
+			return fmt.Errorf("%w: %v", errInvalidMessage, err)
+		}
+		hash := crypto.Keccak256Hash(payload)
+		peer.known.Add(hash, struct{}{})
+		if seen, _ := e.seen.ContainsOrAdd(hash, struct{}{}); seen {
+			continue
+		}
+		cmsg, err := decodeMessage(payload)
+		if err != nil {
+			log.Debug("Dropping peer with invalid consensus message", "peer", peer.id, "err", err)
+			return err
+		}
+		// Relay the message to the rest of the network and process it locally
+		e.gossip(payload)
+
+		e.lock.RLock()
+		core := e.core
+		e.lock.RUnlock()
+
+		if core != nil {
+			core.post(cmsg)
+		}
+	}
+}
+
+// gossip queues a consensus message for sending to all peers not yet known to
+// have it. Peers with full queues miss the message, the round change mechanism
+// recovers from any losses.
+func (e *Engine) gossip(payload []byte) {
+	hash := crypto.Keccak256Hash(payload)
+	e.seen.Add(hash, struct{}{})
+
+	e.peerLock.RLock()
+	defer e.peerLock.RUnlock()
+
+	for _, peer := range e.peers {
+		if peer.known.Contains(hash) {
+			continue
+		}
+		select {
+		case peer.queue <- payload:
+			peer.known.Add(hash, struct{}{})
+		default:
+			log.Debug("Dropping consensus message for slow peer", "peer", peer.id)
+		}
+	}
+}
@@ -18,6 +18,7 @@
 package asm
 
 import (
+	"bytes"
 	"encoding/hex"
 	"fmt"
 
@@ -99,22 +100,43 @@ func (it *instructionIterator) Arg() []byte {
 	return it.arg
 }
 
-// Pretty-print all disassembled EVM instructions to stdout.
+// Pretty-print all disassembled EVM instructions to stdout, in a format
+// that can be assembled again.
 func PrintDisassembled(code string) error {
 	script, err := hex.DecodeString(code)
 	if err != nil {
 		return err
 	}
+	fmt.Print(DisassembleSource(script))
+	return nil
+}
 
-	it := NewInstructionIterator(script)
+// Return the disassembled EVM instructions as assembler source, which the
+// Compiler assembles back into the same code. Pushes keep their width, and
+// bytes not forming instructions, such as data sections or a truncated push
+// at the end, are emitted as raw data. The position of each instruction is
+// noted in a comment.
+func DisassembleSource(script []byte) string {
+	var (
+		out bytes.Buffer
+		it  = NewInstructionIterator(script)
+	)
 	for it.Next() {
-		if it.Arg() != nil && 0 < len(it.Arg()) {
-			fmt.Printf("%05x: %v 0x%x\n", it.PC(), it.Op(), it.Arg())
-		} else {
-			fmt.Printf("%05x: %v\n", it.PC(), it.Op())
+		var instr string
+		switch op := it.Op(); {
+		case op.IsPush():
+			instr = fmt.Sprintf("%v 0x%x", op, it.Arg())
+		case vm.StringToOp(op.String()) == op:
+			instr = op.String()
+		default:
+			instr = fmt.Sprintf("0x%02x", byte(op))
 		}
+		fmt.Fprintf(&out, "%-16s ;; %05x\n", instr, it.PC())
+	}
+	if it.Error() != nil {
+		fmt.Fprintf(&out, "%-16s ;; %05x\n", fmt.Sprintf("0x%x", script[it.PC():]), it.PC())
 	}
-	return it.Error()
+	return out.String()
 }
 
 // Return all disassembled EVM instructions in human-readable format.
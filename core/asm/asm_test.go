@@ -72,3 +72,30 @@ func TestInstructionIteratorEmpty(t *testing.T) {
 		t.Errorf("Expected 0, but got %v instead.", cnt)
 	}
 }
+
+// Tests that disassembled code assembles back into the same code
+func TestDisassembleRoundTrip(t *testing.T) {
+	tests := []string{
+		"",
+		"6060604052600436106100",
+		"5b61000156600c0d0e5bfe", // undefined opcodes
+		"7f" + "00000000000000000000000000000000000000000000000000000000000000ff" + "00",
+		"600160026300", // truncated push
+		"b0b1b2",       // pseudo instructions
+	}
+	for _, test := range tests {
+		code, _ := hex.DecodeString(test)
+		source := DisassembleSource(code)
+
+		c := NewCompiler(false)
+		c.Feed(Lex([]byte(source), false))
+		output, errs := c.Compile()
+		if len(errs) != 0 {
+			t.Errorf("code %s: failed to assemble disassembly: %v\n%s", test, errs, source)
+			continue
+		}
+		if output != test {
+			t.Errorf("code %s: round trip mismatch: have %s\n%s", test, output, source)
+		}
+	}
+}
@@ -18,10 +18,12 @@ package asm
 
 import (
 	"fmt"
+	"io/ioutil"
 	"math/big"
 	"os"
 	"strings"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/core/vm"
 )
@@ -31,60 +33,71 @@ import (
 type Compiler struct {
 	tokens []token
 	binary []interface{}
+	errors []error // errors found while preprocessing
 
 	labels map[string]int
+	pushes []*labelPush
 
-	pc, pos int
+	constants  map[string]token
+	macros     map[string]*macro
+	expansions int
+	include    func(name string) ([]byte, error)
+
+	pos int
 
 	debug bool
 }
 
+// labelPush is a push of a label's position to the binary
+// stack. Unless its width is fixed, the push is sized to
+// fit the position once all labels have been placed.
+type labelPush struct {
+	label token
+	size  int  // push width in bytes
+	fixed bool // whether the width was set explicitly
+}
+
+// labelMarker marks the position of a label definition in
+// the binary stack.
+type labelMarker string
+
 // newCompiler returns a new allocated compiler.
 func NewCompiler(debug bool) *Compiler {
 	return &Compiler{
-		labels: make(map[string]int),
-		debug:  debug,
+		labels:    make(map[string]int),
+		constants: make(map[string]token),
+		macros:    make(map[string]*macro),
+		include:   ioutil.ReadFile,
+		debug:     debug,
 	}
 }
 
+// SetIncludeLoader sets the function used to load the files
+// requested by #include directives. By default files are read
+// relative to the working directory.
+func (c *Compiler) SetIncludeLoader(loader func(name string) ([]byte, error)) {
+	c.include = loader
+}
+
 // Feed feeds tokens in to ch and are interpreted by
 // the compiler.
 //
 // feed is the first pass in the compile stage as it
-// collects the used labels in the program and keeps a
-// program counter which is used to determine the locations
-// of the jump dests. The labels can than be used in the
-// second stage to push labels and determine the right
-// position.
+// runs the preprocessor over the source, expanding the
+// includes, constants and macros into plain instructions.
+// The labels are placed in the second stage, once the
+// size of all instructions is known.
 func (c *Compiler) Feed(ch <-chan token) {
-	var prev token
-	for i := range ch {
-		switch i.typ {
-		case number:
-			num := math.MustParseBig256(i.text).Bytes()
-			if len(num) == 0 {
-				num = []byte{0}
-			}
-			c.pc += len(num)
-		case stringValue:
-			c.pc += len(i.text) - 2
-		case element:
-			c.pc++
-		case labelDef:
-			c.labels[i.text] = c.pc
-			c.pc++
-		case label:
-			c.pc += 4
-			if prev.typ == element && isJump(prev.text) {
-				c.pc++
-			}
-		}
+	c.preprocess(readLines(ch), 0)
 
-		c.tokens = append(c.tokens, i)
-		prev = i
-	}
 	if c.debug {
-		fmt.Fprintln(os.Stderr, "found", len(c.labels), "labels")
+		var labels int
+		for _, t := range c.tokens {
+			if t.typ == labelDef {
+				labels++
+			}
+		}
+		fmt.Fprintln(os.Stderr, "found", labels, "labels")
 	}
 }
 
@@ -95,7 +108,7 @@ func (c *Compiler) Feed(ch <-chan token) {
 // compile is the second stage in the compile phase
 // which compiles the tokens to EVM instructions.
 func (c *Compiler) Compile() (string, []error) {
-	var errors []error
+	errors := c.errors
 	// continue looping over the tokens until
 	// the stack has been exhausted.
 	for c.pos < len(c.tokens) {
@@ -103,6 +116,7 @@ func (c *Compiler) Compile() (string, []error) {
 			errors = append(errors, err)
 		}
 	}
+	errors = append(errors, c.placeLabels()...)
 
 	// turn the binary to hex
 	var bin string
@@ -112,11 +126,59 @@ func (c *Compiler) Compile() (string, []error) {
 			bin += fmt.Sprintf("%x", []byte{byte(v)})
 		case []byte:
 			bin += fmt.Sprintf("%x", v)
+		case *labelPush:
+			pos := big.NewInt(int64(c.labels[v.label.text])).Bytes()
+			pos = append(make([]byte, v.size-len(pos)), pos...)
+			bin += fmt.Sprintf("%x%x", []byte{byte(vm.PUSH1) - 1 + byte(v.size)}, pos)
 		}
 	}
 	return bin, errors
 }
 
+// placeLabels assigns the positions of all labels. Label
+// pushes start out at one byte and are widened until all
+// the positions fit, which only ever moves labels forward
+// and thus terminates.
+func (c *Compiler) placeLabels() []error {
+	for {
+		pc := 0
+		for _, v := range c.binary {
+			switch v := v.(type) {
+			case labelMarker:
+				c.labels[string(v)] = pc
+			case vm.OpCode:
+				pc++
+			case []byte:
+				pc += len(v)
+			case *labelPush:
+				pc += 1 + v.size
+			}
+		}
+		var grown bool
+		for _, push := range c.pushes {
+			if pos, ok := c.labels[push.label.text]; ok && !push.fixed {
+				if size := len(big.NewInt(int64(pos)).Bytes()); size > push.size {
+					push.size, grown = size, true
+				}
+			}
+		}
+		if !grown {
+			break
+		}
+	}
+	var errors []error
+	for _, push := range c.pushes {
+		pos, ok := c.labels[push.label.text]
+		switch {
+		case !ok:
+			errors = append(errors, fmt.Errorf("%d label error: undefined label %s", push.label.lineno, push.label.text))
+		case len(big.NewInt(int64(pos)).Bytes()) > push.size:
+			errors = append(errors, fmt.Errorf("%d label error: position of %s exceeds PUSH%d", push.label.lineno, push.label.text, push.size))
+		}
+	}
+	return errors
+}
+
 // next returns the next token and increments the
 // position.
 func (c *Compiler) next() token {
@@ -139,31 +201,37 @@ func (c *Compiler) compileLine() error {
 		return nil
 	case element:
 		if err := c.compileElement(lvalue); err != nil {
+			c.skipLine()
 			return err
 		}
 	case labelDef:
-		c.compileLabel()
+		if err := c.compileLabel(lvalue); err != nil {
+			c.skipLine()
+			return err
+		}
+	case number, stringValue:
+		c.pushBin(rawBytes(lvalue))
 	case lineEnd:
 		return nil
 	default:
+		c.skipLine()
 		return compileErr(lvalue, lvalue.text, fmt.Sprintf("%v or %v", labelDef, element))
 	}
 
 	if n := c.next(); n.typ != lineEnd {
+		c.skipLine()
 		return compileErr(n, n.text, lineEnd.String())
 	}
 
 	return nil
 }
 
-// compileNumber compiles the number to bytes
-func (c *Compiler) compileNumber(element token) (int, error) {
-	num := math.MustParseBig256(element.text).Bytes()
-	if len(num) == 0 {
-		num = []byte{0}
+// skipLine advances the position past the end of the
+// current line, so compilation can resume after an error.
+func (c *Compiler) skipLine() {
+	for c.pos < len(c.tokens) && c.tokens[c.pos-1].typ != lineEnd {
+		c.pos++
 	}
-	c.pushBin(num)
-	return len(num), nil
 }
 
 // compileElement compiles the element (push & label or both)
@@ -173,63 +241,102 @@ func (c *Compiler) compileElement(element token) error {
 	// check for a jump. jumps must be read and compiled
 	// from right to left.
 	if isJump(element.text) {
-		rvalue := c.next()
-		switch rvalue.typ {
-		case number:
-			// TODO figure out how to return the error properly
-			c.compileNumber(rvalue)
-		case stringValue:
-			// strings are quoted, remove them.
-			c.pushBin(rvalue.text[1 : len(rvalue.text)-2])
-		case label:
-			c.pushBin(vm.PUSH4)
-			pos := big.NewInt(int64(c.labels[rvalue.text])).Bytes()
-			pos = append(make([]byte, 4-len(pos)), pos...)
-			c.pushBin(pos)
-		case lineEnd:
+		if rvalue := c.next(); rvalue.typ == lineEnd {
 			c.pos--
-		default:
-			return compileErr(rvalue, rvalue.text, "number, string or label")
+		} else if err := c.compilePush(rvalue, 0); err != nil {
+			return err
 		}
 		// push the operation
 		c.pushBin(toBinary(element.text))
 		return nil
-	} else if isPush(element.text) {
-		// handle pushes. pushes are read from left to right.
-		var value []byte
-
-		rvalue := c.next()
-		switch rvalue.typ {
-		case number:
-			value = math.MustParseBig256(rvalue.text).Bytes()
-			if len(value) == 0 {
-				value = []byte{0}
-			}
-		case stringValue:
-			value = []byte(rvalue.text[1 : len(rvalue.text)-1])
-		case label:
-			value = big.NewInt(int64(c.labels[rvalue.text])).Bytes()
-			value = append(make([]byte, 4-len(value)), value...)
-		default:
-			return compileErr(rvalue, rvalue.text, "number, string or label")
-		}
+	}
+	// handle pushes. pushes are read from left to right,
+	// sized to fit their value unless the width is given.
+	if isPush(element.text) {
+		return c.compilePush(c.next(), 0)
+	}
+	if op := toBinary(element.text); op.IsPush() && c.tokens[c.pos].typ != lineEnd {
+		return c.compilePush(c.next(), int(op-vm.PUSH1)+1)
+	}
+	if !isInstruction(element.text) {
+		return fmt.Errorf("%d syntax error: unknown instruction %s", element.lineno, element.text)
+	}
+	c.pushBin(toBinary(element.text))
+	return nil
+}
 
-		if len(value) > 32 {
-			return fmt.Errorf("%d type error: unsupported string or number with size > 32", rvalue.lineno)
+// compilePush compiles a push of the value. Pushes of a
+// fixed width are padded to size, otherwise the smallest
+// push fitting the value is used.
+func (c *Compiler) compilePush(rvalue token, size int) error {
+	var value []byte
+
+	switch rvalue.typ {
+	case number:
+		value = math.MustParseBig256(rvalue.text).Bytes()
+		if len(value) == 0 {
+			value = []byte{0}
 		}
-
-		c.pushBin(vm.OpCode(int(vm.PUSH1) - 1 + len(value)))
-		c.pushBin(value)
-	} else {
-		c.pushBin(toBinary(element.text))
+	case stringValue:
+		value = []byte(rvalue.text[1 : len(rvalue.text)-1])
+	case label:
+		push := &labelPush{label: rvalue, size: size, fixed: size > 0}
+		if !push.fixed {
+			push.size = 1
+		}
+		c.pushes = append(c.pushes, push)
+		c.pushBin(push)
+		return nil
+	default:
+		return compileErr(rvalue, rvalue.text, "number, string or label")
 	}
 
+	if len(value) > 32 {
+		return fmt.Errorf("%d type error: unsupported string or number with size > 32", rvalue.lineno)
+	}
+	if size > 0 {
+		if len(value) > size {
+			return fmt.Errorf("%d type error: value %s exceeds PUSH%d", rvalue.lineno, rvalue.text, size)
+		}
+		value = append(make([]byte, size-len(value)), value...)
+	}
+	c.pushBin(vm.OpCode(int(vm.PUSH1) - 1 + len(value)))
+	c.pushBin(value)
 	return nil
 }
 
-// compileLabel pushes a jumpdest to the binary slice.
-func (c *Compiler) compileLabel() {
+// compileLabel marks the position of the label and pushes
+// a jumpdest to the binary slice.
+func (c *Compiler) compileLabel(def token) error {
+	for _, v := range c.binary {
+		if v == labelMarker(def.text) {
+			return fmt.Errorf("%d label error: label %s already defined", def.lineno, def.text)
+		}
+	}
+	c.pushBin(labelMarker(def.text))
 	c.pushBin(vm.JUMPDEST)
+	return nil
+}
+
+// rawBytes returns the bytes of a number or string to be
+// placed into the binary verbatim. Hexadecimal numbers keep
+// their leading zeroes.
+func rawBytes(t token) []byte {
+	if t.typ == stringValue {
+		return []byte(t.text[1 : len(t.text)-1])
+	}
+	if strings.HasPrefix(t.text, "0x") || strings.HasPrefix(t.text, "0X") {
+		digits := t.text[2:]
+		if len(digits)%2 == 1 {
+			digits = "0" + digits
+		}
+		return common.FromHex(digits)
+	}
+	num := math.MustParseBig256(t.text).Bytes()
+	if len(num) == 0 {
+		num = []byte{0}
+	}
+	return num
 }
 
 // pushBin pushes the value v to the binary stack.
@@ -251,6 +358,12 @@ func isJump(op string) bool {
 	return strings.ToUpper(op) == "JUMPI" || strings.ToUpper(op) == "JUMP"
 }
 
+// isInstruction returns whether the string op is the name of an
+// instruction, rather than e.g. a misspelling of one.
+func isInstruction(op string) bool {
+	return toBinary(op) != vm.STOP || strings.ToUpper(op) == "STOP"
+}
+
 // toBinary converts text to a vm.OpCode
 func toBinary(text string) vm.OpCode {
 	return vm.StringToOp(strings.ToUpper(text))
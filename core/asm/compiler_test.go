@@ -17,6 +17,7 @@
 package asm
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -30,14 +31,14 @@ func TestCompiler(t *testing.T) {
 	label:
 	PUSH @label
 `,
-			output: "5a5b6300000001",
+			output: "5a5b6001",
 		},
 		{
 			input: `
 	PUSH @label
 	label:
 `,
-			output: "63000000055b",
+			output: "60025b",
 		},
 		{
 			input: `
@@ -45,15 +46,63 @@ func TestCompiler(t *testing.T) {
 	JUMP
 	label:
 `,
-			output: "6300000006565b",
+			output: "6003565b",
 		},
 		{
 			input: `
 	JUMP @label
 	label:
+`,
+			output: "6003565b",
+		},
+		// Label pushes of an explicit width
+		{
+			input: `
+	GAS
+	label:
+	PUSH4 @label
+`,
+			output: "5a5b6300000001",
+		},
+		{
+			input: `
+	PUSH4 @label
+	label:
+`,
+			output: "63000000055b",
+		},
+		{
+			input: `
+	PUSH4 @label
+	JUMP
+	label:
 `,
 			output: "6300000006565b",
 		},
+		// Label pushes growing past a byte move the labels after them
+		{
+			input:  "\tJUMP @end\n" + strings.Repeat("\tGAS\n", 254) + "\tend:\n",
+			output: "610102" + "56" + strings.Repeat("5a", 254) + "5b",
+		},
+		// Pushes of numbers and strings, with and without a width
+		{
+			input: `
+	PUSH 0x0001
+	PUSH2 1
+	PUSH "ab"
+	JUMPI 0
+`,
+			output: "6001610001616162600057",
+		},
+		// Raw data and comments
+		{
+			input: `
+	STOP ;; halts
+	0x000c
+	"ab"
+`,
+			output: "00000c6162",
+		},
 	}
 	for _, test := range tests {
 		ch := Lex([]byte(test.input), false)
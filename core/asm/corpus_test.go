@@ -0,0 +1,94 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package asm
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm/runtime"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// compileFile assembles a program of the test corpus.
+func compileFile(t *testing.T, name string) []byte {
+	t.Helper()
+
+	src, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", name, err)
+	}
+	c := NewCompiler(false)
+	c.SetIncludeLoader(func(include string) ([]byte, error) {
+		return ioutil.ReadFile(filepath.Join("testdata", include))
+	})
+	c.Feed(Lex(src, false))
+	bin, errs := c.Compile()
+	if len(errs) != 0 {
+		t.Fatalf("failed to compile %s: %v", name, errs)
+	}
+	code, _ := hex.DecodeString(bin)
+	return code
+}
+
+// word encodes a number as a 32 byte word.
+func word(n *big.Int) []byte {
+	return common.LeftPadBytes(n.Bytes(), 32)
+}
+
+// Tests that the programs of the test corpus compute their results when
+// executed, both as assembled and as reassembled from their disassembly.
+func TestCorpus(t *testing.T) {
+	input := []byte("the quick brown fox jumps over the lazy dog")
+	base, exponent, modulus := big.NewInt(3), big.NewInt(1000003), new(big.Int).SetBytes(crypto.Keccak256(input))
+
+	tests := []struct {
+		file   string
+		input  []byte
+		output []byte
+	}{
+		{"identity.easm", input, input},
+		{"identity.easm", nil, nil},
+		{"keccak.easm", input, crypto.Keccak256(input)},
+		{"modexp.easm", append(append(word(base), word(exponent)...), word(modulus)...), word(new(big.Int).Exp(base, exponent, modulus))},
+		{"modexp.easm", append(append(word(base), word(big.NewInt(0))...), word(big.NewInt(1))...), word(big.NewInt(0))},
+		{"modexp.easm", append(append(word(base), word(big.NewInt(0))...), word(big.NewInt(7))...), word(big.NewInt(1))},
+	}
+	for _, test := range tests {
+		code := compileFile(t, test.file)
+
+		c := NewCompiler(false)
+		c.Feed(Lex([]byte(DisassembleSource(code)), false))
+		bin, errs := c.Compile()
+		if len(errs) != 0 || bin != hex.EncodeToString(code) {
+			t.Errorf("%s: disassembly round trip mismatch: %v", test.file, errs)
+		}
+		output, _, err := runtime.Execute(code, test.input, nil)
+		if err != nil {
+			t.Errorf("%s: execution failed: %v", test.file, err)
+			continue
+		}
+		if !bytes.Equal(output, test.output) {
+			t.Errorf("%s: output mismatch: have %x, want %x", test.file, output, test.output)
+		}
+	}
+}
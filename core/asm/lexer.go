@@ -52,6 +52,12 @@ const (
 	labelDef                          // label definition is emitted when a new label is found
 	number                            // number is emitted when a number is found
 	stringValue                       // stringValue is emitted when a string has been found
+	directive                         // directive is emitted when a preprocessor directive is found
+	macroCall                         // macroCall is emitted when a macro invocation is found
+	param                             // param is emitted when a macro parameter reference is found
+	openParen                         // openParen is emitted when an opening parenthesis is found
+	closeParen                        // closeParen is emitted when a closing parenthesis is found
+	comma                             // comma is emitted when an argument separator is found
 
 	Numbers            = "1234567890"                                           // characters representing any decimal number
 	HexadecimalNumbers = Numbers + "aAbBcCdDeEfF"                               // characters representing any hexadecimal
@@ -76,6 +82,12 @@ var stringtokenTypes = []string{
 	labelDef:         "label definition",
 	number:           "number",
 	stringValue:      "string",
+	directive:        "directive",
+	macroCall:        "macro call",
+	param:            "macro parameter",
+	openParen:        "(",
+	closeParen:       ")",
+	comma:            ",",
 }
 
 // lexer is the basic construct for parsing
@@ -215,6 +227,20 @@ func lexLine(l *lexer) stateFn {
 			return lexLabel
 		case r == '"':
 			return lexInsideString
+		case r == '#':
+			return lexDirective
+		case r == '%':
+			l.ignore()
+			return lexMacroCall
+		case r == '$':
+			l.ignore()
+			return lexParam
+		case r == '(':
+			l.emit(openParen)
+		case r == ')':
+			l.emit(closeParen)
+		case r == ',':
+			l.emit(comma)
 		default:
 			return nil
 		}
@@ -222,9 +248,12 @@ func lexLine(l *lexer) stateFn {
 }
 
 // lexComment parses the current position until the end
-// of the line and discards the text.
+// of the line and discards the text. The line end itself
+// is left for lexLine to emit.
 func lexComment(l *lexer) stateFn {
-	l.acceptRunUntil('\n')
+	if l.acceptRunUntil('\n') {
+		l.backup()
+	}
 	l.ignore()
 
 	return lexLine
@@ -241,6 +270,34 @@ func lexLabel(l *lexer) stateFn {
 	return lexLine
 }
 
+// lexDirective parses a preprocessor directive such as
+// #define, emitting it along with the leading hash.
+func lexDirective(l *lexer) stateFn {
+	l.acceptRun(Alpha)
+
+	l.emit(directive)
+
+	return lexLine
+}
+
+// lexMacroCall parses the name of an invoked macro.
+func lexMacroCall(l *lexer) stateFn {
+	l.acceptRun(Alpha + "_" + Numbers)
+
+	l.emit(macroCall)
+
+	return lexLine
+}
+
+// lexParam parses a reference to a macro parameter.
+func lexParam(l *lexer) stateFn {
+	l.acceptRun(Alpha + "_" + Numbers)
+
+	l.emit(param)
+
+	return lexLine
+}
+
 // lexInsideString lexes the inside of a string until
 // the state function finds the closing quote.
 // It returns the lex text state function.
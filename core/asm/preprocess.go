@@ -0,0 +1,305 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package asm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxExpansionDepth is the maximum nesting of includes and macro
+// invocations, guarding against recursive definitions.
+const maxExpansionDepth = 64
+
+// line is a single source line, without the line start and end.
+type line struct {
+	lineno int
+	tokens []token
+}
+
+// macro is a named sequence of lines with parameters, such as:
+//
+//   #macro store(slot, value)
+//       PUSH $value
+//       PUSH $slot
+//       SSTORE
+//   #end
+//
+// which is invoked by "%store(0x01, 42)". Labels defined within
+// a macro are local to each invocation.
+type macro struct {
+	params []string
+	body   []line
+	locals map[string]bool
+}
+
+// readLines collects the tokens delivered by the lexer into lines,
+// dropping the empty ones.
+func readLines(ch <-chan token) []line {
+	var (
+		lines   []line
+		current *line
+	)
+	for t := range ch {
+		switch t.typ {
+		case lineStart:
+			current = &line{lineno: t.lineno}
+		case lineEnd, eof:
+			if current != nil && len(current.tokens) > 0 {
+				lines = append(lines, *current)
+			}
+			current = nil
+		default:
+			if current != nil {
+				current.tokens = append(current.tokens, t)
+			}
+		}
+	}
+	return lines
+}
+
+// preprocess runs the directives and expands the macro invocations of
+// the lines, appending the resulting instructions to the token stream.
+func (c *Compiler) preprocess(lines []line, depth int) {
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		switch first := l.tokens[0]; {
+		case first.typ == directive && first.text == "#define":
+			c.define(l)
+
+		case first.typ == directive && first.text == "#include":
+			c.includeFile(l, depth)
+
+		case first.typ == directive && first.text == "#macro":
+			end := i + 1
+			for ; end < len(lines); end++ {
+				if t := lines[end].tokens[0]; t.typ == directive && (t.text == "#end" || t.text == "#macro") {
+					break
+				}
+			}
+			if end == len(lines) || lines[end].tokens[0].text != "#end" {
+				c.errorf(first, "macro without #end")
+				return
+			}
+			c.defineMacro(l, lines[i+1:end])
+			i = end
+
+		case first.typ == directive:
+			c.errorf(first, "unexpected directive %s", first.text)
+
+		case first.typ == macroCall:
+			c.expand(l, depth)
+
+		default:
+			c.tokens = append(c.tokens, token{typ: lineStart, lineno: l.lineno})
+			for j, t := range l.tokens {
+				if j > 0 {
+					t = c.substitute(t)
+				}
+				if t.typ == param {
+					c.errorf(t, "parameter $%s used outside of a macro", t.text)
+				}
+				c.tokens = append(c.tokens, t)
+			}
+			c.tokens = append(c.tokens, token{typ: lineEnd, lineno: l.lineno})
+		}
+	}
+}
+
+// define handles "#define NAME value", declaring a constant that can be
+// used in place of a number, string or label.
+func (c *Compiler) define(l line) {
+	if len(l.tokens) != 3 || l.tokens[1].typ != element {
+		c.errorf(l.tokens[0], "expected #define NAME value")
+		return
+	}
+	name, value := l.tokens[1].text, c.substitute(l.tokens[2])
+	switch {
+	case isPush(name) || isJump(name) || isInstruction(name):
+		c.errorf(l.tokens[1], "constant %s shadows an instruction", name)
+	case c.defined(name):
+		c.errorf(l.tokens[1], "constant %s already defined", name)
+	case value.typ != number && value.typ != stringValue && value.typ != label:
+		c.errorf(value, "invalid value %s for constant %s", value.text, name)
+	default:
+		c.constants[name] = value
+	}
+}
+
+// defined returns whether a constant of the given name exists.
+func (c *Compiler) defined(name string) bool {
+	_, ok := c.constants[name]
+	return ok
+}
+
+// substitute replaces a reference to a constant by its value.
+func (c *Compiler) substitute(t token) token {
+	if t.typ != element {
+		return t
+	}
+	if value, ok := c.constants[t.text]; ok {
+		value.lineno = t.lineno
+		return value
+	}
+	return t
+}
+
+// includeFile handles `#include "file"`, preprocessing the source of the
+// named file in place of the directive.
+func (c *Compiler) includeFile(l line, depth int) {
+	if len(l.tokens) != 2 || l.tokens[1].typ != stringValue {
+		c.errorf(l.tokens[0], `expected #include "file"`)
+		return
+	}
+	if depth >= maxExpansionDepth {
+		c.errorf(l.tokens[0], "includes nested too deep")
+		return
+	}
+	name := strings.Trim(l.tokens[1].text, `"`)
+	src, err := c.include(name)
+	if err != nil {
+		c.errorf(l.tokens[0], "failed to include %s: %v", name, err)
+		return
+	}
+	c.preprocess(readLines(Lex(src, c.debug)), depth+1)
+}
+
+// defineMacro handles "#macro name(param, ...)", recording the lines up to
+// the closing "#end" as the body of the macro.
+func (c *Compiler) defineMacro(l line, body []line) {
+	if len(l.tokens) < 2 || l.tokens[1].typ != element {
+		c.errorf(l.tokens[0], "expected #macro name(params)")
+		return
+	}
+	name := l.tokens[1].text
+	if _, ok := c.macros[name]; ok {
+		c.errorf(l.tokens[1], "macro %s already defined", name)
+		return
+	}
+	params, ok := c.arguments(l.tokens[2:])
+	if !ok {
+		c.errorf(l.tokens[1], "invalid parameter list of macro %s", name)
+		return
+	}
+	m := &macro{body: body, locals: make(map[string]bool)}
+	for _, p := range params {
+		if p.typ != element {
+			c.errorf(p, "invalid parameter %s of macro %s", p.text, name)
+			return
+		}
+		m.params = append(m.params, p.text)
+	}
+	for _, l := range body {
+		if l.tokens[0].typ == labelDef {
+			m.locals[l.tokens[0].text] = true
+		}
+	}
+	c.macros[name] = m
+}
+
+// expand handles "%name(arg, ...)", preprocessing the body of the macro with
+// the parameters replaced by the arguments.
+func (c *Compiler) expand(l line, depth int) {
+	call := l.tokens[0]
+	m, ok := c.macros[call.text]
+	if !ok {
+		c.errorf(call, "undefined macro %s", call.text)
+		return
+	}
+	if depth >= maxExpansionDepth {
+		c.errorf(call, "macro %s nested too deep", call.text)
+		return
+	}
+	args, ok := c.arguments(l.tokens[1:])
+	if !ok {
+		c.errorf(call, "invalid arguments to macro %s", call.text)
+		return
+	}
+	if len(args) != len(m.params) {
+		c.errorf(call, "macro %s takes %d arguments, got %d", call.text, len(m.params), len(args))
+		return
+	}
+	values := make(map[string]token)
+	for i, arg := range args {
+		values[m.params[i]] = c.substitute(arg)
+	}
+	// Give the local labels names unique to the invocation, which
+	// can't clash with any label in the source.
+	c.expansions++
+	suffix := fmt.Sprintf("#%s.%d", call.text, c.expansions)
+
+	body := make([]line, len(m.body))
+	for i, l := range m.body {
+		body[i] = line{lineno: call.lineno, tokens: make([]token, len(l.tokens))}
+		for j, t := range l.tokens {
+			t.lineno = call.lineno
+			switch {
+			case t.typ == param:
+				value, ok := values[t.text]
+				if !ok {
+					c.errorf(t, "undefined parameter $%s of macro %s", t.text, call.text)
+					return
+				}
+				t = value
+				t.lineno = call.lineno
+			case (t.typ == label || t.typ == labelDef) && m.locals[t.text]:
+				t.text += suffix
+			}
+			body[i].tokens[j] = t
+		}
+	}
+	c.preprocess(body, depth+1)
+}
+
+// arguments parses an optional parenthesized, comma separated list of single
+// token arguments.
+func (c *Compiler) arguments(tokens []token) ([]token, bool) {
+	if len(tokens) == 0 {
+		return nil, true
+	}
+	if tokens[0].typ != openParen || tokens[len(tokens)-1].typ != closeParen {
+		return nil, false
+	}
+	tokens = tokens[1 : len(tokens)-1]
+	if len(tokens) == 0 {
+		return nil, true
+	}
+	var args []token
+	for i, t := range tokens {
+		if i%2 == 1 {
+			if t.typ != comma {
+				return nil, false
+			}
+			continue
+		}
+		switch t.typ {
+		case element, number, stringValue, label, param:
+			args = append(args, t)
+		default:
+			return nil, false
+		}
+	}
+	if len(tokens)%2 == 0 {
+		return nil, false // trailing comma
+	}
+	return args, true
+}
+
+// errorf records an error found while preprocessing.
+func (c *Compiler) errorf(t token, format string, args ...interface{}) {
+	c.errors = append(c.errors, fmt.Errorf("%d preprocessor error: %s", t.lineno, fmt.Sprintf(format, args...)))
+}
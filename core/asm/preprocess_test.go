@@ -0,0 +1,140 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package asm
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// compileWith compiles the source, resolving includes from the given files.
+func compileWith(src string, files map[string]string) (string, []error) {
+	c := NewCompiler(false)
+	c.SetIncludeLoader(func(name string) ([]byte, error) {
+		if src, ok := files[name]; ok {
+			return []byte(src), nil
+		}
+		return nil, fmt.Errorf("file %s not found", name)
+	})
+	c.Feed(Lex([]byte(src), false))
+	return c.Compile()
+}
+
+func TestPreprocessor(t *testing.T) {
+	files := map[string]string{
+		"lib.easm": `
+#define ONE 1
+#macro store(slot, value)
+	PUSH $value
+	PUSH $slot
+	SSTORE
+#end
+`,
+	}
+	tests := []struct {
+		input, output string
+	}{
+		// Constants of numbers, strings and labels
+		{
+			input: `
+#define SLOT 0x20
+#define NAME "ab"
+#define TARGET @end
+	PUSH SLOT
+	PUSH2 NAME
+	JUMP TARGET
+	end:
+`,
+			output: "6020 616162 600856 5b",
+		},
+		// Macros with parameters, invoked with constants and literals
+		{
+			input: `
+#include "lib.easm"
+	%store(ONE, 2)
+	%store(0x03, ONE)
+`,
+			output: "6002600155 6001600355",
+		},
+		// Labels within macros are local to each invocation
+		{
+			input: `
+#macro skip
+	JUMP @over
+	GAS
+	over:
+#end
+	%skip
+	%skip()
+	over:
+`,
+			output: "6004565a5b 6009565a5b 5b",
+		},
+		// Nested macros passing on their parameters
+		{
+			input: `
+#include "lib.easm"
+#macro clear(slot)
+	%store($slot, 0)
+#end
+	%clear(7)
+`,
+			output: "6000600755",
+		},
+	}
+	for _, test := range tests {
+		output, errs := compileWith(test.input, files)
+		if len(errs) != 0 {
+			t.Errorf("compile error: %v\ninput: %s", errs, test.input)
+			continue
+		}
+		if want := strings.Replace(test.output, " ", "", -1); output != want {
+			t.Errorf("incorrect output\ninput: %sgot:  %s\nwant: %s\n", test.input, output, want)
+		}
+	}
+}
+
+func TestPreprocessorErrors(t *testing.T) {
+	tests := []struct {
+		input, err string
+	}{
+		{"#define ADD 1\n", "constant ADD shadows an instruction"},
+		{"#define A 1\n#define A 2\n", "constant A already defined"},
+		{"#macro m\n\tSTOP\n", "macro without #end"},
+		{"\t%missing\n", "undefined macro missing"},
+		{"#macro m(a)\n\tPUSH $a\n#end\n\t%m(1, 2)\n", "macro m takes 1 arguments, got 2"},
+		{"#macro m(a)\n\tPUSH $b\n#end\n\t%m(1)\n", "undefined parameter $b of macro m"},
+		{"#macro m\n\t%m\n#end\n\t%m\n", "macro m nested too deep"},
+		{"#include \"missing.easm\"\n", "failed to include missing.easm"},
+		{"\tPUSH $a\n", "parameter $a used outside of a macro"},
+		{"\tJUMP @missing\n", "undefined label missing"},
+		{"\tADDD\n", "unknown instruction ADDD"},
+		{"\tPUSH1 256\n", "value 256 exceeds PUSH1"},
+		{"a:\na:\n", "label a already defined"},
+	}
+	for _, test := range tests {
+		_, errs := compileWith(test.input, nil)
+		if len(errs) == 0 {
+			t.Errorf("input %q: expected error %q, got none", test.input, test.err)
+			continue
+		}
+		if !strings.Contains(errs[0].Error(), test.err) {
+			t.Errorf("input %q: error mismatch: have %v, want %q", test.input, errs[0], test.err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// BFTExtraVanity is the fixed number of extra-data prefix bytes reserved for
+// proposer vanity in byzantine fault tolerant blocks.
+const BFTExtraVanity = 32
+
+// BFTDigest is the fixed mix digest of blocks sealed by the byzantine fault
+// tolerant proof-of-authority engine, Keccak256("istanbul byzantine fault tolerance").
+//
+// The hash of headers carrying this digest doesn't cover the committed seals in
+// the extra-data, as different validators collect different (but equally valid)
+// sets of them while agreeing on the same block.
+var BFTDigest = common.HexToHash("0x0084ee39aa2e3b345479238c074bf9cc349991f08059b5ef57dfff11e38762a9")
+
+var (
+	errBFTMissingVanity = errors.New("extra-data 32 byte vanity prefix missing")
+)
+
+// BFTVote is a proposal cast by the block proposer to add a new validator to,
+// or remove an existing one from the validator set.
+type BFTVote struct {
+	Address   common.Address // Account being voted on to change its authorization
+	Authorize bool           // Whether to authorize or deauthorize the voted account
+}
+
+// BFTExtra is the consensus data stored in the header extra-data field of byzantine
+// fault tolerant blocks, right after the vanity prefix.
+type BFTExtra struct {
+	Validators     []common.Address // Validator set, only present on epoch transition blocks
+	Vote           *BFTVote         `rlp:"nil"` // Optional vote on the validator set
+	Seal           []byte           // Signature of the proposer over the seal hash
+	CommittedSeals [][]byte         // Signatures of the validators over the block hash
+}
+
+// ExtractBFTExtra decodes the consensus data from the extra-data field of a header.
+func ExtractBFTExtra(h *Header) (*BFTExtra, error) {
+	if len(h.Extra) < BFTExtraVanity {
+		return nil, errBFTMissingVanity
+	}
+	extra := new(BFTExtra)
+	if err := rlp.DecodeBytes(h.Extra[BFTExtraVanity:], extra); err != nil {
+		return nil, err
+	}
+	return extra, nil
+}
+
+// EncodeBFTExtra creates the extra-data field from the vanity and consensus data.
+// The vanity is padded or truncated to BFTExtraVanity bytes.
+func EncodeBFTExtra(vanity []byte, extra *BFTExtra) ([]byte, error) {
+	blob, err := rlp.EncodeToBytes(extra)
+	if err != nil {
+		return nil, err
+	}
+	if len(vanity) < BFTExtraVanity {
+		vanity = append(common.CopyBytes(vanity), bytes.Repeat([]byte{0x00}, BFTExtraVanity-len(vanity))...)
+	}
+	return append(common.CopyBytes(vanity[:BFTExtraVanity]), blob...), nil
+}
+
+// BFTFilteredHeader returns a copy of the header with the committed seals and
+// optionally the proposer seal removed from the extra-data field. Nil is returned
+// if the extra-data is malformed.
+func BFTFilteredHeader(h *Header, keepSeal bool) *Header {
+	extra, err := ExtractBFTExtra(h)
+	if err != nil {
+		return nil
+	}
+	if !keepSeal {
+		extra.Seal = nil
+	}
+	extra.CommittedSeals = nil
+
+	cpy := CopyHeader(h)
+	if cpy.Extra, err = EncodeBFTExtra(h.Extra[:BFTExtraVanity], extra); err != nil {
+		return nil
+	}
+	return cpy
+}
@@ -98,6 +98,12 @@ type headerMarshaling struct {
 // Hash returns the block hash of the header, which is simply the keccak256 hash of its
 // RLP encoding.
 func (h *Header) Hash() common.Hash {
+	// Committed seals of byzantine fault tolerant blocks are not covered
+	if h.MixDigest == BFTDigest {
+		if filtered := BFTFilteredHeader(h, true); filtered != nil {
+			return rlpHash(filtered)
+		}
+	}
 	return rlpHash(h)
 }
 
@@ -216,3 +216,36 @@ func makeBenchBlock() *Block {
 	}
 	return NewBlock(header, txs, uncles, receipts, newHasher())
 }
+
+// Tests that the hash of byzantine fault tolerant headers doesn't cover the
+// committed seals, but does cover everything else.
+func TestBFTHeaderHash(t *testing.T) {
+	extra, err := EncodeBFTExtra([]byte("vanity"), &BFTExtra{Seal: []byte{0x01}})
+	if err != nil {
+		t.Fatalf("failed to encode extra-data: %v", err)
+	}
+	header := &Header{Number: big.NewInt(1), Difficulty: big.NewInt(1), MixDigest: BFTDigest, Extra: extra}
+	hash := header.Hash()
+
+	// Adding committed seals must not change the hash
+	sealed := CopyHeader(header)
+	if sealed.Extra, err = EncodeBFTExtra([]byte("vanity"), &BFTExtra{Seal: []byte{0x01}, CommittedSeals: [][]byte{{0x02}, {0x03}}}); err != nil {
+		t.Fatalf("failed to encode extra-data: %v", err)
+	}
+	if have := sealed.Hash(); have != hash {
+		t.Errorf("committed seals changed hash: have %x, want %x", have, hash)
+	}
+	// Changing the proposer seal or leaving the BFT digest must change the hash
+	resealed := CopyHeader(header)
+	if resealed.Extra, err = EncodeBFTExtra([]byte("vanity"), &BFTExtra{Seal: []byte{0x02}}); err != nil {
+		t.Fatalf("failed to encode extra-data: %v", err)
+	}
+	if resealed.Hash() == hash {
+		t.Errorf("proposer seal not covered by hash")
+	}
+	plain := CopyHeader(sealed)
+	plain.MixDigest = common.Hash{}
+	if plain.Hash() == rlpHash(CopyHeader(header)) || plain.Hash() != rlpHash(plain) {
+		t.Errorf("non-BFT header hash mismatch")
+	}
+}
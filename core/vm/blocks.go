@@ -0,0 +1,356 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// Block analysis splits code into basic blocks, straight runs of instructions
+// which can only be entered at their first instruction and only be left after
+// their last one (or by failing). The static gas and the stack bounds of a block
+// are checked once upon entering it instead of for every instruction, and some
+// common instruction sequences are fused into superinstructions.
+//
+// Execution has to be indistinguishable from the regular interpreter loop, down
+// to the errors and the gas left:
+//
+//   - Blocks end after every instruction observing the gas left, so that the
+//     static gas of later instructions is never charged too early for them.
+//   - A block whose static gas or stack bounds aren't met on entry is bound to
+//     fail, it's left to the regular loop to fail at the exact instruction.
+//   - If the dynamic gas of an instruction exceeds the gas left, the static gas
+//     charged in advance for the rest of the block is given back before giving
+//     up, and the rest of the block is left to the regular loop.
+
+// Kinds of superinstructions.
+const (
+	fusedNone      = iota // Regular instruction of the jump table
+	fusedPushJump         // PUSH followed by JUMP
+	fusedPushJumpi        // PUSH followed by JUMPI
+	fusedDupSwap          // DUP followed by SWAP
+)
+
+// blockInstruction is an instruction of the analysed code, which might be a
+// superinstruction.
+type blockInstruction struct {
+	op     *operation // Operation of the (first) instruction, nil if undefined
+	opcode OpCode     // Opcode of the (first) instruction
+	pc     uint64     // Position of the (first) instruction in the code
+	rest   uint64     // Static gas of the later instructions of the block
+	fused  int        // Kind of superinstruction, fusedNone if a regular one
+
+	dest  uint64 // Jump destination of fused jumps
+	valid bool   // Whether the jump destination of fused jumps is valid
+	dup   int    // Stack item to duplicate by fused DUP and SWAP
+	swap  int    // Stack item to swap with by fused DUP and SWAP
+}
+
+// codeBlock is a basic block of the analysed code.
+type codeBlock struct {
+	first, last int    // Range of the instructions of the block
+	gas         uint64 // Static gas of all instructions of the block
+	minStack    int    // Minimum stack height on entry for no underflow
+	maxStack    int    // Maximum stack height on entry for no overflow
+}
+
+// codeBlocks is the result of the block analysis of some code.
+type codeBlocks struct {
+	instructions []blockInstruction
+	blocks       []codeBlock
+	starts       []uint32 // Index+1 of the block starting at each position, 0 if none
+}
+
+// blockAt returns the block starting at the given position, or nil if none.
+func (c *codeBlocks) blockAt(pc uint64) *codeBlock {
+	if pc >= uint64(len(c.starts)) || c.starts[pc] == 0 {
+		return nil
+	}
+	return &c.blocks[c.starts[pc]-1]
+}
+
+// readsGas returns whether an instruction observes the gas left, either in its
+// dynamic gas or its execution.
+func readsGas(op OpCode) bool {
+	switch op {
+	case GAS, SSTORE, CALL, CALLCODE, DELEGATECALL, STATICCALL, CREATE, CREATE2:
+		return true
+	}
+	return false
+}
+
+// endsBlock returns whether the instruction has to be the last of its block.
+func endsBlock(op OpCode, operation *operation) bool {
+	return operation == nil || operation.halts || operation.jumps || operation.reverts || readsGas(op)
+}
+
+// analyseBlocks splits the code into basic blocks for the given instructions.
+func analyseBlocks(code []byte, jt *JumpTable) *codeBlocks {
+	// Mark where blocks start: at the beginning, at every jump destination and
+	// after every instruction ending a block
+	var (
+		bitmap = codeBitmap(code)
+		starts = make([]bool, len(code)+1)
+	)
+	starts[0] = true
+	for pc := uint64(0); pc < uint64(len(code)); pc++ {
+		if !bitmap.codeSegment(pc) {
+			continue
+		}
+		op := OpCode(code[pc])
+		if op == JUMPDEST {
+			starts[pc] = true
+		}
+		if endsBlock(op, jt[op]) {
+			starts[pc+1] = true
+		}
+	}
+	// Collect the instructions block by block, tracking the static gas and the
+	// stack bounds of every block
+	var (
+		c = &codeBlocks{
+			starts: make([]uint32, len(code)),
+		}
+		costs  []uint64 // Static gas of every instruction
+		block  *codeBlock
+		height int // Stack height relative to the block entry
+	)
+	account := func(op *operation) uint64 {
+		block.gas += op.constantGas
+		if min := op.minStack - height; min > block.minStack {
+			block.minStack = min
+		}
+		if max := op.maxStack - height; max < block.maxStack {
+			block.maxStack = max
+		}
+		height += int(params.StackLimit) - op.maxStack
+		return op.constantGas
+	}
+	for pc := uint64(0); pc < uint64(len(code)); {
+		c.blocks = append(c.blocks, codeBlock{first: len(c.instructions), maxStack: int(params.StackLimit)})
+		c.starts[pc] = uint32(len(c.blocks))
+		block, height = &c.blocks[len(c.blocks)-1], 0
+
+		for {
+			op := OpCode(code[pc])
+			ins := blockInstruction{op: jt[op], opcode: op, pc: pc}
+
+			var cost uint64
+			if ins.op != nil {
+				cost = account(ins.op)
+			}
+			next := pc + 1
+			if op >= PUSH1 && op <= PUSH32 {
+				next += uint64(op - PUSH1 + 1)
+			}
+			// Fuse the instruction with the next one if possible
+			if ins.op != nil && next < uint64(len(code)) && !starts[next] {
+				if second := OpCode(code[next]); jt[second] != nil && fuse(&ins, second, code, bitmap) {
+					cost += account(jt[second])
+					op, next = second, next+1
+				}
+			}
+			c.instructions = append(c.instructions, ins)
+			costs = append(costs, cost)
+
+			pc = next
+			if endsBlock(op, jt[op]) || pc >= uint64(len(code)) || starts[pc] {
+				break
+			}
+		}
+		block.last = len(c.instructions)
+	}
+	// Derive how much static gas each instruction has to leave for the rest of
+	// its block
+	for _, block := range c.blocks {
+		var rest uint64
+		for i := block.last - 1; i >= block.first; i-- {
+			c.instructions[i].rest = rest
+			rest += costs[i]
+		}
+	}
+	return c
+}
+
+// fuse turns the instruction into a superinstruction with the one following it
+// if there's one for the sequence.
+func fuse(ins *blockInstruction, second OpCode, code []byte, bitmap bitvec) bool {
+	switch {
+	case ins.opcode >= PUSH1 && ins.opcode <= PUSH32 && (second == JUMP || second == JUMPI):
+		// The jump destination is a constant, check it upfront
+		size := uint64(ins.opcode - PUSH1 + 1)
+		dest := new(uint256.Int).SetBytes(code[ins.pc+1 : ins.pc+1+size])
+
+		udest, overflow := dest.Uint64WithOverflow()
+		ins.valid = !overflow && udest < uint64(len(code)) && OpCode(code[udest]) == JUMPDEST && bitmap.codeSegment(udest)
+		ins.dest = udest
+
+		if second == JUMP {
+			ins.fused = fusedPushJump
+		} else {
+			ins.fused = fusedPushJumpi
+		}
+		return true
+
+	case ins.opcode >= DUP1 && ins.opcode <= DUP16 && second >= SWAP1 && second <= SWAP16:
+		ins.fused = fusedDupSwap
+		ins.dup = int(ins.opcode-DUP1) + 1
+		ins.swap = int(second-SWAP1) + 2
+		return true
+	}
+	return false
+}
+
+// codeBlocks returns the block analysis of the contract code, or nil if the
+// contract has to be run by the regular interpreter loop.
+func (in *EVMInterpreter) codeBlocks(contract *Contract) *codeBlocks {
+	// Tracing needs every single instruction, EOF code runs on its own
+	// instructions and code without a hash can't be cached
+	if !in.cfg.BlockAnalysis || in.cfg.Debug || contract.Container != nil || contract.CodeHash == (common.Hash{}) {
+		return nil
+	}
+	if blocks, ok := in.blocks[contract.CodeHash]; ok {
+		return blocks
+	}
+	if in.blocks == nil {
+		in.blocks = make(map[common.Hash]*codeBlocks)
+	}
+	blocks := analyseBlocks(contract.Code, (*JumpTable)(&in.cfg.JumpTable))
+	in.blocks[contract.CodeHash] = blocks
+	return blocks
+}
+
+// runBlocks executes the contract block by block, starting at the given
+// position. It returns whether the execution is done, otherwise the regular
+// interpreter loop has to resume it at the updated position.
+func (in *EVMInterpreter) runBlocks(c *codeBlocks, pc *uint64, callContext *callCtx) ([]byte, bool, error) {
+	var (
+		contract = callContext.contract
+		stack    = callContext.stack
+		mem      = callContext.memory
+	)
+	for {
+		// Running past the end of the code is an implicit STOP
+		if *pc >= uint64(len(contract.Code)) {
+			return nil, true, nil
+		}
+		if atomic.LoadInt32(&in.evm.abort) != 0 {
+			return nil, true, nil
+		}
+		block := c.blockAt(*pc)
+		if block == nil {
+			return nil, false, nil
+		}
+		if sLen := stack.len(); sLen < block.minStack || sLen > block.maxStack || contract.Gas < block.gas {
+			return nil, false, nil
+		}
+		contract.Gas -= block.gas
+
+		for i := block.first; i < block.last; i++ {
+			ins := &c.instructions[i]
+			*pc = ins.pc
+
+			switch ins.fused {
+			case fusedPushJump:
+				if !ins.valid {
+					return nil, true, ErrInvalidJump
+				}
+				*pc = ins.dest
+				continue
+
+			case fusedPushJumpi:
+				if cond := stack.pop(); cond.IsZero() {
+					*pc += uint64(ins.opcode-PUSH1) + 3
+				} else {
+					if !ins.valid {
+						return nil, true, ErrInvalidJump
+					}
+					*pc = ins.dest
+				}
+				continue
+
+			case fusedDupSwap:
+				stack.dup(ins.dup)
+				stack.swap(ins.swap)
+				*pc += 2
+				continue
+			}
+			operation := ins.op
+			if operation == nil {
+				return nil, true, &ErrInvalidOpCode{opcode: ins.opcode}
+			}
+			if in.readOnly && in.evm.chainRules.IsByzantium {
+				if operation.writes || (ins.opcode == CALL && stack.Back(2).Sign() != 0) {
+					return nil, true, ErrWriteProtection
+				}
+			}
+			var (
+				memorySize uint64
+				exact      bool // Whether the rest of the block needs the regular loop
+			)
+			if operation.memorySize != nil {
+				memSize, overflow := operation.memorySize(stack)
+				if overflow {
+					return nil, true, ErrGasUintOverflow
+				}
+				if memorySize, overflow = math.SafeMul(toWordSize(memSize), 32); overflow {
+					return nil, true, ErrGasUintOverflow
+				}
+			}
+			if operation.dynamicGas != nil {
+				dynamicCost, err := operation.dynamicGas(in.evm, contract, stack, mem, memorySize)
+				if err != nil {
+					return nil, true, ErrOutOfGas
+				}
+				if !contract.UseGas(dynamicCost) {
+					// Give back the gas charged in advance, maybe it wasn't
+					// needed after all
+					contract.Gas += ins.rest
+					if !contract.UseGas(dynamicCost) {
+						return nil, true, ErrOutOfGas
+					}
+					exact = true
+				}
+			}
+			if memorySize > 0 {
+				mem.Resize(memorySize)
+			}
+			res, err := operation.execute(pc, in, callContext)
+			if operation.returns {
+				in.returnData = common.CopyBytes(res)
+			}
+			switch {
+			case err != nil:
+				return nil, true, err
+			case operation.reverts:
+				return res, true, ErrExecutionReverted
+			case operation.halts:
+				return res, true, nil
+			case !operation.jumps:
+				*pc++
+			}
+			if exact {
+				return nil, false, nil
+			}
+		}
+	}
+}
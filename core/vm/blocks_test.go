@@ -0,0 +1,216 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestAnalyseBlocks(t *testing.T) {
+	// PUSH1 1, PUSH1 9, JUMPI, DUP1, SWAP1, ADD, STOP, JUMPDEST, PUSH1 3, JUMP
+	code := common.FromHex("0x6001600957809001005b600356")
+	c := analyseBlocks(code, &istanbulInstructionSet)
+
+	want := []codeBlock{
+		{first: 0, last: 2, gas: 3 + 3 + 10, minStack: 0, maxStack: 1022},
+		{first: 2, last: 5, gas: 3 + 3 + 3 + 0, minStack: 1, maxStack: 1023},
+		{first: 5, last: 7, gas: 1 + 3 + 8, minStack: 0, maxStack: 1023},
+	}
+	if len(c.blocks) != len(want) {
+		t.Fatalf("block count mismatch: have %d, want %d", len(c.blocks), len(want))
+	}
+	for i, block := range c.blocks {
+		if block != want[i] {
+			t.Errorf("block %d mismatch: have %+v, want %+v", i, block, want[i])
+		}
+	}
+	for pc, index := range map[uint64]int{0: 0, 5: 1, 9: 2} {
+		if block := c.blockAt(pc); block != &c.blocks[index] {
+			t.Errorf("block at %d mismatch: have %+v, want %+v", pc, block, c.blocks[index])
+		}
+	}
+	if block := c.blockAt(2); block != nil {
+		t.Errorf("block at 2 mismatch: have %+v, want none", block)
+	}
+	fused := []struct {
+		fused int
+		rest  uint64
+	}{
+		{fusedNone, 13}, {fusedPushJumpi, 0},
+		{fusedDupSwap, 3}, {fusedNone, 0}, {fusedNone, 0},
+		{fusedNone, 11}, {fusedPushJump, 0},
+	}
+	for i, ins := range c.instructions {
+		if ins.fused != fused[i].fused || ins.rest != fused[i].rest {
+			t.Errorf("instruction %d mismatch: have fused %d rest %d, want fused %d rest %d", i, ins.fused, ins.rest, fused[i].fused, fused[i].rest)
+		}
+	}
+	if !c.instructions[1].valid || c.instructions[1].dest != 9 {
+		t.Errorf("fused JUMPI destination mismatch: have %d (valid %v), want 9", c.instructions[1].dest, c.instructions[1].valid)
+	}
+	if c.instructions[6].valid {
+		t.Errorf("fused JUMP destination 3 considered valid")
+	}
+}
+
+// blockParityResult is the outcome of a call, which has to be the same with and
+// without block analysis.
+type blockParityResult struct {
+	ret  []byte
+	gas  uint64
+	err  string
+	root common.Hash
+	logs int
+}
+
+// runBlockParity calls the code with the given gas, with or without block
+// analysis.
+func runBlockParity(code []byte, gas uint64, static bool, blocks bool) blockParityResult {
+	var (
+		address = common.Address{0xc0, 0xde}
+		caller  = common.Address{0xca, 0x11}
+	)
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.SetCode(address, code)
+	statedb.SetState(address, common.Hash{}, common.Hash{31: 1})
+
+	vmctx := BlockContext{
+		CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+		Transfer:    func(StateDB, common.Address, common.Address, *big.Int) {},
+		BlockNumber: new(big.Int),
+	}
+	evm := NewEVM(vmctx, TxContext{}, statedb, params.AllEthashProtocolChanges, Config{BlockAnalysis: blocks})
+	statedb.PrepareAccessList(caller, &address, evm.ActivePrecompiles(), nil)
+
+	var (
+		ret []byte
+		err error
+	)
+	if static {
+		ret, gas, err = evm.StaticCall(AccountRef(caller), address, nil, gas)
+	} else {
+		ret, gas, err = evm.Call(AccountRef(caller), address, nil, gas, new(big.Int))
+	}
+	return blockParityResult{
+		ret:  ret,
+		gas:  gas,
+		err:  fmt.Sprint(err),
+		root: statedb.IntermediateRoot(true),
+		logs: len(statedb.Logs()),
+	}
+}
+
+// checkBlockParity checks that the code behaves the same with and without
+// block analysis when called with the given gas.
+func checkBlockParity(t *testing.T, name string, code []byte, gas uint64, static bool) {
+	t.Helper()
+
+	want := runBlockParity(code, gas, static, false)
+	have := runBlockParity(code, gas, static, true)
+	if !bytes.Equal(have.ret, want.ret) || have.gas != want.gas || have.err != want.err || have.root != want.root || have.logs != want.logs {
+		t.Fatalf("%s with %d gas (static %v): result mismatch: have %+v, want %+v", name, gas, static, have, want)
+	}
+}
+
+// Tests that block analysis doesn't change the outcome of code execution, no
+// matter where it runs out of gas.
+func TestBlockAnalysisParity(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+	}{
+		// Loop counting down from 5, storing the counter in memory and the
+		// final memory size in storage
+		{"loop", "0x60055b600190038060005280600257505960015500"},
+		// Memory expansion in the middle of a block
+		{"memory", "0x600060ff5260016002600360046005600660076008600960006000f3"},
+		// Stack underflow in the middle of a block
+		{"underflow", "0x60016002600301015001"},
+		// Stack overflow in a loop
+		{"overflow", "0x5b6000600056"},
+		// Invalid constant jumps, taken or not
+		{"jump", "0x6003565b00"},
+		{"badjump", "0x600456"},
+		{"jumpi", "0x6000600757600160075700"},
+		// Logging after memory expansion, forbidden in static calls
+		{"log", "0x6001601f5360016000a060016000a0"},
+		// Gas observing instructions
+		{"gas", "0x5a6000555a600155600060006000600060003062fffffff1505a600255"},
+		// Reverting with data
+		{"revert", "0x60aa600052602060006001600155fd"},
+		// Undefined instruction in the middle of a block
+		{"undefined", "0x600160020c00"},
+		// Truncated PUSH at the end of the code
+		{"truncated", "0x6001600255600360"},
+		// Returning the sum of a few fused DUP and SWAP
+		{"dupswap", "0x6001600280918190910160005260206000f3"},
+	}
+	for _, tt := range tests {
+		code := common.FromHex(tt.code)
+		// Try every gas limit up to the cheap instructions running out, and
+		// then a few up to the storage writes running out
+		for gas := uint64(0); gas < 30000; {
+			checkBlockParity(t, tt.name, code, gas, false)
+			checkBlockParity(t, tt.name, code, gas, true)
+			if gas < 400 {
+				gas++
+			} else {
+				gas += 101
+			}
+		}
+		checkBlockParity(t, tt.name, code, 1000000, false)
+		checkBlockParity(t, tt.name, code, 1000000, true)
+	}
+}
+
+// Tests that block analysis doesn't change the outcome of executing random code.
+func TestBlockAnalysisRandomParity(t *testing.T) {
+	// Bias the random code towards instructions that make it run for a while
+	ops := []OpCode{
+		STOP, ADD, MUL, SUB, DIV, LT, GT, EQ, ISZERO, AND, NOT, SHA3,
+		CALLVALUE, CALLDATALOAD, CODESIZE, CODECOPY, RETURNDATASIZE, RETURNDATACOPY,
+		POP, MLOAD, MSTORE, MSTORE8, SLOAD, SSTORE, JUMP, JUMPI, PC, MSIZE, GAS, JUMPDEST,
+		PUSH1, PUSH1, PUSH1, PUSH1, PUSH2, PUSH32, DUP1, DUP2, DUP3, SWAP1, SWAP2,
+		LOG0, LOG1, CALL, STATICCALL, RETURN, REVERT, 0x0c, 0xfe,
+	}
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		code := make([]byte, 32+rnd.Intn(96))
+		for j := 0; j < len(code); j++ {
+			code[j] = byte(ops[rnd.Intn(len(ops))])
+			// Keep most jump destinations within the code
+			if code[j] == byte(PUSH1) && j+1 < len(code) {
+				code[j+1] = byte(rnd.Intn(len(code)))
+				j++
+			}
+		}
+		name := fmt.Sprintf("%x", code)
+		for _, gas := range []uint64{0, 10, 50, 100, 500, 1000, 5000, 25000, 100000} {
+			checkBlockParity(t, name, code, gas+uint64(rnd.Intn(10)), false)
+			checkBlockParity(t, name, code, gas+uint64(rnd.Intn(10)), true)
+		}
+	}
+}
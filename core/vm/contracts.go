@@ -128,6 +128,29 @@ func init() {
 	}
 }
 
+// ActivePrecompiles returns the addresses of the precompiles enabled with the given
+// chain rules.
+func ActivePrecompiles(rules params.Rules) []common.Address {
+	var precompiles []common.Address
+	switch {
+	case rules.IsBerlin:
+		precompiles = PrecompiledAddressesBerlin
+	case rules.IsIstanbul:
+		precompiles = PrecompiledAddressesIstanbul
+	case rules.IsByzantium:
+		precompiles = PrecompiledAddressesByzantium
+	default:
+		precompiles = PrecompiledAddressesHomestead
+	}
+	if len(rules.Precompiles) == 0 {
+		return precompiles
+	}
+	// Custom precompiles are active too, don't pollute the shared lists
+	active := make([]common.Address, 0, len(precompiles)+len(rules.Precompiles))
+	active = append(active, precompiles...)
+	return append(active, rules.Precompiles...)
+}
+
 // RunPrecompiledContract runs and evaluates the output of a precompiled contract.
 // It returns
 // - the returned bytes,
@@ -0,0 +1,195 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto/bls12381"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// precompileRegistry contains the precompiled contracts private networks can
+// activate at addresses of their choice through the chain config, by name.
+var (
+	precompileRegistry   = make(map[string]PrecompiledContract)
+	precompileRegistryMu sync.RWMutex
+)
+
+func init() {
+	RegisterPrecompile("bls12381Verify", &bls12381Verify{})
+}
+
+// RegisterPrecompile adds a precompiled contract to the registry, which chain
+// configs can refer to by the given name. Registration is meant to happen
+// upon initialization, before any chain config is checked.
+func RegisterPrecompile(name string, p PrecompiledContract) error {
+	precompileRegistryMu.Lock()
+	defer precompileRegistryMu.Unlock()
+
+	if name == "" {
+		return errors.New("empty precompile name")
+	}
+	if _, ok := precompileRegistry[name]; ok {
+		return fmt.Errorf("precompile %q already registered", name)
+	}
+	precompileRegistry[name] = p
+	return nil
+}
+
+// RegisteredPrecompiles returns the names of the registered precompiled
+// contracts, in alphabetical order.
+func RegisteredPrecompiles() []string {
+	precompileRegistryMu.RLock()
+	defer precompileRegistryMu.RUnlock()
+
+	names := make([]string, 0, len(precompileRegistry))
+	for name := range precompileRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CheckPrecompiles checks that the custom precompiled contracts of the chain
+// config are registered and don't shadow any of the standard ones.
+func CheckPrecompiles(config *params.ChainConfig) error {
+	precompileRegistryMu.RLock()
+	defer precompileRegistryMu.RUnlock()
+
+	for addr, precompile := range config.Precompiles {
+		if precompile == nil {
+			return fmt.Errorf("precompile %x: missing configuration", addr)
+		}
+		if _, ok := precompileRegistry[precompile.Name]; !ok {
+			return fmt.Errorf("precompile %x: unknown implementation %q", addr, precompile.Name)
+		}
+		if _, ok := PrecompiledContractsBerlin[addr]; ok {
+			return fmt.Errorf("precompile %x: address of a standard precompile", addr)
+		}
+		if _, ok := PrecompiledContractsBLS[addr]; ok {
+			return fmt.Errorf("precompile %x: address of a standard precompile", addr)
+		}
+	}
+	return nil
+}
+
+// customPrecompiles returns the custom precompiled contracts active under the
+// given chain rules. Contracts missing from the registry are left out, chain
+// configs are expected to be checked upon setup.
+func customPrecompiles(config *params.ChainConfig, rules params.Rules) map[common.Address]PrecompiledContract {
+	if len(rules.Precompiles) == 0 {
+		return nil
+	}
+	precompileRegistryMu.RLock()
+	defer precompileRegistryMu.RUnlock()
+
+	precompiles := make(map[common.Address]PrecompiledContract, len(rules.Precompiles))
+	for _, addr := range rules.Precompiles {
+		precompile := config.Precompiles[addr]
+		p, ok := precompileRegistry[precompile.Name]
+		if !ok {
+			continue
+		}
+		if precompile.Gas != nil {
+			p = &linearGasPrecompile{PrecompiledContract: p, gas: *precompile.Gas}
+		}
+		precompiles[addr] = p
+	}
+	return precompiles
+}
+
+// linearGasPrecompile is a precompiled contract charging the gas cost declared
+// by the chain config instead of its own.
+type linearGasPrecompile struct {
+	PrecompiledContract
+	gas params.PrecompileGas
+}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract,
+// saturating at the maximum uint64 if the configured prices overflow.
+func (c *linearGasPrecompile) RequiredGas(input []byte) uint64 {
+	words := uint64(len(input)+31) / 32
+	gas, overflow := math.SafeMul(words, c.gas.Word)
+	if overflow {
+		return math.MaxUint64
+	}
+	if gas, overflow = math.SafeAdd(gas, c.gas.Base); overflow {
+		return math.MaxUint64
+	}
+	return gas
+}
+
+// bls12381Verify verifies a BLS signature over BLS12-381, with public keys in
+// G1 and signatures in G2. It's not part of any fork, but available for chain
+// configs to activate.
+type bls12381Verify struct{}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract.
+func (c *bls12381Verify) RequiredGas(input []byte) uint64 {
+	return params.Bls12381PairingBaseGas + 2*params.Bls12381PairingPerPairGas
+}
+
+func (c *bls12381Verify) Run(input []byte) ([]byte, error) {
+	// The call expects `640` bytes as an input that is interpreted as byte
+	// concatenation of:
+	// - `128` bytes of G1 point encoding of the public key
+	// - `256` bytes of G2 point encoding of the message, already hashed to
+	//   the curve (e.g. with the MapG2 precompile)
+	// - `256` bytes of G2 point encoding of the signature
+	// Output is a `32` bytes where the last single byte is `0x01` if the
+	// signature is valid and `0x00` otherwise.
+	if len(input) != 640 {
+		return nil, errBLS12381InvalidInputLength
+	}
+	e := bls12381.NewPairingEngine()
+	g1, g2 := e.G1, e.G2
+
+	pubkey, err := g1.DecodePoint(input[:128])
+	if err != nil {
+		return nil, err
+	}
+	message, err := g2.DecodePoint(input[128:384])
+	if err != nil {
+		return nil, err
+	}
+	signature, err := g2.DecodePoint(input[384:])
+	if err != nil {
+		return nil, err
+	}
+	if !g1.InCorrectSubgroup(pubkey) {
+		return nil, errBLS12381G1PointSubgroup
+	}
+	if !g2.InCorrectSubgroup(message) || !g2.InCorrectSubgroup(signature) {
+		return nil, errBLS12381G2PointSubgroup
+	}
+	// Check e(pubkey, message) == e(generator, signature), rejecting the
+	// public key at infinity which would accept the signature at infinity
+	e.AddPair(pubkey, message)
+	e.AddPairInv(g1.One(), signature)
+
+	out := make([]byte, 32)
+	if !g1.IsZero(pubkey) && e.Check() {
+		out[31] = 1
+	}
+	return out, nil
+}
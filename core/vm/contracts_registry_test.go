@@ -0,0 +1,152 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestRegisterPrecompile(t *testing.T) {
+	if err := RegisterPrecompile("", &dataCopy{}); err == nil {
+		t.Errorf("registered precompile without name")
+	}
+	if err := RegisterPrecompile("bls12381Verify", &dataCopy{}); err == nil {
+		t.Errorf("registered precompile twice")
+	}
+	if err := RegisterPrecompile("testIdentity", &dataCopy{}); err != nil {
+		t.Fatalf("failed to register precompile: %v", err)
+	}
+	defer func() {
+		precompileRegistryMu.Lock()
+		delete(precompileRegistry, "testIdentity")
+		precompileRegistryMu.Unlock()
+	}()
+	var found bool
+	for _, name := range RegisteredPrecompiles() {
+		found = found || name == "testIdentity"
+	}
+	if !found {
+		t.Errorf("registered precompile missing from %v", RegisteredPrecompiles())
+	}
+}
+
+func TestCheckPrecompiles(t *testing.T) {
+	tests := []struct {
+		precompiles map[common.Address]*params.PrecompileConfig
+		fail        bool
+	}{
+		{nil, false},
+		{map[common.Address]*params.PrecompileConfig{{0xb1}: {Name: "bls12381Verify", Block: big.NewInt(0)}}, false},
+		{map[common.Address]*params.PrecompileConfig{{0xb1}: {Name: "unknown", Block: big.NewInt(0)}}, true},
+		{map[common.Address]*params.PrecompileConfig{{0xb1}: nil}, true},
+		{map[common.Address]*params.PrecompileConfig{common.BytesToAddress([]byte{1}): {Name: "bls12381Verify"}}, true},
+		{map[common.Address]*params.PrecompileConfig{common.BytesToAddress([]byte{10}): {Name: "bls12381Verify"}}, true},
+	}
+	for i, tt := range tests {
+		config := *params.TestChainConfig
+		config.Precompiles = tt.precompiles
+		if err := CheckPrecompiles(&config); (err != nil) != tt.fail {
+			t.Errorf("test %d: failure mismatch: have %v, want failure %v", i, err, tt.fail)
+		}
+	}
+}
+
+// Tests that custom precompiles are only callable from their activation block,
+// charging the gas declared by the chain config if any.
+func TestCustomPrecompileActivation(t *testing.T) {
+	var (
+		plain  = common.Address{0xb1}
+		priced = common.Address{0xb2}
+		input  = make([]byte, 640) // Infinity points, not a valid signature
+		config = *params.TestChainConfig
+	)
+	config.Precompiles = map[common.Address]*params.PrecompileConfig{
+		plain:  {Name: "bls12381Verify", Block: big.NewInt(10)},
+		priced: {Name: "bls12381Verify", Block: big.NewInt(20), Gas: &params.PrecompileGas{Base: 1000, Word: 10}},
+	}
+	tests := []struct {
+		number  int64
+		addr    common.Address
+		active  bool
+		gasUsed uint64
+	}{
+		{9, plain, false, 0},
+		{10, plain, true, 161000},
+		{19, priced, false, 0},
+		{20, priced, true, 1000 + 20*10},
+	}
+	for i, tt := range tests {
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		vmctx := BlockContext{
+			CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+			Transfer:    func(StateDB, common.Address, common.Address, *big.Int) {},
+			BlockNumber: big.NewInt(tt.number),
+		}
+		evm := NewEVM(vmctx, TxContext{}, statedb, &config, Config{})
+
+		var active bool
+		for _, addr := range evm.ActivePrecompiles() {
+			active = active || addr == tt.addr
+		}
+		if active != tt.active {
+			t.Errorf("test %d: activity mismatch: have %v, want %v", i, active, tt.active)
+		}
+		ret, gas, err := evm.Call(AccountRef(common.Address{}), tt.addr, input, 200000, new(big.Int))
+		if err != nil {
+			t.Fatalf("test %d: failed to call precompile: %v", i, err)
+		}
+		if tt.active && len(ret) != 32 || !tt.active && len(ret) != 0 {
+			t.Errorf("test %d: output mismatch: %x", i, ret)
+		}
+		if used := 200000 - gas; used != tt.gasUsed {
+			t.Errorf("test %d: gas used mismatch: have %d, want %d", i, used, tt.gasUsed)
+		}
+	}
+	// The shared lists of the standard precompiles must not be extended
+	for _, addr := range PrecompiledAddressesBerlin {
+		if addr == plain || addr == priced {
+			t.Fatalf("custom precompile %x leaked into the standard precompiles", addr)
+		}
+	}
+}
+
+func TestLinearGasOverflow(t *testing.T) {
+	tests := []struct {
+		gas   params.PrecompileGas
+		input int
+		want  uint64
+	}{
+		{params.PrecompileGas{Base: 1000, Word: 10}, 0, 1000},
+		{params.PrecompileGas{Base: 1000, Word: 10}, 33, 1020},
+		{params.PrecompileGas{Base: math.MaxUint64, Word: 1}, 32, math.MaxUint64},
+		{params.PrecompileGas{Base: 1, Word: math.MaxUint64}, 64, math.MaxUint64},
+		{params.PrecompileGas{Base: 1, Word: math.MaxUint64}, 32, math.MaxUint64},
+	}
+	for i, tt := range tests {
+		p := &linearGasPrecompile{PrecompiledContract: &bls12381Verify{}, gas: tt.gas}
+		if have := p.RequiredGas(make([]byte, tt.input)); have != tt.want {
+			t.Errorf("test %d: gas mismatch: have %d, want %d", i, have, tt.want)
+		}
+	}
+}
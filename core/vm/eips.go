@@ -29,6 +29,8 @@ var activators = map[int]func(*JumpTable){
 	2200: enable2200,
 	1884: enable1884,
 	1344: enable1344,
+	3540: enable3540,
+	3670: enable3670,
 }
 
 // EnableEIP enables the given EIP on the config.
@@ -144,3 +146,17 @@ func enable2929(jt *JumpTable) {
 	jt[SELFDESTRUCT].constantGas = params.SelfdestructGasEIP150
 	jt[SELFDESTRUCT].dynamicGas = gasSelfdestructEIP2929
 }
+
+// enable3540 enables "EIP-3540: EVM Object Format (EOF) v1"
+// https://eips.ethereum.org/EIPS/eip-3540
+//
+// The legacy instructions are left unchanged, EOF contracts are executed by
+// the separate jump table of the interpreter.
+func enable3540(jt *JumpTable) {}
+
+// enable3670 enables "EIP-3670: EOF - Code Validation"
+// https://eips.ethereum.org/EIPS/eip-3670
+//
+// The code of EOF contracts is validated upon deployment, it has no effect
+// without EIP-3540.
+func enable3670(jt *JumpTable) {}
@@ -0,0 +1,286 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/holiman/uint256"
+)
+
+// EVM object format (EOF) v1, an experimental container for contract code.
+//
+// A container starts with the magic 0xef00 and a version byte, followed by the
+// section headers (a kind byte and a big endian uint16 size each), terminated
+// by a zero byte and followed by the section contents:
+//
+//     0xef00 0x01 | 0x01 code_size | [0x02 data_size] | 0x00 | code | [data]
+//
+// Only the code section is executed, with the program counter and the jump
+// destinations relative to its start. The data section is only accessible by
+// copying the code of the contract.
+const (
+	eofFormatByte = 0xef // First byte of a container, unused by legacy code
+	eofMagic      = 0x00 // Second byte of a container
+	eof1Version   = 0x01 // Version of the only supported container format
+
+	eofSectionTerminator = 0x00
+	eofSectionCode       = 0x01
+	eofSectionData       = 0x02
+
+	// opInvalid is the designated invalid instruction, which code validation
+	// accepts despite not being defined by the jump table.
+	opInvalid OpCode = 0xfe
+)
+
+// List of EOF container errors
+var (
+	ErrInvalidCode             = errors.New("invalid code: must not begin with 0xef")
+	ErrInvalidEOFMagic         = errors.New("invalid EOF magic")
+	ErrInvalidEOFVersion       = errors.New("invalid EOF version")
+	ErrIncompleteEOFHeader     = errors.New("incomplete EOF header")
+	ErrUnknownEOFSection       = errors.New("unknown EOF section kind")
+	ErrMissingCodeSection      = errors.New("missing code section")
+	ErrDuplicateEOFSection     = errors.New("duplicate EOF section")
+	ErrEmptyEOFSection         = errors.New("empty EOF section")
+	ErrInvalidEOFContainerSize = errors.New("invalid EOF container size")
+	ErrUndefinedInstruction    = errors.New("undefined instruction")
+	ErrTruncatedImmediate      = errors.New("truncated instruction immediate")
+	ErrLegacyCodeFromEOF       = errors.New("EOF initcode must deploy EOF code")
+)
+
+// eofHeader is the parsed header of an EOF v1 container.
+type eofHeader struct {
+	codeSize uint16 // Size of the code section
+	dataSize uint16 // Size of the data section, zero if absent
+}
+
+// hasEOFMagic returns whether the code is meant to be an EOF container.
+func hasEOFMagic(code []byte) bool {
+	return len(code) >= 2 && code[0] == eofFormatByte && code[1] == eofMagic
+}
+
+// parseEOF1Header parses and checks the header of an EOF v1 container,
+// including that the sections make up the entire container.
+func parseEOF1Header(container []byte) (*eofHeader, error) {
+	if !hasEOFMagic(container) {
+		return nil, ErrInvalidEOFMagic
+	}
+	if len(container) < 3 {
+		return nil, ErrIncompleteEOFHeader
+	}
+	if container[2] != eof1Version {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidEOFVersion, container[2])
+	}
+	var (
+		header   = new(eofHeader)
+		pos      = 3
+		seenCode bool
+		seenData bool
+	)
+	for {
+		if pos >= len(container) {
+			return nil, ErrIncompleteEOFHeader
+		}
+		kind := container[pos]
+		if kind == eofSectionTerminator {
+			pos++
+			break
+		}
+		if pos+3 > len(container) {
+			return nil, ErrIncompleteEOFHeader
+		}
+		size := uint16(container[pos+1])<<8 | uint16(container[pos+2])
+		switch kind {
+		case eofSectionCode:
+			if seenCode {
+				return nil, fmt.Errorf("%w: code", ErrDuplicateEOFSection)
+			}
+			seenCode, header.codeSize = true, size
+		case eofSectionData:
+			// The code section must precede the data section
+			if !seenCode {
+				return nil, ErrMissingCodeSection
+			}
+			if seenData {
+				return nil, fmt.Errorf("%w: data", ErrDuplicateEOFSection)
+			}
+			seenData, header.dataSize = true, size
+		default:
+			return nil, fmt.Errorf("%w: %d", ErrUnknownEOFSection, kind)
+		}
+		if size == 0 {
+			return nil, fmt.Errorf("%w: kind %d", ErrEmptyEOFSection, kind)
+		}
+		pos += 3
+	}
+	if !seenCode {
+		return nil, ErrMissingCodeSection
+	}
+	if want := pos + int(header.codeSize) + int(header.dataSize); len(container) != want {
+		return nil, fmt.Errorf("%w: have %d, want %d", ErrInvalidEOFContainerSize, len(container), want)
+	}
+	return header, nil
+}
+
+// size returns the size of the header itself.
+func (h *eofHeader) size() int {
+	if h.dataSize == 0 {
+		return 3 + 3 + 1
+	}
+	return 3 + 3 + 3 + 1
+}
+
+// code returns the code section of the container the header was parsed from.
+func (h *eofHeader) code(container []byte) []byte {
+	begin := h.size()
+	return container[begin : begin+int(h.codeSize)]
+}
+
+// validateCode checks that the code only consists of instructions defined by
+// the jump table, and that the immediates of the PUSH instructions are not
+// truncated by the end of the code.
+func validateCode(code []byte, jt *JumpTable) error {
+	for pc := 0; pc < len(code); pc++ {
+		op := OpCode(code[pc])
+		if jt[op] == nil && op != opInvalid {
+			return fmt.Errorf("%w: %v at %d", ErrUndefinedInstruction, op, pc)
+		}
+		if op >= PUSH1 && op <= PUSH32 {
+			pc += int(op - PUSH1 + 1)
+			if pc >= len(code) {
+				return fmt.Errorf("%w: %v", ErrTruncatedImmediate, op)
+			}
+		}
+	}
+	return nil
+}
+
+// validateEOF checks that the container is a valid EOF v1 container, and
+// unless disabled, that its code section passes code validation.
+func validateEOF(container []byte, jt *JumpTable, validateCodes bool) (*eofHeader, error) {
+	header, err := parseEOF1Header(container)
+	if err != nil {
+		return nil, err
+	}
+	if validateCodes {
+		if err := validateCode(header.code(container), jt); err != nil {
+			return nil, err
+		}
+	}
+	return header, nil
+}
+
+// newEOFInstructionSet returns the instructions of EOF contracts, which are
+// the instructions of the given legacy jump table with the code accessing
+// instructions operating on the entire container instead of the code section.
+func newEOFInstructionSet(legacy JumpTable) JumpTable {
+	jt := legacy
+
+	codeSize := *jt[CODESIZE]
+	codeSize.execute = opCodeSizeEOF
+	jt[CODESIZE] = &codeSize
+
+	codeCopy := *jt[CODECOPY]
+	codeCopy.execute = opCodeCopyEOF
+	jt[CODECOPY] = &codeCopy
+
+	return jt
+}
+
+func opCodeSizeEOF(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) ([]byte, error) {
+	l := new(uint256.Int)
+	l.SetUint64(uint64(len(callContext.contract.Container)))
+	callContext.stack.push(l)
+	return nil, nil
+}
+
+func opCodeCopyEOF(pc *uint64, interpreter *EVMInterpreter, callContext *callCtx) ([]byte, error) {
+	var (
+		memOffset  = callContext.stack.pop()
+		codeOffset = callContext.stack.pop()
+		length     = callContext.stack.pop()
+	)
+	uint64CodeOffset, overflow := codeOffset.Uint64WithOverflow()
+	if overflow {
+		uint64CodeOffset = 0xffffffffffffffff
+	}
+	codeCopy := getData(callContext.contract.Container, uint64CodeOffset, length.Uint64())
+	callContext.memory.Set(memOffset.Uint64(), length.Uint64(), codeCopy)
+
+	return nil, nil
+}
+
+// loadEOF switches the contract to executing the code section if its code is
+// an EOF container. Malformed containers are left to fail as legacy code on
+// their leading 0xef byte.
+func (in *EVMInterpreter) loadEOF(contract *Contract) bool {
+	if contract.Container != nil {
+		return true
+	}
+	if !hasEOFMagic(contract.Code) {
+		return false
+	}
+	header, err := parseEOF1Header(contract.Code)
+	if err != nil {
+		return false
+	}
+	contract.Container, contract.Code = contract.Code, header.code(contract.Code)
+	return true
+}
+
+// eofTable returns the EOF instruction table of the running interpreter and
+// whether EOF code is validated, or nil if EOF is disabled.
+func (evm *EVM) eofTable() (*JumpTable, bool) {
+	in, ok := evm.interpreter.(*EVMInterpreter)
+	if !ok || in.eof == nil {
+		return nil, false
+	}
+	return in.eof, in.eofValidation
+}
+
+// validateInitcode checks the code of a contract creation before running it.
+// It returns whether the initcode is an EOF container.
+func (evm *EVM) validateInitcode(code []byte) (bool, error) {
+	jt, validateCodes := evm.eofTable()
+	if jt == nil || !hasEOFMagic(code) {
+		return false, nil
+	}
+	_, err := validateEOF(code, jt, validateCodes)
+	return err == nil, err
+}
+
+// validateDeployment checks the code returned by a contract creation before
+// it gets deployed.
+func (evm *EVM) validateDeployment(code []byte, eofInitcode bool) error {
+	jt, validateCodes := evm.eofTable()
+	if jt == nil {
+		return nil
+	}
+	if len(code) > 0 && code[0] == eofFormatByte {
+		if !hasEOFMagic(code) {
+			return ErrInvalidCode
+		}
+		_, err := validateEOF(code, jt, validateCodes)
+		return err
+	}
+	if eofInitcode {
+		return ErrLegacyCodeFromEOF
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// eofContainer assembles an EOF v1 container, leaving out the data section if
+// the data is empty.
+func eofContainer(code, data []byte) []byte {
+	container := []byte{eofFormatByte, eofMagic, eof1Version, eofSectionCode, byte(len(code) >> 8), byte(len(code))}
+	if len(data) > 0 {
+		container = append(container, eofSectionData, byte(len(data)>>8), byte(len(data)))
+	}
+	container = append(container, eofSectionTerminator)
+	container = append(container, code...)
+	return append(container, data...)
+}
+
+// deployCode returns code section copying the given code from the given offset
+// of the contract code and returning it for deployment.
+func deployCode(code []byte, offset int) []byte {
+	// PUSH1 len, DUP1, PUSH1 offset, PUSH1 0, CODECOPY, PUSH1 0, RETURN
+	return []byte{0x60, byte(len(code)), 0x80, 0x60, byte(offset), 0x60, 0x00, 0x39, 0x60, 0x00, 0xf3}
+}
+
+// legacyInitcode returns legacy initcode deploying the given code.
+func legacyInitcode(code []byte) []byte {
+	return append(deployCode(code, 11), code...)
+}
+
+// eofInitcode returns EOF initcode deploying the given code, stored in its
+// data section.
+func eofInitcode(code []byte) []byte {
+	return eofContainer(deployCode(code, 10+11), code)
+}
+
+func TestParseEOF1Header(t *testing.T) {
+	for i, tt := range []struct {
+		code   string
+		header *eofHeader
+		err    error
+	}{
+		{"0xef000101000100fe", &eofHeader{codeSize: 1}, nil},
+		{"0xef00010100010200020000aabb", &eofHeader{codeSize: 1, dataSize: 2}, nil},
+		{"0xef0001010002006001", &eofHeader{codeSize: 2}, nil},
+		{"0xef", nil, ErrInvalidEOFMagic},
+		{"0xef01", nil, ErrInvalidEOFMagic},
+		{"0xef00", nil, ErrIncompleteEOFHeader},
+		{"0xef000201000100fe", nil, ErrInvalidEOFVersion},
+		{"0xef0001", nil, ErrIncompleteEOFHeader},
+		{"0xef000101", nil, ErrIncompleteEOFHeader},
+		{"0xef00010100", nil, ErrIncompleteEOFHeader},
+		{"0xef0001010001", nil, ErrIncompleteEOFHeader},
+		{"0xef000100", nil, ErrMissingCodeSection},
+		{"0xef0001020001000000", nil, ErrMissingCodeSection},
+		{"0xef000101000000", nil, ErrEmptyEOFSection},
+		{"0xef000101000102000000fe", nil, ErrEmptyEOFSection},
+		{"0xef0001010001010001000000", nil, ErrDuplicateEOFSection},
+		{"0xef0001010001020001020001000000", nil, ErrDuplicateEOFSection},
+		{"0xef0001030001000000", nil, ErrUnknownEOFSection},
+		{"0xef000101000100", nil, ErrInvalidEOFContainerSize},
+		{"0xef000101000100fefe", nil, ErrInvalidEOFContainerSize},
+		{"0xef00010100010200010000", nil, ErrInvalidEOFContainerSize},
+	} {
+		header, err := parseEOF1Header(common.FromHex(tt.code))
+		if !errors.Is(err, tt.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+			continue
+		}
+		if tt.header != nil && *header != *tt.header {
+			t.Errorf("test %d: header mismatch: have %+v, want %+v", i, header, tt.header)
+		}
+	}
+}
+
+func TestValidateCode(t *testing.T) {
+	jt := newEOFInstructionSet(istanbulInstructionSet)
+	for i, tt := range []struct {
+		code string
+		err  error
+	}{
+		{"0x00", nil},
+		{"0x6001", nil},
+		{"0x7f" + "00000000000000000000000000000000000000000000000000000000000000ff", nil},
+		{"0xfe", nil},
+		{"0x60ef00", nil}, // undefined instructions within immediates
+		{"0x60", ErrTruncatedImmediate},
+		{"0x600160", ErrTruncatedImmediate},
+		{"0x7f" + "000000000000000000000000000000000000000000000000000000000000ff", ErrTruncatedImmediate},
+		{"0x0c", ErrUndefinedInstruction},
+		{"0xef", ErrUndefinedInstruction},
+		{"0x6001b0", ErrUndefinedInstruction},
+		{"0x5c", ErrUndefinedInstruction}, // BEGINSUB is not enabled in istanbul
+	} {
+		if err := validateCode(common.FromHex(tt.code), &jt); !errors.Is(err, tt.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+}
+
+// Tests that contract creation enforces the container format, and that EOF
+// contracts execute their code section.
+func TestEOFExecution(t *testing.T) {
+	// Runtime storing CODESIZE in slot 0, jumping relative to the code section
+	// and storing the first word of the container from its data section on
+	// in slot 1
+	runtime := eofContainer(common.FromHex("0x38600055600856fe5b60016020600039600051600155"), []byte{0xaa})
+
+	tests := []struct {
+		eips     []int
+		initcode []byte
+		err      error
+	}{
+		{[]int{3540, 3670}, legacyInitcode(runtime), nil},
+		{[]int{3540, 3670}, eofInitcode(runtime), nil},
+		{[]int{3540, 3670}, legacyInitcode(eofContainer([]byte{0x60}, nil)), ErrTruncatedImmediate},
+		{[]int{3540}, legacyInitcode(eofContainer([]byte{0x60}, nil)), nil},
+		{[]int{3540, 3670}, legacyInitcode([]byte{0xef, 0x01}), ErrInvalidCode},
+		{[]int{3540, 3670}, legacyInitcode(append(runtime, 0x00)), ErrInvalidEOFContainerSize},
+		{[]int{3540, 3670}, eofInitcode([]byte{0x00}), ErrLegacyCodeFromEOF},
+		{[]int{3540, 3670}, append(eofInitcode(runtime), 0x00), ErrInvalidEOFContainerSize},
+		{nil, legacyInitcode([]byte{0xef, 0x01}), nil},
+		{nil, eofInitcode(runtime), &ErrInvalidOpCode{opcode: eofFormatByte}},
+	}
+	for i, tt := range tests {
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		vmctx := BlockContext{
+			CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+			Transfer:    func(StateDB, common.Address, common.Address, *big.Int) {},
+		}
+		evm := NewEVM(vmctx, TxContext{}, statedb, params.AllEthashProtocolChanges, Config{ExtraEips: tt.eips})
+
+		_, address, _, err := evm.Create(AccountRef(common.Address{}), tt.initcode, 1000000, new(big.Int))
+		if (err == nil) != (tt.err == nil) || err != nil && !errors.Is(err, tt.err) && err.Error() != tt.err.Error() {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+			continue
+		}
+		if err != nil {
+			if code := statedb.GetCode(address); len(code) != 0 {
+				t.Errorf("test %d: code deployed despite error: %x", i, code)
+			}
+			continue
+		}
+		if !bytes.Equal(statedb.GetCode(address), runtime) {
+			continue
+		}
+		if _, _, err := evm.Call(AccountRef(common.Address{}), address, nil, 1000000, new(big.Int)); err != nil {
+			t.Fatalf("test %d: failed to call contract: %v", i, err)
+		}
+		if have := statedb.GetState(address, common.Hash{}); have != common.BigToHash(big.NewInt(int64(len(runtime)))) {
+			t.Errorf("test %d: code size mismatch: have %x, want %d", i, have, len(runtime))
+		}
+		if have := statedb.GetState(address, common.Hash{31: 1}); have != (common.Hash{0xaa}) {
+			t.Errorf("test %d: copied data mismatch: have %x", i, have)
+		}
+	}
+}
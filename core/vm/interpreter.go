@@ -38,6 +38,8 @@ type Config struct {
 	EVMInterpreter   string // External EVM interpreter options
 
 	ExtraEips []int // Additional EIPS that are to be enabled
+
+	BlockAnalysis bool // Enables executing code by basic blocks with fused instructions
 }
 
 // Interpreter is used to run Ethereum based contracts and will utilise the
@@ -86,6 +88,11 @@ type EVMInterpreter struct {
 	hasher    keccakState // Keccak256 hasher instance shared across opcodes
 	hasherBuf common.Hash // Keccak256 hasher result array shared aross opcodes
 
+	eof           *JumpTable // EOF instruction table, nil unless EIP-3540 is enabled
+	eofValidation bool       // Whether EOF code is validated upon deployment (EIP-3670)
+
+	blocks map[common.Hash]*codeBlocks // Block analysis of the executed code by code hash
+
 	readOnly   bool   // Whether to throw on stateful modifications
 	returnData []byte // Last CALL's return data for subsequent reuse
 }
@@ -124,11 +131,20 @@ func NewEVMInterpreter(evm *EVM, cfg Config) *EVMInterpreter {
 		}
 		cfg.JumpTable = jt
 	}
-
-	return &EVMInterpreter{
+	in := &EVMInterpreter{
 		evm: evm,
 		cfg: cfg,
 	}
+	for _, eip := range cfg.ExtraEips {
+		switch eip {
+		case 3540:
+			jt := newEOFInstructionSet(cfg.JumpTable)
+			in.eof = &jt
+		case 3670:
+			in.eofValidation = true
+		}
+	}
+	return in
 }
 
 // Run loops and evaluates the contract's code with the given input data and returns
@@ -187,6 +203,12 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 	}()
 	contract.Input = input
 
+	// Execute the code section of EOF contracts with their own instructions
+	jumpTable := (*JumpTable)(&in.cfg.JumpTable)
+	if in.eof != nil && in.loadEOF(contract) {
+		jumpTable = in.eof
+	}
+
 	if in.cfg.Debug {
 		defer func() {
 			if err != nil {
@@ -198,6 +220,14 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 			}
 		}()
 	}
+	// Execute the code by basic blocks if enabled, leaving the rest to the
+	// regular loop if the exact failure needs to be found
+	if blocks := in.codeBlocks(contract); blocks != nil {
+		res, done, err := in.runBlocks(blocks, &pc, callContext)
+		if done {
+			return res, err
+		}
+	}
 	// The Interpreter main run loop (contextual). This loop runs until either an
 	// explicit STOP, RETURN or SELFDESTRUCT is executed, an error occurred during
 	// the execution of one of the operations or until the done flag is set by the
@@ -216,7 +246,7 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 		// Get the operation from the jump table and validate the stack to ensure there are
 		// enough stack items available to perform the operation.
 		op = contract.GetOp(pc)
-		operation := in.cfg.JumpTable[op]
+		operation := jumpTable[op]
 		if operation == nil {
 			return nil, &ErrInvalidOpCode{opcode: op}
 		}
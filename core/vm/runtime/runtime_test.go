@@ -348,8 +348,15 @@ func (s *stepCounter) CaptureEnd(output []byte, gasUsed uint64, t time.Duration,
 // benchmarkNonModifyingCode benchmarks code, but if the code modifies the
 // state, this should not be used, since it does not reset the state between runs.
 func benchmarkNonModifyingCode(gas uint64, code []byte, name string, b *testing.B) {
+	benchmarkNonModifyingCodeConfig(gas, code, name, vm.Config{}, b)
+}
+
+// benchmarkNonModifyingCodeConfig benchmarks code like benchmarkNonModifyingCode,
+// with the given EVM configuration.
+func benchmarkNonModifyingCodeConfig(gas uint64, code []byte, name string, evmConfig vm.Config, b *testing.B) {
 	cfg := new(Config)
 	setDefaults(cfg)
+	cfg.EVMConfig = evmConfig
 	cfg.State, _ = state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
 	cfg.GasLimit = gas
 	var (
@@ -502,6 +509,92 @@ func BenchmarkSimpleLoop(b *testing.B) {
 	//benchmarkNonModifyingCode(10000000, loopingCode, "loop-10M", b)
 }
 
+// BenchmarkBlockAnalysis compares executing compute heavy code instruction by
+// instruction with executing it by basic blocks.
+func BenchmarkBlockAnalysis(b *testing.B) {
+	// Counts down from 100000
+	countdown := []byte{
+		byte(vm.PUSH3), 0x01, 0x86, 0xa0,
+		byte(vm.JUMPDEST), // [ count ]
+		byte(vm.PUSH1), 1,
+		byte(vm.SWAP1),
+		byte(vm.SUB),
+		byte(vm.DUP1),
+		byte(vm.PUSH1), 4,
+		byte(vm.JUMPI),
+		byte(vm.STOP),
+	}
+	// Computes the 100000th fibonacci number (mod 2^256)
+	fibonacci := []byte{
+		byte(vm.PUSH3), 0x01, 0x86, 0xa0,
+		byte(vm.PUSH1), 0,
+		byte(vm.PUSH1), 1,
+		byte(vm.JUMPDEST), // [ count, a, b ]
+		byte(vm.DUP2),
+		byte(vm.DUP2),
+		byte(vm.ADD),
+		byte(vm.SWAP2),
+		byte(vm.POP),
+		byte(vm.SWAP1), // [ count, b, a+b ]
+		byte(vm.SWAP2),
+		byte(vm.PUSH1), 1,
+		byte(vm.SWAP1),
+		byte(vm.SUB),
+		byte(vm.SWAP2), // [ count-1, b, a+b ]
+		byte(vm.DUP3),
+		byte(vm.PUSH1), 8,
+		byte(vm.JUMPI),
+		byte(vm.STOP),
+	}
+	// Hashes the first word of memory into itself 100000 times
+	keccak := []byte{
+		byte(vm.PUSH3), 0x01, 0x86, 0xa0,
+		byte(vm.JUMPDEST), // [ count ]
+		byte(vm.PUSH1), 0x20,
+		byte(vm.PUSH1), 0,
+		byte(vm.SHA3),
+		byte(vm.PUSH1), 0,
+		byte(vm.MSTORE),
+		byte(vm.PUSH1), 1,
+		byte(vm.SWAP1),
+		byte(vm.SUB),
+		byte(vm.DUP1),
+		byte(vm.PUSH1), 4,
+		byte(vm.JUMPI),
+		byte(vm.STOP),
+	}
+	// Stores the counter in memory and loads it back 100000 times
+	memory := []byte{
+		byte(vm.PUSH3), 0x01, 0x86, 0xa0,
+		byte(vm.JUMPDEST), // [ count ]
+		byte(vm.DUP1),
+		byte(vm.PUSH1), 0,
+		byte(vm.MSTORE),
+		byte(vm.PUSH1), 0,
+		byte(vm.MLOAD),
+		byte(vm.POP),
+		byte(vm.PUSH1), 1,
+		byte(vm.SWAP1),
+		byte(vm.SUB),
+		byte(vm.DUP1),
+		byte(vm.PUSH1), 4,
+		byte(vm.JUMPI),
+		byte(vm.STOP),
+	}
+	for _, program := range []struct {
+		name string
+		code []byte
+	}{
+		{"countdown", countdown},
+		{"fibonacci", fibonacci},
+		{"keccak", keccak},
+		{"memory", memory},
+	} {
+		benchmarkNonModifyingCodeConfig(100000000, program.code, program.name+"-plain", vm.Config{}, b)
+		benchmarkNonModifyingCodeConfig(100000000, program.code, program.name+"-blocks", vm.Config{BlockAnalysis: true}, b)
+	}
+}
+
 // TestEip2929Cases contains various testcases that are used for
 // EIP-2929 about gas repricings
 func TestEip2929Cases(t *testing.T) {
@@ -93,6 +93,8 @@ type Ethereum struct {
 
 	p2pServer *p2p.Server
 
+	engineHeadSub event.Subscription // Chain head feed of the consensus engine, if it tracks the head
+
 	lock sync.RWMutex // Protects the variadic fields (e.g. gas price and etherbase)
 }
 
@@ -509,6 +511,9 @@ func (s *Ethereum) Protocols() []p2p.Protocol {
 	if s.config.SnapshotCache > 0 {
 		protos = append(protos, snap.MakeProtocols((*snapHandler)(s.handler), s.snapDialCandidates)...)
 	}
+	if bft, ok := s.engine.(consensus.BFT); ok {
+		protos = append(protos, bft.Protocols()...)
+	}
 	return protos
 }
 
@@ -530,14 +535,79 @@ func (s *Ethereum) Start() error {
 	}
 	// Start the networking layer and the light server if requested
 	s.handler.Start(maxPeers)
+
+	// Start the agreement protocol if the consensus engine requires one
+	if bft, ok := s.engine.(consensus.BFT); ok {
+		if err := bft.Start(s.blockchain, s.verifyProposal, s.insertCommitted); err != nil {
+			return err
+		}
+		s.startEngineHeadUpdater(bft.NewChainHead)
+	}
+	// Track the signer activity on the canonical chain for the signer alerts
+	if c, ok := s.engine.(*clique.Clique); ok {
+		s.startEngineHeadUpdater(func(header *types.Header) {
+			c.NewChainHead(s.blockchain, header)
+		})
+	}
+	return nil
+}
+
+// verifyProposal fully validates a block proposed for agreement, including the
+// state transition on top of its parent.
+func (s *Ethereum) verifyProposal(block *types.Block) error {
+	if err := s.blockchain.Validator().ValidateBody(block); err != nil {
+		return err
+	}
+	parent := s.blockchain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	statedb, err := s.blockchain.StateAt(parent.Root())
+	if err != nil {
+		return err
+	}
+	receipts, _, usedGas, err := s.blockchain.Processor().Process(block, statedb, *s.blockchain.GetVMConfig())
+	if err != nil {
+		return err
+	}
+	return s.blockchain.Validator().ValidateState(block, statedb, receipts, usedGas)
+}
+
+// insertCommitted imports a block committed by the agreement protocol and
+// announces it to the network.
+func (s *Ethereum) insertCommitted(block *types.Block) error {
+	if _, err := s.blockchain.InsertChain(types.Blocks{block}); err != nil {
+		return err
+	}
+	s.eventMux.Post(core.NewMinedBlockEvent{Block: block})
 	return nil
 }
 
+// startEngineHeadUpdater feeds the new chain heads into the consensus engine.
+func (s *Ethereum) startEngineHeadUpdater(update func(*types.Header)) {
+	heads := make(chan core.ChainHeadEvent, 10)
+	s.engineHeadSub = s.blockchain.SubscribeChainHeadEvent(heads)
+
+	go func() {
+		for {
+			select {
+			case ev := <-heads:
+				update(ev.Block.Header())
+			case <-s.engineHeadSub.Err():
+				return
+			}
+		}
+	}()
+}
+
 // Stop implements node.Lifecycle, terminating all internal goroutines used by the
 // Ethereum protocol.
 func (s *Ethereum) Stop() error {
 	// Stop all the peer-related stuff first.
 	s.handler.Stop()
+	if s.engineHeadSub != nil {
+		s.engineHeadSub.Unsubscribe()
+	}
 
 	// Then stop everything else.
 	s.bloomIndexer.Close()
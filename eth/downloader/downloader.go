@@ -276,13 +276,32 @@ func (d *Downloader) Progress() ethereum.SyncProgress {
 	default:
 		log.Error("Unknown downloader chain/mode combo", "light", d.lightchain != nil, "full", d.blockchain != nil, "mode", mode)
 	}
-	return ethereum.SyncProgress{
+	progress := ethereum.SyncProgress{
 		StartingBlock: d.syncStatsChainOrigin,
 		CurrentBlock:  current,
 		HighestBlock:  d.syncStatsChainHeight,
 		PulledStates:  d.syncStatsState.processed,
 		KnownStates:   d.syncStatsState.processed + d.syncStatsState.pending,
 	}
+	if mode == SnapSync {
+		status := d.SnapSyncer.Status()
+
+		progress.SyncedAccounts = status.AccountSynced
+		progress.SyncedAccountBytes = uint64(status.AccountBytes)
+		progress.SyncedBytecodes = status.BytecodeSynced
+		progress.SyncedBytecodeBytes = uint64(status.BytecodeBytes)
+		progress.SyncedStorage = status.StorageSynced
+		progress.SyncedStorageBytes = uint64(status.StorageBytes)
+		progress.RemainingAccounts = status.AccountRemaining
+
+		progress.HealedTrienodes = status.TrienodeHealSynced
+		progress.HealedTrienodeBytes = uint64(status.TrienodeHealBytes)
+		progress.HealedBytecodes = status.BytecodeHealSynced
+		progress.HealedBytecodeBytes = uint64(status.BytecodeHealBytes)
+		progress.HealingTrienodes = status.TrienodeHealPending
+		progress.HealingBytecode = status.BytecodeHealPending
+	}
+	return progress
 }
 
 // Synchronising returns whether the downloader is currently retrieving blocks.
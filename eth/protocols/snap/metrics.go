@@ -0,0 +1,61 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snap
+
+import (
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var (
+	accountSyncedGauge    = metrics.NewRegisteredGauge("eth/protocols/snap/sync/accounts", nil)
+	accountBytesGauge     = metrics.NewRegisteredGauge("eth/protocols/snap/sync/accounts/bytes", nil)
+	accountRemainingGauge = metrics.NewRegisteredGauge("eth/protocols/snap/sync/accounts/remaining", nil)
+	bytecodeSyncedGauge   = metrics.NewRegisteredGauge("eth/protocols/snap/sync/bytecodes", nil)
+	bytecodeBytesGauge    = metrics.NewRegisteredGauge("eth/protocols/snap/sync/bytecodes/bytes", nil)
+	storageSyncedGauge    = metrics.NewRegisteredGauge("eth/protocols/snap/sync/storage", nil)
+	storageBytesGauge     = metrics.NewRegisteredGauge("eth/protocols/snap/sync/storage/bytes", nil)
+
+	trienodeHealSyncedGauge  = metrics.NewRegisteredGauge("eth/protocols/snap/heal/trienodes", nil)
+	trienodeHealBytesGauge   = metrics.NewRegisteredGauge("eth/protocols/snap/heal/trienodes/bytes", nil)
+	trienodeHealPendingGauge = metrics.NewRegisteredGauge("eth/protocols/snap/heal/trienodes/pending", nil)
+	bytecodeHealSyncedGauge  = metrics.NewRegisteredGauge("eth/protocols/snap/heal/bytecodes", nil)
+	bytecodeHealBytesGauge   = metrics.NewRegisteredGauge("eth/protocols/snap/heal/bytecodes/bytes", nil)
+	bytecodeHealPendingGauge = metrics.NewRegisteredGauge("eth/protocols/snap/heal/bytecodes/pending", nil)
+	healPendingGauge         = metrics.NewRegisteredGauge("eth/protocols/snap/heal/pending", nil)
+)
+
+// updateStatusMetrics mirrors a sync status report into the metrics system.
+func updateStatusMetrics(status *Status) {
+	if !metrics.Enabled {
+		return
+	}
+	accountSyncedGauge.Update(int64(status.AccountSynced))
+	accountBytesGauge.Update(int64(status.AccountBytes))
+	accountRemainingGauge.Update(int64(status.AccountRemaining))
+	bytecodeSyncedGauge.Update(int64(status.BytecodeSynced))
+	bytecodeBytesGauge.Update(int64(status.BytecodeBytes))
+	storageSyncedGauge.Update(int64(status.StorageSynced))
+	storageBytesGauge.Update(int64(status.StorageBytes))
+
+	trienodeHealSyncedGauge.Update(int64(status.TrienodeHealSynced))
+	trienodeHealBytesGauge.Update(int64(status.TrienodeHealBytes))
+	trienodeHealPendingGauge.Update(int64(status.TrienodeHealPending))
+	bytecodeHealSyncedGauge.Update(int64(status.BytecodeHealSynced))
+	bytecodeHealBytesGauge.Update(int64(status.BytecodeHealBytes))
+	bytecodeHealPendingGauge.Update(int64(status.BytecodeHealPending))
+	healPendingGauge.Update(int64(status.HealPending))
+}
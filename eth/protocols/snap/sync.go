@@ -337,6 +337,36 @@ type syncProgress struct {
 	BytecodeHealNops   uint64             // Number of bytecodes not requested
 }
 
+// Status is a point-in-time report of a snap sync, meant to be exposed to users
+// via the RPC APIs and metrics. Opposed to syncProgress, it's never persisted, but
+// rather derived from the live state of the syncer.
+type Status struct {
+	Root    common.Hash `json:"root"`    // State root being synced
+	Healing bool        `json:"healing"` // Flag whether the sync is in the healing phase
+
+	// Status report during syncing phase
+	AccountSynced    uint64             `json:"accountSynced"`    // Number of accounts downloaded
+	AccountBytes     common.StorageSize `json:"accountBytes"`     // Number of account trie bytes persisted to disk
+	AccountRemaining uint64             `json:"accountRemaining"` // Estimated number of accounts still to download
+	BytecodeSynced   uint64             `json:"bytecodeSynced"`   // Number of bytecodes downloaded
+	BytecodeBytes    common.StorageSize `json:"bytecodeBytes"`    // Number of bytecode bytes downloaded
+	StorageSynced    uint64             `json:"storageSynced"`    // Number of storage slots downloaded
+	StorageBytes     common.StorageSize `json:"storageBytes"`     // Number of storage trie bytes persisted to disk
+
+	// Status report during healing phase
+	TrienodeHealSynced  uint64             `json:"trienodeHealSynced"`  // Number of state trie nodes downloaded
+	TrienodeHealBytes   common.StorageSize `json:"trienodeHealBytes"`   // Number of state trie bytes persisted to disk
+	TrienodeHealDups    uint64             `json:"trienodeHealDups"`    // Number of state trie nodes already processed
+	TrienodeHealNops    uint64             `json:"trienodeHealNops"`    // Number of state trie nodes not requested
+	TrienodeHealPending uint64             `json:"trienodeHealPending"` // Number of state trie nodes queued for retrieval
+	BytecodeHealSynced  uint64             `json:"bytecodeHealSynced"`  // Number of bytecodes downloaded
+	BytecodeHealBytes   common.StorageSize `json:"bytecodeHealBytes"`   // Number of bytecodes persisted to disk
+	BytecodeHealDups    uint64             `json:"bytecodeHealDups"`    // Number of bytecodes already processed
+	BytecodeHealNops    uint64             `json:"bytecodeHealNops"`    // Number of bytecodes not requested
+	BytecodeHealPending uint64             `json:"bytecodeHealPending"` // Number of bytecodes queued for retrieval
+	HealPending         uint64             `json:"healPending"`         // Number of items pending in the heal scheduler
+}
+
 // SyncPeer abstracts out the methods required for a peer to be synced against
 // with the goal of allowing the construction of mock peers without the full
 // blown networking.
@@ -439,6 +469,7 @@ type Syncer struct {
 	startTime time.Time   // Time instance when snapshot sync started
 	startAcc  common.Hash // Account hash where sync started from
 	logTime   time.Time   // Time instance when status was last reported
+	status    Status      // Status report of the last sync event, exposed to users
 
 	pend sync.WaitGroup // Tracks network request goroutines for graceful shutdown
 	lock sync.RWMutex   // Protects fields that can change outside of sync (peers, reqs, root)
@@ -558,6 +589,7 @@ func (s *Syncer) Sync(root common.Hash, cancel chan struct{}) error {
 	}
 	// Retrieve the previous sync status from LevelDB and abort if already synced
 	s.loadSyncStatus()
+	s.updateStatus(true)
 	if len(s.tasks) == 0 && s.healer.scheduler.Pending() == 0 {
 		log.Debug("Snapshot sync already completed")
 		return nil
@@ -568,6 +600,7 @@ func (s *Syncer) Sync(root common.Hash, cancel chan struct{}) error {
 		}
 		s.cleanAccountTasks()
 		s.saveSyncStatus()
+		s.updateStatus(false)
 	}()
 
 	log.Debug("Starting snapshot sync cycle", "root", root)
@@ -644,6 +677,7 @@ func (s *Syncer) Sync(root common.Hash, cancel chan struct{}) error {
 			s.processBytecodeHealResponse(res)
 		}
 		// Report stats if something meaningful happened
+		s.updateStatus(true)
 		s.report(false)
 	}
 }
@@ -672,8 +706,12 @@ func (s *Syncer) loadSyncStatus() {
 
 			s.trienodeHealSynced = progress.TrienodeHealSynced
 			s.trienodeHealBytes = progress.TrienodeHealBytes
+			s.trienodeHealDups = progress.TrienodeHealDups
+			s.trienodeHealNops = progress.TrienodeHealNops
 			s.bytecodeHealSynced = progress.BytecodeHealSynced
 			s.bytecodeHealBytes = progress.BytecodeHealBytes
+			s.bytecodeHealDups = progress.BytecodeHealDups
+			s.bytecodeHealNops = progress.BytecodeHealNops
 			return
 		}
 	}
@@ -685,7 +723,9 @@ func (s *Syncer) loadSyncStatus() {
 	s.bytecodeSynced, s.bytecodeBytes = 0, 0
 	s.storageSynced, s.storageBytes = 0, 0
 	s.trienodeHealSynced, s.trienodeHealBytes = 0, 0
+	s.trienodeHealDups, s.trienodeHealNops = 0, 0
 	s.bytecodeHealSynced, s.bytecodeHealBytes = 0, 0
+	s.bytecodeHealDups, s.bytecodeHealNops = 0, 0
 
 	var next common.Hash
 	step := new(big.Int).Sub(
@@ -722,8 +762,12 @@ func (s *Syncer) saveSyncStatus() {
 		StorageBytes:       s.storageBytes,
 		TrienodeHealSynced: s.trienodeHealSynced,
 		TrienodeHealBytes:  s.trienodeHealBytes,
+		TrienodeHealDups:   s.trienodeHealDups,
+		TrienodeHealNops:   s.trienodeHealNops,
 		BytecodeHealSynced: s.bytecodeHealSynced,
 		BytecodeHealBytes:  s.bytecodeHealBytes,
+		BytecodeHealDups:   s.bytecodeHealDups,
+		BytecodeHealNops:   s.bytecodeHealNops,
 	}
 	status, err := json.Marshal(progress)
 	if err != nil {
@@ -2546,6 +2590,76 @@ func (s *Syncer) onHealByteCodes(peer SyncPeer, id uint64, bytecodes [][]byte) e
 	return nil
 }
 
+// Status retrieves a report of the current (or last) snap sync cycle.
+func (s *Syncer) Status() Status {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.status
+}
+
+// updateStatus recalculates the user facing sync status from the internal state
+// of the syncer and mirrors it into the metrics system. The sync is only reported
+// as healing while running with heal tasks pending. It must only be called from
+// the sync loop.
+func (s *Syncer) updateStatus(running bool) {
+	status := Status{
+		Root:               s.root,
+		AccountSynced:      s.accountSynced,
+		AccountBytes:       s.accountBytes,
+		AccountRemaining:   s.estimateRemainingAccounts(),
+		BytecodeSynced:     s.bytecodeSynced,
+		BytecodeBytes:      s.bytecodeBytes,
+		StorageSynced:      s.storageSynced,
+		StorageBytes:       s.storageBytes,
+		TrienodeHealSynced: s.trienodeHealSynced,
+		TrienodeHealBytes:  s.trienodeHealBytes,
+		TrienodeHealDups:   s.trienodeHealDups,
+		TrienodeHealNops:   s.trienodeHealNops,
+		BytecodeHealSynced: s.bytecodeHealSynced,
+		BytecodeHealBytes:  s.bytecodeHealBytes,
+		BytecodeHealDups:   s.bytecodeHealDups,
+		BytecodeHealNops:   s.bytecodeHealNops,
+	}
+	if s.healer != nil {
+		status.TrienodeHealPending = uint64(len(s.healer.trieTasks))
+		status.BytecodeHealPending = uint64(len(s.healer.codeTasks))
+		status.HealPending = uint64(s.healer.scheduler.Pending())
+
+		pending := status.TrienodeHealPending + status.BytecodeHealPending + status.HealPending
+		status.Healing = running && len(s.tasks) == 0 && pending > 0
+	}
+	s.lock.Lock()
+	s.status = status
+	s.lock.Unlock()
+
+	updateStatusMetrics(&status)
+}
+
+// estimateRemainingAccounts extrapolates the number of accounts still to be
+// downloaded from the fraction of the hash space already covered by the sync.
+func (s *Syncer) estimateRemainingAccounts() uint64 {
+	if len(s.tasks) == 0 {
+		return 0
+	}
+	accountGaps := new(big.Int)
+	for _, task := range s.tasks {
+		accountGaps.Add(accountGaps, new(big.Int).Sub(task.Last.Big(), task.Next.Big()))
+	}
+	accountFills := new(big.Int).Sub(hashSpace, accountGaps)
+	if accountFills.BitLen() == 0 {
+		return 0
+	}
+	estimate := new(big.Int).Div(
+		new(big.Int).Mul(new(big.Int).SetUint64(s.accountSynced), accountGaps),
+		accountFills,
+	)
+	if !estimate.IsUint64() {
+		return 0
+	}
+	return estimate.Uint64()
+}
+
 // hashSpace is the total size of the 256 bit hash space for accounts.
 var hashSpace = new(big.Int).Exp(common.Big2, common.Big256, nil)
 
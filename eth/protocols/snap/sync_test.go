@@ -620,6 +620,48 @@ func TestSyncWithStorage(t *testing.T) {
 	}
 }
 
+// TestSyncStatus tests that the sync status reported to users reflects the
+// downloaded state once sync completes.
+func TestSyncStatus(t *testing.T) {
+	t.Parallel()
+
+	cancel := make(chan struct{})
+	sourceAccountTrie, elems, storageTries, storageElems := makeAccountTrieWithStorage(3, 3000, true)
+
+	source := newTestPeer("source", t, cancel)
+	source.accountTrie = sourceAccountTrie
+	source.accountValues = elems
+	source.storageTries = storageTries
+	source.storageValues = storageElems
+
+	syncer := setupSyncer(source)
+	if status := syncer.Status(); status.AccountSynced != 0 || status.Root != (common.Hash{}) || status.Healing {
+		t.Fatalf("unexpected status before sync: %+v", status)
+	}
+	if err := syncer.Sync(sourceAccountTrie.Hash(), cancel); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	status := syncer.Status()
+	if status.Root != sourceAccountTrie.Hash() {
+		t.Errorf("root mismatch: have %x, want %x", status.Root, sourceAccountTrie.Hash())
+	}
+	if status.Healing {
+		t.Errorf("finished sync reported as healing")
+	}
+	if status.AccountSynced != uint64(len(elems)) {
+		t.Errorf("synced accounts mismatch: have %d, want %d", status.AccountSynced, len(elems))
+	}
+	if status.AccountRemaining != 0 {
+		t.Errorf("remaining accounts mismatch: have %d, want 0", status.AccountRemaining)
+	}
+	if status.StorageSynced == 0 || status.StorageBytes == 0 {
+		t.Errorf("storage slots not reported: %d@%v", status.StorageSynced, status.StorageBytes)
+	}
+	if status.TrienodeHealPending != 0 || status.BytecodeHealPending != 0 || status.HealPending != 0 {
+		t.Errorf("heal queues not empty: %+v", status)
+	}
+}
+
 // TestMultiSyncManyUseless contains one good peer, and many which doesn't return anything valuable at all
 func TestMultiSyncManyUseless(t *testing.T) {
 	t.Parallel()
@@ -36,6 +36,8 @@ import (
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/eth/tracers/gasprofile"
+	"github.com/ethereum/go-ethereum/eth/tracers/sourcemap"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/log"
@@ -75,6 +77,10 @@ type Backend interface {
 // API is the collection of tracing APIs exposed over the private debugging endpoint.
 type API struct {
 	backend Backend
+
+	sessions     map[rpc.ID]*debugSession // Interactive debugging sessions in progress
+	debugActive  int                      // Number of debugging sessions still executing
+	sessionsLock sync.Mutex               // Protects the debugging sessions
 }
 
 // NewAPI creates a new API definition for the tracing methods of the Ethereum service.
@@ -157,9 +163,11 @@ func (api *API) blockByNumberAndHash(ctx context.Context, number rpc.BlockNumber
 // TraceConfig holds extra parameters to trace functions.
 type TraceConfig struct {
 	*vm.LogConfig
-	Tracer  *string
-	Timeout *string
-	Reexec  *uint64
+	Tracer     *string
+	Timeout    *string
+	Reexec     *uint64
+	SourceMap  *sourcemap.Config  // Compiler output to annotate the struct logs with
+	GasProfile *gasprofile.Config // Options of the gas profiler, if profiling
 }
 
 // StdTraceConfig holds extra parameters to standard-json trace functions.
@@ -726,6 +734,17 @@ func (api *API) traceTx(ctx context.Context, message core.Message, vmctx vm.Bloc
 		err       error
 		txContext = core.NewEVMTxContext(message)
 	)
+	if config != nil {
+		var tracers int
+		for _, set := range []bool{config.Tracer != nil, config.SourceMap != nil, config.GasProfile != nil} {
+			if set {
+				tracers++
+			}
+		}
+		if tracers > 1 {
+			return nil, errors.New("only one of tracer, sourceMap and gasProfile may be set")
+		}
+	}
 	switch {
 	case config != nil && config.Tracer != nil:
 		// Define a meaningful timeout of a single transaction trace
@@ -747,6 +766,14 @@ func (api *API) traceTx(ctx context.Context, message core.Message, vmctx vm.Bloc
 		}()
 		defer cancel()
 
+	case config != nil && config.SourceMap != nil:
+		if tracer, err = sourcemap.New(config.SourceMap); err != nil {
+			return nil, err
+		}
+
+	case config != nil && config.GasProfile != nil:
+		tracer = gasprofile.New(config.GasProfile)
+
 	case config == nil:
 		tracer = vm.NewStructLogger(nil)
 
@@ -776,6 +803,16 @@ func (api *API) traceTx(ctx context.Context, message core.Message, vmctx vm.Bloc
 			StructLogs:  ethapi.FormatLogs(tracer.StructLogs()),
 		}, nil
 
+	case *sourcemap.Tracer:
+		res := tracer.Result()
+		res.Gas = result.UsedGas
+		return res, nil
+
+	case *gasprofile.Profiler:
+		res := tracer.Result()
+		res.Gas = result.UsedGas
+		return res, nil
+
 	case *Tracer:
 		return tracer.GetResult()
 
@@ -0,0 +1,188 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/eth/tracers/debugger"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// maxDebugSessions is the maximum number of interactive debugging sessions that
+// may be executing at the same time. Every such session pins the historical state
+// of its transaction until its execution finishes or it is stopped.
+const maxDebugSessions = 4
+
+// debugSessionTimeout is the time after which a debugging session not controlled
+// anymore is stopped and removed.
+var debugSessionTimeout = 5 * time.Minute
+
+// errUnknownSession is returned if a debugging session is controlled that does
+// not exist or was already stopped.
+var errUnknownSession = errors.New("unknown debug session")
+
+// debugSession is an interactive debugging session replaying a transaction.
+type debugSession struct {
+	debugger *debugger.Debugger
+	release  func()      // Releases the state of the transaction once finished
+	timer    *time.Timer // Stops the session if not controlled for a while
+	lock     sync.Mutex
+}
+
+// DebugSession is the handle of a started debugging session.
+type DebugSession struct {
+	ID     rpc.ID           `json:"id"`               // Identifier to control the session with
+	Step   *debugger.Step   `json:"step"`             // Instruction the execution paused at
+	Result *debugger.Result `json:"result,omitempty"` // Result if the execution finished without pausing
+}
+
+// DebugTransaction starts replaying a historical transaction under an interactive
+// debugger, pausing at its first instruction. The session is controlled with
+// DebugCommand and needs to be terminated with DebugStop.
+func (api *API) DebugTransaction(ctx context.Context, hash common.Hash, config *TraceConfig) (*DebugSession, error) {
+	_, blockHash, blockNumber, index, err := api.backend.GetTransaction(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	// It shouldn't happen in practice.
+	if blockNumber == 0 {
+		return nil, errors.New("genesis is not traceable")
+	}
+	reexec := defaultTraceReexec
+	if config != nil && config.Reexec != nil {
+		reexec = *config.Reexec
+	}
+	block, err := api.blockByNumberAndHash(ctx, rpc.BlockNumber(blockNumber), blockHash)
+	if err != nil {
+		return nil, err
+	}
+	// Reserve a slot for the session, but retrieve the state without holding
+	// the lock, it might have to be regenerated
+	api.sessionsLock.Lock()
+	if api.debugActive >= maxDebugSessions {
+		api.sessionsLock.Unlock()
+		return nil, fmt.Errorf("too many debug sessions in progress (max %d)", maxDebugSessions)
+	}
+	api.debugActive++
+	api.sessionsLock.Unlock()
+
+	msg, vmctx, statedb, release, err := api.backend.StateAtTransaction(ctx, block, int(index), reexec)
+	if err != nil {
+		api.releaseDebugSlot()
+		return nil, err
+	}
+	var (
+		dbg       = debugger.New()
+		txContext = core.NewEVMTxContext(msg)
+		vmenv     = vm.NewEVM(vmctx, txContext, statedb, api.backend.ChainConfig(), vm.Config{Debug: true, Tracer: dbg})
+	)
+	step, err := dbg.Start(func() error {
+		_, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(msg.Gas()))
+		return err
+	})
+	if err != nil {
+		release()
+		api.releaseDebugSlot()
+		return nil, err
+	}
+	session := &debugSession{debugger: dbg, release: release}
+	if step == nil {
+		api.finishSession(session)
+	}
+	id := rpc.NewID()
+
+	api.sessionsLock.Lock()
+	if api.sessions == nil {
+		api.sessions = make(map[rpc.ID]*debugSession)
+	}
+	api.sessions[id] = session
+	session.timer = time.AfterFunc(debugSessionTimeout, func() { api.DebugStop(id) })
+	api.sessionsLock.Unlock()
+
+	return &DebugSession{ID: id, Step: step, Result: dbg.Result()}, nil
+}
+
+// DebugCommand runs a debugger command in a debugging session, such as "step",
+// "next", "continue" or "break op SSTORE". The result depends on the command.
+func (api *API) DebugCommand(id rpc.ID, command string) (interface{}, error) {
+	api.sessionsLock.Lock()
+	session := api.sessions[id]
+	api.sessionsLock.Unlock()
+
+	if session == nil {
+		return nil, errUnknownSession
+	}
+	session.lock.Lock()
+	defer session.lock.Unlock()
+
+	// Don't time out while executing, the command might run for a while
+	session.timer.Stop()
+	defer session.timer.Reset(debugSessionTimeout)
+
+	res, err := session.debugger.Execute(command)
+	if session.debugger.Finished() {
+		api.finishSession(session)
+	}
+	return res, err
+}
+
+// DebugStop aborts a debugging session, releasing all resources held by it.
+func (api *API) DebugStop(id rpc.ID) error {
+	api.sessionsLock.Lock()
+	session := api.sessions[id]
+	delete(api.sessions, id)
+	api.sessionsLock.Unlock()
+
+	if session == nil {
+		return errUnknownSession
+	}
+	session.lock.Lock()
+	defer session.lock.Unlock()
+
+	session.timer.Stop()
+	session.debugger.Stop()
+	api.finishSession(session)
+	return nil
+}
+
+// finishSession releases the state of the session once the execution finished
+// and frees its slot for a new session. The session lock is held by the caller,
+// unless the session isn't known yet.
+func (api *API) finishSession(s *debugSession) {
+	if s.release == nil {
+		return
+	}
+	s.release()
+	s.release = nil
+
+	api.releaseDebugSlot()
+}
+
+// releaseDebugSlot frees the slot reserved by a session no longer executing.
+func (api *API) releaseDebugSlot() {
+	api.sessionsLock.Lock()
+	api.debugActive--
+	api.sessionsLock.Unlock()
+}
@@ -38,6 +38,9 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/tracers/debugger"
+	"github.com/ethereum/go-ethereum/eth/tracers/gasprofile"
+	"github.com/ethereum/go-ethereum/eth/tracers/sourcemap"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/params"
@@ -354,6 +357,224 @@ func TestTraceTransaction(t *testing.T) {
 	}
 }
 
+func TestDebugTransaction(t *testing.T) {
+	t.Parallel()
+
+	api, target := newDebugTestAPI(t)
+
+	session, err := api.DebugTransaction(context.Background(), target, nil)
+	if err != nil {
+		t.Fatalf("Failed to start debug session: %v", err)
+	}
+	if session.Step == nil || session.Step.PC != 0 || session.Step.Op != "PUSH1" {
+		t.Fatalf("Debug session paused at unexpected step: %+v", session.Step)
+	}
+	if _, err := api.DebugCommand(session.ID, "break op SSTORE"); err != nil {
+		t.Fatalf("Failed to add breakpoint: %v", err)
+	}
+	res, err := api.DebugCommand(session.ID, "continue")
+	if err != nil {
+		t.Fatalf("Failed to continue: %v", err)
+	}
+	if step, ok := res.(*debugger.Step); !ok || step.PC != 4 || len(step.Stack) != 2 {
+		t.Fatalf("Debug session paused at unexpected step: %+v", res)
+	}
+	res, _ = api.DebugCommand(session.ID, "continue")
+	if result, ok := res.(*debugger.Result); !ok || result.Error != "" {
+		t.Fatalf("Unexpected debug session result: %+v", res)
+	}
+	if err := api.DebugStop(session.ID); err != nil {
+		t.Fatalf("Failed to stop debug session: %v", err)
+	}
+	if _, err := api.DebugCommand(session.ID, "step"); err != errUnknownSession {
+		t.Fatalf("Stopped debug session still controllable: %v", err)
+	}
+}
+
+// newDebugTestAPI creates a tracing API over a single transaction calling a
+// contract storing 42 into slot 1.
+func newDebugTestAPI(t *testing.T) (*API, common.Hash) {
+	accounts := newAccounts(1)
+	contract := common.HexToAddress("0xc0de")
+	genesis := &core.Genesis{Alloc: core.GenesisAlloc{
+		accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+		contract:         {Code: common.Hex2Bytes("602a60015500"), Balance: common.Big0},
+	}}
+	target := common.Hash{}
+	signer := types.HomesteadSigner{}
+	api := NewAPI(newTestBackend(t, 1, genesis, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(uint64(i), contract, big.NewInt(0), 100000, big.NewInt(0), nil), signer, accounts[0].key)
+		b.AddTx(tx)
+		target = tx.Hash()
+	}))
+	return api, target
+}
+
+// Tests that only executing debug sessions count towards the session limit.
+func TestDebugSessionLimit(t *testing.T) {
+	t.Parallel()
+
+	api, target := newDebugTestAPI(t)
+
+	var ids []rpc.ID
+	for i := 0; i < maxDebugSessions; i++ {
+		session, err := api.DebugTransaction(context.Background(), target, nil)
+		if err != nil {
+			t.Fatalf("Failed to start debug session %d: %v", i, err)
+		}
+		ids = append(ids, session.ID)
+	}
+	if _, err := api.DebugTransaction(context.Background(), target, nil); err == nil {
+		t.Fatalf("Debug session started beyond the limit")
+	}
+	// Finish one of the sessions without stopping it, the slot should be freed
+	if res, _ := api.DebugCommand(ids[0], "continue"); res == nil {
+		t.Fatalf("Debug session didn't finish")
+	}
+	session, err := api.DebugTransaction(context.Background(), target, nil)
+	if err != nil {
+		t.Fatalf("Failed to start debug session after another finished: %v", err)
+	}
+	// Stopping the finished session mustn't free another slot
+	if err := api.DebugStop(ids[0]); err != nil {
+		t.Fatalf("Failed to stop debug session: %v", err)
+	}
+	if _, err := api.DebugTransaction(context.Background(), target, nil); err == nil {
+		t.Fatalf("Debug session started beyond the limit")
+	}
+	for _, id := range append(ids[1:], session.ID) {
+		if err := api.DebugStop(id); err != nil {
+			t.Fatalf("Failed to stop debug session: %v", err)
+		}
+	}
+	if api.debugActive != 0 {
+		t.Fatalf("Debug session slots leaked: %d", api.debugActive)
+	}
+}
+
+// Tests that debug sessions not controlled anymore are stopped and removed.
+func TestDebugSessionTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { debugSessionTimeout = timeout }(debugSessionTimeout)
+	debugSessionTimeout = 100 * time.Millisecond
+
+	api, target := newDebugTestAPI(t)
+
+	session, err := api.DebugTransaction(context.Background(), target, nil)
+	if err != nil {
+		t.Fatalf("Failed to start debug session: %v", err)
+	}
+	// Controlling the session should keep it alive
+	for i := 0; i < 3; i++ {
+		time.Sleep(debugSessionTimeout / 2)
+		if _, err := api.DebugCommand(session.ID, "break op STOP"); err != nil {
+			t.Fatalf("Failed to control debug session: %v", err)
+		}
+	}
+	time.Sleep(3 * debugSessionTimeout)
+	if _, err := api.DebugCommand(session.ID, "step"); err != errUnknownSession {
+		t.Fatalf("Idle debug session still controllable: %v", err)
+	}
+	api.sessionsLock.Lock()
+	defer api.sessionsLock.Unlock()
+
+	if len(api.sessions) != 0 || api.debugActive != 0 {
+		t.Fatalf("Idle debug session not removed: %d sessions, %d executing", len(api.sessions), api.debugActive)
+	}
+}
+
+func TestTraceTransactionSourceMap(t *testing.T) {
+	t.Parallel()
+
+	// Initialize test accounts and a contract storing 42 into slot 1
+	accounts := newAccounts(1)
+	contract := common.HexToAddress("0xc0de")
+	genesis := &core.Genesis{Alloc: core.GenesisAlloc{
+		accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+		contract:         {Code: common.Hex2Bytes("602a60015500"), Balance: common.Big0},
+	}}
+	target := common.Hash{}
+	signer := types.HomesteadSigner{}
+	api := NewAPI(newTestBackend(t, 1, genesis, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(uint64(i), contract, big.NewInt(0), 100000, big.NewInt(0), nil), signer, accounts[0].key)
+		b.AddTx(tx)
+		target = tx.Hash()
+	}))
+	source := "contract Store {\n    function store() public {\n        value = 42;\n    }\n}\n"
+	combined := `{"contracts":{"Store.sol:Store":{"bin-runtime":"602a60015500","srcmap-runtime":"55:10:0;;;","abi":"[]"}},"sourceList":["Store.sol"]}`
+
+	result, err := api.TraceTransaction(context.Background(), target, &TraceConfig{
+		SourceMap: &sourcemap.Config{CombinedJSON: []byte(combined), Sources: map[string]string{"Store.sol": source}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to trace transaction: %v", err)
+	}
+	res, ok := result.(*sourcemap.Result)
+	if !ok || len(res.StructLogs) != 4 {
+		t.Fatalf("Unexpected trace result: %+v", result)
+	}
+	if loc := res.StructLogs[2].Source; loc == nil || loc.String() != "Store.store (Store.sol:3:9)" {
+		t.Errorf("Unexpected source location: %v", loc)
+	}
+}
+
+func TestTraceCallGasProfile(t *testing.T) {
+	t.Parallel()
+
+	// Initialize test accounts and a contract storing 42 into slot 1
+	accounts := newAccounts(1)
+	contract := common.HexToAddress("0xc0de")
+	genesis := &core.Genesis{Alloc: core.GenesisAlloc{
+		accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+		contract:         {Code: common.Hex2Bytes("602a60015500"), Balance: common.Big0},
+	}}
+	api := NewAPI(newTestBackend(t, 1, genesis, func(i int, b *core.BlockGen) {}))
+
+	result, err := api.TraceCall(context.Background(), ethapi.CallArgs{
+		From: &accounts[0].addr,
+		To:   &contract,
+		Data: &hexutil.Bytes{0xde, 0xad, 0xbe, 0xef},
+	}, rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber), &TraceConfig{GasProfile: &gasprofile.Config{}})
+	if err != nil {
+		t.Fatalf("Failed to trace call: %v", err)
+	}
+	res, ok := result.(*gasprofile.Result)
+	if !ok || len(res.Frames) != 1 {
+		t.Fatalf("Unexpected trace result: %+v", result)
+	}
+	if frame := res.Frames[0]; frame.Function != "0xdeadbeef" || frame.Gas != res.Gas-params.TxGas-4*params.TxDataNonZeroGasEIP2028 {
+		t.Errorf("Unexpected frame profile: %+v, total gas %d", frame, res.Gas)
+	}
+	if op := res.Opcodes[0]; op.Op != "SSTORE" || op.Count != 1 {
+		t.Errorf("Unexpected top opcode: %+v", op)
+	}
+}
+
+func TestTraceConflictingTracers(t *testing.T) {
+	t.Parallel()
+
+	accounts := newAccounts(1)
+	genesis := &core.Genesis{Alloc: core.GenesisAlloc{
+		accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+	}}
+	api := NewAPI(newTestBackend(t, 1, genesis, func(i int, b *core.BlockGen) {}))
+
+	tracer := "callTracer"
+	configs := []*TraceConfig{
+		{Tracer: &tracer, GasProfile: &gasprofile.Config{}},
+		{Tracer: &tracer, SourceMap: &sourcemap.Config{}},
+		{SourceMap: &sourcemap.Config{}, GasProfile: &gasprofile.Config{}},
+	}
+	for i, config := range configs {
+		_, err := api.TraceCall(context.Background(), ethapi.CallArgs{
+			From: &accounts[0].addr,
+			To:   &accounts[0].addr,
+		}, rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber), config)
+		if err == nil {
+			t.Errorf("config %d: conflicting tracers accepted", i)
+		}
+	}
+}
+
 func TestTraceBlock(t *testing.T) {
 	t.Parallel()
 
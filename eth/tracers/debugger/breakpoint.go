@@ -0,0 +1,160 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package debugger
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/holiman/uint256"
+)
+
+// BreakpointKind is the type of condition a breakpoint triggers on.
+type BreakpointKind string
+
+const (
+	BreakPC      BreakpointKind = "pc"     // Break before executing the instruction at a program counter
+	BreakOp      BreakpointKind = "op"     // Break before executing an opcode
+	BreakStorage BreakpointKind = "sstore" // Break before writing to a storage slot
+)
+
+// Breakpoint is a condition pausing the execution whenever it's met.
+type Breakpoint struct {
+	ID      int             `json:"id"`
+	Kind    BreakpointKind  `json:"kind"`
+	PC      uint64          `json:"pc,omitempty"`      // Program counter to break at for pc breakpoints
+	Op      string          `json:"op,omitempty"`      // Opcode name to break at for op breakpoints
+	Slot    *common.Hash    `json:"slot,omitempty"`    // Storage slot to break on writes to, any if nil
+	Address *common.Address `json:"address,omitempty"` // Contract the breakpoint is restricted to, any if nil
+}
+
+// ParseBreakpoint parses a textual breakpoint specification in the form of
+//
+//   pc <counter> [@<address>]
+//   op <opcode> [@<address>]
+//   sstore [<slot>] [@<address>]
+//
+// Numbers may be given in decimal or 0x prefixed hexadecimal format.
+func ParseBreakpoint(spec string) (Breakpoint, error) {
+	var bp Breakpoint
+
+	fields := strings.Fields(spec)
+	if n := len(fields); n > 0 && strings.HasPrefix(fields[n-1], "@") {
+		addr := strings.TrimPrefix(fields[n-1], "@")
+		if !common.IsHexAddress(addr) {
+			return bp, fmt.Errorf("invalid address %q", addr)
+		}
+		bp.Address = new(common.Address)
+		*bp.Address = common.HexToAddress(addr)
+		fields = fields[:n-1]
+	}
+	if len(fields) == 0 {
+		return bp, fmt.Errorf("missing breakpoint kind")
+	}
+	bp.Kind, fields = BreakpointKind(strings.ToLower(fields[0])), fields[1:]
+
+	switch bp.Kind {
+	case BreakPC:
+		if len(fields) != 1 {
+			return bp, fmt.Errorf("usage: pc <counter> [@<address>]")
+		}
+		pc, err := parseNumber(fields[0])
+		if err != nil || !pc.IsUint64() {
+			return bp, fmt.Errorf("invalid program counter %q", fields[0])
+		}
+		bp.PC = pc.Uint64()
+
+	case BreakOp:
+		if len(fields) != 1 {
+			return bp, fmt.Errorf("usage: op <opcode> [@<address>]")
+		}
+		op := vm.StringToOp(strings.ToUpper(fields[0]))
+		if op.String() != strings.ToUpper(fields[0]) {
+			return bp, fmt.Errorf("unknown opcode %q", fields[0])
+		}
+		bp.Op = op.String()
+
+	case BreakStorage:
+		if len(fields) > 1 {
+			return bp, fmt.Errorf("usage: sstore [<slot>] [@<address>]")
+		}
+		if len(fields) == 1 {
+			slot, err := parseNumber(fields[0])
+			if err != nil || slot.BitLen() > 256 {
+				return bp, fmt.Errorf("invalid storage slot %q", fields[0])
+			}
+			bp.Slot = new(common.Hash)
+			*bp.Slot = common.BigToHash(slot)
+		}
+
+	default:
+		return bp, fmt.Errorf("unknown breakpoint kind %q", bp.Kind)
+	}
+	return bp, nil
+}
+
+// parseNumber parses a non-negative decimal or 0x prefixed hexadecimal number.
+func parseNumber(s string) (*big.Int, error) {
+	n, ok := new(big.Int).SetString(s, 0)
+	if !ok || n.Sign() < 0 {
+		return nil, fmt.Errorf("invalid number %q", s)
+	}
+	return n, nil
+}
+
+// String implements fmt.Stringer, formatting the breakpoint in the same form
+// as it is parsed from.
+func (bp *Breakpoint) String() string {
+	var spec string
+	switch bp.Kind {
+	case BreakPC:
+		spec = fmt.Sprintf("pc %d", bp.PC)
+	case BreakOp:
+		spec = fmt.Sprintf("op %s", bp.Op)
+	case BreakStorage:
+		spec = "sstore"
+		if bp.Slot != nil {
+			spec += " " + bp.Slot.Hex()
+		}
+	}
+	if bp.Address != nil {
+		spec += " @" + bp.Address.Hex()
+	}
+	return spec
+}
+
+// matches reports whether the breakpoint triggers on an instruction.
+func (bp *Breakpoint) matches(pc uint64, op vm.OpCode, stack []uint256.Int, addr common.Address) bool {
+	if bp.Address != nil && *bp.Address != addr {
+		return false
+	}
+	switch bp.Kind {
+	case BreakPC:
+		return bp.PC == pc
+	case BreakOp:
+		return bp.Op == op.String()
+	case BreakStorage:
+		if op != vm.SSTORE || len(stack) == 0 {
+			return false
+		}
+		return bp.Slot == nil || *bp.Slot == common.Hash(stack[len(stack)-1].Bytes32())
+	}
+	return false
+}
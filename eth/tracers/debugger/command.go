@@ -0,0 +1,137 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package debugger
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Usage describes the commands understood by Execute.
+const Usage = `Commands:
+  step, s                  execute the next instruction, stepping into calls
+  next, n                  execute the next instruction, stepping over calls
+  finish, f                run until the current call returns
+  continue, c              run until a breakpoint is hit or an error occurs
+  break, b <breakpoint>    add a breakpoint, one of:
+                             pc <counter> [@<address>]
+                             op <opcode> [@<address>]
+                             sstore [<slot>] [@<address>]
+  delete, d <id>           remove a breakpoint
+  breakpoints, bl          list the breakpoints
+  where, w                 show the current instruction and machine state
+  storage, st <slot>       show a storage slot of the current contract
+  balance <address>        show the balance of an account
+  result, r                show the result of the finished execution
+  stop                     abort the execution`
+
+// Execute runs a textual debugger command, as typed into an interactive
+// session. Depending on the command, it returns a *Step, a *Result, a
+// *Breakpoint, a []*Breakpoint, a common.Hash or a *big.Int. Stepping commands
+// return the *Result instead of a *Step once the execution finished.
+func (d *Debugger) Execute(command string) (interface{}, error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return nil, errors.New("empty command")
+	}
+	name, args := fields[0], fields[1:]
+
+	switch name {
+	case "step", "s", "next", "n", "finish", "f", "continue", "c":
+		if len(args) != 0 {
+			return nil, fmt.Errorf("%s takes no arguments", name)
+		}
+		var (
+			step *Step
+			err  error
+		)
+		switch name {
+		case "step", "s":
+			step, err = d.Step()
+		case "next", "n":
+			step, err = d.Next()
+		case "finish", "f":
+			step, err = d.Finish()
+		default:
+			step, err = d.Continue()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if step == nil {
+			return d.Result(), nil
+		}
+		return step, nil
+
+	case "break", "b":
+		bp, err := ParseBreakpoint(strings.Join(args, " "))
+		if err != nil {
+			return nil, err
+		}
+		return d.AddBreakpoint(bp), nil
+
+	case "delete", "d":
+		if len(args) != 1 {
+			return nil, errors.New("usage: delete <id>")
+		}
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid breakpoint id %q", args[0])
+		}
+		return nil, d.RemoveBreakpoint(id)
+
+	case "breakpoints", "bl":
+		return d.Breakpoints(), nil
+
+	case "where", "w":
+		if err := d.checkPaused(); err != nil {
+			return nil, err
+		}
+		return d.current, nil
+
+	case "storage", "st":
+		if len(args) != 1 {
+			return nil, errors.New("usage: storage <slot>")
+		}
+		slot, err := parseNumber(args[0])
+		if err != nil || slot.BitLen() > 256 {
+			return nil, fmt.Errorf("invalid storage slot %q", args[0])
+		}
+		return d.Storage(common.BigToHash(slot))
+
+	case "balance":
+		if len(args) != 1 || !common.IsHexAddress(args[0]) {
+			return nil, errors.New("usage: balance <address>")
+		}
+		return d.Balance(common.HexToAddress(args[0]))
+
+	case "result", "r":
+		if !d.Finished() {
+			return nil, errors.New("execution not finished")
+		}
+		return d.Result(), nil
+
+	case "stop":
+		d.Stop()
+		return d.Result(), nil
+	}
+	return nil, fmt.Errorf("unknown command %q", name)
+}
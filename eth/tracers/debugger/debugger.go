@@ -0,0 +1,366 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package debugger implements an interactive EVM debugger as a vm.Tracer which
+// pauses execution before every instruction of interest, allowing a controller
+// to inspect the machine state and decide how to resume.
+//
+// The execution being debugged runs on a background goroutine and is blocked
+// inside the tracer hooks while paused. The controller methods of a Debugger
+// must not be called concurrently; they are only safe to use from a single
+// goroutine driving the session.
+package debugger
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/holiman/uint256"
+)
+
+var (
+	// ErrNotStarted is returned if the debugger is controlled before an execution
+	// was started with it.
+	ErrNotStarted = errors.New("execution not started")
+
+	// ErrAlreadyStarted is returned if an execution is started on a debugger
+	// which was already used.
+	ErrAlreadyStarted = errors.New("execution already started")
+
+	// ErrFinished is returned if the debugger is controlled after the execution
+	// being debugged has finished.
+	ErrFinished = errors.New("execution finished")
+)
+
+// mode is the condition under which the debugger pauses next.
+type mode int
+
+const (
+	modeStep     mode = iota // Pause at the next instruction
+	modeNext                 // Pause at the next instruction not in a nested call
+	modeFinish               // Pause at the next instruction of a parent call
+	modeContinue             // Pause only on breakpoints and faults
+	modeDetach               // Never pause again
+)
+
+// Step is a snapshot of the machine state at a paused instruction.
+type Step struct {
+	Reason  string                      `json:"reason"`          // Why the execution paused
+	PC      uint64                      `json:"pc"`              // Program counter of the instruction
+	Op      string                      `json:"op"`              // Name of the instruction
+	Gas     uint64                      `json:"gas"`             // Gas available before the instruction
+	Cost    uint64                      `json:"gasCost"`         // Gas cost of the instruction
+	Depth   int                         `json:"depth"`           // Call depth, 1 for the outermost call
+	Address common.Address              `json:"address"`         // Contract being executed
+	Stack   []*hexutil.Big              `json:"stack"`           // Stack items, bottom first
+	Memory  hexutil.Bytes               `json:"memory"`          // Memory contents
+	Storage map[common.Hash]common.Hash `json:"storage"`         // Current values of the storage slots accessed so far
+	Error   string                      `json:"error,omitempty"` // Error raised by the instruction, if faulted
+}
+
+// Result is the outcome of a debugged execution.
+type Result struct {
+	Output  hexutil.Bytes `json:"output"`          // Return or revert data of the outermost call
+	GasUsed uint64        `json:"gasUsed"`         // Gas used by the outermost call
+	Error   string        `json:"error,omitempty"` // Error the execution failed with, if any
+}
+
+// Debugger is a vm.Tracer pausing the traced execution according to stepping
+// commands and breakpoints.
+type Debugger struct {
+	breakpoints []*Breakpoint // Active breakpoints, sorted by id
+	lastID      int           // Id of the last breakpoint added
+
+	mode  mode // Condition under which to pause next
+	depth int  // Call depth the last stepping command was issued at
+
+	accessed map[common.Address]map[common.Hash]struct{} // Storage slots accessed by each contract
+
+	started bool          // Whether an execution was started
+	current *Step         // Step the execution is paused at, nil if running or finished
+	env     *vm.EVM       // Environment of the paused execution for state inspection
+	result  *Result       // Result of the execution once finished
+	paused  chan *Step    // Delivers the step the execution paused at
+	resume  chan struct{} // Resumes a paused execution
+	done    chan struct{} // Closed when the execution finishes
+}
+
+// New creates a debugger which pauses at the first instruction executed.
+func New() *Debugger {
+	return &Debugger{
+		mode:     modeStep,
+		accessed: make(map[common.Address]map[common.Hash]struct{}),
+		paused:   make(chan *Step),
+		resume:   make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start runs the given execution on a background goroutine, with the debugger
+// expected to be configured as its tracer. It waits until the execution pauses
+// for the first time and returns the step paused at, or nil if the execution
+// finished without pausing.
+func (d *Debugger) Start(run func() error) (*Step, error) {
+	if d.started {
+		return nil, ErrAlreadyStarted
+	}
+	d.started = true
+
+	go func() {
+		defer close(d.done)
+		if err := run(); err != nil && d.result == nil {
+			d.result = &Result{Error: err.Error()}
+		}
+	}()
+	return d.wait(), nil
+}
+
+// wait blocks until the execution either pauses or finishes.
+func (d *Debugger) wait() *Step {
+	select {
+	case step := <-d.paused:
+		d.current = step
+	case <-d.done:
+		d.current = nil
+	}
+	return d.current
+}
+
+// proceed resumes a paused execution in the given mode and waits for it to
+// pause again or to finish.
+func (d *Debugger) proceed(m mode) (*Step, error) {
+	if !d.started {
+		return nil, ErrNotStarted
+	}
+	if d.current == nil {
+		return nil, ErrFinished
+	}
+	d.mode, d.depth = m, d.current.Depth
+	d.resume <- struct{}{}
+	return d.wait(), nil
+}
+
+// Step resumes execution until the next instruction, stepping into calls.
+func (d *Debugger) Step() (*Step, error) {
+	return d.proceed(modeStep)
+}
+
+// Next resumes execution until the next instruction in the current call frame
+// or a parent one, stepping over nested calls.
+func (d *Debugger) Next() (*Step, error) {
+	return d.proceed(modeNext)
+}
+
+// Finish resumes execution until the current call frame returns to its parent.
+func (d *Debugger) Finish() (*Step, error) {
+	return d.proceed(modeFinish)
+}
+
+// Continue resumes execution until a breakpoint is hit or an error occurs.
+func (d *Debugger) Continue() (*Step, error) {
+	return d.proceed(modeContinue)
+}
+
+// Stop detaches the debugger, aborting the execution and waiting for it to
+// finish.
+func (d *Debugger) Stop() {
+	if !d.started || d.current == nil {
+		return
+	}
+	d.env.Cancel()
+	d.proceed(modeDetach)
+}
+
+// Current returns the step the execution is paused at, or nil if it's not
+// paused.
+func (d *Debugger) Current() *Step {
+	return d.current
+}
+
+// Finished reports whether the execution being debugged has finished.
+func (d *Debugger) Finished() bool {
+	return d.started && d.current == nil
+}
+
+// Result returns the outcome of the execution, or nil if it hasn't finished.
+func (d *Debugger) Result() *Result {
+	if !d.Finished() {
+		return nil
+	}
+	return d.result
+}
+
+// Storage returns the current value of a storage slot of the contract the
+// execution is paused in.
+func (d *Debugger) Storage(slot common.Hash) (common.Hash, error) {
+	if err := d.checkPaused(); err != nil {
+		return common.Hash{}, err
+	}
+	return d.env.StateDB.GetState(d.current.Address, slot), nil
+}
+
+// Balance returns the current balance of an account at the paused instruction.
+func (d *Debugger) Balance(addr common.Address) (*big.Int, error) {
+	if err := d.checkPaused(); err != nil {
+		return nil, err
+	}
+	return d.env.StateDB.GetBalance(addr), nil
+}
+
+// checkPaused returns an error if the execution isn't paused.
+func (d *Debugger) checkPaused() error {
+	if !d.started {
+		return ErrNotStarted
+	}
+	if d.current == nil {
+		return ErrFinished
+	}
+	return nil
+}
+
+// AddBreakpoint adds a breakpoint, returning it with its id assigned.
+func (d *Debugger) AddBreakpoint(bp Breakpoint) *Breakpoint {
+	d.lastID++
+	bp.ID = d.lastID
+	d.breakpoints = append(d.breakpoints, &bp)
+	return &bp
+}
+
+// RemoveBreakpoint removes the breakpoint with the given id.
+func (d *Debugger) RemoveBreakpoint(id int) error {
+	for i, bp := range d.breakpoints {
+		if bp.ID == id {
+			d.breakpoints = append(d.breakpoints[:i], d.breakpoints[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("no breakpoint %d", id)
+}
+
+// Breakpoints returns the active breakpoints.
+func (d *Debugger) Breakpoints() []*Breakpoint {
+	cpy := make([]*Breakpoint, len(d.breakpoints))
+	copy(cpy, d.breakpoints)
+	return cpy
+}
+
+// CaptureStart implements vm.Tracer.
+func (d *Debugger) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	return nil
+}
+
+// CaptureState implements vm.Tracer, pausing the execution if a stepping
+// command or breakpoint calls for it.
+func (d *Debugger) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, rData []byte, contract *vm.Contract, depth int, err error) error {
+	if d.mode == modeDetach {
+		return nil
+	}
+	// Track the accessed storage slots to display them when pausing
+	data := stack.Data()
+	if (op == vm.SLOAD || op == vm.SSTORE) && len(data) > 0 {
+		addr := contract.Address()
+		if d.accessed[addr] == nil {
+			d.accessed[addr] = make(map[common.Hash]struct{})
+		}
+		d.accessed[addr][common.Hash(data[len(data)-1].Bytes32())] = struct{}{}
+	}
+	var reason string
+	switch {
+	case err != nil:
+		reason = "fault"
+	default:
+		for _, bp := range d.breakpoints {
+			if bp.matches(pc, op, data, contract.Address()) {
+				reason = fmt.Sprintf("breakpoint %d", bp.ID)
+				break
+			}
+		}
+	}
+	if reason == "" {
+		switch {
+		case d.mode == modeStep,
+			d.mode == modeNext && depth <= d.depth,
+			d.mode == modeFinish && depth < d.depth:
+			reason = "step"
+		}
+	}
+	if reason == "" {
+		return nil
+	}
+	d.pause(env, d.snapshot(env, reason, pc, op, gas, cost, memory, data, contract, depth, err))
+	return nil
+}
+
+// CaptureFault implements vm.Tracer, pausing the execution at the instruction
+// which raised an error.
+func (d *Debugger) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if d.mode == modeDetach {
+		return nil
+	}
+	d.pause(env, d.snapshot(env, "fault", pc, op, gas, cost, memory, stack.Data(), contract, depth, err))
+	return nil
+}
+
+// CaptureEnd implements vm.Tracer, recording the result of the execution.
+func (d *Debugger) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error {
+	d.result = &Result{
+		Output:  common.CopyBytes(output),
+		GasUsed: gasUsed,
+	}
+	if err != nil {
+		d.result.Error = err.Error()
+	}
+	return nil
+}
+
+// snapshot copies the machine state into a step.
+func (d *Debugger) snapshot(env *vm.EVM, reason string, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack []uint256.Int, contract *vm.Contract, depth int, err error) *Step {
+	step := &Step{
+		Reason:  reason,
+		PC:      pc,
+		Op:      op.String(),
+		Gas:     gas,
+		Cost:    cost,
+		Depth:   depth,
+		Address: contract.Address(),
+		Stack:   make([]*hexutil.Big, len(stack)),
+		Memory:  common.CopyBytes(memory.Data()),
+		Storage: make(map[common.Hash]common.Hash),
+	}
+	for i := range stack {
+		step.Stack[i] = (*hexutil.Big)(stack[i].ToBig())
+	}
+	for slot := range d.accessed[step.Address] {
+		step.Storage[slot] = env.StateDB.GetState(step.Address, slot)
+	}
+	if err != nil {
+		step.Error = err.Error()
+	}
+	return step
+}
+
+// pause hands the step over to the controller and blocks until resumed.
+func (d *Debugger) pause(env *vm.EVM, step *Step) {
+	d.env = env
+	d.paused <- step
+	<-d.resume
+	d.env = nil
+}
@@ -0,0 +1,186 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package debugger
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/runtime"
+)
+
+var (
+	callerAddr = common.HexToAddress("0xca11e4")
+	calleeAddr = common.HexToAddress("0xca11ee")
+
+	// callerCode calls the callee, then stores 7 into slot 2:
+	//   0 PUSH1 0 (x5), 10 PUSH20 callee, 31 GAS, 32 CALL, 33 POP,
+	//   34 PUSH1 7, 36 PUSH1 2, 38 SSTORE, 39 STOP
+	callerCode = append(append(common.Hex2Bytes("60006000600060006000"+"73"), calleeAddr.Bytes()...),
+		common.Hex2Bytes("5af15060076002550000")...)
+
+	// calleeCode stores 42 into slot 1:
+	//   0 PUSH1 42, 2 PUSH1 1, 4 SSTORE, 5 STOP
+	calleeCode = common.Hex2Bytes("602a60015500")
+)
+
+// startDebugger runs the test contracts with a new debugger attached.
+func startDebugger(t *testing.T) (*Debugger, *Step) {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.SetCode(callerAddr, callerCode)
+	statedb.SetCode(calleeAddr, calleeCode)
+
+	d := New()
+	step, err := d.Start(func() error {
+		_, _, err := runtime.Call(callerAddr, nil, &runtime.Config{
+			State:     statedb,
+			EVMConfig: vm.Config{Debug: true, Tracer: d},
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("failed to start execution: %v", err)
+	}
+	return d, step
+}
+
+// checkStep verifies that the execution paused at the expected location.
+func checkStep(t *testing.T, step *Step, pc uint64, depth int, reason string) {
+	t.Helper()
+	if step == nil {
+		t.Fatalf("execution finished, want pause at pc %d depth %d", pc, depth)
+	}
+	if step.PC != pc || step.Depth != depth || step.Reason != reason {
+		t.Fatalf("pause mismatch: have pc %d depth %d (%s), want pc %d depth %d (%s)", step.PC, step.Depth, step.Reason, pc, depth, reason)
+	}
+}
+
+// Tests stepping into, over and out of nested calls.
+func TestStepping(t *testing.T) {
+	d, step := startDebugger(t)
+	checkStep(t, step, 0, 1, "step")
+
+	step, _ = d.Step()
+	checkStep(t, step, 2, 1, "step")
+
+	// Step into the call and back out of it
+	bp, _ := ParseBreakpoint("pc 32")
+	d.AddBreakpoint(bp)
+
+	step, _ = d.Continue()
+	checkStep(t, step, 32, 1, "breakpoint 1")
+	if step.Op != "CALL" || len(step.Stack) != 7 || common.BigToAddress(step.Stack[5].ToInt()) != calleeAddr {
+		t.Fatalf("unexpected machine state at call: %s %v", step.Op, step.Stack)
+	}
+	step, _ = d.Step()
+	checkStep(t, step, 0, 2, "step")
+	if step.Address != calleeAddr {
+		t.Fatalf("callee address mismatch: have %x, want %x", step.Address, calleeAddr)
+	}
+	step, _ = d.Finish()
+	checkStep(t, step, 33, 1, "step")
+
+	// Run to the end and make sure the debugger is done
+	if step, _ = d.Continue(); step != nil {
+		t.Fatalf("execution paused after finishing: %+v", step)
+	}
+	if result := d.Result(); result == nil || result.Error != "" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if _, err := d.Step(); err != ErrFinished {
+		t.Fatalf("stepping finished execution: have %v, want %v", err, ErrFinished)
+	}
+}
+
+// Tests that stepping over a call doesn't pause inside it.
+func TestStepOver(t *testing.T) {
+	d, _ := startDebugger(t)
+	d.AddBreakpoint(Breakpoint{Kind: BreakPC, PC: 32})
+
+	step, _ := d.Continue()
+	checkStep(t, step, 32, 1, "breakpoint 1")
+	step, _ = d.Next()
+	checkStep(t, step, 33, 1, "step")
+}
+
+// Tests opcode and storage breakpoints and inspecting the storage.
+func TestBreakpoints(t *testing.T) {
+	d, _ := startDebugger(t)
+
+	if _, err := d.Execute("break op sstore @" + calleeAddr.Hex()); err != nil {
+		t.Fatalf("failed to add op breakpoint: %v", err)
+	}
+	if _, err := d.Execute("break sstore 2"); err != nil {
+		t.Fatalf("failed to add storage breakpoint: %v", err)
+	}
+	res, _ := d.Execute("c")
+	checkStep(t, res.(*Step), 4, 2, "breakpoint 1")
+
+	res, _ = d.Execute("continue")
+	checkStep(t, res.(*Step), 38, 1, "breakpoint 2")
+	if value, _ := d.Storage(common.BigToHash(common.Big2)); value != (common.Hash{}) {
+		t.Fatalf("slot written before SSTORE: %x", value)
+	}
+	res, _ = d.Execute("step")
+	checkStep(t, res.(*Step), 39, 1, "step")
+
+	want := common.BigToHash(big.NewInt(7))
+	if value, _ := d.Execute("storage 0x2"); value != want {
+		t.Fatalf("slot value mismatch: have %x, want %x", value, want)
+	}
+	if value := res.(*Step).Storage[common.BigToHash(common.Big2)]; value != want {
+		t.Fatalf("accessed slot value mismatch: have %x, want %x", value, want)
+	}
+	if _, err := d.Execute("delete 1"); err != nil {
+		t.Fatalf("failed to delete breakpoint: %v", err)
+	}
+	if bps, _ := d.Execute("bl"); len(bps.([]*Breakpoint)) != 1 {
+		t.Fatalf("breakpoint count mismatch: have %d, want 1", len(bps.([]*Breakpoint)))
+	}
+	if res, _ = d.Execute("c"); res.(*Result) == nil {
+		t.Fatalf("execution not finished")
+	}
+}
+
+// Tests that stopping the debugger lets the execution run to completion.
+func TestStop(t *testing.T) {
+	d, _ := startDebugger(t)
+	d.Stop()
+	if !d.Finished() {
+		t.Fatalf("execution not finished after stopping")
+	}
+}
+
+// Tests that invalid breakpoint specifications are rejected.
+func TestParseBreakpoint(t *testing.T) {
+	valid := []string{"pc 10", "pc 0x1f @0x00000000000000000000000000000000000000ff", "op CALL", "op sload", "sstore", "sstore 0x01"}
+	for _, spec := range valid {
+		if _, err := ParseBreakpoint(spec); err != nil {
+			t.Errorf("valid breakpoint %q rejected: %v", spec, err)
+		}
+	}
+	invalid := []string{"", "pc", "pc -1", "pc x", "op FOO", "sstore 1 2", "line 10", "pc 1 @0x12"}
+	for _, spec := range invalid {
+		if _, err := ParseBreakpoint(spec); err == nil {
+			t.Errorf("invalid breakpoint %q accepted", spec)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package gasprofile implements a native tracer aggregating the gas spent by an
+// execution per call frame, function selector and opcode.
+//
+// Gas is attributed to instructions by the drop in available gas between the
+// consecutive steps of a call frame, minus the gas used by any sub-calls made in
+// between. This charges the actual gas spent by an instruction, including the
+// gas refunded by calls and the gas burnt by failures, rather than the upfront
+// cost reported for it.
+package gasprofile
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// Config are the options of the gas profiler.
+type Config struct {
+	// NoOpcodes omits the opcodes from the flame graph stacks, leaving only the
+	// call frames. The opcode summary is collected regardless.
+	NoOpcodes bool `json:"noOpcodes"`
+}
+
+// Frame is the gas profile of a single call frame.
+type Frame struct {
+	Depth    int            `json:"depth"`
+	Address  common.Address `json:"address"`
+	Function string         `json:"function"`
+	Gas      uint64         `json:"gas"`     // Gas spent by the frame and its sub-calls
+	SelfGas  uint64         `json:"selfGas"` // Gas spent by the frame's own instructions
+	Failed   bool           `json:"failed,omitempty"`
+}
+
+// Function is the gas profile of all calls to a function of a contract.
+type Function struct {
+	Address  common.Address `json:"address"`
+	Function string         `json:"function"`
+	Calls    int            `json:"calls"`
+	Gas      uint64         `json:"gas"`
+	SelfGas  uint64         `json:"selfGas"`
+}
+
+// Opcode is the gas profile of all executions of an opcode.
+type Opcode struct {
+	Op    string `json:"op"`
+	Count int    `json:"count"`
+	Gas   uint64 `json:"gas"`
+}
+
+// Result is the gas profile of an execution.
+type Result struct {
+	Gas        uint64      `json:"gas"`
+	Failed     bool        `json:"failed"`
+	Frames     []*Frame    `json:"frames"`     // Call frames, in the order they were entered
+	Functions  []*Function `json:"functions"`  // Functions, by descending gas
+	Opcodes    []*Opcode   `json:"opcodes"`    // Opcodes, by descending gas
+	Flamegraph []string    `json:"flamegraph"` // Collapsed stacks, as "frame;frame;op gas"
+}
+
+// frame is the tracking state of a call frame.
+type frame struct {
+	profile  *Frame
+	stack    string // Collapsed stack of the frame
+	startGas uint64 // Gas available at the first instruction
+	callGas  uint64 // Gas spent by sub-calls since the pending instruction
+
+	pending bool      // Whether an instruction awaits its gas to be attributed
+	op      vm.OpCode // Last instruction executed
+	gas     uint64    // Gas available before the last instruction
+	cost    uint64    // Upfront cost of the last instruction
+	err     error     // Error the frame failed with
+}
+
+// Profiler is a vm.Tracer aggregating the gas spent by an execution.
+type Profiler struct {
+	config Config
+
+	frames []*frame
+	create bool // Whether the next frame entered is a contract creation
+
+	profile   []*Frame
+	functions map[string]*Function
+	opcodes   map[vm.OpCode]*Opcode
+	stacks    map[string]uint64
+
+	gasUsed uint64
+	err     error
+}
+
+// New creates a gas profiler. The config may be nil to use the defaults.
+func New(config *Config) *Profiler {
+	p := &Profiler{
+		functions: make(map[string]*Function),
+		opcodes:   make(map[vm.OpCode]*Opcode),
+		stacks:    make(map[string]uint64),
+	}
+	if config != nil {
+		p.config = *config
+	}
+	return p
+}
+
+// CaptureStart implements vm.Tracer.
+func (p *Profiler) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	p.create = create
+	return nil
+}
+
+// CaptureState implements vm.Tracer, attributing the gas spent by the previous
+// instruction of the call frame.
+func (p *Profiler) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, rData []byte, contract *vm.Contract, depth int, err error) error {
+	for depth < len(p.frames) {
+		p.leave()
+	}
+	if depth > len(p.frames) {
+		p.enter(contract, depth, gas)
+	}
+	f := p.frames[len(p.frames)-1]
+	if f.pending {
+		p.charge(f, sub(f.gas, gas+f.callGas))
+	}
+	f.pending, f.op, f.gas, f.cost, f.err = true, op, gas, cost, err
+	f.callGas = 0
+
+	p.create = op == vm.CREATE || op == vm.CREATE2
+	return nil
+}
+
+// CaptureFault implements vm.Tracer, recording the failure of the call frame.
+func (p *Profiler) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if depth == len(p.frames) {
+		p.frames[depth-1].err = err
+	}
+	return nil
+}
+
+// CaptureEnd implements vm.Tracer, closing all call frames still open.
+func (p *Profiler) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error {
+	for len(p.frames) > 0 {
+		p.leave()
+	}
+	p.gasUsed, p.err = gasUsed, err
+	return nil
+}
+
+// enter starts profiling a new call frame.
+func (p *Profiler) enter(contract *vm.Contract, depth int, gas uint64) {
+	var function string
+	switch {
+	case p.create:
+		function = "constructor"
+	case len(contract.Input) < 4:
+		function = "fallback"
+	default:
+		function = hexutil.Encode(contract.Input[:4])
+	}
+	f := &frame{
+		profile: &Frame{
+			Depth:    depth,
+			Address:  contract.Address(),
+			Function: function,
+		},
+		stack:    fmt.Sprintf("%s:%s", contract.Address().Hex(), function),
+		startGas: gas,
+	}
+	if len(p.frames) > 0 {
+		f.stack = p.frames[len(p.frames)-1].stack + ";" + f.stack
+	}
+	p.frames = append(p.frames, f)
+	p.profile = append(p.profile, f.profile)
+}
+
+// leave closes the innermost call frame, attributing the gas spent by its last
+// instruction and charging the frame's gas to its caller.
+func (p *Profiler) leave() {
+	f := p.frames[len(p.frames)-1]
+	p.frames = p.frames[:len(p.frames)-1]
+
+	// All remaining gas is burnt by failures other than reverts
+	remaining := sub(f.gas, f.callGas)
+	if f.pending {
+		spent := f.cost
+		if f.err != nil && f.err != vm.ErrExecutionReverted {
+			spent = remaining
+		}
+		p.charge(f, spent)
+		remaining = sub(remaining, spent)
+	}
+	f.profile.Gas = sub(f.startGas, remaining)
+	f.profile.Failed = f.err != nil
+
+	key := f.profile.Address.Hex() + ":" + f.profile.Function
+	fn := p.functions[key]
+	if fn == nil {
+		fn = &Function{Address: f.profile.Address, Function: f.profile.Function}
+		p.functions[key] = fn
+	}
+	fn.Calls++
+	fn.Gas += f.profile.Gas
+	fn.SelfGas += f.profile.SelfGas
+
+	if len(p.frames) > 0 {
+		p.frames[len(p.frames)-1].callGas += f.profile.Gas
+	}
+}
+
+// charge attributes the gas spent by the pending instruction of a frame.
+func (p *Profiler) charge(f *frame, gas uint64) {
+	f.pending = false
+	f.profile.SelfGas += gas
+
+	op := p.opcodes[f.op]
+	if op == nil {
+		op = &Opcode{Op: f.op.String()}
+		p.opcodes[f.op] = op
+	}
+	op.Count++
+	op.Gas += gas
+
+	stack := f.stack
+	if !p.config.NoOpcodes {
+		stack += ";" + f.op.String()
+	}
+	p.stacks[stack] += gas
+}
+
+// Result returns the gas profile of the execution.
+func (p *Profiler) Result() *Result {
+	result := &Result{
+		Gas:        p.gasUsed,
+		Failed:     p.err != nil,
+		Frames:     p.profile,
+		Functions:  make([]*Function, 0, len(p.functions)),
+		Opcodes:    make([]*Opcode, 0, len(p.opcodes)),
+		Flamegraph: p.Flamegraph(),
+	}
+	if result.Frames == nil {
+		result.Frames = []*Frame{}
+	}
+	for _, fn := range p.functions {
+		result.Functions = append(result.Functions, fn)
+	}
+	sort.Slice(result.Functions, func(i, j int) bool {
+		a, b := result.Functions[i], result.Functions[j]
+		if a.Gas != b.Gas {
+			return a.Gas > b.Gas
+		}
+		if a.Address != b.Address {
+			return a.Address.Hex() < b.Address.Hex()
+		}
+		return a.Function < b.Function
+	})
+	for _, op := range p.opcodes {
+		result.Opcodes = append(result.Opcodes, op)
+	}
+	sort.Slice(result.Opcodes, func(i, j int) bool {
+		a, b := result.Opcodes[i], result.Opcodes[j]
+		if a.Gas != b.Gas {
+			return a.Gas > b.Gas
+		}
+		return a.Op < b.Op
+	})
+	return result
+}
+
+// Flamegraph returns the gas profile in the collapsed stack format consumed by
+// flamegraph.pl and compatible tools, one sorted "frame;frame;op gas" line per
+// distinct stack.
+func (p *Profiler) Flamegraph() []string {
+	lines := make([]string, 0, len(p.stacks))
+	for stack, gas := range p.stacks {
+		if gas > 0 {
+			lines = append(lines, fmt.Sprintf("%s %d", stack, gas))
+		}
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// sub returns a-b, or zero if b is larger. Call stipends are granted on top of
+// the gas charged to the caller, so the gas used by a sub-call may exceed the
+// drop in the caller's gas.
+func sub(a, b uint64) uint64 {
+	if a < b {
+		return 0
+	}
+	return a - b
+}
@@ -0,0 +1,134 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package gasprofile
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/runtime"
+)
+
+var (
+	callerAddr = common.HexToAddress("0xca11e7")
+	calleeAddr = common.HexToAddress("0xca11ee")
+)
+
+// profile executes the caller contract with the given code, and the callee with
+// code pushing two values and stopping.
+func profile(t *testing.T, code []byte, gas uint64, config *Config) *Result {
+	t.Helper()
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.SetCode(callerAddr, code)
+	statedb.SetCode(calleeAddr, common.Hex2Bytes("6001600200"))
+
+	profiler := New(config)
+	runtime.Call(callerAddr, common.Hex2Bytes("a9059cbb0000"), &runtime.Config{
+		GasLimit:  gas,
+		State:     statedb,
+		EVMConfig: vm.Config{Debug: true, Tracer: profiler},
+	})
+	return profiler.Result()
+}
+
+// callCode returns code calling the callee with all the available gas.
+func callCode() []byte {
+	// PUSH1 0 (x4), PUSH1 0 (value), PUSH20 callee, GAS, CALL, POP, STOP
+	return common.Hex2Bytes(fmt.Sprintf("6000600060006000600073%x5af15000", calleeAddr))
+}
+
+// Tests that the gas of nested calls is split between the frames.
+func TestProfileCalls(t *testing.T) {
+	result := profile(t, callCode(), 100000, nil)
+	if result.Failed || len(result.Frames) != 2 {
+		t.Fatalf("unexpected result: failed %v, %d frames", result.Failed, len(result.Frames))
+	}
+	caller, callee := result.Frames[0], result.Frames[1]
+	if caller.Gas != result.Gas {
+		t.Errorf("caller gas mismatch: have %d, want %d", caller.Gas, result.Gas)
+	}
+	if caller.Function != "0xa9059cbb" || callee.Function != "fallback" {
+		t.Errorf("function mismatch: caller %s, callee %s", caller.Function, callee.Function)
+	}
+	if callee.Gas != 6 || callee.SelfGas != 6 {
+		t.Errorf("callee gas mismatch: have %d/%d, want 6/6", callee.Gas, callee.SelfGas)
+	}
+	if caller.SelfGas+callee.SelfGas != result.Gas {
+		t.Errorf("self gas mismatch: have %d, want %d", caller.SelfGas+callee.SelfGas, result.Gas)
+	}
+	var total uint64
+	for _, op := range result.Opcodes {
+		total += op.Gas
+	}
+	if total != result.Gas {
+		t.Errorf("opcode gas mismatch: have %d, want %d", total, result.Gas)
+	}
+	// The CALL itself is charged the cold account access only
+	if op := result.Opcodes[0]; op.Op != "CALL" || op.Count != 1 || op.Gas != 2600 {
+		t.Errorf("top opcode mismatch: have %+v", op)
+	}
+	frames := fmt.Sprintf("%s:0xa9059cbb", callerAddr.Hex())
+	want := []string{
+		frames + ";" + calleeAddr.Hex() + ":fallback;PUSH1 6",
+		frames + ";CALL 2600",
+		frames + ";GAS 2",
+		frames + ";POP 2",
+		frames + ";PUSH1 15",
+		frames + ";PUSH20 3",
+	}
+	if !reflect.DeepEqual(result.Flamegraph, want) {
+		t.Errorf("flamegraph mismatch:\nhave %q\nwant %q", result.Flamegraph, want)
+	}
+}
+
+// Tests that the flame graph can be collapsed to call frames.
+func TestProfileNoOpcodes(t *testing.T) {
+	result := profile(t, callCode(), 100000, &Config{NoOpcodes: true})
+
+	frames := fmt.Sprintf("%s:0xa9059cbb", callerAddr.Hex())
+	want := []string{
+		fmt.Sprintf("%s %d", frames, result.Frames[0].SelfGas),
+		fmt.Sprintf("%s;%s:fallback 6", frames, calleeAddr.Hex()),
+	}
+	if !reflect.DeepEqual(result.Flamegraph, want) {
+		t.Errorf("flamegraph mismatch:\nhave %q\nwant %q", result.Flamegraph, want)
+	}
+	if len(result.Functions) != 2 || result.Functions[0].Calls != 1 || result.Functions[0].Gas != result.Gas {
+		t.Errorf("function summary mismatch: %+v", result.Functions)
+	}
+}
+
+// Tests that failures are charged all the gas they burn.
+func TestProfileFailure(t *testing.T) {
+	// PUSH1 1, INVALID
+	result := profile(t, common.Hex2Bytes("6001fe"), 50000, nil)
+	if !result.Failed || len(result.Frames) != 1 || !result.Frames[0].Failed {
+		t.Fatalf("failure not recorded: %+v", result)
+	}
+	if result.Frames[0].Gas != 50000 || result.Frames[0].SelfGas != 50000 {
+		t.Errorf("frame gas mismatch: have %d/%d, want 50000/50000", result.Frames[0].Gas, result.Frames[0].SelfGas)
+	}
+	if op := result.Opcodes[0]; op.Op != "opcode 0xfe not defined" || op.Gas != 50000-3 {
+		t.Errorf("failing opcode mismatch: have %+v", op)
+	}
+}
@@ -0,0 +1,250 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Divergence is the first point the local execution and the reference
+// disagree on.
+type Divergence struct {
+	Tx      int         // Index of the first diverging transaction, -1 if only the block diverges
+	TxHash  common.Hash // Hash of the first diverging transaction
+	Reasons []string    // Differences between the outcomes
+	Step    *Step       // First diverging step of the transaction, if traced by both
+}
+
+// Step is the first diverging step of a transaction trace.
+type Step struct {
+	Index     int        // Index of the step in the struct logs
+	Local     *StructLog // Local step, nil if the local trace ended before
+	Reference *StructLog // Reference step, nil if the reference trace ended before
+}
+
+// String implements fmt.Stringer, formatting the divergence as a report.
+func (d *Divergence) String() string {
+	var report string
+	if d.Tx < 0 {
+		report = "Divergence in block outcome:\n"
+	} else {
+		report = fmt.Sprintf("Divergence at transaction %d [%x]:\n", d.Tx, d.TxHash)
+	}
+	for _, reason := range d.Reasons {
+		report += "  " + reason + "\n"
+	}
+	if d.Step != nil {
+		report += fmt.Sprintf("First diverging step %d:\n", d.Step.Index)
+		report += fmt.Sprintf("  local:     %v\n", d.Step.Local)
+		report += fmt.Sprintf("  reference: %v\n", d.Step.Reference)
+	}
+	return report
+}
+
+// String implements fmt.Stringer.
+func (l *StructLog) String() string {
+	if l == nil {
+		return "<end of trace>"
+	}
+	return fmt.Sprintf("pc=%d op=%s gas=%d cost=%d depth=%d stack=[%s]", l.Pc, l.Op, l.Gas, l.GasCost, l.Depth, strings.Join(l.Stack, " "))
+}
+
+// Compare returns the first divergence between the local and the reference
+// outcome of a block, or nil if they agree on everything both provide.
+func Compare(local, ref *Result) *Divergence {
+	n := len(local.Txs)
+	if len(ref.Txs) < n {
+		n = len(ref.Txs)
+	}
+	// Intermediate roots accumulate all prior changes, so once diverged, they
+	// stay diverged and the first diverging one can be bisected.
+	first := sort.Search(n, func(i int) bool {
+		l, r := local.Txs[i].Root, ref.Txs[i].Root
+		return l != nil && r != nil && *l != *r
+	})
+	// Some differences don't make it into the state, check the transactions up
+	// to the diverging root one by one.
+	for i := 0; i <= first && i < n; i++ {
+		reasons, step := compareTx(local.Txs[i], ref.Txs[i])
+		if len(reasons) > 0 {
+			return &Divergence{Tx: i, TxHash: local.Txs[i].Hash, Reasons: reasons, Step: step}
+		}
+	}
+	if len(ref.Txs) > 0 && len(local.Txs) != len(ref.Txs) {
+		d := &Divergence{Tx: n, Reasons: []string{fmt.Sprintf("transaction count: local %d, reference %d", len(local.Txs), len(ref.Txs))}}
+		if n < len(local.Txs) {
+			d.TxHash = local.Txs[n].Hash
+		}
+		return d
+	}
+	// The transactions agree, compare the finalized block
+	var reasons []string
+	if local.Root != nil && ref.Root != nil && *local.Root != *ref.Root {
+		reasons = append(reasons, fmt.Sprintf("state root: local %x, reference %x", *local.Root, *ref.Root))
+	}
+	if local.ReceiptRoot != nil && ref.ReceiptRoot != nil && *local.ReceiptRoot != *ref.ReceiptRoot {
+		reasons = append(reasons, fmt.Sprintf("receipts root: local %x, reference %x", *local.ReceiptRoot, *ref.ReceiptRoot))
+	}
+	if local.Bloom != nil && ref.Bloom != nil && *local.Bloom != *ref.Bloom {
+		reasons = append(reasons, "logs bloom differs")
+	}
+	if local.GasUsed != nil && ref.GasUsed != nil && *local.GasUsed != *ref.GasUsed {
+		reasons = append(reasons, fmt.Sprintf("gas used: local %d, reference %d", *local.GasUsed, *ref.GasUsed))
+	}
+	if len(reasons) > 0 {
+		return &Divergence{Tx: -1, Reasons: reasons}
+	}
+	return nil
+}
+
+// compareTx returns the differences between two outcomes of a transaction and
+// the first diverging step of their traces.
+func compareTx(local, ref *Tx) ([]string, *Step) {
+	var reasons []string
+	if local.Error != ref.Error && (local.Error == "" || ref.Error == "") {
+		reasons = append(reasons, fmt.Sprintf("application: local %q, reference %q", local.Error, ref.Error))
+	}
+	if local.Root != nil && ref.Root != nil && *local.Root != *ref.Root {
+		reasons = append(reasons, fmt.Sprintf("intermediate root: local %x, reference %x", *local.Root, *ref.Root))
+	}
+	if l, r := local.Receipt, ref.Receipt; l != nil && r != nil {
+		if l.Status != r.Status {
+			reasons = append(reasons, fmt.Sprintf("status: local %d, reference %d", l.Status, r.Status))
+		}
+		if l.GasUsed != r.GasUsed {
+			reasons = append(reasons, fmt.Sprintf("gas used: local %d, reference %d", l.GasUsed, r.GasUsed))
+		}
+		if l.CumulativeGasUsed != r.CumulativeGasUsed {
+			reasons = append(reasons, fmt.Sprintf("cumulative gas used: local %d, reference %d", l.CumulativeGasUsed, r.CumulativeGasUsed))
+		}
+		if l.Bloom != r.Bloom {
+			reasons = append(reasons, "logs bloom differs")
+		}
+		if l.LogsHash != r.LogsHash {
+			reasons = append(reasons, fmt.Sprintf("logs hash: local %x, reference %x", l.LogsHash, r.LogsHash))
+		}
+	}
+	// Traces of debug_traceBlock lack receipts, check what they have in common
+	if l, r := local.Receipt, ref.Trace; l != nil && ref.Receipt == nil && r != nil {
+		if uint64(l.GasUsed) != r.Gas {
+			reasons = append(reasons, fmt.Sprintf("gas used: local %d, reference %d", l.GasUsed, r.Gas))
+		}
+		if failed := uint64(l.Status) == types.ReceiptStatusFailed; failed != r.Failed {
+			reasons = append(reasons, fmt.Sprintf("failed: local %v, reference %v", failed, r.Failed))
+		}
+	}
+	var step *Step
+	if l, r := local.Trace, ref.Trace; l != nil && r != nil {
+		if l.Gas != r.Gas {
+			reasons = append(reasons, fmt.Sprintf("trace gas: local %d, reference %d", l.Gas, r.Gas))
+		}
+		if l.Failed != r.Failed {
+			reasons = append(reasons, fmt.Sprintf("trace failed: local %v, reference %v", l.Failed, r.Failed))
+		}
+		if !strings.EqualFold(trimHex(l.ReturnValue), trimHex(r.ReturnValue)) {
+			reasons = append(reasons, fmt.Sprintf("return value: local %s, reference %s", l.ReturnValue, r.ReturnValue))
+		}
+		if step = compareTrace(l, r); step != nil {
+			reasons = append(reasons, fmt.Sprintf("struct logs diverge at step %d", step.Index))
+		}
+	}
+	return reasons, step
+}
+
+// compareTrace returns the first step two traces differ at, or nil if they
+// agree. Stacks are only compared if both traces contain them.
+func compareTrace(local, ref *Trace) *Step {
+	for i := 0; i < len(local.StructLogs) || i < len(ref.StructLogs); i++ {
+		var l, r *StructLog
+		if i < len(local.StructLogs) {
+			l = &local.StructLogs[i]
+		}
+		if i < len(ref.StructLogs) {
+			r = &ref.StructLogs[i]
+		}
+		if l == nil || r == nil || !equalStep(l, r) {
+			return &Step{Index: i, Local: l, Reference: r}
+		}
+	}
+	return nil
+}
+
+// equalStep returns whether two struct logs describe the same step.
+func equalStep(a, b *StructLog) bool {
+	if a.Pc != b.Pc || a.Op != b.Op || a.Gas != b.Gas || a.GasCost != b.GasCost || a.Depth != b.Depth {
+		return false
+	}
+	if a.Stack == nil || b.Stack == nil {
+		return true
+	}
+	if len(a.Stack) != len(b.Stack) {
+		return false
+	}
+	for i := range a.Stack {
+		av, bv := stackValue(a.Stack[i]), stackValue(b.Stack[i])
+		if av == nil || bv == nil || av.Cmp(bv) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// LoadReference parses a reference outcome of a block: either a result saved
+// by a replay, or the output of debug_traceBlock, optionally still wrapped in
+// its JSON-RPC response.
+func LoadReference(blob []byte) (*Result, error) {
+	blob = bytes.TrimSpace(blob)
+	if len(blob) > 0 && blob[0] == '[' {
+		return fromTraces(blob)
+	}
+	var response struct {
+		Version string          `json:"jsonrpc"`
+		Result  json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(blob, &response); err == nil && response.Version != "" {
+		return LoadReference(response.Result)
+	}
+	result := new(Result)
+	if err := json.Unmarshal(blob, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// fromTraces converts the output of debug_traceBlock into a reference. Failed
+// traces are left out of the comparison.
+func fromTraces(blob []byte) (*Result, error) {
+	var traces []struct {
+		Result *Trace `json:"result"`
+		Error  string `json:"error"`
+	}
+	if err := json.Unmarshal(blob, &traces); err != nil {
+		return nil, err
+	}
+	result := new(Result)
+	for _, trace := range traces {
+		result.Txs = append(result.Txs, &Tx{Trace: trace.Result})
+	}
+	return result, nil
+}
@@ -0,0 +1,262 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package replay re-executes blocks to pin down consensus failures.
+//
+// A block is executed on top of the state of its parent, recording the state
+// root and receipt after every transaction. The outcome is compared against a
+// reference, such as a dump of the same replay on a healthy node, the output
+// of debug_traceBlock of another node or the header of the block itself. Upon
+// a divergence, the first diverging transaction is re-executed with a tracer
+// to find the first diverging step.
+package replay
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/consensus/misc"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// StructLog is a step of a transaction trace, in the format of the struct logs
+// of debug_traceBlock.
+type StructLog struct {
+	Pc      uint64   `json:"pc"`
+	Op      string   `json:"op"`
+	Gas     uint64   `json:"gas"`
+	GasCost uint64   `json:"gasCost"`
+	Depth   int      `json:"depth"`
+	Stack   []string `json:"stack,omitempty"`
+}
+
+// Trace is the struct log trace of a transaction, in the format of the results
+// of debug_traceBlock.
+type Trace struct {
+	Gas         uint64      `json:"gas"`
+	Failed      bool        `json:"failed"`
+	ReturnValue string      `json:"returnValue"`
+	StructLogs  []StructLog `json:"structLogs"`
+}
+
+// Receipt is the consensus relevant part of a transaction receipt.
+type Receipt struct {
+	Status            hexutil.Uint64 `json:"status"`
+	CumulativeGasUsed hexutil.Uint64 `json:"cumulativeGasUsed"`
+	GasUsed           hexutil.Uint64 `json:"gasUsed"`
+	Bloom             types.Bloom    `json:"logsBloom"`
+	LogsHash          common.Hash    `json:"logsHash"`
+}
+
+// Tx is the outcome of executing a transaction. Fields the source of the
+// outcome doesn't provide are left empty.
+type Tx struct {
+	Hash    common.Hash  `json:"hash"`
+	Root    *common.Hash `json:"root,omitempty"` // Intermediate state root after the transaction
+	Receipt *Receipt     `json:"receipt,omitempty"`
+	Trace   *Trace       `json:"trace,omitempty"`
+	Error   string       `json:"error,omitempty"` // Failure to apply the transaction
+}
+
+// Result is the outcome of executing a block. Fields the source of the outcome
+// doesn't provide are left empty.
+type Result struct {
+	Block       common.Hash     `json:"block"`
+	Root        *common.Hash    `json:"stateRoot,omitempty"` // State root after finalizing the block
+	ReceiptRoot *common.Hash    `json:"receiptsRoot,omitempty"`
+	Bloom       *types.Bloom    `json:"logsBloom,omitempty"`
+	GasUsed     *hexutil.Uint64 `json:"gasUsed,omitempty"`
+	Txs         []*Tx           `json:"txs"`
+}
+
+// FromHeader returns the outcome of the block claimed by its header, which
+// lacks the outcomes of the individual transactions.
+func FromHeader(block *types.Block) *Result {
+	var (
+		root        = block.Root()
+		receiptRoot = block.ReceiptHash()
+		bloom       = block.Bloom()
+		gasUsed     = hexutil.Uint64(block.GasUsed())
+	)
+	return &Result{
+		Block:       block.Hash(),
+		Root:        &root,
+		ReceiptRoot: &receiptRoot,
+		Bloom:       &bloom,
+		GasUsed:     &gasUsed,
+	}
+}
+
+// Execute runs the block on top of the state of its parent, tracing the
+// transactions the filter selects. Execution stops at the first transaction
+// that can't be applied.
+func Execute(chain *core.BlockChain, block *types.Block, statedb *state.StateDB, trace func(i int) bool) *Result {
+	var (
+		config   = chain.Config()
+		header   = block.Header()
+		gp       = new(core.GasPool).AddGas(block.GasLimit())
+		usedGas  = new(uint64)
+		receipts types.Receipts
+		result   = &Result{Block: block.Hash()}
+	)
+	// Mutate the state the same way the state processor does
+	if config.DAOForkSupport && config.DAOForkBlock != nil && config.DAOForkBlock.Cmp(block.Number()) == 0 {
+		misc.ApplyDAOHardFork(statedb)
+	}
+	for i, tx := range block.Transactions() {
+		var (
+			vmConf vm.Config
+			logger *vm.StructLogger
+		)
+		if trace != nil && trace(i) {
+			logger = vm.NewStructLogger(&vm.LogConfig{DisableMemory: true, DisableStorage: true, DisableReturnData: true})
+			vmConf = vm.Config{Debug: true, Tracer: logger}
+		}
+		statedb.Prepare(tx.Hash(), block.Hash(), i)
+
+		receipt, err := core.ApplyTransaction(config, chain, nil, gp, statedb, header, tx, usedGas, vmConf)
+		if err != nil {
+			result.Txs = append(result.Txs, &Tx{Hash: tx.Hash(), Error: err.Error()})
+			return result
+		}
+		receipts = append(receipts, receipt)
+
+		root := statedb.IntermediateRoot(config.IsEIP158(block.Number()))
+		outcome := &Tx{
+			Hash: tx.Hash(),
+			Root: &root,
+			Receipt: &Receipt{
+				Status:            hexutil.Uint64(receipt.Status),
+				CumulativeGasUsed: hexutil.Uint64(receipt.CumulativeGasUsed),
+				GasUsed:           hexutil.Uint64(receipt.GasUsed),
+				Bloom:             receipt.Bloom,
+				LogsHash:          rlpHash(receipt.Logs),
+			},
+		}
+		if logger != nil {
+			outcome.Trace = &Trace{
+				Gas:         receipt.GasUsed,
+				Failed:      receipt.Status == types.ReceiptStatusFailed,
+				ReturnValue: fmt.Sprintf("%x", logger.Output()),
+				StructLogs:  formatLogs(logger.StructLogs()),
+			}
+		}
+		result.Txs = append(result.Txs, outcome)
+	}
+	// Finalize the block, applying the rewards of the consensus engine
+	chain.Engine().Finalize(chain, header, statedb, block.Transactions(), block.Uncles())
+
+	var (
+		root        = statedb.IntermediateRoot(config.IsEIP158(block.Number()))
+		receiptRoot = types.DeriveSha(receipts, trie.NewStackTrie(nil))
+		bloom       = types.CreateBloom(receipts)
+		gasUsed     = hexutil.Uint64(*usedGas)
+	)
+	result.Root, result.ReceiptRoot, result.Bloom, result.GasUsed = &root, &receiptRoot, &bloom, &gasUsed
+	return result
+}
+
+// Replay executes the block, compares its outcome against the reference and
+// upon a divergence re-executes it, tracing the first diverging transaction to
+// locate the first diverging step.
+func Replay(chain *core.BlockChain, block *types.Block, ref *Result) (*Result, *Divergence, error) {
+	parent := chain.GetHeaderByHash(block.ParentHash())
+	if parent == nil {
+		return nil, nil, fmt.Errorf("parent block %x not found", block.ParentHash())
+	}
+	statedb, err := chain.StateAt(parent.Root)
+	if err != nil {
+		return nil, nil, fmt.Errorf("state of parent block %d [%x] not available: %v", parent.Number, block.ParentHash(), err)
+	}
+	local := Execute(chain, block, statedb.Copy(), nil)
+
+	d := Compare(local, ref)
+	if d != nil && d.Tx < 0 {
+		return local, d, nil
+	}
+	// Trace the transactions the reference has traces of, up to the first one
+	// known to diverge, to find the first diverging step
+	limit := len(ref.Txs)
+	if d != nil && d.Tx < limit {
+		limit = d.Tx + 1
+	}
+	traced := func(i int) bool {
+		return i < limit && ref.Txs[i].Trace != nil
+	}
+	for i := 0; i < limit; i++ {
+		if traced(i) {
+			local = Execute(chain, block, statedb, traced)
+			return local, Compare(local, ref), nil
+		}
+	}
+	return local, d, nil
+}
+
+// formatLogs converts the struct logs of the tracer into the format of
+// debug_traceBlock.
+func formatLogs(logs []vm.StructLog) []StructLog {
+	formatted := make([]StructLog, len(logs))
+	for i, log := range logs {
+		formatted[i] = StructLog{
+			Pc:      log.Pc,
+			Op:      log.Op.String(),
+			Gas:     log.Gas,
+			GasCost: log.GasCost,
+			Depth:   log.Depth,
+		}
+		if log.Stack != nil {
+			formatted[i].Stack = make([]string, len(log.Stack))
+			for j, value := range log.Stack {
+				formatted[i].Stack[j] = fmt.Sprintf("%x", math.PaddedBigBytes(value, 32))
+			}
+		}
+	}
+	return formatted
+}
+
+// stackValue parses a stack item, accepting both the padded and the compact
+// hex formats of the implementations.
+func stackValue(item string) *big.Int {
+	value, ok := new(big.Int).SetString(trimHex(item), 16)
+	if !ok {
+		return nil
+	}
+	return value
+}
+
+// trimHex strips the hex prefix of a string, if any.
+func trimHex(s string) string {
+	if len(s) >= 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X') {
+		return s[2:]
+	}
+	return s
+}
+
+func rlpHash(x interface{}) (h common.Hash) {
+	hw := crypto.NewKeccakState()
+	rlp.Encode(hw, x)
+	hw.Read(h[:])
+	return h
+}
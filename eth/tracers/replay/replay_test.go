@@ -0,0 +1,185 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package replay
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// newTestChain creates a chain with a block of three transactions, each storing
+// and logging its calldata in a contract.
+func newTestChain(t *testing.T) (*core.BlockChain, *types.Block) {
+	var (
+		key, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender   = crypto.PubkeyToAddress(key.PublicKey)
+		contract = common.HexToAddress("0xc0de")
+		gspec    = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				sender: {Balance: big.NewInt(params.Ether)},
+				// calldataload(0) sstore(0), mstore(0), log0(0, 32)
+				contract: {Code: common.FromHex("0x6000358060005560005260206000a000"), Balance: new(big.Int)},
+			},
+		}
+		signer = types.LatestSigner(gspec.Config)
+	)
+	gendb := rawdb.NewMemoryDatabase()
+	genesis := gspec.MustCommit(gendb)
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, 1, func(i int, b *core.BlockGen) {
+		for j := 0; j < 3; j++ {
+			data := common.LeftPadBytes([]byte{byte(j + 1)}, 32)
+			tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(sender), contract, nil, 100000, big.NewInt(1), data), signer, key)
+			b.AddTx(tx)
+		}
+	})
+	db := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(db)
+
+	chain, err := core.NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	return chain, blocks[0]
+}
+
+// Tests replaying blocks against the outcome claimed by their header.
+func TestReplayHeader(t *testing.T) {
+	chain, block := newTestChain(t)
+	defer chain.Stop()
+
+	local, d, err := Replay(chain, block, FromHeader(block))
+	if err != nil {
+		t.Fatalf("failed to replay block: %v", err)
+	}
+	if d != nil {
+		t.Fatalf("unexpected divergence: %v", d)
+	}
+	if len(local.Txs) != 3 {
+		t.Fatalf("transaction count mismatch: have %d, want 3", len(local.Txs))
+	}
+	// Tamper with the state root, the divergence can't be pinned on a transaction
+	header := block.Header()
+	header.Root = common.Hash{0x01}
+	bad := block.WithSeal(header)
+
+	if _, d, err = Replay(chain, bad, FromHeader(bad)); err != nil {
+		t.Fatalf("failed to replay bad block: %v", err)
+	}
+	if d == nil || d.Tx != -1 || !strings.Contains(d.String(), "state root") {
+		t.Fatalf("block divergence mismatch: %v", d)
+	}
+}
+
+// Tests replaying blocks against the result saved by a replay elsewhere.
+func TestReplayDump(t *testing.T) {
+	chain, block := newTestChain(t)
+	defer chain.Stop()
+
+	local, _, err := Replay(chain, block, FromHeader(block))
+	if err != nil {
+		t.Fatalf("failed to replay block: %v", err)
+	}
+	blob, _ := json.Marshal(local)
+
+	ref, err := LoadReference(blob)
+	if err != nil {
+		t.Fatalf("failed to load reference: %v", err)
+	}
+	if _, d, _ := Replay(chain, block, ref); d != nil {
+		t.Fatalf("unexpected divergence: %v", d)
+	}
+	// Diverge the state after the second transaction onwards
+	for _, tx := range ref.Txs[1:] {
+		tx.Root = &common.Hash{0x02}
+	}
+	_, d, err := Replay(chain, block, ref)
+	if err != nil {
+		t.Fatalf("failed to replay block: %v", err)
+	}
+	if d == nil || d.Tx != 1 || d.TxHash != block.Transactions()[1].Hash() {
+		t.Fatalf("divergence mismatch: %v", d)
+	}
+	// Receipts diverging without the roots must be found too
+	ref, _ = LoadReference(blob)
+	ref.Txs[2].Receipt.LogsHash = common.Hash{0x03}
+	if _, d, _ = Replay(chain, block, ref); d == nil || d.Tx != 2 {
+		t.Fatalf("receipt divergence mismatch: %v", d)
+	}
+}
+
+// Tests replaying blocks against the output of debug_traceBlock, pinning down
+// the diverging step.
+func TestReplayTraces(t *testing.T) {
+	chain, block := newTestChain(t)
+	defer chain.Stop()
+
+	parent := chain.GetBlockByHash(block.ParentHash())
+	statedb, _ := chain.StateAt(parent.Root())
+	traced := Execute(chain, block, statedb, func(int) bool { return true })
+
+	// Assemble the traces like another implementation would, using compact stack
+	// items and wrapped into the JSON-RPC response
+	var traces []map[string]interface{}
+	for _, tx := range traced.Txs {
+		for i, log := range tx.Trace.StructLogs {
+			for j, item := range log.Stack {
+				tx.Trace.StructLogs[i].Stack[j] = fmt.Sprintf("0x%x", stackValue(item))
+			}
+		}
+		traces = append(traces, map[string]interface{}{"result": tx.Trace})
+	}
+	load := func() *Result {
+		blob, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "result": traces})
+		ref, err := LoadReference(blob)
+		if err != nil {
+			t.Fatalf("failed to load reference: %v", err)
+		}
+		return ref
+	}
+	if _, d, _ := Replay(chain, block, load()); d != nil {
+		t.Fatalf("unexpected divergence: %v", d)
+	}
+	// Diverge a stack item of the last transaction, which leaves the gas intact
+	traced.Txs[2].Trace.StructLogs[3].Stack[0] = "0x2a"
+
+	_, d, err := Replay(chain, block, load())
+	if err != nil {
+		t.Fatalf("failed to replay block: %v", err)
+	}
+	if d == nil || d.Tx != 2 || d.Step == nil || d.Step.Index != 3 {
+		t.Fatalf("divergence mismatch: %v", d)
+	}
+	if d.Step.Local.Op != "PUSH1" || !strings.Contains(d.String(), "First diverging step 3") {
+		t.Errorf("step report mismatch:\n%v", d)
+	}
+}
@@ -0,0 +1,359 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package sourcemap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/compiler"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Config is the compiler output to annotate traces with.
+type Config struct {
+	// CombinedJSON is the output of solc --combined-json, containing at least
+	// bin-runtime and srcmap-runtime, and bin and srcmap to annotate contract
+	// creations too.
+	CombinedJSON json.RawMessage `json:"combinedJson"`
+
+	// Sources maps the source file names in the sourceList of the compiler
+	// output to their contents. Files missing are annotated without lines.
+	Sources map[string]string `json:"sources"`
+}
+
+// Location is a position in the source code.
+type Location struct {
+	Contract string `json:"contract"`           // Name of the contract the code belongs to
+	File     string `json:"file,omitempty"`     // Source file name
+	Line     int    `json:"line,omitempty"`     // Line number, starting from 1
+	Column   int    `json:"column,omitempty"`   // Column number in bytes, starting from 1
+	Function string `json:"function,omitempty"` // Enclosing function or modifier
+}
+
+// String implements fmt.Stringer, formatting the location like a compiler
+// diagnostic.
+func (l *Location) String() string {
+	loc := l.Contract
+	if l.Function != "" {
+		loc += "." + l.Function
+	}
+	if l.File != "" {
+		loc = fmt.Sprintf("%s (%s:%d:%d)", loc, l.File, l.Line, l.Column)
+	}
+	return loc
+}
+
+// sourceFile is a source file with the information needed to resolve offsets.
+type sourceFile struct {
+	name      string
+	lines     []int      // Offsets of the line starts
+	functions []function // Function definitions, in order of appearance
+}
+
+// function is the byte range of a function, modifier or constructor definition.
+type function struct {
+	name       string
+	start, end int
+}
+
+// functionRegexp matches the start of function-like definitions.
+var functionRegexp = regexp.MustCompile(`\b(?:function\s+(\w+)|modifier\s+(\w+)|(constructor|fallback|receive))\s*\(`)
+
+// newSourceFile indexes the lines and functions of a source file. Functions are
+// found heuristically by matching their braces, ignoring the contents of string
+// literals and comments.
+func newSourceFile(name, source string) *sourceFile {
+	file := &sourceFile{name: name, lines: []int{0}}
+	for i := 0; i < len(source); i++ {
+		if source[i] == '\n' {
+			file.lines = append(file.lines, i+1)
+		}
+	}
+	for _, match := range functionRegexp.FindAllStringSubmatchIndex(source, -1) {
+		var name string
+		for i := 2; i < len(match); i += 2 {
+			if match[i] >= 0 {
+				name = source[match[i]:match[i+1]]
+			}
+		}
+		body := strings.IndexAny(source[match[1]:], "{;")
+		if body < 0 || source[match[1]+body] == ';' {
+			continue // Declaration without implementation
+		}
+		start := match[1] + body
+		if end := matchBrace(source, start); end > 0 {
+			file.functions = append(file.functions, function{name: name, start: match[0], end: end})
+		}
+	}
+	return file
+}
+
+// matchBrace returns the offset past the brace closing the one at start, or -1
+// if it is unbalanced.
+func matchBrace(source string, start int) int {
+	depth := 0
+	for i := start; i < len(source); i++ {
+		switch {
+		case strings.HasPrefix(source[i:], "//"):
+			if end := strings.IndexByte(source[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				return -1
+			}
+		case strings.HasPrefix(source[i:], "/*"):
+			if end := strings.Index(source[i:], "*/"); end >= 0 {
+				i += end + 1
+			} else {
+				return -1
+			}
+		case source[i] == '"' || source[i] == '\'':
+			for j := i + 1; j < len(source); j++ {
+				if source[j] == '\\' {
+					j++
+				} else if source[j] == source[i] {
+					i = j
+					break
+				}
+			}
+		case source[i] == '{':
+			depth++
+		case source[i] == '}':
+			if depth--; depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
+
+// locate resolves a source range into a location.
+func (f *sourceFile) locate(loc *Location, offset, length int) {
+	line := sort.Search(len(f.lines), func(i int) bool { return f.lines[i] > offset }) - 1
+	loc.File = f.name
+	loc.Line = line + 1
+	loc.Column = offset - f.lines[line] + 1
+
+	// Find the innermost function enclosing the range
+	for _, fn := range f.functions {
+		if fn.start <= offset && offset+length <= fn.end {
+			loc.Function = fn.name
+		}
+	}
+}
+
+// codeMap maps the program counters of a contract's code to source locations.
+type codeMap struct {
+	contract string
+	entries  []compiler.SourceMapEntry // Source map entries, by instruction index
+	index    []int                     // Instruction index by program counter, -1 for push data
+	sources  []*sourceFile             // Source files, by source list index
+	files    []string                  // Source file names, by source list index
+}
+
+// newCodeMap creates the mapping of a contract's code to its source map.
+func newCodeMap(contract string, code []byte, srcmap string, sources []*sourceFile, files []string) (*codeMap, error) {
+	entries, err := compiler.ParseSourceMap(srcmap)
+	if err != nil {
+		return nil, err
+	}
+	m := &codeMap{
+		contract: contract,
+		entries:  entries,
+		index:    make([]int, len(code)),
+		sources:  sources,
+		files:    files,
+	}
+	for pc, n := 0, 0; pc < len(code); n++ {
+		m.index[pc] = n
+		next := pc + 1
+		if op := vm.OpCode(code[pc]); op.IsPush() {
+			next += int(op - vm.PUSH1 + 1)
+		}
+		for pc++; pc < next && pc < len(code); pc++ {
+			m.index[pc] = -1
+		}
+	}
+	return m, nil
+}
+
+// entry returns the source map entry of the instruction at a program counter.
+func (m *codeMap) entry(pc uint64) (compiler.SourceMapEntry, bool) {
+	if pc >= uint64(len(m.index)) || m.index[pc] < 0 || m.index[pc] >= len(m.entries) {
+		return compiler.SourceMapEntry{}, false
+	}
+	return m.entries[m.index[pc]], true
+}
+
+// locate returns the source location of the instruction at a program counter.
+// Instructions without a source location are attributed to the contract only.
+func (m *codeMap) locate(pc uint64) *Location {
+	loc := &Location{Contract: m.contract}
+
+	entry, ok := m.entry(pc)
+	if !ok || entry.File < 0 || entry.File >= len(m.files) {
+		return loc
+	}
+	if source := m.sources[entry.File]; source != nil {
+		source.locate(loc, entry.Offset, entry.Length)
+	} else {
+		loc.File = m.files[entry.File]
+	}
+	return loc
+}
+
+// artifact is the compiled code of a contract with its source maps.
+type artifact struct {
+	name     string
+	creation []byte // Creation code, nil if unavailable
+	srcmap   string // Source map of the creation code
+	runtime  *codeMap
+}
+
+// artifacts is the set of compiled contracts to match executed code against.
+type artifacts struct {
+	contracts []*artifact
+	runtimes  map[common.Hash]*artifact // Runtime artifacts by code skeleton hash
+	sources   []*sourceFile
+	files     []string
+}
+
+// newArtifacts parses the compiler output and indexes the contained contracts.
+func newArtifacts(config *Config) (*artifacts, error) {
+	if config == nil || len(config.CombinedJSON) == 0 {
+		return nil, errors.New("no compiler output")
+	}
+	// The combined output may either be passed as JSON object or JSON string
+	blob := []byte(config.CombinedJSON)
+	var str string
+	if err := json.Unmarshal(blob, &str); err == nil {
+		blob = []byte(str)
+	}
+	contracts, err := compiler.ParseCombinedJSON(blob, "", "", "", "")
+	if err != nil {
+		return nil, err
+	}
+	a := &artifacts{runtimes: make(map[common.Hash]*artifact)}
+
+	names := make([]string, 0, len(contracts))
+	for name := range contracts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		contract := contracts[name]
+		if a.files == nil {
+			a.files = contract.Info.SourceList
+			for _, file := range a.files {
+				var source *sourceFile
+				if content, ok := config.Sources[file]; ok {
+					source = newSourceFile(file, content)
+				}
+				a.sources = append(a.sources, source)
+			}
+		}
+		short := name
+		if i := strings.LastIndex(name, ":"); i >= 0 {
+			short = name[i+1:]
+		}
+		art := &artifact{name: short}
+		if code := decodeCode(contract.RuntimeCode); len(code) > 0 {
+			if art.runtime, err = newCodeMap(short, code, contract.Info.SrcMapRuntime, a.sources, a.files); err != nil {
+				return nil, fmt.Errorf("contract %s: %v", name, err)
+			}
+			a.runtimes[skeletonHash(code)] = art
+		}
+		if srcmap, ok := contract.Info.SrcMap.(string); ok {
+			art.creation, art.srcmap = decodeCode(contract.Code), srcmap
+		}
+		a.contracts = append(a.contracts, art)
+	}
+	return a, nil
+}
+
+// decodeCode decodes hex encoded bytecode, zeroing unresolved library link
+// placeholders.
+func decodeCode(hexcode string) []byte {
+	hexcode = strings.TrimPrefix(hexcode, "0x")
+	for {
+		start := strings.Index(hexcode, "__")
+		if start < 0 || start+40 > len(hexcode) {
+			break
+		}
+		hexcode = hexcode[:start] + strings.Repeat("0", 40) + hexcode[start+40:]
+	}
+	return common.FromHex(hexcode)
+}
+
+// skeleton returns the code with all push data and the trailing metadata hash
+// zeroed, so that deployed code matches its compiler output regardless of
+// immutables, linked libraries or metadata differences.
+func skeleton(code []byte) []byte {
+	skel := common.CopyBytes(code)
+
+	// Strip the CBOR encoded metadata, its length is in the last two bytes
+	if n := len(skel); n >= 2 {
+		if meta := int(skel[n-2])<<8 | int(skel[n-1]); meta+2 <= n && meta > 0 && skel[n-meta-2] >= 0xa0 && skel[n-meta-2] <= 0xbf {
+			skel = skel[:n-meta-2]
+		}
+	}
+	for pc := 0; pc < len(skel); pc++ {
+		if op := vm.OpCode(skel[pc]); op.IsPush() {
+			for i := 0; i < int(op-vm.PUSH1+1) && pc+1 < len(skel); i++ {
+				pc++
+				skel[pc] = 0
+			}
+		}
+	}
+	return skel
+}
+
+// skeletonHash returns the hash of a code skeleton.
+func skeletonHash(code []byte) common.Hash {
+	return crypto.Keccak256Hash(skeleton(code))
+}
+
+// match returns the code map of the executed code, or nil if it's not part of
+// the compiler output.
+func (a *artifacts) match(code []byte, create bool) *codeMap {
+	if !create {
+		if art := a.runtimes[skeletonHash(code)]; art != nil {
+			return art.runtime
+		}
+		return nil
+	}
+	// Creation code is followed by the constructor arguments, match prefixes
+	for _, art := range a.contracts {
+		if len(art.creation) == 0 || len(code) < len(art.creation) {
+			continue
+		}
+		if string(skeleton(code[:len(art.creation)])) == string(skeleton(art.creation)) {
+			m, err := newCodeMap(art.name, code, art.srcmap, a.sources, a.files)
+			if err != nil {
+				return nil
+			}
+			return m
+		}
+	}
+	return nil
+}
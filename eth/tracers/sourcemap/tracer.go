@@ -0,0 +1,232 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package sourcemap implements a tracer annotating the executed instructions
+// with the Solidity source code they were compiled from, using the source maps
+// emitted by solc.
+package sourcemap
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/compiler"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// Step is an executed instruction annotated with its source location.
+type Step struct {
+	Pc      uint64    `json:"pc"`
+	Op      string    `json:"op"`
+	Gas     uint64    `json:"gas"`
+	GasCost uint64    `json:"gasCost"`
+	Depth   int       `json:"depth"`
+	Error   string    `json:"error,omitempty"`
+	Source  *Location `json:"source,omitempty"`
+}
+
+// Call is a call frame annotated with the contract and functions executed.
+type Call struct {
+	Depth   int            `json:"depth"`
+	Address common.Address `json:"address"`
+	Create  bool           `json:"create,omitempty"`
+	Entry   *Location      `json:"entry,omitempty"` // First location within a function
+	Exit    *Location      `json:"exit,omitempty"`  // Last location executed
+}
+
+// StackFrame is an entry of a source level stack trace.
+type StackFrame struct {
+	Address  common.Address `json:"address"`
+	Location *Location      `json:"location,omitempty"`
+}
+
+// Result is the annotated trace of an execution.
+type Result struct {
+	Gas         uint64       `json:"gas"`
+	Failed      bool         `json:"failed"`
+	ReturnValue string       `json:"returnValue"`
+	StructLogs  []Step       `json:"structLogs"`
+	Calls       []*Call      `json:"calls"`
+	StackTrace  []StackFrame `json:"stackTrace,omitempty"` // Innermost first, if the execution failed
+}
+
+// frame is the tracking state of a call frame.
+type frame struct {
+	address  common.Address
+	code     *codeMap    // Source mapping of the executed code, nil if unknown
+	call     *Call       // Annotated call of the frame
+	last     *Location   // Location of the last executed instruction
+	internal []*Location // Call sites of the internal functions being executed
+}
+
+// Tracer is a vm.Tracer collecting the executed instructions and call frames
+// annotated with their source locations.
+type Tracer struct {
+	artifacts *artifacts
+	runtimes  map[common.Hash]*codeMap // Runtime code maps by code hash, nil if unknown
+	creations map[common.Hash]*codeMap // Creation code maps by code hash, nil if unknown
+
+	frames []*frame
+	create bool // Whether the next frame entered is a contract creation
+
+	steps      []Step
+	calls      []*Call
+	trace      []StackFrame // Stack trace of the last revert
+	traceDepth int          // Depth the last revert originated at
+
+	output  []byte
+	gasUsed uint64
+	err     error
+}
+
+// New creates a tracer annotating executions with the given compiler output.
+func New(config *Config) (*Tracer, error) {
+	artifacts, err := newArtifacts(config)
+	if err != nil {
+		return nil, err
+	}
+	return &Tracer{
+		artifacts: artifacts,
+		runtimes:  make(map[common.Hash]*codeMap),
+		creations: make(map[common.Hash]*codeMap),
+	}, nil
+}
+
+// CaptureStart implements vm.Tracer.
+func (t *Tracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	t.create = create
+	return nil
+}
+
+// CaptureState implements vm.Tracer, annotating an instruction and tracking the
+// call frames it belongs to.
+func (t *Tracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, rData []byte, contract *vm.Contract, depth int, err error) error {
+	// Leave returned frames and enter new ones
+	if depth < len(t.frames) {
+		t.frames = t.frames[:depth]
+	}
+	if depth > len(t.frames) {
+		t.enter(contract, depth)
+	}
+	f := t.frames[len(t.frames)-1]
+
+	step := Step{Pc: pc, Op: op.String(), Gas: gas, GasCost: cost, Depth: depth}
+	if err != nil {
+		step.Error = err.Error()
+	}
+	if f.code != nil {
+		step.Source = f.code.locate(pc)
+		f.last = step.Source
+
+		if f.call.Entry == nil && step.Source.Function != "" {
+			f.call.Entry = step.Source
+		}
+		f.call.Exit = step.Source
+
+		// Track the internal function calls using the jump annotations
+		if entry, ok := f.code.entry(pc); ok && op == vm.JUMP {
+			switch entry.Jump {
+			case compiler.JumpInto:
+				f.internal = append(f.internal, step.Source)
+			case compiler.JumpOutOf:
+				if len(f.internal) > 0 {
+					f.internal = f.internal[:len(f.internal)-1]
+				}
+			}
+		}
+	}
+	t.steps = append(t.steps, step)
+	t.create = op == vm.CREATE || op == vm.CREATE2
+
+	if op == vm.REVERT || err != nil {
+		t.captureTrace(depth)
+	}
+	return nil
+}
+
+// enter starts tracking a new call frame.
+func (t *Tracer) enter(contract *vm.Contract, depth int) {
+	cache := t.runtimes
+	if t.create {
+		cache = t.creations
+	}
+	code, ok := cache[contract.CodeHash]
+	if !ok {
+		code = t.artifacts.match(contract.Code, t.create)
+		cache[contract.CodeHash] = code
+	}
+	f := &frame{
+		address: contract.Address(),
+		code:    code,
+		call:    &Call{Depth: depth, Address: contract.Address(), Create: t.create},
+	}
+	t.frames = append(t.frames, f)
+	t.calls = append(t.calls, f.call)
+}
+
+// captureTrace records the source level stack trace of a revert. Reverts in
+// parent frames of the last one are assumed to propagate it and are ignored to
+// keep the trace of the origin.
+func (t *Tracer) captureTrace(depth int) {
+	if t.trace != nil && depth < t.traceDepth {
+		return
+	}
+	t.trace, t.traceDepth = nil, depth
+	for i := len(t.frames) - 1; i >= 0; i-- {
+		f := t.frames[i]
+		t.trace = append(t.trace, StackFrame{Address: f.address, Location: f.last})
+		for j := len(f.internal) - 1; j >= 0; j-- {
+			t.trace = append(t.trace, StackFrame{Address: f.address, Location: f.internal[j]})
+		}
+	}
+}
+
+// CaptureFault implements vm.Tracer, recording the stack trace of the failure.
+func (t *Tracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if op != vm.REVERT {
+		t.captureTrace(depth)
+	}
+	return nil
+}
+
+// CaptureEnd implements vm.Tracer, recording the outcome of the execution.
+func (t *Tracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	t.output, t.gasUsed, t.err = common.CopyBytes(output), gasUsed, err
+	return nil
+}
+
+// Result returns the annotated trace of the execution.
+func (t *Tracer) Result() *Result {
+	result := &Result{
+		Gas:         t.gasUsed,
+		Failed:      t.err != nil,
+		ReturnValue: fmt.Sprintf("%x", t.output),
+		StructLogs:  t.steps,
+		Calls:       t.calls,
+	}
+	if result.StructLogs == nil {
+		result.StructLogs = []Step{}
+	}
+	if result.Calls == nil {
+		result.Calls = []*Call{}
+	}
+	if result.Failed {
+		result.StackTrace = t.trace
+	}
+	return result
+}
@@ -0,0 +1,153 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package sourcemap
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/runtime"
+)
+
+const testSource = `pragma solidity >0.0.0;
+
+contract Test {
+    function fail() public {
+        // Calls the failing check, "{" in comments is ignored
+        check(1);
+    }
+
+    function check(uint x) internal {
+        revert();
+    }
+
+    function declared() external;
+}
+`
+
+// testArtifact assembles the compiler output of the test contract by hand:
+//
+//   0 PUSH1 6, 2 PUSH1 7, 4 JUMP (into check), 5 STOP, 6 JUMPDEST,
+//   7 JUMPDEST (check), 8 PUSH1 0, 10 DUP1, 11 REVERT
+func testArtifact() (*Config, []byte) {
+	var (
+		call  = strings.Index(testSource, "check(1)")
+		check = strings.Index(testSource, "function check")
+		rev   = strings.Index(testSource, "revert()")
+	)
+	srcmap := fmt.Sprintf("%d:8:0:-;;:::i;;;%d:60;%d:8;;", call, check, rev)
+	code := common.Hex2Bytes("6006600756005b5b600080fd")
+
+	combined, _ := json.Marshal(map[string]interface{}{
+		"contracts": map[string]interface{}{
+			"Test.sol:Test": map[string]string{
+				"bin-runtime":    common.Bytes2Hex(code),
+				"srcmap-runtime": srcmap,
+				"abi":            "[]",
+			},
+		},
+		"sourceList": []string{"Test.sol"},
+		"version":    "0.8.0",
+	})
+	return &Config{CombinedJSON: combined, Sources: map[string]string{"Test.sol": testSource}}, code
+}
+
+// Tests that instructions and reverts are annotated with their source.
+func TestSourceTrace(t *testing.T) {
+	config, code := testArtifact()
+	tracer, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create tracer: %v", err)
+	}
+	// Deploy the code with a different push value, as if it were an immutable
+	code[1] = 0x09
+
+	address := common.HexToAddress("0xc0de")
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.SetCode(address, code)
+
+	_, _, err = runtime.Call(address, nil, &runtime.Config{
+		State:     statedb,
+		EVMConfig: vm.Config{Debug: true, Tracer: tracer},
+	})
+	if err != vm.ErrExecutionReverted {
+		t.Fatalf("execution error mismatch: have %v, want %v", err, vm.ErrExecutionReverted)
+	}
+	result := tracer.Result()
+	if !result.Failed || len(result.StructLogs) != 7 || len(result.Calls) != 1 {
+		t.Fatalf("unexpected result: failed %v, %d steps, %d calls", result.Failed, len(result.StructLogs), len(result.Calls))
+	}
+	first := result.StructLogs[0].Source
+	if first == nil || first.Contract != "Test" || first.File != "Test.sol" || first.Line != 6 || first.Column != 9 || first.Function != "fail" {
+		t.Errorf("first step location mismatch: %+v", first)
+	}
+	if call := result.Calls[0]; call.Entry.Function != "fail" || call.Exit.Function != "check" {
+		t.Errorf("call annotation mismatch: entry %v, exit %v", call.Entry, call.Exit)
+	}
+	want := []string{"Test.check (Test.sol:10:9)", "Test.fail (Test.sol:6:9)"}
+	if len(result.StackTrace) != len(want) {
+		t.Fatalf("stack trace length mismatch: have %d, want %d", len(result.StackTrace), len(want))
+	}
+	for i, frame := range result.StackTrace {
+		if frame.Address != address || frame.Location.String() != want[i] {
+			t.Errorf("stack frame %d mismatch: have %x %v, want %s", i, frame.Address, frame.Location, want[i])
+		}
+	}
+}
+
+// Tests that unknown code is traced without annotations.
+func TestSourceTraceUnknownCode(t *testing.T) {
+	config, _ := testArtifact()
+	tracer, _ := New(config)
+
+	address := common.HexToAddress("0xc0de")
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.SetCode(address, common.Hex2Bytes("600160020100"))
+
+	runtime.Call(address, nil, &runtime.Config{
+		State:     statedb,
+		EVMConfig: vm.Config{Debug: true, Tracer: tracer},
+	})
+	result := tracer.Result()
+	if result.Failed || len(result.StructLogs) != 4 {
+		t.Fatalf("unexpected result: failed %v, %d steps", result.Failed, len(result.StructLogs))
+	}
+	for i, step := range result.StructLogs {
+		if step.Source != nil {
+			t.Errorf("step %d: unknown code annotated: %v", i, step.Source)
+		}
+	}
+}
+
+// Tests that function definitions are found in the sources.
+func TestSourceFunctions(t *testing.T) {
+	file := newSourceFile("Test.sol", testSource)
+
+	var names []string
+	for _, fn := range file.functions {
+		names = append(names, fn.name)
+	}
+	if have := strings.Join(names, ","); have != "fail,check" {
+		t.Errorf("functions mismatch: have %s, want fail,check", have)
+	}
+}
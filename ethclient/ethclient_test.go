@@ -265,6 +265,9 @@ func TestEthClient(t *testing.T) {
 		"TestAtFunctions": {
 			func(t *testing.T) { testAtFunctions(t, client) },
 		},
+		"TestCreateAccessList": {
+			func(t *testing.T) { testCreateAccessList(t, client) },
+		},
 	}
 
 	t.Parallel()
@@ -489,6 +492,36 @@ func testCallContract(t *testing.T, client *rpc.Client) {
 	}
 }
 
+func testCreateAccessList(t *testing.T, client *rpc.Client) {
+	ec := NewClient(client)
+
+	// Accounts and slots given upfront are retained in the created list
+	prefilled := types.AccessList{{
+		Address:     common.HexToAddress("0xdead"),
+		StorageKeys: []common.Hash{common.HexToHash("0x01")},
+	}}
+	msg := ethereum.CallMsg{
+		From:       testAddr,
+		To:         &common.Address{},
+		GasPrice:   big.NewInt(1),
+		Value:      big.NewInt(1),
+		AccessList: prefilled,
+	}
+	acl, gas, vmerr, err := ec.CreateAccessList(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if vmerr != "" {
+		t.Fatalf("unexpected execution error: %v", vmerr)
+	}
+	if !reflect.DeepEqual(*acl, prefilled) {
+		t.Fatalf("access list mismatch: have %v, want %v", *acl, prefilled)
+	}
+	if want := params.TxGas + params.TxAccessListAddressGas + params.TxAccessListStorageKeyGas; gas != want {
+		t.Fatalf("gas used mismatch: have %d, want %d", gas, want)
+	}
+}
+
 func testAtFunctions(t *testing.T, client *rpc.Client) {
 	ec := NewClient(client)
 	// send a transaction for some interesting pending status
@@ -337,6 +337,22 @@ func (h *clientHandler) handleMsg(p *serverPeer) error {
 			ReqID:   resp.ReqID,
 			Obj:     resp.Status,
 		}
+	case msg.Code == SnapRangesMsg && p.version >= lpv5:
+		p.Log().Trace("Received state range response")
+		var resp struct {
+			ReqID, BV uint64
+			Data      []SnapRangeResp
+		}
+		if err := msg.Decode(&resp); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		p.fcServer.ReceivedReply(resp.ReqID, resp.BV)
+		p.answeredRequest(resp.ReqID)
+		deliverMsg = &Msg{
+			MsgType: MsgSnapRanges,
+			ReqID:   resp.ReqID,
+			Obj:     resp.Data,
+		}
 	case msg.Code == StopMsg && p.version >= lpv3:
 		p.freeze()
 		h.backend.retriever.frozen(p)
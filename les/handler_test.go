@@ -17,6 +17,7 @@
 package les
 
 import (
+	"bytes"
 	"encoding/binary"
 	"math/big"
 	"math/rand"
@@ -469,6 +470,83 @@ func testGetStaleProof(t *testing.T, protocol int) {
 	check(bc.CurrentHeader().Number.Uint64(), true) // Fresh proof
 }
 
+// Tests that account ranges can be retrieved along with their range proofs.
+func TestGetSnapRangesLes5(t *testing.T) { testGetSnapRanges(t, lpv5) }
+
+func testGetSnapRanges(t *testing.T, protocol int) {
+	netconfig := testnetConfig{
+		blocks:    core.TriesInMemory + 4,
+		protocol:  protocol,
+		nopruning: true,
+	}
+	server, _, tearDown := newClientServerEnv(t, netconfig)
+	defer tearDown()
+
+	rawPeer, closePeer, _ := server.newRawPeer(t, "peer", protocol)
+	defer closePeer()
+
+	bc := server.handler.blockchain
+
+	check := func(number uint64, wantOK bool) {
+		header := bc.GetHeaderByNumber(number)
+		req := SnapRangeReq{
+			BHash: header.Hash(),
+			Limit: common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"),
+		}
+		sendRequest(rawPeer.app, GetSnapRangesMsg, 42, []SnapRangeReq{req})
+
+		msg, err := rawPeer.app.ReadMsg()
+		if err != nil {
+			t.Fatalf("failed to read response: %v", err)
+		}
+		if msg.Code != SnapRangesMsg {
+			t.Fatalf("message code mismatch: have %d, want %d", msg.Code, SnapRangesMsg)
+		}
+		var resp struct {
+			ReqID, BV uint64
+			Data      []SnapRangeResp
+		}
+		if err := msg.Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if len(resp.Data) != 1 {
+			t.Fatalf("response count mismatch: have %d, want 1", len(resp.Data))
+		}
+		if !wantOK {
+			if len(resp.Data[0].Keys) != 0 || len(resp.Data[0].Proof) != 0 {
+				t.Fatalf("unexpected range served for block %d", number)
+			}
+			return
+		}
+		// Collect the expected accounts from the trie
+		tr, _ := trie.New(header.Root, trie.NewDatabase(server.db))
+		it := trie.NewIterator(tr.NodeIterator(nil))
+		var keys [][]byte
+		for it.Next() {
+			keys = append(keys, it.Key)
+		}
+		if len(resp.Data[0].Keys) != len(keys) {
+			t.Fatalf("account count mismatch: have %d, want %d", len(resp.Data[0].Keys), len(keys))
+		}
+		have := make([][]byte, len(resp.Data[0].Keys))
+		for i, key := range resp.Data[0].Keys {
+			if !bytes.Equal(key[:], keys[i]) {
+				t.Fatalf("account %d mismatch: have %x, want %x", i, key, keys[i])
+			}
+			have[i] = common.CopyBytes(key[:])
+		}
+		_, _, _, more, err := trie.VerifyRangeProof(header.Root, req.Origin[:], have[len(have)-1], have, resp.Data[0].Values, resp.Data[0].Proof.NodeSet())
+		if err != nil {
+			t.Fatalf("invalid range proof: %v", err)
+		}
+		if more {
+			t.Fatalf("unexpected trailing accounts")
+		}
+	}
+	check(2, false)                                 // Stale state
+	check(bc.CurrentHeader().Number.Uint64(), true) // Fresh state
+}
+
 // Tests that CHT proofs can be correctly retrieved.
 func TestGetCHTProofsLes2(t *testing.T) { testGetCHTProofs(t, 2) }
 func TestGetCHTProofsLes3(t *testing.T) { testGetCHTProofs(t, 3) }
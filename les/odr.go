@@ -92,6 +92,7 @@ const (
 	MsgProofsV2
 	MsgHelperTrieProofs
 	MsgTxStatus
+	MsgSnapRanges
 )
 
 // Msg encodes a LES message that delivers reply data for a request
@@ -17,6 +17,7 @@
 package les
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -43,6 +44,7 @@ var (
 	errCHTHashMismatch     = errors.New("cht hash mismatch")
 	errCHTNumberMismatch   = errors.New("cht number mismatch")
 	errUselessNodes        = errors.New("useless nodes in merkle proof nodeset")
+	errRangeOutOfBounds    = errors.New("range entries outside of requested range")
 )
 
 type LesOdrRequest interface {
@@ -68,6 +70,8 @@ func LesRequest(req light.OdrRequest) LesOdrRequest {
 		return (*BloomRequest)(r)
 	case *light.TxStatusRequest:
 		return (*TxStatusRequest)(r)
+	case *light.SnapRangeRequest:
+		return (*SnapRangeRequest)(r)
 	default:
 		return nil
 	}
@@ -534,3 +538,96 @@ func (db *readTraceDB) Has(key []byte) (bool, error) {
 	_, err := db.Get(key)
 	return err == nil, nil
 }
+
+// SnapRangeReq is a request for a range of accounts or storage slots. If AccKey
+// is empty, the range is taken from the account trie, otherwise from the storage
+// trie of the account with the given hash.
+type SnapRangeReq struct {
+	BHash         common.Hash
+	AccKey        []byte
+	Origin, Limit common.Hash
+	Bytes         uint64
+}
+
+// SnapRangeResp is the response to a single SnapRangeReq. Responses are
+// returned in the order of the requests; a request which couldn't be served
+// yields an empty response.
+type SnapRangeResp struct {
+	Keys   []common.Hash
+	Values [][]byte
+	Proof  light.NodeList
+}
+
+// ODR request type for state ranges, see LesOdrRequest interface
+type SnapRangeRequest light.SnapRangeRequest
+
+// GetCost returns the cost of the given ODR request according to the serving
+// peer's cost table (implementation of LesOdrRequest)
+func (r *SnapRangeRequest) GetCost(peer *serverPeer) uint64 {
+	return peer.getRequestCost(GetSnapRangesMsg, 1)
+}
+
+// CanSend tells if a certain peer is suitable for serving the given request
+func (r *SnapRangeRequest) CanSend(peer *serverPeer) bool {
+	return peer.version >= lpv5 && peer.HasBlock(r.Id.BlockHash, r.Id.BlockNumber, true)
+}
+
+// Request sends an ODR request to the LES network (implementation of LesOdrRequest)
+func (r *SnapRangeRequest) Request(reqID uint64, peer *serverPeer) error {
+	peer.Log().Debug("Requesting state range", "root", r.Id.Root, "origin", r.Origin, "limit", r.Limit)
+	req := SnapRangeReq{
+		BHash:  r.Id.BlockHash,
+		AccKey: r.Id.AccKey,
+		Origin: r.Origin,
+		Limit:  r.Limit,
+		Bytes:  r.Bytes,
+	}
+	return peer.requestSnapRanges(reqID, []SnapRangeReq{req})
+}
+
+// Valid processes an ODR request reply message from the LES network
+// returns true and stores results in memory if the message was a valid reply
+// to the request (implementation of LesOdrRequest)
+func (r *SnapRangeRequest) Validate(db ethdb.Database, msg *Msg) error {
+	log.Debug("Validating state range", "root", r.Id.Root, "origin", r.Origin, "limit", r.Limit)
+
+	// Ensure we have a correct message with a single range
+	if msg.MsgType != MsgSnapRanges {
+		return errInvalidMessageType
+	}
+	resps := msg.Obj.([]SnapRangeResp)
+	if len(resps) != 1 {
+		return errInvalidEntryCount
+	}
+	resp := resps[0]
+	if len(resp.Keys) != len(resp.Values) {
+		return errInvalidEntryCount
+	}
+	// The entries must be ordered and start at the requested origin. Only the
+	// last entry may exceed the requested limit.
+	keys := make([][]byte, len(resp.Keys))
+	for i, key := range resp.Keys {
+		if bytes.Compare(key[:], r.Origin[:]) < 0 {
+			return errRangeOutOfBounds
+		}
+		if i > 0 && bytes.Compare(resp.Keys[i-1][:], key[:]) >= 0 {
+			return errRangeOutOfBounds
+		}
+		if i < len(resp.Keys)-1 && bytes.Compare(key[:], r.Limit[:]) >= 0 {
+			return errRangeOutOfBounds
+		}
+		keys[i] = common.CopyBytes(key[:])
+	}
+	// Verify the entries against the trie root
+	proof := resp.Proof.NodeSet()
+	var last []byte
+	if len(keys) > 0 {
+		last = keys[len(keys)-1]
+	}
+	_, _, _, more, err := trie.VerifyRangeProof(r.Id.Root, r.Origin[:], last, keys, resp.Values, proof)
+	if err != nil {
+		return err
+	}
+	r.Keys, r.Values, r.More, r.Proof = resp.Keys, resp.Values, more, proof
+	return nil
+}
@@ -37,6 +37,7 @@ import (
 	"github.com/ethereum/go-ethereum/light"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
 )
 
 type odrTestFn func(ctx context.Context, db ethdb.Database, config *params.ChainConfig, bc *core.BlockChain, lc *light.LightChain, bhash common.Hash) []byte
@@ -111,6 +112,38 @@ func odrAccounts(ctx context.Context, db ethdb.Database, config *params.ChainCon
 	return res
 }
 
+func TestOdrStateRangeLes5(t *testing.T) { testOdr(t, lpv5, 0, false, odrStateRange) }
+
+func odrStateRange(ctx context.Context, db ethdb.Database, config *params.ChainConfig, bc *core.BlockChain, lc *light.LightChain, bhash common.Hash) []byte {
+	var (
+		keys   []common.Hash
+		values [][]byte
+	)
+	if bc != nil {
+		header := bc.GetHeaderByHash(bhash)
+		tr, err := trie.New(header.Root, trie.NewDatabase(db))
+		if err != nil {
+			return nil
+		}
+		it := trie.NewIterator(tr.NodeIterator(nil))
+		for it.Next() {
+			keys = append(keys, common.BytesToHash(it.Key))
+			values = append(values, it.Value)
+		}
+	} else {
+		header := lc.GetHeaderByHash(bhash)
+		limit := common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+
+		var err error
+		keys, values, _, err = light.GetStateRange(ctx, lc.Odr(), light.StateTrieID(header), common.Hash{}, limit)
+		if err != nil {
+			return nil
+		}
+	}
+	res, _ := rlp.EncodeToBytes([]interface{}{keys, values})
+	return res
+}
+
 func TestOdrContractCallLes2(t *testing.T) { testOdr(t, 2, 2, true, odrContractCall) }
 func TestOdrContractCallLes3(t *testing.T) { testOdr(t, 3, 2, true, odrContractCall) }
 func TestOdrContractCallLes4(t *testing.T) { testOdr(t, 4, 2, true, odrContractCall) }
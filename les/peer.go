@@ -479,6 +479,12 @@ func (p *serverPeer) requestTxStatus(reqID uint64, txHashes []common.Hash) error
 	return p.sendRequest(GetTxStatusMsg, reqID, txHashes, len(txHashes))
 }
 
+// requestSnapRanges fetches a batch of state ranges with range proofs from a remote node.
+func (p *serverPeer) requestSnapRanges(reqID uint64, reqs []SnapRangeReq) error {
+	p.Log().Debug("Fetching batch of state ranges", "count", len(reqs))
+	return p.sendRequest(GetSnapRangesMsg, reqID, reqs, len(reqs))
+}
+
 // sendTxs creates a reply with a batch of transactions to be added to the remote transaction pool.
 func (p *serverPeer) sendTxs(reqID uint64, amount int, txs rlp.RawValue) error {
 	p.Log().Debug("Sending batch of transactions", "amount", amount, "size", len(txs))
@@ -664,6 +670,9 @@ func (p *serverPeer) Handshake(genesis common.Hash, forkid forkid.ID, forkFilter
 
 		if !p.onlyAnnounce {
 			for msgCode := range reqAvgTimeCost {
+				if msgCode >= ProtocolLengths[uint(p.version)] {
+					continue // Message introduced in a later protocol version
+				}
 				if p.fcCosts[msgCode] == nil {
 					return errResp(ErrUselessPeer, "peer does not support message %d", msgCode)
 				}
@@ -915,6 +924,12 @@ func (p *clientPeer) replyTxStatus(reqID uint64, stats []light.TxStatus) *reply
 	return &reply{p.rw, TxStatusMsg, reqID, data}
 }
 
+// replySnapRanges creates a reply with a batch of state ranges and their proofs.
+func (p *clientPeer) replySnapRanges(reqID uint64, resps []SnapRangeResp) *reply {
+	data, _ := rlp.EncodeToBytes(resps)
+	return &reply{p.rw, SnapRangesMsg, reqID, data}
+}
+
 // sendAnnounce announces the availability of a number of blocks through
 // a hash notification.
 func (p *clientPeer) sendAnnounce(request announceData) error {
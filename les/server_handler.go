@@ -55,6 +55,7 @@ const (
 	MaxHelperTrieProofsFetch = 64  // Amount of helper tries to be fetched per retrieval request
 	MaxTxSend                = 64  // Amount of transactions to be send per request
 	MaxTxStatus              = 256 // Amount of transactions to queried per request
+	MaxSnapRangesFetch       = 16  // Amount of state ranges to be fetched per retrieval request
 )
 
 var (
@@ -17,12 +17,15 @@
 package les
 
 import (
+	"bytes"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/light"
 	"github.com/ethereum/go-ethereum/log"
@@ -63,7 +66,9 @@ type RequestType struct {
 // by the protocol handler when calling the send function of the returned reply struct.
 type serveRequestFn func(backend serverBackend, peer *clientPeer, waitOrStop func() bool) *reply
 
-// Les3 contains the request types supported by les/2 and les/3
+// Les3 contains the request types supported by les/2 and les/3. The state range
+// request is only available from les/5 on, older peers can't send it because
+// it is outside their protocol length.
 var Les3 = map[uint64]RequestType{
 	GetBlockHeadersMsg: {
 		Name:             "block header request",
@@ -145,6 +150,16 @@ var Les3 = map[uint64]RequestType{
 		ServingTimeMeter: miscServingTimeTxStatusTimer,
 		Handle:           handleGetTxStatus,
 	},
+	GetSnapRangesMsg: {
+		Name:             "state range request",
+		MaxCount:         MaxSnapRangesFetch,
+		InPacketsMeter:   miscInSnapRangePacketsMeter,
+		InTrafficMeter:   miscInSnapRangeTrafficMeter,
+		OutPacketsMeter:  miscOutSnapRangePacketsMeter,
+		OutTrafficMeter:  miscOutSnapRangeTrafficMeter,
+		ServingTimeMeter: miscServingTimeSnapRangeTimer,
+		Handle:           handleGetSnapRanges,
+	},
 }
 
 // handleGetBlockHeaders handles a block header request
@@ -551,6 +566,183 @@ func handleGetTxStatus(msg Decoder) (serveRequestFn, uint64, uint64, error) {
 	}, r.ReqID, uint64(len(r.Hashes)), nil
 }
 
+// handleGetSnapRanges handles a state range request
+func handleGetSnapRanges(msg Decoder) (serveRequestFn, uint64, uint64, error) {
+	var r GetSnapRangesPacket
+	if err := msg.Decode(&r); err != nil {
+		return nil, 0, 0, err
+	}
+	return func(backend serverBackend, p *clientPeer, waitOrStop func() bool) *reply {
+		var (
+			bytes int
+			resps []SnapRangeResp
+		)
+		bc := backend.BlockChain()
+		for i, request := range r.Reqs {
+			if i != 0 && !waitOrStop() {
+				return nil
+			}
+			// Look up the state root belonging to the request
+			header := bc.GetHeaderByHash(request.BHash)
+			if header == nil {
+				p.Log().Warn("Failed to retrieve header for state range", "hash", request.BHash)
+				p.bumpInvalid()
+				resps = append(resps, SnapRangeResp{})
+				continue
+			}
+			// Refuse to search stale state data in the database since looking for
+			// a non-exist key is kind of expensive.
+			local := bc.CurrentHeader().Number.Uint64()
+			if !backend.ArchiveMode() && header.Number.Uint64()+core.TriesInMemory <= local {
+				p.Log().Debug("Reject stale state range request", "number", header.Number.Uint64(), "head", local)
+				p.bumpInvalid()
+				resps = append(resps, SnapRangeResp{})
+				continue
+			}
+			limit := request.Bytes
+			if limit == 0 || limit > softResponseLimit {
+				limit = softResponseLimit
+			}
+			resp, err := serveSnapRange(bc, header, request, limit)
+			if err != nil {
+				p.Log().Warn("Failed to serve state range", "block", header.Number, "hash", header.Hash(), "account", common.BytesToHash(request.AccKey), "err", err)
+				resps = append(resps, SnapRangeResp{})
+				continue
+			}
+			resps = append(resps, *resp)
+			for _, value := range resp.Values {
+				bytes += common.HashLength + len(value)
+			}
+			if bytes += resp.Proof.DataSize(); bytes >= softResponseLimit {
+				break
+			}
+		}
+		return p.replySnapRanges(r.ReqID, resps)
+	}, r.ReqID, uint64(len(r.Reqs)), nil
+}
+
+// serveSnapRange collects the accounts or storage slots of the requested range,
+// along with the Merkle proofs of the range boundaries.
+func serveSnapRange(bc *core.BlockChain, header *types.Header, req SnapRangeReq, limit uint64) (*SnapRangeResp, error) {
+	var (
+		statedb = bc.StateCache()
+		root    = header.Root
+		tr      state.Trie
+		err     error
+	)
+	if len(req.AccKey) == 0 {
+		tr, err = statedb.OpenTrie(root)
+	} else {
+		var account state.Account
+		if account, err = getAccount(statedb.TrieDB(), root, common.BytesToHash(req.AccKey)); err != nil {
+			return nil, err
+		}
+		tr, err = statedb.OpenStorageTrie(common.BytesToHash(req.AccKey), account.Root)
+	}
+	if err != nil {
+		return nil, err
+	}
+	it := newStateRangeIterator(bc, tr, root, req)
+	defer it.Release()
+
+	var (
+		resp SnapRangeResp
+		size uint64
+		last common.Hash
+	)
+	for size < limit && it.Next() {
+		hash, value := it.Hash(), it.Value()
+		if value == nil {
+			return nil, errors.New("invalid snapshot entry")
+		}
+		last = hash
+		size += uint64(common.HashLength + len(value))
+		resp.Keys = append(resp.Keys, hash)
+		resp.Values = append(resp.Values, value)
+
+		if bytes.Compare(hash[:], req.Limit[:]) >= 0 {
+			break
+		}
+	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+	// Generate the Merkle proofs for the first and last entry
+	proof := light.NewNodeSet()
+	if err := tr.Prove(req.Origin[:], 0, proof); err != nil {
+		return nil, err
+	}
+	if len(resp.Keys) > 0 {
+		if err := tr.Prove(last[:], 0, proof); err != nil {
+			return nil, err
+		}
+	}
+	resp.Proof = proof.NodeList()
+	return &resp, nil
+}
+
+// stateRangeIterator iterates over the entries of an account or storage trie
+// in key order. Values are returned in trie encoding.
+type stateRangeIterator interface {
+	Next() bool
+	Error() error
+	Hash() common.Hash
+	Value() []byte
+	Release()
+}
+
+// newStateRangeIterator creates an iterator for the requested range. The state
+// snapshot is used if it covers the requested root, otherwise the iterator
+// falls back to walking the trie.
+func newStateRangeIterator(bc *core.BlockChain, tr state.Trie, root common.Hash, req SnapRangeReq) stateRangeIterator {
+	if snaps := bc.Snapshots(); snaps != nil {
+		if len(req.AccKey) == 0 {
+			if it, err := snaps.AccountIterator(root, req.Origin); err == nil {
+				return &snapAccountIterator{AccountIterator: it}
+			}
+		} else {
+			if it, err := snaps.StorageIterator(root, common.BytesToHash(req.AccKey), req.Origin); err == nil {
+				return &snapStorageIterator{StorageIterator: it}
+			}
+		}
+	}
+	return &trieRangeIterator{it: trie.NewIterator(tr.NodeIterator(req.Origin[:]))}
+}
+
+// snapAccountIterator converts the slim snapshot accounts to trie encoding.
+type snapAccountIterator struct {
+	snapshot.AccountIterator
+}
+
+func (it *snapAccountIterator) Value() []byte {
+	account, err := snapshot.FullAccountRLP(it.Account())
+	if err != nil {
+		return nil
+	}
+	return account
+}
+
+// snapStorageIterator returns the storage slots of a snapshot, which are
+// stored in trie encoding already.
+type snapStorageIterator struct {
+	snapshot.StorageIterator
+}
+
+func (it *snapStorageIterator) Value() []byte {
+	return common.CopyBytes(it.Slot())
+}
+
+// trieRangeIterator iterates over the leaves of a trie.
+type trieRangeIterator struct {
+	it *trie.Iterator
+}
+
+func (it *trieRangeIterator) Next() bool        { return it.it.Next() }
+func (it *trieRangeIterator) Error() error      { return it.it.Err }
+func (it *trieRangeIterator) Hash() common.Hash { return common.BytesToHash(it.it.Key) }
+func (it *trieRangeIterator) Value() []byte     { return common.CopyBytes(it.it.Value) }
+func (it *trieRangeIterator) Release()          {}
+
 // txStatus returns the status of a specified transaction.
 func txStatus(b serverBackend, hash common.Hash) light.TxStatus {
 	var stat light.TxStatus
@@ -93,6 +93,25 @@ func (req *TrieRequest) StoreResult(db ethdb.Database) {
 	req.Proof.Store(db)
 }
 
+// SnapRangeRequest is the ODR request type for retrieving a consecutive range of
+// accounts (if Id refers to a state trie) or storage slots (if Id refers to a
+// storage trie), verified by a Merkle range proof.
+type SnapRangeRequest struct {
+	Id            *TrieID
+	Origin, Limit common.Hash // first and last key of the requested range
+	Bytes         uint64      // soft limit of the response size, zero means no limit
+
+	Keys   []common.Hash // hashed account addresses or storage slot keys
+	Values [][]byte      // trie-encoded accounts or storage values
+	More   bool          // set if the trie contains more entries after the last key
+	Proof  *NodeSet
+}
+
+// StoreResult stores the retrieved data in local database
+func (req *SnapRangeRequest) StoreResult(db ethdb.Database) {
+	req.Proof.Store(db)
+}
+
 // CodeRequest is the ODR request type for retrieving contract code
 type CodeRequest struct {
 	Id   *TrieID // references storage trie of the account
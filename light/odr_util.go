@@ -269,6 +269,18 @@ func GetBloomBits(ctx context.Context, odr OdrBackend, bit uint, sections []uint
 	return result, nil
 }
 
+// GetStateRange retrieves a range of accounts or storage slots starting at origin
+// from the trie identified by id. Entries up to limit are returned, or fewer if the
+// server response size limit is reached first. The returned flag reports whether
+// the trie contains more entries after the last returned key.
+func GetStateRange(ctx context.Context, odr OdrBackend, id *TrieID, origin, limit common.Hash) ([]common.Hash, [][]byte, bool, error) {
+	r := &SnapRangeRequest{Id: id, Origin: origin, Limit: limit}
+	if err := odr.Retrieve(ctx, r); err != nil {
+		return nil, nil, false, err
+	}
+	return r.Keys, r.Values, r.More, nil
+}
+
 // GetTransaction retrieves a canonical transaction by hash and also returns
 // its position in the chain. There is no guarantee in the LES protocol that
 // the mined transaction will be retrieved back for sure because of different
@@ -19,7 +19,9 @@
 package geth
 
 import (
+	"fmt"
 	"math/big"
+	"reflect"
 	"strings"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
@@ -99,6 +101,10 @@ func (opts *TransactOpts) GetNonce() int64      { return opts.opts.Nonce.Int64()
 func (opts *TransactOpts) GetValue() *BigInt    { return &BigInt{opts.opts.Value} }
 func (opts *TransactOpts) GetGasPrice() *BigInt { return &BigInt{opts.opts.GasPrice} }
 func (opts *TransactOpts) GetGasLimit() int64   { return int64(opts.opts.GasLimit) }
+func (opts *TransactOpts) GetTxType() int       { return int(opts.opts.TxType) }
+func (opts *TransactOpts) GetCreateAccessList() bool {
+	return opts.opts.CreateAccessList
+}
 
 // GetSigner cannot be reliably implemented without identity preservation (https://github.com/golang/go/issues/16876)
 // func (opts *TransactOpts) GetSigner() Signer { return &signer{opts.opts.Signer} }
@@ -122,12 +128,17 @@ func (opts *TransactOpts) SetValue(value *BigInt)      { opts.opts.Value = value
 func (opts *TransactOpts) SetGasPrice(price *BigInt)   { opts.opts.GasPrice = price.bigint }
 func (opts *TransactOpts) SetGasLimit(limit int64)     { opts.opts.GasLimit = uint64(limit) }
 func (opts *TransactOpts) SetContext(context *Context) { opts.opts.Context = context.context }
+func (opts *TransactOpts) SetTxType(txType int)        { opts.opts.TxType = uint8(txType) }
+func (opts *TransactOpts) SetCreateAccessList(create bool) {
+	opts.opts.CreateAccessList = create
+}
 
 // BoundContract is the base wrapper object that reflects a contract on the
 // Ethereum network. It contains a collection of methods that are used by the
 // higher level contract bindings to operate.
 type BoundContract struct {
 	contract *bind.BoundContract
+	abi      abi.ABI
 	address  common.Address
 	deployer *types.Transaction
 }
@@ -140,12 +151,17 @@ func DeployContract(opts *TransactOpts, abiJSON string, bytecode []byte, client
 	if err != nil {
 		return nil, err
 	}
-	addr, tx, bound, err := bind.DeployContract(&opts.opts, parsed, common.CopyBytes(bytecode), client.client, args.objects...)
+	params, err := unwrapTuples(parsed.Constructor.Inputs, args.objects)
+	if err != nil {
+		return nil, err
+	}
+	addr, tx, bound, err := bind.DeployContract(&opts.opts, parsed, common.CopyBytes(bytecode), client.client, params...)
 	if err != nil {
 		return nil, err
 	}
 	return &BoundContract{
 		contract: bound,
+		abi:      parsed,
 		address:  addr,
 		deployer: tx,
 	}, nil
@@ -160,6 +176,7 @@ func BindContract(address *Address, abiJSON string, client *EthereumClient) (con
 	}
 	return &BoundContract{
 		contract: bind.NewBoundContract(address.address, parsed, client.client, client.client, client.client),
+		abi:      parsed,
 		address:  address.address,
 	}, nil
 }
@@ -175,18 +192,76 @@ func (c *BoundContract) GetDeployer() *Transaction {
 // Call invokes the (constant) contract method with params as input values and
 // sets the output to result.
 func (c *BoundContract) Call(opts *CallOpts, out *Interfaces, method string, args *Interfaces) error {
+	params, err := c.unwrapInputs(method, args)
+	if err != nil {
+		return err
+	}
+	// Tuple outputs need to be disassembled into wrapped field lists
+	if m, ok := c.abi.Methods[method]; ok && c.hasTupleOutputs(m) {
+		return c.callTuples(opts, out, m, params)
+	}
 	results := make([]interface{}, len(out.objects))
 	copy(results, out.objects)
-	if err := c.contract.Call(&opts.opts, &results, method, args.objects...); err != nil {
+	if err := c.contract.Call(&opts.opts, &results, method, params...); err != nil {
 		return err
 	}
 	copy(out.objects, results)
 	return nil
 }
 
+// callTuples invokes a contract method returning tuples, unpacking the raw
+// results and converting them into the wrapped representation.
+func (c *BoundContract) callTuples(opts *CallOpts, out *Interfaces, method abi.Method, params []interface{}) error {
+	if len(out.objects) != len(method.Outputs) {
+		return fmt.Errorf("output count mismatch: have %d, want %d", len(out.objects), len(method.Outputs))
+	}
+	var results []interface{}
+	if err := c.contract.Call(&opts.opts, &results, method.Name, params...); err != nil {
+		return err
+	}
+	for i, output := range method.Outputs {
+		if hasTuple(output.Type) {
+			out.objects[i] = wrapTuple(output.Type, reflect.ValueOf(results[i]))
+			continue
+		}
+		// Plain outputs are written into the preallocated defaults
+		dst := reflect.ValueOf(out.objects[i])
+		if dst.Kind() != reflect.Ptr || dst.IsNil() {
+			return fmt.Errorf("output %d: uninitialized value", i)
+		}
+		abi.ConvertType(results[i], out.objects[i])
+	}
+	return nil
+}
+
+// hasTupleOutputs reports whether any of the method's outputs contains a tuple.
+func (c *BoundContract) hasTupleOutputs(method abi.Method) bool {
+	for _, output := range method.Outputs {
+		if hasTuple(output.Type) {
+			return true
+		}
+	}
+	return false
+}
+
+// unwrapInputs converts the wrapped arguments of a method into the values the
+// abi package expects. Unknown methods are passed through for the binder to
+// report.
+func (c *BoundContract) unwrapInputs(method string, args *Interfaces) ([]interface{}, error) {
+	m, ok := c.abi.Methods[method]
+	if !ok {
+		return args.objects, nil
+	}
+	return unwrapTuples(m.Inputs, args.objects)
+}
+
 // Transact invokes the (paid) contract method with params as input values.
 func (c *BoundContract) Transact(opts *TransactOpts, method string, args *Interfaces) (tx *Transaction, _ error) {
-	rawTx, err := c.contract.Transact(&opts.opts, method, args.objects...)
+	params, err := c.unwrapInputs(method, args)
+	if err != nil {
+		return nil, err
+	}
+	rawTx, err := c.contract.Transact(&opts.opts, method, params...)
 	if err != nil {
 		return nil, err
 	}
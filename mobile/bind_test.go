@@ -0,0 +1,111 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package geth
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+)
+
+const structsABI = `[{"inputs":[{"components":[{"name":"id","type":"uint256"},{"components":[{"name":"x","type":"int64"},{"name":"y","type":"int64"}],"name":"points","type":"tuple[]"}],"name":"paths","type":"tuple[]"}],"name":"first","outputs":[{"components":[{"name":"id","type":"uint256"},{"components":[{"name":"x","type":"int64"},{"name":"y","type":"int64"}],"name":"points","type":"tuple[]"}],"name":"path","type":"tuple"},{"name":"count","type":"uint256"}],"stateMutability":"pure","type":"function"}]`
+
+// returnCode creates contract code returning the given data for any call.
+func returnCode(data []byte) []byte {
+	size := []byte{byte(len(data) >> 8), byte(len(data))}
+	code := []byte{
+		0x61, size[0], size[1], // PUSH2 size
+		0x60, 14, // PUSH1 offset of the data
+		0x60, 0x00, // PUSH1 0
+		0x39,                   // CODECOPY
+		0x61, size[0], size[1], // PUSH2 size
+		0x60, 0x00, // PUSH1 0
+		0xf3, // RETURN
+	}
+	return append(code, data...)
+}
+
+// Tests that calling a method returning both a tuple and plain values through
+// the bound contract fills in the wrapped outputs.
+func TestBoundContractCallTuples(t *testing.T) {
+	parsed, err := abi.JSON(strings.NewReader(structsABI))
+	if err != nil {
+		t.Fatalf("failed to parse ABI: %v", err)
+	}
+	type point struct {
+		X int64
+		Y int64
+	}
+	type path struct {
+		Id     *big.Int
+		Points []point
+	}
+	output, err := parsed.Methods["first"].Outputs.Pack(path{big.NewInt(7), []point{{1, 2}, {3, 4}}}, big.NewInt(42))
+	if err != nil {
+		t.Fatalf("failed to pack outputs: %v", err)
+	}
+	addr := common.HexToAddress("0xc0de")
+	sim := backends.NewSimulatedBackend(core.GenesisAlloc{addr: {Code: returnCode(output), Balance: common.Big0}}, 10000000)
+	defer sim.Close()
+
+	contract := &BoundContract{
+		contract: bind.NewBoundContract(addr, parsed, sim, sim, sim),
+		abi:      parsed,
+		address:  addr,
+	}
+	// Assemble the arguments and results the way the generated Java code does
+	args := NewInterfaces(1)
+	arg0 := NewInterface()
+	arg0.SetTuples(NewInterfaces(0))
+	args.Set(0, arg0)
+
+	results := NewInterfaces(2)
+	result0 := NewInterface()
+	result0.SetDefaultTuple()
+	results.Set(0, result0)
+	result1 := NewInterface()
+	result1.SetDefaultBigInt()
+	results.Set(1, result1)
+
+	if err := contract.Call(NewCallOpts(), results, "first", args); err != nil {
+		t.Fatalf("failed to call contract: %v", err)
+	}
+	if count, _ := results.Get(1); count.GetBigInt().GetInt64() != 42 {
+		t.Errorf("count mismatch: have %v, want 42", count.GetBigInt())
+	}
+	ret, _ := results.Get(0)
+	fields := ret.GetTuple()
+	if id, _ := fields.Get(0); id.GetBigInt().GetInt64() != 7 {
+		t.Errorf("path id mismatch: have %v, want 7", id.GetBigInt())
+	}
+	points, _ := fields.Get(1)
+	if size := points.GetTuples().Size(); size != 2 {
+		t.Fatalf("point count mismatch: have %d, want 2", size)
+	}
+	last, _ := points.GetTuples().Get(1)
+	x, _ := last.GetTuple().Get(0)
+	y, _ := last.GetTuple().Get(1)
+	if x.GetInt64() != 3 || y.GetInt64() != 4 {
+		t.Errorf("point mismatch: have (%d, %d), want (3, 4)", x.GetInt64(), y.GetInt64())
+	}
+}
@@ -20,8 +20,11 @@ package geth
 
 import (
 	"errors"
+	"fmt"
 	"math/big"
+	"reflect"
 
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 )
 
@@ -119,6 +122,13 @@ func (i *Interface) SetUint64s(bigints *BigInts) {
 func (i *Interface) SetBigInt(bigint *BigInt)    { i.object = &bigint.bigint }
 func (i *Interface) SetBigInts(bigints *BigInts) { i.object = &bigints.bigints }
 
+// SetTuple sets a struct value, its fields wrapped in declaration order.
+func (i *Interface) SetTuple(fields *Interfaces) { i.object = fields }
+
+// SetTuples sets an array of struct values (or nested arrays thereof), each
+// element wrapped as a tuple.
+func (i *Interface) SetTuples(items *Interfaces) { i.object = items }
+
 func (i *Interface) SetDefaultBool()      { i.object = new(bool) }
 func (i *Interface) SetDefaultBools()     { i.object = new([]bool) }
 func (i *Interface) SetDefaultString()    { i.object = new(string) }
@@ -147,6 +157,8 @@ func (i *Interface) SetDefaultUint64()    { i.object = new(uint64) }
 func (i *Interface) SetDefaultUint64s()   { i.object = new([]uint64) }
 func (i *Interface) SetDefaultBigInt()    { i.object = new(*big.Int) }
 func (i *Interface) SetDefaultBigInts()   { i.object = new([]*big.Int) }
+func (i *Interface) SetDefaultTuple()     { i.object = new(Interfaces) }
+func (i *Interface) SetDefaultTuples()    { i.object = new(Interfaces) }
 
 func (i *Interface) GetBool() bool            { return *i.object.(*bool) }
 func (i *Interface) GetBools() *Bools         { return &Bools{*i.object.(*[]bool)} }
@@ -241,6 +253,12 @@ func (i *Interface) GetUint64s() *BigInts {
 func (i *Interface) GetBigInt() *BigInt   { return &BigInt{*i.object.(**big.Int)} }
 func (i *Interface) GetBigInts() *BigInts { return &BigInts{*i.object.(*[]*big.Int)} }
 
+// GetTuple returns the fields of a struct value, wrapped in declaration order.
+func (i *Interface) GetTuple() *Interfaces { return i.object.(*Interfaces) }
+
+// GetTuples returns an array of struct values, each element wrapped as a tuple.
+func (i *Interface) GetTuples() *Interfaces { return i.object.(*Interfaces) }
+
 // Interfaces is a slices of wrapped generic objects.
 type Interfaces struct {
 	objects []interface{}
@@ -274,3 +292,138 @@ func (i *Interfaces) Set(index int, object *Interface) error {
 	i.objects[index] = object.object
 	return nil
 }
+
+// hasTuple reports whether the ABI type is or contains a tuple, needing explicit
+// conversion between the wrapped field lists and the Go representation.
+func hasTuple(kind abi.Type) bool {
+	switch kind.T {
+	case abi.TupleTy:
+		return true
+	case abi.SliceTy, abi.ArrayTy:
+		return hasTuple(*kind.Elem)
+	default:
+		return false
+	}
+}
+
+// unwrapTuples converts wrapped arguments into the values expected by the abi
+// package, assembling the Go structs of any tuple arguments.
+func unwrapTuples(args abi.Arguments, objects []interface{}) ([]interface{}, error) {
+	if len(args) != len(objects) {
+		return nil, fmt.Errorf("argument count mismatch: have %d, want %d", len(objects), len(args))
+	}
+	unwrapped := make([]interface{}, len(objects))
+	for i, arg := range args {
+		if !hasTuple(arg.Type) {
+			unwrapped[i] = objects[i]
+			continue
+		}
+		val, err := unwrapTuple(arg.Type, objects[i])
+		if err != nil {
+			return nil, fmt.Errorf("argument %q: %v", arg.Name, err)
+		}
+		unwrapped[i] = val.Interface()
+	}
+	return unwrapped, nil
+}
+
+// unwrapTuple converts a wrapped value of the given ABI type into its Go
+// representation, recursively assembling structs from wrapped field lists.
+func unwrapTuple(kind abi.Type, object interface{}) (reflect.Value, error) {
+	typ := kind.GetType()
+
+	switch {
+	case kind.T == abi.TupleTy:
+		fields, ok := object.(*Interfaces)
+		if !ok || fields == nil {
+			return reflect.Value{}, fmt.Errorf("expected tuple, got %T", object)
+		}
+		if len(fields.objects) != len(kind.TupleElems) {
+			return reflect.Value{}, fmt.Errorf("tuple field count mismatch: have %d, want %d", len(fields.objects), len(kind.TupleElems))
+		}
+		val := reflect.New(typ).Elem()
+		for i, elem := range kind.TupleElems {
+			field, err := unwrapTuple(*elem, fields.objects[i])
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("field %q: %v", kind.TupleRawNames[i], err)
+			}
+			val.Field(i).Set(field)
+		}
+		return val, nil
+
+	case (kind.T == abi.SliceTy || kind.T == abi.ArrayTy) && hasTuple(*kind.Elem):
+		items, ok := object.(*Interfaces)
+		if !ok || items == nil {
+			return reflect.Value{}, fmt.Errorf("expected tuple list, got %T", object)
+		}
+		var val reflect.Value
+		if kind.T == abi.SliceTy {
+			val = reflect.MakeSlice(typ, len(items.objects), len(items.objects))
+		} else {
+			if len(items.objects) != kind.Size {
+				return reflect.Value{}, fmt.Errorf("array length mismatch: have %d, want %d", len(items.objects), kind.Size)
+			}
+			val = reflect.New(typ).Elem()
+		}
+		for i, item := range items.objects {
+			elem, err := unwrapTuple(*kind.Elem, item)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("item %d: %v", i, err)
+			}
+			val.Index(i).Set(elem)
+		}
+		return val, nil
+	}
+	// Plain values are stored behind pointers by the setters, dereference them
+	val := reflect.ValueOf(object)
+	for val.Kind() == reflect.Ptr && val.Type() != typ {
+		val = val.Elem()
+	}
+	switch {
+	case !val.IsValid():
+		return reflect.Value{}, fmt.Errorf("missing %v value", kind)
+	case val.Type().AssignableTo(typ):
+		return val, nil
+	case val.Kind() == reflect.Slice && typ.Kind() == reflect.Array:
+		// Fixed size arrays (e.g. bytes32) are wrapped as slices
+		if val.Len() != typ.Len() {
+			return reflect.Value{}, fmt.Errorf("array length mismatch: have %d, want %d", val.Len(), typ.Len())
+		}
+		arr := reflect.New(typ).Elem()
+		reflect.Copy(arr, val)
+		return arr, nil
+	case val.Type().ConvertibleTo(typ):
+		return val.Convert(typ), nil
+	}
+	return reflect.Value{}, fmt.Errorf("cannot use %v as %v", val.Type(), typ)
+}
+
+// wrapTuple converts a Go value of the given ABI type into the representation
+// expected by the getters, recursively disassembling structs into wrapped field
+// lists.
+func wrapTuple(kind abi.Type, val reflect.Value) interface{} {
+	switch {
+	case kind.T == abi.TupleTy:
+		fields := NewInterfaces(len(kind.TupleElems))
+		for i, elem := range kind.TupleElems {
+			fields.objects[i] = wrapTuple(*elem, val.Field(i))
+		}
+		return fields
+
+	case (kind.T == abi.SliceTy || kind.T == abi.ArrayTy) && hasTuple(*kind.Elem):
+		items := NewInterfaces(val.Len())
+		for i := 0; i < val.Len(); i++ {
+			items.objects[i] = wrapTuple(*kind.Elem, val.Index(i))
+		}
+		return items
+	}
+	// Plain values are returned behind pointers, arrays as slices
+	if val.Kind() == reflect.Array {
+		slice := reflect.MakeSlice(reflect.SliceOf(val.Type().Elem()), val.Len(), val.Len())
+		reflect.Copy(slice, val)
+		val = slice
+	}
+	ptr := reflect.New(val.Type())
+	ptr.Elem().Set(val)
+	return ptr.Interface()
+}
@@ -22,6 +22,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 )
 
@@ -60,6 +61,8 @@ func TestInterfaceGetSet(t *testing.T) {
 		{"Uint64s", &BigInts{[]*big.Int{big.NewInt(1), big.NewInt(2)}}, &BigInts{[]*big.Int{big.NewInt(1), big.NewInt(2)}}},
 		{"BigInt", NewBigInt(1), NewBigInt(1)},
 		{"BigInts", &BigInts{[]*big.Int{big.NewInt(1), big.NewInt(2)}}, &BigInts{[]*big.Int{big.NewInt(1), big.NewInt(2)}}},
+		{"Tuple", NewInterfaces(2), NewInterfaces(2)},
+		{"Tuples", NewInterfaces(1), NewInterfaces(1)},
 	}
 
 	args := NewInterfaces(len(tests))
@@ -88,3 +91,76 @@ func TestInterfaceGetSet(t *testing.T) {
 		}
 	}
 }
+
+// Tests that wrapped struct values, including nested arrays of structs, can be
+// converted into their Go representation, packed and converted back.
+func TestInterfaceTuples(t *testing.T) {
+	typ, err := abi.NewType("tuple[]", "", []abi.ArgumentMarshaling{
+		{Name: "id", Type: "uint256"},
+		{Name: "tag", Type: "bytes32"},
+		{Name: "points", Type: "tuple[2][]", Components: []abi.ArgumentMarshaling{
+			{Name: "x", Type: "int64"},
+			{Name: "y", Type: "int64"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create type: %v", err)
+	}
+	// Assemble the values the way the generated Java code does
+	point := func(x, y int64) *Interface {
+		fields := NewInterfaces(2)
+		fields.objects[0] = &x
+		fields.objects[1] = &y
+
+		iface := NewInterface()
+		iface.SetTuple(fields)
+		return iface
+	}
+	pair := NewInterfaces(2)
+	pair.Set(0, point(1, 2))
+	pair.Set(1, point(3, 4))
+
+	pairs := NewInterfaces(1)
+	pairsItem := NewInterface()
+	pairsItem.SetTuples(pair)
+	pairs.Set(0, pairsItem)
+
+	fields := NewInterfaces(3)
+	id, tag, points := NewInterface(), NewInterface(), NewInterface()
+	id.SetBigInt(NewBigInt(42))
+	tag.SetBinary(common.HexToHash("0xdeadbeef").Bytes())
+	points.SetTuples(pairs)
+	fields.Set(0, id)
+	fields.Set(1, tag)
+	fields.Set(2, points)
+
+	items := NewInterfaces(1)
+	item := NewInterface()
+	item.SetTuple(fields)
+	items.Set(0, item)
+
+	// Convert the wrapped values into Go ones and ensure they can be packed
+	args := abi.Arguments{{Name: "paths", Type: typ}}
+	params, err := unwrapTuples(args, []interface{}{items})
+	if err != nil {
+		t.Fatalf("failed to unwrap tuples: %v", err)
+	}
+	packed, err := args.Pack(params...)
+	if err != nil {
+		t.Fatalf("failed to pack tuples: %v", err)
+	}
+	unpacked, err := args.Unpack(packed)
+	if err != nil {
+		t.Fatalf("failed to unpack tuples: %v", err)
+	}
+	// Convert the unpacked values back and ensure nothing was lost
+	if wrapped := wrapTuple(typ, reflect.ValueOf(unpacked[0])); !reflect.DeepEqual(wrapped, items) {
+		t.Errorf("tuple round trip mismatch: have %v, want %v", wrapped, items)
+	}
+	// Ensure malformed values are rejected
+	short := NewInterfaces(1)
+	short.Set(0, NewInterface())
+	if _, err := unwrapTuples(args, []interface{}{short}); err == nil {
+		t.Errorf("malformed tuple accepted")
+	}
+}
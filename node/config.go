@@ -28,6 +28,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/accounts/external"
+	"github.com/ethereum/go-ethereum/accounts/hsm"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/accounts/scwallet"
 	"github.com/ethereum/go-ethereum/accounts/usbwallet"
@@ -101,6 +102,16 @@ type Config struct {
 	// SmartCardDaemonPath is the path to the smartcard daemon's socket
 	SmartCardDaemonPath string `toml:",omitempty"`
 
+	// HSMModule is the path to the PKCS#11 module of a hardware security module.
+	HSMModule string `toml:",omitempty"`
+
+	// HSMSlot is the slot of the token holding the account keys in the HSM module.
+	HSMSlot uint `toml:",omitempty"`
+
+	// HSMPINFile is the file containing the user PIN to open the HSM token with.
+	// If empty, the token needs to be opened through the personal API.
+	HSMPINFile string `toml:",omitempty"`
+
 	// IPCPath is the requested location to place the IPC endpoint. If the path is
 	// a simple file name, it is placed inside the data directory (or on the root
 	// pipe path on Windows), whereas if it's a resolvable path name (absolute or
@@ -516,6 +527,14 @@ func makeAccountManager(conf *Config) (*accounts.Manager, string, error) {
 				backends = append(backends, schub)
 			}
 		}
+		if len(conf.HSMModule) > 0 {
+			// Start an HSM hub for the token in the PKCS#11 module
+			if hsmhub, err := hsm.NewPKCS11HubWithPINFile(conf.HSMModule, conf.HSMSlot, conf.HSMPINFile); err != nil {
+				log.Warn(fmt.Sprintf("Failed to start HSM hub, disabling: %v", err))
+			} else {
+				backends = append(backends, hsmhub)
+			}
+		}
 	}
 
 	return accounts.NewManager(&accounts.Config{InsecureUnlockAllowed: conf.InsecureUnlockAllowed}, backends...), ephemeral, nil
@@ -67,6 +67,31 @@ func (t tcpDialer) Dial(ctx context.Context, dest *enode.Node) (net.Conn, error)
 	return t.d.DialContext(ctx, "tcp", nodeAddr(dest).String())
 }
 
+// transportDialer implements NodeDialer using the additional transports
+// configured on the server. Nodes which don't support any of them, or which
+// can't be reached through them, are dialed using the fallback dialer.
+type transportDialer struct {
+	transports []Transport
+	fallback   NodeDialer
+}
+
+func (t *transportDialer) Dial(ctx context.Context, dest *enode.Node) (net.Conn, error) {
+	for _, tr := range t.transports {
+		if !tr.Supported(dest) {
+			continue
+		}
+		fd, err := tr.Dial(ctx, dest)
+		if err == nil {
+			return &transportConn{Conn: fd, tr: tr}, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		log.Trace("Transport dial failed, falling back", "id", dest.ID(), "transport", tr.Name(), "err", err)
+	}
+	return t.fallback.Dial(ctx, dest)
+}
+
 func nodeAddr(n *enode.Node) net.Addr {
 	return &net.TCPAddr{IP: n.IP(), Port: n.TCP()}
 }
@@ -540,6 +565,10 @@ func (t *dialTask) dial(d *dialScheduler, dest *enode.Node) error {
 		d.log.Trace("Dial error", "id", t.dest.ID(), "addr", nodeAddr(t.dest), "conn", t.flags, "err", cleanupDialErr(err))
 		return &dialError{err}
 	}
+	if tc, ok := fd.(*transportConn); ok {
+		tc.Conn = newMeteredConn(tc.Conn, false, &net.TCPAddr{IP: dest.IP(), Port: dest.TCP()})
+		return d.setupFunc(tc, t.flags, dest)
+	}
 	mfd := newMeteredConn(fd, false, &net.TCPAddr{IP: dest.IP(), Port: dest.TCP()})
 	return d.setupFunc(mfd, t.flags, dest)
 }
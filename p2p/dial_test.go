@@ -394,6 +394,54 @@ func TestDialSchedResolve(t *testing.T) {
 	})
 }
 
+// rejectingTransport is a Transport supported by all nodes, failing every dial.
+type rejectingTransport struct {
+	Transport
+	dials int
+}
+
+func (t *rejectingTransport) Name() string                 { return "reject" }
+func (t *rejectingTransport) Supported(n *enode.Node) bool { return true }
+
+func (t *rejectingTransport) Dial(ctx context.Context, dest *enode.Node) (net.Conn, error) {
+	t.dials++
+	return nil, errors.New("connection refused")
+}
+
+// This test checks that nodes are dialed through RLPx over TCP if dialing them
+// through an additional transport fails.
+func TestDialTransportFallback(t *testing.T) {
+	t.Parallel()
+
+	var (
+		node     = newNode(uintID(0x01), "127.0.0.1:30303")
+		tr       = new(rejectingTransport)
+		fallback = newDialTestDialer()
+		dialer   = &transportDialer{transports: []Transport{tr}, fallback: fallback}
+		result   = make(chan net.Conn, 1)
+	)
+	go func() {
+		fd, err := dialer.Dial(context.Background(), node)
+		if err != nil {
+			t.Errorf("dial failed: %v", err)
+		}
+		result <- fd
+	}()
+	if err := fallback.waitForDials([]*enode.Node{node}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fallback.completeDials([]enode.ID{node.ID()}, nil); err != nil {
+		t.Fatal(err)
+	}
+	fd := <-result
+	if _, ok := fd.(*transportConn); ok || fd == nil {
+		t.Fatalf("connection not established through fallback: %v", fd)
+	}
+	if tr.dials != 1 {
+		t.Fatalf("transport dial count mismatch: have %d, want 1", tr.dials)
+	}
+}
+
 // -------
 // Code below here is the framework for the tests above.
 
@@ -0,0 +1,221 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package mux
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/ecies"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+var (
+	errBadSignature    = errors.New("invalid handshake signature")
+	errWrongIdentity   = errors.New("remote identity doesn't match dial destination")
+	errInvalidPubkey   = errors.New("invalid public key in handshake")
+	errHandshakeFailed = errors.New("handshake failed")
+)
+
+// authPacket is sent by the initiator of a session.
+//
+// The signature is made with the static node key over
+// keccak256(ephemeral || nonce || recipient), which binds the ephemeral
+// key to the identity of both parties.
+type authPacket struct {
+	Pubkey    [64]byte
+	Ephemeral [64]byte
+	Nonce     [32]byte
+	Signature []byte
+
+	// Ignore additional fields (forward-compatibility).
+	Rest []rlp.RawValue `rlp:"tail"`
+}
+
+// authAckPacket is the reply of the recipient. Its signature covers
+// keccak256(ephemeral || nonce || initiator nonce).
+type authAckPacket struct {
+	Pubkey    [64]byte
+	Ephemeral [64]byte
+	Nonce     [32]byte
+	Signature []byte
+
+	// Ignore additional fields (forward-compatibility).
+	Rest []rlp.RawValue `rlp:"tail"`
+}
+
+// handshakeState contains the ephemeral key material of one side.
+type handshakeState struct {
+	ephemeral *ecdsa.PrivateKey
+	nonce     [32]byte
+}
+
+func newHandshakeState() (*handshakeState, error) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	h := &handshakeState{ephemeral: key}
+	if _, err := rand.Read(h.nonce[:]); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// makeAuth creates the initiator's auth packet.
+func (h *handshakeState) makeAuth(prv *ecdsa.PrivateKey, remote *ecdsa.PublicKey) ([]byte, error) {
+	var msg authPacket
+	copy(msg.Pubkey[:], crypto.FromECDSAPub(&prv.PublicKey)[1:])
+	copy(msg.Ephemeral[:], crypto.FromECDSAPub(&h.ephemeral.PublicKey)[1:])
+	msg.Nonce = h.nonce
+
+	sig, err := crypto.Sign(authHash(msg.Ephemeral[:], msg.Nonce[:], crypto.FromECDSAPub(remote)[1:]), prv)
+	if err != nil {
+		return nil, err
+	}
+	msg.Signature = sig
+	return encodePacket(packetAuth, &msg)
+}
+
+// handleAuth verifies the initiator's auth packet and returns the packet
+// along with the initiator's static and ephemeral keys.
+func handleAuth(prv *ecdsa.PrivateKey, data []byte) (*authPacket, *ecdsa.PublicKey, *ecdsa.PublicKey, error) {
+	var msg authPacket
+	if err := rlp.DecodeBytes(data, &msg); err != nil {
+		return nil, nil, nil, err
+	}
+	static, ephemeral, err := verifyKeys(msg.Pubkey, msg.Ephemeral)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	hash := authHash(msg.Ephemeral[:], msg.Nonce[:], crypto.FromECDSAPub(&prv.PublicKey)[1:])
+	if err := verifySignature(hash, msg.Signature, msg.Pubkey); err != nil {
+		return nil, nil, nil, err
+	}
+	return &msg, static, ephemeral, nil
+}
+
+// makeAuthAck creates the recipient's reply to the given auth packet.
+func (h *handshakeState) makeAuthAck(prv *ecdsa.PrivateKey, auth *authPacket) ([]byte, error) {
+	var msg authAckPacket
+	copy(msg.Pubkey[:], crypto.FromECDSAPub(&prv.PublicKey)[1:])
+	copy(msg.Ephemeral[:], crypto.FromECDSAPub(&h.ephemeral.PublicKey)[1:])
+	msg.Nonce = h.nonce
+
+	sig, err := crypto.Sign(authHash(msg.Ephemeral[:], msg.Nonce[:], auth.Nonce[:]), prv)
+	if err != nil {
+		return nil, err
+	}
+	msg.Signature = sig
+	return encodePacket(packetAuthAck, &msg)
+}
+
+// handleAuthAck verifies the recipient's reply. The static key of the
+// recipient must match the dial destination.
+func (h *handshakeState) handleAuthAck(remote *ecdsa.PublicKey, data []byte) (*authAckPacket, *ecdsa.PublicKey, error) {
+	var msg authAckPacket
+	if err := rlp.DecodeBytes(data, &msg); err != nil {
+		return nil, nil, err
+	}
+	static, ephemeral, err := verifyKeys(msg.Pubkey, msg.Ephemeral)
+	if err != nil {
+		return nil, nil, err
+	}
+	if static.X.Cmp(remote.X) != 0 || static.Y.Cmp(remote.Y) != 0 {
+		return nil, nil, errWrongIdentity
+	}
+	if err := verifySignature(authHash(msg.Ephemeral[:], msg.Nonce[:], h.nonce[:]), msg.Signature, msg.Pubkey); err != nil {
+		return nil, nil, err
+	}
+	return &msg, ephemeral, nil
+}
+
+// secrets derives the session ciphers from the ephemeral key exchange.
+// The initiator's egress cipher is the recipient's ingress cipher and
+// vice versa.
+func (h *handshakeState) secrets(remoteEphemeral *ecdsa.PublicKey, initNonce, respNonce []byte, initiator bool) (egress, ingress cipher.AEAD, err error) {
+	shared, err := ecies.ImportECDSA(h.ephemeral).GenerateShared(ecies.ImportECDSAPublic(remoteEphemeral), 16, 16)
+	if err != nil {
+		return nil, nil, err
+	}
+	initKey := crypto.Keccak256(shared, initNonce, respNonce, []byte("initiator"))
+	respKey := crypto.Keccak256(shared, initNonce, respNonce, []byte("recipient"))
+	if !initiator {
+		initKey, respKey = respKey, initKey
+	}
+	if egress, err = newGCM(initKey); err != nil {
+		return nil, nil, err
+	}
+	if ingress, err = newGCM(respKey); err != nil {
+		return nil, nil, err
+	}
+	return egress, ingress, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func authHash(ephemeral, nonce, extra []byte) []byte {
+	return crypto.Keccak256(ephemeral, nonce, extra)
+}
+
+func verifyKeys(static, ephemeral [64]byte) (*ecdsa.PublicKey, *ecdsa.PublicKey, error) {
+	s, err := crypto.UnmarshalPubkey(append([]byte{4}, static[:]...))
+	if err != nil {
+		return nil, nil, errInvalidPubkey
+	}
+	e, err := crypto.UnmarshalPubkey(append([]byte{4}, ephemeral[:]...))
+	if err != nil {
+		return nil, nil, errInvalidPubkey
+	}
+	return s, e, nil
+}
+
+func verifySignature(hash, sig []byte, pubkey [64]byte) error {
+	if len(sig) != crypto.SignatureLength {
+		return errBadSignature
+	}
+	pub, err := crypto.Ecrecover(hash, sig)
+	if err != nil {
+		return errBadSignature
+	}
+	if len(pub) != 65 || string(pub[1:]) != string(pubkey[:]) {
+		return errBadSignature
+	}
+	return nil
+}
+
+func encodePacket(kind byte, msg interface{}) ([]byte, error) {
+	enc, err := rlp.EncodeToBytes(msg)
+	if err != nil {
+		return nil, err
+	}
+	if len(enc)+1 > maxPacketSize {
+		return nil, fmt.Errorf("handshake packet too large (%d bytes)", len(enc))
+	}
+	return append([]byte{kind}, enc...), nil
+}
@@ -0,0 +1,209 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package mux
+
+import (
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const acceptQueueLen = 64
+
+// Listener accepts sessions on a UDP socket. Packets are demultiplexed into
+// sessions by their source address. A new session is created when an auth
+// packet arrives from an unknown address.
+type Listener struct {
+	conn      *net.UDPConn
+	accept    chan *session
+	closing   chan struct{}
+	closeOnce sync.Once
+
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+// Listen creates a listener on the given UDP address.
+func Listen(addr string) (*Listener, error) {
+	laddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", laddr)
+	if err != nil {
+		return nil, err
+	}
+	l := &Listener{
+		conn:     conn,
+		accept:   make(chan *session, acceptQueueLen),
+		closing:  make(chan struct{}),
+		sessions: make(map[string]*session),
+	}
+	go l.readLoop()
+	return l, nil
+}
+
+// Accept waits for the next inbound session.
+func (l *Listener) Accept() (net.Conn, error) {
+	select {
+	case s := <-l.accept:
+		return s, nil
+	case <-l.closing:
+		return nil, errClosed
+	}
+}
+
+// Close closes the UDP socket and all sessions on it.
+func (l *Listener) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		close(l.closing)
+		err = l.conn.Close()
+	})
+	return err
+}
+
+// Addr returns the local address of the socket.
+func (l *Listener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
+
+func (l *Listener) readLoop() {
+	defer func() {
+		l.mu.Lock()
+		for _, s := range l.sessions {
+			s.shutdown()
+		}
+		l.sessions = nil
+		l.mu.Unlock()
+		l.Close()
+	}()
+
+	for {
+		buf := make([]byte, maxPacketSize+1)
+		n, from, err := l.conn.ReadFromUDP(buf)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Temporary() {
+				continue
+			}
+			return
+		}
+		if n == 0 || n > maxPacketSize {
+			continue
+		}
+		l.dispatch(from, buf[:n])
+	}
+}
+
+// dispatch hands a packet to the session of its sender.
+func (l *Listener) dispatch(from *net.UDPAddr, p []byte) {
+	key := from.String()
+
+	l.mu.Lock()
+	s := l.sessions[key]
+	if s == nil {
+		if p[0] != packetAuth {
+			l.mu.Unlock()
+			return
+		}
+		s = newSession(l, from)
+		select {
+		case l.accept <- s:
+			l.sessions[key] = s
+		default:
+			// Accept queue is full, drop the session. The remote end
+			// will retry the handshake.
+			l.mu.Unlock()
+			return
+		}
+	}
+	l.mu.Unlock()
+	s.deliver(p)
+}
+
+func (l *Listener) remove(s *session) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.sessions[s.key] == s {
+		delete(l.sessions, s.key)
+	}
+}
+
+// session is the net.Conn of a single remote address on the shared socket.
+type session struct {
+	l         *Listener
+	addr      *net.UDPAddr
+	key       string
+	in        chan []byte
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newSession(l *Listener, addr *net.UDPAddr) *session {
+	return &session{
+		l:      l,
+		addr:   addr,
+		key:    addr.String(),
+		in:     make(chan []byte, packetQueueLen),
+		closed: make(chan struct{}),
+	}
+}
+
+// deliver queues an inbound packet. Packets are dropped if the queue is full.
+func (s *session) deliver(p []byte) {
+	select {
+	case s.in <- p:
+	default:
+	}
+}
+
+func (s *session) Read(b []byte) (int, error) {
+	select {
+	case p := <-s.in:
+		return copy(b, p), nil
+	case <-s.closed:
+		return 0, io.EOF
+	}
+}
+
+func (s *session) Write(b []byte) (int, error) {
+	select {
+	case <-s.closed:
+		return 0, errClosed
+	default:
+	}
+	return s.l.conn.WriteToUDP(b, s.addr)
+}
+
+func (s *session) Close() error {
+	s.shutdown()
+	s.l.remove(s)
+	return nil
+}
+
+func (s *session) shutdown() {
+	s.closeOnce.Do(func() { close(s.closed) })
+}
+
+func (s *session) LocalAddr() net.Addr  { return s.l.conn.LocalAddr() }
+func (s *session) RemoteAddr() net.Addr { return s.addr }
+
+// Deadlines are handled by Conn, sessions ignore them.
+func (s *session) SetDeadline(t time.Time) error      { return nil }
+func (s *session) SetReadDeadline(t time.Time) error  { return nil }
+func (s *session) SetWriteDeadline(t time.Time) error { return nil }
@@ -0,0 +1,672 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package mux implements a datagram-based devp2p session transport.
+//
+// Sessions run on top of UDP, many of them sharing a single socket. Each session
+// is authenticated with the secp256k1 node keys of both parties and encrypted with
+// AES-GCM keys derived from an ephemeral key exchange. Messages are split into
+// fragments which are delivered reliably and in order using sequence numbers,
+// cumulative acknowledgements and retransmission.
+package mux
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Packet kinds.
+const (
+	packetAuth    = 0x01
+	packetAuthAck = 0x02
+	packetSealed  = 0x03
+)
+
+// Frame types carried in sealed packets.
+const (
+	frameData  = 0x01
+	frameAck   = 0x02
+	frameClose = 0x03
+
+	flagLast = 0x01 // marks the last fragment of a message
+)
+
+const (
+	maxPacketSize   = 1280
+	sealHeaderSize  = 1 + 8 // kind, packet number
+	sealOverhead    = sealHeaderSize + 16
+	frameHeaderSize = 1 + 8 + 1 // type, sequence number, flags
+	maxChunkSize    = maxPacketSize - sealOverhead - frameHeaderSize
+	maxMessageSize  = 16 * 1024 * 1024
+
+	sendWindow     = 64   // maximum number of unacknowledged fragments
+	maxSendQueue   = 4096 // fragments queued before Write blocks
+	maxRecvQueue   = 64   // received messages buffered before new data is dropped
+	packetQueueLen = 256
+
+	retransmitInterval = 200 * time.Millisecond
+	handshakeRetry     = 500 * time.Millisecond
+	closeLinger        = 1 * time.Second
+)
+
+var (
+	errClosed          = errors.New("use of closed mux connection")
+	errMessageTooLarge = errors.New("message too large")
+	errNoHandshake     = errors.New("handshake not completed")
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// Conn is a session of the multiplexed transport. The underlying connection
+// must preserve message boundaries, i.e. every Read must return exactly one
+// packet written by the remote end. Connected UDP sockets, sessions accepted
+// from a Listener and net.Pipe all satisfy this.
+type Conn struct {
+	fd       net.Conn
+	dialDest *ecdsa.PublicKey
+
+	mu            sync.Mutex
+	readDeadline  time.Time
+	writeDeadline time.Time
+	started       bool // set when the handshake has completed
+	running       bool // set when the I/O goroutines have been launched
+	closed        bool
+	closeOnce     sync.Once
+
+	// Session keys, established by Handshake.
+	egress, ingress cipher.AEAD
+	egressPN        uint64
+	auth, authAck   []byte // recipient only: retransmitted on duplicate auth
+
+	packets chan []byte // received by readLoop
+	readErr error       // valid after packets is closed
+
+	wmu        sync.Mutex
+	wqueue     [][]byte
+	wclosed    bool
+	wsignal    chan struct{}
+	writerDone chan struct{}
+
+	writeCh  chan []fragment
+	deliver  chan message
+	closeReq chan struct{}
+	done     chan struct{}
+	err      error // valid after done is closed
+}
+
+type fragment struct {
+	seq  uint64
+	last bool
+	data []byte
+}
+
+type message struct {
+	code     uint64
+	data     []byte
+	wireSize int
+}
+
+// NewConn wraps the given network connection. dialDest is the public key of
+// the remote node when dialing, and nil for inbound connections.
+func NewConn(fd net.Conn, dialDest *ecdsa.PublicKey) *Conn {
+	return &Conn{
+		fd:         fd,
+		dialDest:   dialDest,
+		packets:    make(chan []byte, packetQueueLen),
+		wsignal:    make(chan struct{}, 1),
+		writerDone: make(chan struct{}),
+		writeCh:    make(chan []fragment),
+		deliver:    make(chan message),
+		closeReq:   make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// SetReadDeadline sets the deadline for Read and for the handshake.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readDeadline = t
+	return nil
+}
+
+// SetWriteDeadline sets the deadline for Write.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeDeadline = t
+	return nil
+}
+
+// SetDeadline sets both the read and write deadline.
+func (c *Conn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readDeadline, c.writeDeadline = t, t
+	return nil
+}
+
+// Handshake performs the session handshake. It must be called before
+// data can be exchanged on the connection.
+func (c *Conn) Handshake(prv *ecdsa.PrivateKey) (*ecdsa.PublicKey, error) {
+	c.mu.Lock()
+	if c.closed || c.running {
+		c.mu.Unlock()
+		return nil, errClosed
+	}
+	c.running = true
+	c.mu.Unlock()
+	go c.readLoop()
+	go c.writeLoop()
+
+	var (
+		remote *ecdsa.PublicKey
+		err    error
+	)
+	if c.dialDest != nil {
+		remote, err = c.initiatorHandshake(prv)
+	} else {
+		remote, err = c.recipientHandshake(prv)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil, errClosed
+	}
+	c.started = true
+	go c.loop()
+	return remote, nil
+}
+
+func (c *Conn) initiatorHandshake(prv *ecdsa.PrivateKey) (*ecdsa.PublicKey, error) {
+	h, err := newHandshakeState()
+	if err != nil {
+		return nil, err
+	}
+	auth, err := h.makeAuth(prv, c.dialDest)
+	if err != nil {
+		return nil, err
+	}
+	c.send(auth)
+
+	retry := time.NewTicker(handshakeRetry)
+	defer retry.Stop()
+	timeout, stop := c.deadlineTimer(&c.readDeadline)
+	defer stop()
+	for {
+		select {
+		case p, ok := <-c.packets:
+			if !ok {
+				return nil, c.readErr
+			}
+			if len(p) == 0 || p[0] != packetAuthAck {
+				continue
+			}
+			ack, ephemeral, err := h.handleAuthAck(c.dialDest, p[1:])
+			if err != nil {
+				return nil, err
+			}
+			c.egress, c.ingress, err = h.secrets(ephemeral, h.nonce[:], ack.Nonce[:], true)
+			if err != nil {
+				return nil, err
+			}
+			return c.dialDest, nil
+		case <-retry.C:
+			c.send(auth)
+		case <-timeout:
+			return nil, timeoutError{}
+		}
+	}
+}
+
+func (c *Conn) recipientHandshake(prv *ecdsa.PrivateKey) (*ecdsa.PublicKey, error) {
+	timeout, stop := c.deadlineTimer(&c.readDeadline)
+	defer stop()
+	for {
+		select {
+		case p, ok := <-c.packets:
+			if !ok {
+				return nil, c.readErr
+			}
+			if len(p) == 0 || p[0] != packetAuth {
+				continue
+			}
+			auth, static, ephemeral, err := handleAuth(prv, p[1:])
+			if err != nil {
+				return nil, err
+			}
+			h, err := newHandshakeState()
+			if err != nil {
+				return nil, err
+			}
+			ack, err := h.makeAuthAck(prv, auth)
+			if err != nil {
+				return nil, err
+			}
+			c.egress, c.ingress, err = h.secrets(ephemeral, auth.Nonce[:], h.nonce[:], false)
+			if err != nil {
+				return nil, err
+			}
+			c.auth, c.authAck = p, ack
+			c.send(ack)
+			return static, nil
+		case <-timeout:
+			return nil, timeoutError{}
+		}
+	}
+}
+
+// Read reads a message from the connection.
+func (c *Conn) Read() (code uint64, data []byte, wireSize int, err error) {
+	if !c.isStarted() {
+		return 0, nil, 0, errNoHandshake
+	}
+	timeout, stop := c.deadlineTimer(&c.readDeadline)
+	defer stop()
+	select {
+	case msg := <-c.deliver:
+		return msg.code, msg.data, msg.wireSize, nil
+	case <-c.done:
+		return 0, nil, 0, c.err
+	case <-timeout:
+		return 0, nil, 0, timeoutError{}
+	}
+}
+
+// Write queues a message for sending. It returns the size of the message on
+// the wire. Write blocks while too many fragments are waiting for delivery.
+func (c *Conn) Write(code uint64, data []byte) (uint32, error) {
+	if !c.isStarted() {
+		return 0, errNoHandshake
+	}
+	if len(data) > maxMessageSize {
+		return 0, errMessageTooLarge
+	}
+	payload := make([]byte, binary.MaxVarintLen64+len(data))
+	n := binary.PutUvarint(payload, code)
+	payload = append(payload[:n], data...)
+
+	var frags []fragment
+	for len(payload) > 0 {
+		chunk := payload
+		if len(chunk) > maxChunkSize {
+			chunk = chunk[:maxChunkSize]
+		}
+		payload = payload[len(chunk):]
+		frags = append(frags, fragment{data: chunk, last: len(payload) == 0})
+	}
+
+	timeout, stop := c.deadlineTimer(&c.writeDeadline)
+	defer stop()
+	select {
+	case c.writeCh <- frags:
+		return uint32(n + len(data) + len(frags)*(sealOverhead+frameHeaderSize)), nil
+	case <-c.done:
+		return 0, c.err
+	case <-timeout:
+		return 0, timeoutError{}
+	}
+}
+
+// Close closes the connection. Messages already passed to Write are delivered
+// before the connection is shut down, unless that takes too long.
+func (c *Conn) Close() error {
+	c.closeOnce.Do(func() {
+		c.mu.Lock()
+		c.closed = true
+		started := c.started
+		c.mu.Unlock()
+
+		if started {
+			close(c.closeReq)
+		} else {
+			c.shutdown(errClosed)
+		}
+	})
+	<-c.done
+	return nil
+}
+
+func (c *Conn) isStarted() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.started
+}
+
+// deadlineTimer returns a channel which fires when the given deadline expires.
+func (c *Conn) deadlineTimer(deadline *time.Time) (<-chan time.Time, func()) {
+	c.mu.Lock()
+	t := *deadline
+	c.mu.Unlock()
+
+	if t.IsZero() {
+		return nil, func() {}
+	}
+	timer := time.NewTimer(time.Until(t))
+	return timer.C, func() { timer.Stop() }
+}
+
+// loop runs the session after the handshake. It owns the send and receive state.
+func (c *Conn) loop() {
+	var (
+		sendq    []fragment // unacknowledged fragments, sendq[0] has sequence number sendBase
+		sendBase uint64
+		nextSeq  uint64
+		inflight int // number of fragments in sendq which have been transmitted
+		dupAcks  int
+		fastRtx  = ^uint64(0) // sendBase of the last fast retransmit
+		recvNext uint64
+		partial  []byte
+		partSize int
+		msgs     []message
+
+		rtx        = time.NewTimer(retransmitInterval)
+		rtxActive  = true
+		closing    bool
+		linger     <-chan time.Time
+		remoteDone bool
+		err        error
+	)
+	defer rtx.Stop()
+
+	fillWindow := func() {
+		for inflight < len(sendq) && inflight < sendWindow {
+			c.sendFragment(sendq[inflight])
+			inflight++
+		}
+		if inflight > 0 && !rtxActive {
+			rtx.Reset(retransmitInterval)
+			rtxActive = true
+		}
+	}
+
+loop:
+	for {
+		var (
+			writeCh   chan []fragment
+			deliverCh chan message
+			next      message
+		)
+		if !closing && !remoteDone && len(sendq) < maxSendQueue {
+			writeCh = c.writeCh
+		}
+		if len(msgs) > 0 {
+			deliverCh = c.deliver
+			next = msgs[0]
+		} else if remoteDone {
+			err = io.EOF
+			break loop
+		}
+
+		select {
+		case p, ok := <-c.packets:
+			if !ok {
+				err = c.readErr
+				break loop
+			}
+			if remoteDone {
+				continue
+			}
+			if p[0] == packetAuth {
+				// The initiator didn't receive our reply, send it again.
+				if bytes.Equal(p, c.auth) {
+					c.send(c.authAck)
+				}
+				continue
+			}
+			frame, ferr := c.open(p)
+			if ferr != nil || len(frame) < frameHeaderSize {
+				continue // ignore undecryptable packets
+			}
+			seq := binary.BigEndian.Uint64(frame[1:9])
+			switch frame[0] {
+			case frameData:
+				if seq == recvNext && len(msgs) < maxRecvQueue {
+					partial = append(partial, frame[frameHeaderSize:]...)
+					partSize += sealOverhead + len(frame)
+					if len(partial) > maxMessageSize+binary.MaxVarintLen64 {
+						err = errMessageTooLarge
+						break loop
+					}
+					recvNext++
+					if frame[9]&flagLast != 0 {
+						code, n := binary.Uvarint(partial)
+						if n <= 0 {
+							err = fmt.Errorf("invalid message code")
+							break loop
+						}
+						msgs = append(msgs, message{code: code, data: partial[n:], wireSize: partSize})
+						partial, partSize = nil, 0
+					}
+				}
+				c.sendFrame(frameAck, recvNext, 0, nil)
+
+			case frameAck:
+				if seq == sendBase && inflight > 0 && fastRtx != sendBase {
+					// Duplicate acknowledgements signal a lost fragment. Don't
+					// wait for the retransmission timer in that case.
+					if dupAcks++; dupAcks >= 3 {
+						for i := 0; i < inflight; i++ {
+							c.sendFragment(sendq[i])
+						}
+						fastRtx, dupAcks = sendBase, 0
+					}
+				}
+				if seq > sendBase && seq <= sendBase+uint64(inflight) {
+					acked := int(seq - sendBase)
+					dupAcks = 0
+					sendq = sendq[acked:]
+					inflight -= acked
+					sendBase = seq
+					if rtxActive && !rtx.Stop() {
+						<-rtx.C
+					}
+					rtxActive = false
+					fillWindow()
+				}
+				if closing && len(sendq) == 0 {
+					break loop
+				}
+
+			case frameClose:
+				remoteDone = true
+			}
+
+		case frags := <-writeCh:
+			for i := range frags {
+				frags[i].seq = nextSeq
+				nextSeq++
+			}
+			sendq = append(sendq, frags...)
+			fillWindow()
+
+		case deliverCh <- next:
+			msgs = msgs[1:]
+
+		case <-rtx.C:
+			rtxActive = false
+			if !remoteDone {
+				// Go-back-N: retransmit everything which isn't acknowledged yet.
+				for i := 0; i < inflight; i++ {
+					c.sendFragment(sendq[i])
+				}
+			}
+			if inflight > 0 {
+				rtx.Reset(retransmitInterval)
+				rtxActive = true
+			}
+
+		case <-c.closeReq:
+			closing = true
+			if len(sendq) == 0 {
+				break loop
+			}
+			linger = time.After(closeLinger)
+
+		case <-linger:
+			break loop
+		}
+	}
+	if closing {
+		c.sendFrame(frameClose, 0, 0, nil)
+		err = errClosed
+	}
+	c.shutdown(err)
+}
+
+// shutdown flushes pending packets, closes the network connection and
+// releases all waiters.
+func (c *Conn) shutdown(err error) {
+	c.wmu.Lock()
+	c.wclosed = true
+	c.wmu.Unlock()
+	c.signalWriter()
+
+	c.mu.Lock()
+	running := c.running
+	c.mu.Unlock()
+	if running {
+		select {
+		case <-c.writerDone:
+		case <-time.After(closeLinger):
+		}
+	}
+	c.fd.Close()
+	c.err = err
+	close(c.done)
+}
+
+func (c *Conn) sendFragment(f fragment) {
+	var flags byte
+	if f.last {
+		flags = flagLast
+	}
+	c.sendFrame(frameData, f.seq, flags, f.data)
+}
+
+func (c *Conn) sendFrame(typ byte, seq uint64, flags byte, data []byte) {
+	frame := make([]byte, frameHeaderSize+len(data))
+	frame[0] = typ
+	binary.BigEndian.PutUint64(frame[1:9], seq)
+	frame[9] = flags
+	copy(frame[frameHeaderSize:], data)
+	c.send(c.seal(frame))
+}
+
+// seal encrypts a frame. The packet number is used as the AEAD nonce and is
+// authenticated as additional data.
+func (c *Conn) seal(frame []byte) []byte {
+	header := make([]byte, sealHeaderSize, sealOverhead+len(frame))
+	header[0] = packetSealed
+	binary.BigEndian.PutUint64(header[1:], c.egressPN)
+	c.egressPN++
+	return c.egress.Seal(header, packetNonce(header[1:]), frame, header)
+}
+
+func (c *Conn) open(p []byte) ([]byte, error) {
+	if len(p) < sealOverhead || p[0] != packetSealed {
+		return nil, errors.New("invalid packet")
+	}
+	header := p[:sealHeaderSize]
+	return c.ingress.Open(nil, packetNonce(header[1:]), p[sealHeaderSize:], header)
+}
+
+func packetNonce(pn []byte) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce[4:], pn)
+	return nonce
+}
+
+// readLoop reads packets from the network connection.
+func (c *Conn) readLoop() {
+	defer close(c.packets)
+	for {
+		buf := make([]byte, maxPacketSize+1)
+		n, err := c.fd.Read(buf)
+		if err != nil {
+			c.readErr = err
+			return
+		}
+		if n == 0 || n > maxPacketSize {
+			continue
+		}
+		select {
+		case c.packets <- buf[:n]:
+		case <-c.done:
+			c.readErr = errClosed
+			return
+		}
+	}
+}
+
+// send queues a packet for writing. It never blocks.
+func (c *Conn) send(p []byte) {
+	c.wmu.Lock()
+	if !c.wclosed {
+		c.wqueue = append(c.wqueue, p)
+	}
+	c.wmu.Unlock()
+	c.signalWriter()
+}
+
+func (c *Conn) signalWriter() {
+	select {
+	case c.wsignal <- struct{}{}:
+	default:
+	}
+}
+
+// writeLoop writes queued packets to the network connection. Writes happen on
+// a separate goroutine so reading is never blocked by a slow writer.
+func (c *Conn) writeLoop() {
+	defer close(c.writerDone)
+	for {
+		c.wmu.Lock()
+		queue, closed := c.wqueue, c.wclosed
+		c.wqueue = nil
+		c.wmu.Unlock()
+
+		for _, p := range queue {
+			if _, err := c.fd.Write(p); err != nil {
+				c.wmu.Lock()
+				c.wqueue, c.wclosed = nil, true
+				c.wmu.Unlock()
+				return
+			}
+		}
+		if closed && len(queue) == 0 {
+			return
+		}
+		if len(queue) == 0 {
+			<-c.wsignal
+		}
+	}
+}
@@ -0,0 +1,260 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package mux
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/simulations/pipes"
+)
+
+type handshakeResult struct {
+	conn   *Conn
+	remote *ecdsa.PublicKey
+	err    error
+}
+
+// createPeers runs the handshake on both ends of the given connections.
+func createPeers(t *testing.T, fd1, fd2 net.Conn) (*Conn, *Conn) {
+	prv1, _ := crypto.GenerateKey()
+	prv2, _ := crypto.GenerateKey()
+
+	var (
+		c1  = NewConn(fd1, &prv2.PublicKey)
+		c2  = NewConn(fd2, nil)
+		res = make(chan handshakeResult, 2)
+	)
+	for _, c := range []*Conn{c1, c2} {
+		c.SetDeadline(time.Now().Add(5 * time.Second))
+	}
+	go func() {
+		remote, err := c1.Handshake(prv1)
+		res <- handshakeResult{c1, remote, err}
+	}()
+	go func() {
+		remote, err := c2.Handshake(prv2)
+		res <- handshakeResult{c2, remote, err}
+	}()
+	for i := 0; i < 2; i++ {
+		r := <-res
+		if r.err != nil {
+			t.Fatalf("handshake failed: %v", r.err)
+		}
+		want := &prv2.PublicKey
+		if r.conn == c2 {
+			want = &prv1.PublicKey
+		}
+		if r.remote.X.Cmp(want.X) != 0 || r.remote.Y.Cmp(want.Y) != 0 {
+			t.Fatal("wrong remote key")
+		}
+	}
+	for _, c := range []*Conn{c1, c2} {
+		c.SetDeadline(time.Time{})
+	}
+	return c1, c2
+}
+
+func checkExchange(t *testing.T, c1, c2 *Conn) {
+	var (
+		small = []byte("hello")
+		large = bytes.Repeat([]byte{0xab, 0xcd, 0xef}, 100000)
+		wg    sync.WaitGroup
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10; i++ {
+			if _, err := c1.Write(uint64(i), small); err != nil {
+				t.Errorf("write error: %v", err)
+				return
+			}
+		}
+		if _, err := c1.Write(100, large); err != nil {
+			t.Errorf("write error: %v", err)
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		code, data, _, err := c2.Read()
+		if err != nil {
+			t.Fatalf("read error: %v", err)
+		}
+		if code != uint64(i) || !bytes.Equal(data, small) {
+			t.Fatalf("wrong message %d: code %d, data %x", i, code, data)
+		}
+	}
+	code, data, wireSize, err := c2.Read()
+	if err != nil {
+		t.Fatalf("read error: %v", err)
+	}
+	if code != 100 || !bytes.Equal(data, large) {
+		t.Fatalf("large message mismatch: code %d, len %d", code, len(data))
+	}
+	if wireSize <= len(large) {
+		t.Errorf("wire size %d smaller than message", wireSize)
+	}
+	wg.Wait()
+}
+
+func TestConnPipe(t *testing.T) {
+	fd1, fd2, _ := pipes.NetPipe()
+	c1, c2 := createPeers(t, fd1, fd2)
+	defer c2.Close()
+
+	checkExchange(t, c1, c2)
+	checkExchange(t, c2, c1)
+
+	// Closing one end delivers pending messages and then EOF.
+	if _, err := c1.Write(7, []byte("bye")); err != nil {
+		t.Fatal(err)
+	}
+	go c1.Close()
+	if code, _, _, err := c2.Read(); err != nil || code != 7 {
+		t.Fatalf("expected last message, got code %d, err %v", code, err)
+	}
+	if _, _, _, err := c2.Read(); err != io.EOF {
+		t.Fatalf("expected EOF after close, got %v", err)
+	}
+}
+
+func TestConnWrongIdentity(t *testing.T) {
+	fd1, fd2, _ := pipes.NetPipe()
+	prv1, _ := crypto.GenerateKey()
+	prv2, _ := crypto.GenerateKey()
+	other, _ := crypto.GenerateKey()
+
+	c1 := NewConn(fd1, &other.PublicKey)
+	c2 := NewConn(fd2, nil)
+	c1.SetDeadline(time.Now().Add(500 * time.Millisecond))
+	c2.SetDeadline(time.Now().Add(500 * time.Millisecond))
+	defer c1.Close()
+	defer c2.Close()
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := c2.Handshake(prv2)
+		errc <- err
+	}()
+	if _, err := c1.Handshake(prv1); err == nil {
+		t.Fatal("handshake succeeded with wrong recipient")
+	}
+	if err := <-errc; err != errBadSignature {
+		t.Fatalf("recipient accepted auth for another node: %v", err)
+	}
+}
+
+func TestConnReadTimeout(t *testing.T) {
+	fd1, fd2, _ := pipes.NetPipe()
+	c1, c2 := createPeers(t, fd1, fd2)
+	defer c1.Close()
+	defer c2.Close()
+
+	c2.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	_, _, _, err := c2.Read()
+	if nerr, ok := err.(net.Error); !ok || !nerr.Timeout() {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+}
+
+// lossyConn drops every n-th packet written to it.
+type lossyConn struct {
+	net.Conn
+	mu    sync.Mutex
+	n     int
+	count int
+}
+
+func (c *lossyConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	c.count++
+	drop := c.count%c.n == 0
+	c.mu.Unlock()
+	if drop {
+		return len(b), nil
+	}
+	return c.Conn.Write(b)
+}
+
+func TestConnPacketLoss(t *testing.T) {
+	fd1, fd2, _ := pipes.NetPipe()
+	c1, c2 := createPeers(t, &lossyConn{Conn: fd1, n: 17}, &lossyConn{Conn: fd2, n: 13})
+	defer c1.Close()
+	defer c2.Close()
+
+	checkExchange(t, c1, c2)
+	checkExchange(t, c2, c1)
+}
+
+func TestListener(t *testing.T) {
+	l, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	for i := 0; i < 3; i++ {
+		t.Run(fmt.Sprintf("session-%d", i), func(t *testing.T) {
+			fd1, err := net.Dial("udp", l.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			accepted := make(chan net.Conn, 1)
+			go func() {
+				fd, err := l.Accept()
+				if err != nil {
+					t.Error(err)
+				}
+				accepted <- fd
+			}()
+			// The session is created by the first auth packet, so the
+			// handshake must run before Accept returns.
+			var (
+				prv1, _ = crypto.GenerateKey()
+				prv2, _ = crypto.GenerateKey()
+				c1      = NewConn(fd1, &prv2.PublicKey)
+				errc    = make(chan error, 1)
+			)
+			c1.SetDeadline(time.Now().Add(5 * time.Second))
+			go func() {
+				_, err := c1.Handshake(prv1)
+				errc <- err
+			}()
+			c2 := NewConn(<-accepted, nil)
+			c2.SetDeadline(time.Now().Add(5 * time.Second))
+			if _, err := c2.Handshake(prv2); err != nil {
+				t.Fatal(err)
+			}
+			if err := <-errc; err != nil {
+				t.Fatal(err)
+			}
+			c1.SetDeadline(time.Time{})
+			c2.SetDeadline(time.Time{})
+
+			checkExchange(t, c1, c2)
+			checkExchange(t, c2, c1)
+			c1.Close()
+			c2.Close()
+		})
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package mux
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"net"
+
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
+
+// Entry is the "mux" ENR entry. It advertises the UDP port on which the node
+// accepts sessions of the multiplexed transport.
+type Entry uint16
+
+// ENRKey implements enr.Entry.
+func (Entry) ENRKey() string { return "mux" }
+
+// Transport implements p2p.Transport. Add it to p2p.Config.Transports to
+// connect to nodes advertising the transport through it.
+type Transport struct {
+	// ListenAddr is the UDP address for inbound sessions. If empty, the
+	// transport is only used for dialing.
+	ListenAddr string
+}
+
+var _ p2p.Transport = (*Transport)(nil)
+
+// Name implements p2p.Transport.
+func (t *Transport) Name() string { return "mux" }
+
+// Listen implements p2p.Transport.
+func (t *Transport) Listen() (net.Listener, enr.Entry, error) {
+	if t.ListenAddr == "" {
+		return nil, nil, nil
+	}
+	l, err := Listen(t.ListenAddr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return l, Entry(l.Addr().(*net.UDPAddr).Port), nil
+}
+
+// Supported implements p2p.Transport.
+func (t *Transport) Supported(n *enode.Node) bool {
+	var port Entry
+	return n.Load(&port) == nil && port != 0 && n.IP() != nil
+}
+
+// Dial implements p2p.Transport.
+func (t *Transport) Dial(ctx context.Context, dest *enode.Node) (net.Conn, error) {
+	var port Entry
+	if err := dest.Load(&port); err != nil {
+		return nil, fmt.Errorf("node doesn't advertise mux transport: %v", err)
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, "udp", (&net.UDPAddr{IP: dest.IP(), Port: int(port)}).String())
+}
+
+// NewConn implements p2p.Transport.
+func (t *Transport) NewConn(fd net.Conn, dialDest *ecdsa.PublicKey) p2p.TransportConn {
+	return NewConn(fd, dialDest)
+}
@@ -0,0 +1,138 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package mux
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/testlog"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
+)
+
+func startServer(t *testing.T, transports []p2p.Transport, run func(*p2p.Peer, p2p.MsgReadWriter) error) *p2p.Server {
+	key, _ := crypto.GenerateKey()
+	srv := &p2p.Server{Config: p2p.Config{
+		PrivateKey:  key,
+		MaxPeers:    10,
+		ListenAddr:  "127.0.0.1:0",
+		NoDiscovery: true,
+		Transports:  transports,
+		Logger:      testlog.Logger(t, log.LvlTrace),
+		Protocols:   []p2p.Protocol{{Name: "test", Version: 1, Length: 1, Run: run}},
+	}}
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return srv
+}
+
+// This test checks that two servers supporting the transport connect through it,
+// and that RLPx is used if the remote end doesn't advertise it.
+func TestServerTransport(t *testing.T) {
+	tests := []struct {
+		name           string
+		dialer, remote []p2p.Transport
+		wantNetwork    string
+		wantAdvertised bool
+	}{
+		{
+			name:           "both",
+			dialer:         []p2p.Transport{&Transport{ListenAddr: "127.0.0.1:0"}},
+			remote:         []p2p.Transport{&Transport{ListenAddr: "127.0.0.1:0"}},
+			wantNetwork:    "udp",
+			wantAdvertised: true,
+		},
+		{
+			name:           "dial-only",
+			dialer:         []p2p.Transport{&Transport{}},
+			remote:         []p2p.Transport{&Transport{ListenAddr: "127.0.0.1:0"}},
+			wantNetwork:    "udp",
+			wantAdvertised: true,
+		},
+		{
+			name:        "remote-unsupported",
+			dialer:      []p2p.Transport{&Transport{ListenAddr: "127.0.0.1:0"}},
+			wantNetwork: "tcp",
+		},
+		{
+			name:           "local-unsupported",
+			remote:         []p2p.Transport{&Transport{ListenAddr: "127.0.0.1:0"}},
+			wantNetwork:    "tcp",
+			wantAdvertised: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			received := make(chan []uint, 1)
+			remote := startServer(t, test.remote, func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+				msg, err := rw.ReadMsg()
+				if err != nil {
+					return err
+				}
+				var content []uint
+				if err := msg.Decode(&content); err != nil {
+					return err
+				}
+				received <- content
+				_, err = rw.ReadMsg()
+				return err
+			})
+			defer remote.Stop()
+
+			dialerRun := func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+				if err := p2p.Send(rw, 0, []uint{1, 2, 3}); err != nil {
+					return err
+				}
+				_, err := rw.ReadMsg()
+				return err
+			}
+			dialer := startServer(t, test.dialer, dialerRun)
+			defer dialer.Stop()
+
+			var port Entry
+			node := remote.Self()
+			if advertised := node.Load(&port) == nil; advertised != test.wantAdvertised {
+				t.Fatalf("mux entry advertised: %t, want %t", advertised, test.wantAdvertised)
+			}
+
+			dialer.AddPeer(node)
+			select {
+			case content := <-received:
+				if !reflect.DeepEqual(content, []uint{1, 2, 3}) {
+					t.Fatalf("wrong message content %v", content)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("message not received")
+			}
+			peers := dialer.Peers()
+			if len(peers) != 1 {
+				t.Fatalf("wrong number of peers: %d", len(peers))
+			}
+			if network := peers[0].RemoteAddr().Network(); network != test.wantNetwork {
+				t.Errorf("peer connected over %s, want %s", network, test.wantNetwork)
+			}
+			if _, ok := peers[0].RemoteAddr().(*net.UDPAddr); ok != (test.wantNetwork == "udp") {
+				t.Errorf("wrong remote address type %T", peers[0].RemoteAddr())
+			}
+		})
+	}
+}
@@ -148,6 +148,11 @@ type Config struct {
 	// If NoDial is true, the server will not dial any peers.
 	NoDial bool `toml:",omitempty"`
 
+	// Transports contains additional session transports. Nodes advertising one
+	// of these transports in their record are dialed through it, falling back
+	// to RLPx over TCP if that fails. All other connections use RLPx over TCP.
+	Transports []Transport `toml:"-"`
+
 	// If EnableMsgEvents is set then the server will emit PeerEvents
 	// whenever a message is sent to or received from a peer
 	EnableMsgEvents bool
@@ -173,6 +178,7 @@ type Server struct {
 	running bool
 
 	listener     net.Listener
+	trListeners  []net.Listener // listeners of Config.Transports
 	ourHandshake *protoHandshake
 	loopWG       sync.WaitGroup // loop, listenLoop
 	peerFeed     event.Feed
@@ -400,6 +406,9 @@ func (srv *Server) Stop() {
 		// this unblocks listener Accept
 		srv.listener.Close()
 	}
+	for _, l := range srv.trListeners {
+		l.Close()
+	}
 	close(srv.quit)
 	srv.lock.Unlock()
 	srv.loopWG.Wait()
@@ -478,6 +487,9 @@ func (srv *Server) Start() (err error) {
 			return err
 		}
 	}
+	if err := srv.setupTransports(); err != nil {
+		return err
+	}
 	if err := srv.setupDiscovery(); err != nil {
 		return err
 	}
@@ -630,6 +642,9 @@ func (srv *Server) setupDialScheduler() {
 	if config.dialer == nil {
 		config.dialer = tcpDialer{&net.Dialer{Timeout: defaultDialTimeout}}
 	}
+	if len(srv.Transports) > 0 {
+		config.dialer = &transportDialer{transports: srv.Transports, fallback: config.dialer}
+	}
 	srv.dialsched = newDialScheduler(config, srv.discmix, srv.SetupConn)
 	for _, n := range srv.StaticNodes {
 		srv.dialsched.addStatic(n)
@@ -677,7 +692,28 @@ func (srv *Server) setupListening() error {
 	}
 
 	srv.loopWG.Add(1)
-	go srv.listenLoop()
+	go srv.listenLoop(srv.listener, nil)
+	return nil
+}
+
+// setupTransports starts the listeners of all additional transports and
+// advertises them in the local node record.
+func (srv *Server) setupTransports() error {
+	for _, tr := range srv.Transports {
+		listener, entry, err := tr.Listen()
+		if err != nil {
+			return fmt.Errorf("can't start %s transport: %v", tr.Name(), err)
+		}
+		if listener == nil {
+			continue
+		}
+		if entry != nil {
+			srv.localnode.Set(entry)
+		}
+		srv.trListeners = append(srv.trListeners, listener)
+		srv.loopWG.Add(1)
+		go srv.listenLoop(listener, tr)
+	}
 	return nil
 }
 
@@ -826,9 +862,13 @@ func (srv *Server) addPeerChecks(peers map[enode.ID]*Peer, inboundCount int, c *
 }
 
 // listenLoop runs in its own goroutine and accepts
-// inbound connections.
-func (srv *Server) listenLoop() {
-	srv.log.Debug("TCP listener up", "addr", srv.listener.Addr())
+// inbound connections. The transport is nil for the RLPx listener.
+func (srv *Server) listenLoop(listener net.Listener, tr Transport) {
+	if tr == nil {
+		srv.log.Debug("TCP listener up", "addr", listener.Addr())
+	} else {
+		srv.log.Debug("Transport listener up", "transport", tr.Name(), "addr", listener.Addr())
+	}
 
 	// The slots channel limits accepts of new connections.
 	tokens := defaultMaxPendingPeers
@@ -859,7 +899,7 @@ func (srv *Server) listenLoop() {
 			lastLog time.Time
 		)
 		for {
-			fd, err = srv.listener.Accept()
+			fd, err = listener.Accept()
 			if netutil.IsTemporaryError(err) {
 				if time.Since(lastLog) > 1*time.Second {
 					srv.log.Debug("Temporary read error", "err", err)
@@ -890,6 +930,9 @@ func (srv *Server) listenLoop() {
 			fd = newMeteredConn(fd, true, addr)
 			srv.log.Trace("Accepted connection", "addr", fd.RemoteAddr())
 		}
+		if tr != nil {
+			fd = &transportConn{Conn: fd, tr: tr}
+		}
 		go func() {
 			srv.SetupConn(fd, inboundConn, nil)
 			slots <- struct{}{}
@@ -920,10 +963,14 @@ func (srv *Server) checkInboundConn(fd net.Conn, remoteIP net.IP) error {
 // or the handshakes have failed.
 func (srv *Server) SetupConn(fd net.Conn, flags connFlag, dialDest *enode.Node) error {
 	c := &conn{fd: fd, flags: flags, cont: make(chan error)}
-	if dialDest == nil {
-		c.transport = srv.newTransport(fd, nil)
+	var dialPubkey *ecdsa.PublicKey
+	if dialDest != nil {
+		dialPubkey = dialDest.Pubkey()
+	}
+	if tc, ok := fd.(*transportConn); ok {
+		c.transport = newSessionTransport(tc.tr, tc.Conn, dialPubkey)
 	} else {
-		c.transport = srv.newTransport(fd, dialDest.Pubkey())
+		c.transport = srv.newTransport(fd, dialPubkey)
 	}
 
 	err := srv.setupConn(c, flags, dialDest)
@@ -994,9 +1041,13 @@ func (srv *Server) setupConn(c *conn, flags connFlag, dialDest *enode.Node) erro
 func nodeFromConn(pubkey *ecdsa.PublicKey, conn net.Conn) *enode.Node {
 	var ip net.IP
 	var port int
-	if tcp, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
-		ip = tcp.IP
-		port = tcp.Port
+	switch addr := conn.RemoteAddr().(type) {
+	case *net.TCPAddr:
+		ip = addr.IP
+		port = addr.Port
+	case *net.UDPAddr:
+		// Sessions of other transports don't reveal the TCP port.
+		ip = addr.IP
 	}
 	return enode.NewV4(pubkey, ip, port, port)
 }
@@ -18,6 +18,7 @@ package p2p
 
 import (
 	"bytes"
+	"context"
 	"crypto/ecdsa"
 	"fmt"
 	"io"
@@ -27,6 +28,8 @@ import (
 
 	"github.com/ethereum/go-ethereum/common/bitutil"
 	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
 	"github.com/ethereum/go-ethereum/p2p/rlpx"
 	"github.com/ethereum/go-ethereum/rlp"
 )
@@ -42,18 +45,76 @@ const (
 	discWriteTimeout = 1 * time.Second
 )
 
+// Transport is a session transport which can be used alongside the default
+// RLPx-over-TCP transport. Transports are enabled through Config.Transports.
+// When dialing a node which advertises an enabled transport in its record, the
+// connection is established through that transport instead of RLPx, unless
+// dialing it fails.
+type Transport interface {
+	// Name returns the name of the transport, used for logging.
+	Name() string
+
+	// Listen starts accepting inbound sessions. The returned record entry is
+	// added to the local node record to advertise the transport. A transport
+	// that doesn't accept inbound sessions returns a nil listener.
+	Listen() (net.Listener, enr.Entry, error)
+
+	// Supported reports whether the given node advertises the transport.
+	Supported(n *enode.Node) bool
+
+	// Dial establishes a connection to the transport endpoint of the node.
+	Dial(ctx context.Context, dest *enode.Node) (net.Conn, error)
+
+	// NewConn wraps a connection created by Dial or accepted from the listener
+	// into a message connection. dialDest is nil for inbound connections.
+	NewConn(fd net.Conn, dialDest *ecdsa.PublicKey) TransportConn
+}
+
+// TransportConn is an authenticated, encrypted message connection created by
+// a Transport. Its method set matches rlpx.Conn.
+type TransportConn interface {
+	// Handshake performs the encryption handshake using the node key and
+	// returns the public key of the remote node.
+	Handshake(prv *ecdsa.PrivateKey) (*ecdsa.PublicKey, error)
+
+	// Read reads a message from the connection. The returned data buffer is
+	// valid until the next call to Read.
+	Read() (code uint64, data []byte, wireSize int, err error)
+
+	// Write writes a message to the connection and returns its size on the wire.
+	Write(code uint64, data []byte) (uint32, error)
+
+	SetDeadline(time.Time) error
+	SetReadDeadline(time.Time) error
+	SetWriteDeadline(time.Time) error
+	Close() error
+}
+
+// transportConn marks a network connection established through one of the
+// Config.Transports. SetupConn uses it to pick the right message transport.
+type transportConn struct {
+	net.Conn
+	tr Transport
+}
+
 // rlpxTransport is the transport used by actual (non-test) connections.
-// It wraps an RLPx connection with locks and read/write deadlines.
+// It wraps an RLPx connection, or the message connection of another
+// Transport, with locks and read/write deadlines.
 type rlpxTransport struct {
 	rmu, wmu sync.Mutex
 	wbuf     bytes.Buffer
-	conn     *rlpx.Conn
+	conn     TransportConn
 }
 
 func newRLPX(conn net.Conn, dialDest *ecdsa.PublicKey) transport {
 	return &rlpxTransport{conn: rlpx.NewConn(conn, dialDest)}
 }
 
+// newSessionTransport wraps the message connection of a Transport.
+func newSessionTransport(tr Transport, conn net.Conn, dialDest *ecdsa.PublicKey) transport {
+	return &rlpxTransport{conn: tr.NewConn(conn, dialDest)}
+}
+
 func (t *rlpxTransport) ReadMsg() (Msg, error) {
 	t.rmu.Lock()
 	defer t.rmu.Unlock()
@@ -141,7 +202,9 @@ func (t *rlpxTransport) doProtoHandshake(our *protoHandshake) (their *protoHands
 		return nil, fmt.Errorf("write error: %v", err)
 	}
 	// If the protocol version supports Snappy encoding, upgrade immediately
-	t.conn.SetSnappy(their.Version >= snappyProtocolVersion)
+	if conn, ok := t.conn.(interface{ SetSnappy(bool) }); ok {
+		conn.SetSnappy(their.Version >= snappyProtocolVersion)
+	}
 
 	return their, nil
 }
@@ -17,9 +17,11 @@
 package params
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"math/big"
+	"sort"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -244,16 +246,16 @@ var (
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, new(EthashConfig), nil}
+	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, new(EthashConfig), nil, nil, nil}
 
 	// AllCliqueProtocolChanges contains every protocol change (EIPs) introduced
 	// and accepted by the Ethereum core developers into the Clique consensus.
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}}
+	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}, nil, nil}
 
-	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, new(EthashConfig), nil}
+	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, new(EthashConfig), nil, nil, nil}
 	TestRules       = TestChainConfig.Rules(new(big.Int))
 )
 
@@ -332,6 +334,10 @@ type ChainConfig struct {
 	// Various consensus engines
 	Ethash *EthashConfig `json:"ethash,omitempty"`
 	Clique *CliqueConfig `json:"clique,omitempty"`
+	IBFT   *IBFTConfig   `json:"ibft,omitempty"`
+
+	// Custom precompiled contracts of private networks, by address
+	Precompiles map[common.Address]*PrecompileConfig `json:"precompiles,omitempty"`
 }
 
 // EthashConfig is the consensus engine configs for proof-of-work based sealing.
@@ -353,6 +359,54 @@ func (c *CliqueConfig) String() string {
 	return "clique"
 }
 
+// IBFTConfig is the consensus engine configs for byzantine fault tolerant
+// proof-of-authority based sealing.
+type IBFTConfig struct {
+	Period         uint64 `json:"period"`         // Number of seconds between blocks to enforce
+	Epoch          uint64 `json:"epoch"`          // Epoch length to reset votes and checkpoint
+	RequestTimeout uint64 `json:"requestTimeout"` // Milliseconds to wait for a round to complete before changing it
+}
+
+// String implements the stringer interface, returning the consensus engine details.
+func (c *IBFTConfig) String() string {
+	return "ibft"
+}
+
+// PrecompileConfig activates a custom precompiled contract, implemented by a
+// precompile registered with the EVM under the given name.
+type PrecompileConfig struct {
+	Name  string         `json:"name"`          // Name the implementation is registered by
+	Block *big.Int       `json:"block"`         // Activation block (nil = never, 0 = from genesis)
+	Gas   *PrecompileGas `json:"gas,omitempty"` // Gas cost overriding the one of the implementation
+}
+
+// PrecompileGas is a linear gas cost of a precompiled contract, charging a base
+// cost and a cost per word of input.
+type PrecompileGas struct {
+	Base uint64 `json:"base"`
+	Word uint64 `json:"word"`
+}
+
+// block returns the activation block of the precompile, nil if not configured.
+func (c *PrecompileConfig) block() *big.Int {
+	if c == nil {
+		return nil
+	}
+	return c.Block
+}
+
+// sameContract returns whether two configurations define the same contract,
+// disregarding their activation blocks.
+func (c *PrecompileConfig) sameContract(other *PrecompileConfig) bool {
+	if c == nil || other == nil {
+		return c == other
+	}
+	if c.Name != other.Name || (c.Gas == nil) != (other.Gas == nil) {
+		return false
+	}
+	return c.Gas == nil || *c.Gas == *other.Gas
+}
+
 // String implements the fmt.Stringer interface.
 func (c *ChainConfig) String() string {
 	var engine interface{}
@@ -361,10 +415,12 @@ func (c *ChainConfig) String() string {
 		engine = c.Ethash
 	case c.Clique != nil:
 		engine = c.Clique
+	case c.IBFT != nil:
+		engine = c.IBFT
 	default:
 		engine = "unknown"
 	}
-	return fmt.Sprintf("{ChainID: %v Homestead: %v DAO: %v DAOSupport: %v EIP150: %v EIP155: %v EIP158: %v Byzantium: %v Constantinople: %v Petersburg: %v Istanbul: %v, Muir Glacier: %v, Berlin: %v, YOLO v3: %v, Engine: %v}",
+	return fmt.Sprintf("{ChainID: %v Homestead: %v DAO: %v DAOSupport: %v EIP150: %v EIP155: %v EIP158: %v Byzantium: %v Constantinople: %v Petersburg: %v Istanbul: %v, Muir Glacier: %v, Berlin: %v, YOLO v3: %v, Precompiles: %d, Engine: %v}",
 		c.ChainID,
 		c.HomesteadBlock,
 		c.DAOForkBlock,
@@ -379,6 +435,7 @@ func (c *ChainConfig) String() string {
 		c.MuirGlacierBlock,
 		c.BerlinBlock,
 		c.YoloV3Block,
+		len(c.Precompiles),
 		engine,
 	)
 }
@@ -445,6 +502,45 @@ func (c *ChainConfig) IsEWASM(num *big.Int) bool {
 	return isForked(c.EWASMBlock, num)
 }
 
+// ActivePrecompiles returns the addresses of the custom precompiled contracts
+// active at num, in ascending order.
+func (c *ChainConfig) ActivePrecompiles(num *big.Int) []common.Address {
+	// Rules are derived for every transaction, keep chains without custom
+	// precompiles free of allocations
+	if len(c.Precompiles) == 0 {
+		return nil
+	}
+	var active []common.Address
+	for _, addr := range c.precompileAddresses(nil) {
+		if isForked(c.Precompiles[addr].block(), num) {
+			active = append(active, addr)
+		}
+	}
+	return active
+}
+
+// precompileAddresses returns the addresses of the custom precompiled contracts
+// configured in either config, in ascending order.
+func (c *ChainConfig) precompileAddresses(other *ChainConfig) []common.Address {
+	set := make(map[common.Address]struct{})
+	for addr := range c.Precompiles {
+		set[addr] = struct{}{}
+	}
+	if other != nil {
+		for addr := range other.Precompiles {
+			set[addr] = struct{}{}
+		}
+	}
+	addrs := make([]common.Address, 0, len(set))
+	for addr := range set {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return bytes.Compare(addrs[i][:], addrs[j][:]) < 0
+	})
+	return addrs
+}
+
 // CheckCompatible checks whether scheduled fork transitions have been imported
 // with a mismatching chain configuration.
 func (c *ChainConfig) CheckCompatible(newcfg *ChainConfig, height uint64) *ConfigCompatError {
@@ -556,6 +652,15 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if isForkIncompatible(c.EWASMBlock, newcfg.EWASMBlock, head) {
 		return newCompatError("ewasm fork block", c.EWASMBlock, newcfg.EWASMBlock)
 	}
+	for _, addr := range c.precompileAddresses(newcfg) {
+		stored, config := c.Precompiles[addr], newcfg.Precompiles[addr]
+		if isForkIncompatible(stored.block(), config.block(), head) {
+			return newCompatError(fmt.Sprintf("precompile %x activation block", addr), stored.block(), config.block())
+		}
+		if !stored.sameContract(config) && isForked(stored.block(), head) {
+			return newCompatError(fmt.Sprintf("precompile %x contract", addr), stored.block(), config.block())
+		}
+	}
 	return nil
 }
 
@@ -624,6 +729,7 @@ type Rules struct {
 	IsHomestead, IsEIP150, IsEIP155, IsEIP158               bool
 	IsByzantium, IsConstantinople, IsPetersburg, IsIstanbul bool
 	IsBerlin                                                bool
+	Precompiles                                             []common.Address // Active custom precompiled contracts
 }
 
 // Rules ensures c's ChainID is not nil.
@@ -643,5 +749,6 @@ func (c *ChainConfig) Rules(num *big.Int) Rules {
 		IsPetersburg:     c.IsPetersburg(num),
 		IsIstanbul:       c.IsIstanbul(num),
 		IsBerlin:         c.IsBerlin(num),
+		Precompiles:      c.ActivePrecompiles(num),
 	}
 }
@@ -20,6 +20,8 @@ import (
 	"math/big"
 	"reflect"
 	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
 )
 
 func TestCheckCompatible(t *testing.T) {
@@ -87,6 +89,51 @@ func TestCheckCompatible(t *testing.T) {
 				RewindTo:     30,
 			},
 		},
+		{
+			stored:  &ChainConfig{Precompiles: map[common.Address]*PrecompileConfig{{0xb1}: {Name: "a", Block: big.NewInt(10)}}},
+			new:     &ChainConfig{Precompiles: map[common.Address]*PrecompileConfig{{0xb1}: {Name: "a", Block: big.NewInt(20)}}},
+			head:    9,
+			wantErr: nil,
+		},
+		{
+			stored: &ChainConfig{Precompiles: map[common.Address]*PrecompileConfig{{0xb1}: {Name: "a", Block: big.NewInt(10)}}},
+			new:    &ChainConfig{Precompiles: map[common.Address]*PrecompileConfig{{0xb1}: {Name: "a", Block: big.NewInt(20)}}},
+			head:   15,
+			wantErr: &ConfigCompatError{
+				What:         "precompile b100000000000000000000000000000000000000 activation block",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    big.NewInt(20),
+				RewindTo:     9,
+			},
+		},
+		{
+			stored:  &ChainConfig{Precompiles: map[common.Address]*PrecompileConfig{{0xb1}: {Name: "a", Block: big.NewInt(10)}}},
+			new:     &ChainConfig{Precompiles: map[common.Address]*PrecompileConfig{{0xb1}: {Name: "b", Block: big.NewInt(10)}}},
+			head:    9,
+			wantErr: nil,
+		},
+		{
+			stored: &ChainConfig{Precompiles: map[common.Address]*PrecompileConfig{{0xb1}: {Name: "a", Block: big.NewInt(10)}}},
+			new:    &ChainConfig{Precompiles: map[common.Address]*PrecompileConfig{{0xb1}: {Name: "a", Block: big.NewInt(10), Gas: &PrecompileGas{Base: 100}}}},
+			head:   10,
+			wantErr: &ConfigCompatError{
+				What:         "precompile b100000000000000000000000000000000000000 contract",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    big.NewInt(10),
+				RewindTo:     9,
+			},
+		},
+		{
+			stored: &ChainConfig{},
+			new:    &ChainConfig{Precompiles: map[common.Address]*PrecompileConfig{{0xb1}: {Name: "a", Block: big.NewInt(5)}}},
+			head:   10,
+			wantErr: &ConfigCompatError{
+				What:         "precompile b100000000000000000000000000000000000000 activation block",
+				StoredConfig: nil,
+				NewConfig:    big.NewInt(5),
+				RewindTo:     4,
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -96,3 +143,10 @@ func TestCheckCompatible(t *testing.T) {
 		}
 	}
 }
+
+func TestRulesWithoutPrecompilesAllocs(t *testing.T) {
+	config, num := *TestChainConfig, big.NewInt(1)
+	if allocs := testing.AllocsPerRun(100, func() { config.ActivePrecompiles(num) }); allocs != 0 {
+		t.Errorf("active precompiles of config without any allocated %v times", allocs)
+	}
+}
@@ -97,6 +97,13 @@ func (ui *headlessUi) ApproveNewAccount(request *core.NewAccountRequest) (core.N
 	return core.NewAccountResponse{false}, nil
 }
 
+func (ui *headlessUi) ApproveSafeProposal(request *core.SafeProposalRequest) (core.SafeProposalResponse, error) {
+	if <-ui.approveCh == "Y" {
+		return core.SafeProposalResponse{Approved: true}, nil
+	}
+	return core.SafeProposalResponse{Approved: false}, nil
+}
+
 func (ui *headlessUi) ShowError(message string) {
 	//stdout is used by communication
 	fmt.Fprintln(os.Stderr, message)
@@ -19,6 +19,7 @@ package core
 import (
 	"context"
 	"encoding/json"
+	"strings"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -26,6 +27,22 @@ import (
 	"github.com/ethereum/go-ethereum/log"
 )
 
+// Decision is the outcome of an automated approval of a request, along with the
+// reasons explaining how it was reached.
+type Decision struct {
+	Request string   // UI method deciding the request, e.g. ApproveTx
+	Action  string   // Outcome of the decision: approve, reject or manual
+	Rule    string   // Name of the rule which decided the request, if any
+	Reasons []string // Explanation of the rules considered
+	Meta    Metadata // Metadata of the request being decided
+}
+
+// DecisionAuditor is implemented by audit logs able to record the decisions of
+// automated approvers.
+type DecisionAuditor interface {
+	AuditDecision(decision *Decision)
+}
+
 type AuditLogger struct {
 	log log.Logger
 	api ExternalAPI
@@ -122,3 +139,96 @@ func NewAuditLogger(path string, api ExternalAPI) (*AuditLogger, error) {
 	l.Info("Configured", "audit log", path)
 	return &AuditLogger{l, api}, nil
 }
+
+// AuditDecision records the decision of an automated approver.
+func (l *AuditLogger) AuditDecision(d *Decision) {
+	l.log.Info(d.Request, "type", "decision", "metadata", d.Meta.String(),
+		"action", d.Action, "rule", d.Rule, "reasons", strings.Join(d.Reasons, "; "))
+}
+
+// SafeAuditLogger records the requests to and the responses of the multisig API
+// in the audit log.
+type SafeAuditLogger struct {
+	log log.Logger
+	api *MultisigAPI
+}
+
+// Multisig wraps the multisig API, recording its requests in the same audit log.
+func (l *AuditLogger) Multisig(api *MultisigAPI) *SafeAuditLogger {
+	return &SafeAuditLogger{l.log, api}
+}
+
+func (l *SafeAuditLogger) Propose(ctx context.Context, gnosisTx GnosisSafeTx, owners []common.MixedcaseAddress, threshold int) (*SafeProposal, error) {
+	data, _ := json.Marshal(gnosisTx) // can ignore error, marshalling what we just unmarshalled
+	addrs := make([]string, len(owners))
+	for i, owner := range owners {
+		addrs[i] = owner.String()
+	}
+	l.log.Info("SafePropose", "type", "request", "metadata", MetadataFromContext(ctx).String(),
+		"data", string(data), "owners", strings.Join(addrs, ","), "threshold", threshold)
+	res, e := l.api.Propose(ctx, gnosisTx, owners, threshold)
+	l.logProposal("SafePropose", res, e)
+	return res, e
+}
+
+func (l *SafeAuditLogger) Proposal(ctx context.Context, hash common.Hash) (*SafeProposal, error) {
+	l.log.Info("SafeProposal", "type", "request", "metadata", MetadataFromContext(ctx).String(), "hash", hash)
+	res, e := l.api.Proposal(ctx, hash)
+	l.logProposal("SafeProposal", res, e)
+	return res, e
+}
+
+func (l *SafeAuditLogger) Proposals(ctx context.Context) ([]*SafeProposal, error) {
+	l.log.Info("SafeProposals", "type", "request", "metadata", MetadataFromContext(ctx).String())
+	res, e := l.api.Proposals(ctx)
+	hashes := make([]string, len(res))
+	for i, proposal := range res {
+		hashes[i] = proposal.Hash().Hex()
+	}
+	l.log.Info("SafeProposals", "type", "response", "data", strings.Join(hashes, ","), "error", e)
+	return res, e
+}
+
+func (l *SafeAuditLogger) Collect(ctx context.Context, hash common.Hash, methodSelector *string) (*SafeProposal, error) {
+	sel := "<nil>"
+	if methodSelector != nil {
+		sel = *methodSelector
+	}
+	l.log.Info("SafeCollect", "type", "request", "metadata", MetadataFromContext(ctx).String(),
+		"hash", hash, "selector", sel)
+	res, e := l.api.Collect(ctx, hash, methodSelector)
+	l.logProposal("SafeCollect", res, e)
+	return res, e
+}
+
+func (l *SafeAuditLogger) Confirm(ctx context.Context, hash common.Hash, owner common.MixedcaseAddress, signature hexutil.Bytes) (*SafeProposal, error) {
+	l.log.Info("SafeConfirm", "type", "request", "metadata", MetadataFromContext(ctx).String(),
+		"hash", hash, "owner", owner.String(), "signature", common.Bytes2Hex(signature))
+	res, e := l.api.Confirm(ctx, hash, owner, signature)
+	l.logProposal("SafeConfirm", res, e)
+	return res, e
+}
+
+func (l *SafeAuditLogger) Combine(ctx context.Context, hash common.Hash) (hexutil.Bytes, error) {
+	l.log.Info("SafeCombine", "type", "request", "metadata", MetadataFromContext(ctx).String(), "hash", hash)
+	b, e := l.api.Combine(ctx, hash)
+	l.log.Info("SafeCombine", "type", "response", "data", common.Bytes2Hex(b), "error", e)
+	return b, e
+}
+
+func (l *SafeAuditLogger) Discard(ctx context.Context, hash common.Hash) error {
+	l.log.Info("SafeDiscard", "type", "request", "metadata", MetadataFromContext(ctx).String(), "hash", hash)
+	e := l.api.Discard(ctx, hash)
+	l.log.Info("SafeDiscard", "type", "response", "error", e)
+	return e
+}
+
+// logProposal records the proposal returned by a multisig request.
+func (l *SafeAuditLogger) logProposal(method string, proposal *SafeProposal, err error) {
+	if proposal != nil {
+		data, _ := json.Marshal(proposal) // can ignore error, marshalling what we just unmarshalled
+		l.log.Info(method, "type", "response", "data", string(data), "error", err)
+	} else {
+		l.log.Info(method, "type", "response", "data", proposal, "error", err)
+	}
+}
@@ -207,6 +207,51 @@ func (ui *CommandlineUI) ApproveNewAccount(request *NewAccountRequest) (NewAccou
 	return NewAccountResponse{true}, nil
 }
 
+// ApproveSafeProposal prompt the user for confirmation to carry out a stage of a multisig proposal
+func (ui *CommandlineUI) ApproveSafeProposal(request *SafeProposalRequest) (SafeProposalResponse, error) {
+
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	proposal := request.Proposal
+	fmt.Printf("-------- Safe multisig request (%s) -------\n\n", request.Stage)
+	switch request.Stage {
+	case SafeStagePropose:
+		fmt.Printf("A request has been made to create a new multisig proposal.\n")
+	case SafeStageSign:
+		fmt.Printf("A request has been made to collect the signature of owner %v.\n", request.Owner.Hex())
+	case SafeStageConfirm:
+		fmt.Printf("A request has been made to import the signature of owner %v.\n", request.Owner.Hex())
+	case SafeStageCombine:
+		fmt.Printf("A request has been made to release the combined signatures.\n")
+	case SafeStageDiscard:
+		fmt.Printf("A request has been made to discard the proposal.\n")
+	}
+	fmt.Printf("\nsafe:      %v\n", proposal.Tx.Safe.Address().Hex())
+	fmt.Printf("to:        %v\n", proposal.Tx.To.Address().Hex())
+	fmt.Printf("value:     %v wei\n", proposal.Tx.Value.String())
+	fmt.Printf("operation: %d\n", proposal.Tx.Operation)
+	fmt.Printf("nonce:     %v\n", &proposal.Tx.Nonce)
+	if proposal.Tx.Data != nil {
+		fmt.Printf("data:      %v\n", proposal.Tx.Data)
+	}
+	fmt.Printf("safeTxHash: %v\n", proposal.Hash().Hex())
+	fmt.Printf("\nSigned by %d of %d owners, threshold %d:\n", len(proposal.Signatures), len(proposal.Owners), proposal.Threshold)
+	for _, owner := range proposal.Owners {
+		if _, ok := proposal.Signatures[owner]; ok {
+			fmt.Printf("  [x] %v\n", owner.Hex())
+		} else {
+			fmt.Printf("  [ ] %v\n", owner.Hex())
+		}
+	}
+	fmt.Printf("-------------------------------------------\n")
+	showMetadata(request.Meta)
+	if !ui.confirm() {
+		return SafeProposalResponse{false}, nil
+	}
+	return SafeProposalResponse{true}, nil
+}
+
 // ShowError displays error message to user
 func (ui *CommandlineUI) ShowError(message string) {
 	fmt.Printf("## Error \n%s\n", message)
@@ -0,0 +1,481 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/storage"
+)
+
+// Stages of a multisig proposal, each of which needs to be approved by the UI
+// (or the rule engine) before being carried out.
+const (
+	SafeStagePropose = "propose" // A new proposal is created
+	SafeStageSign    = "sign"    // A partial signature is requested from a cosigner
+	SafeStageConfirm = "confirm" // An externally created partial signature is imported
+	SafeStageCombine = "combine" // The combined signature blob is released
+	SafeStageDiscard = "discard" // A proposal is removed
+)
+
+const (
+	// safeProposalPrefix is the storage key prefix of pending proposals.
+	safeProposalPrefix = "safe-proposal-"
+
+	// safeProposalIndex is the storage key of the list of pending proposals.
+	safeProposalIndex = "safe-proposals"
+)
+
+var (
+	errUnknownProposal   = errors.New("unknown proposal")
+	errProposalExists    = errors.New("proposal already exists")
+	errThresholdNotMet   = errors.New("signature threshold not met")
+	errInvalidSafeSigLen = errors.New("invalid signature length")
+)
+
+// SafeProposal is a Gnosis Safe transaction pending the signatures of multiple
+// owners. Once at least threshold owners signed it, the signatures can be
+// combined into the blob accepted by the Safe's execTransaction.
+type SafeProposal struct {
+	Tx         GnosisSafeTx                     `json:"tx"`
+	Owners     []common.Address                 `json:"owners"`
+	Threshold  int                              `json:"threshold"`
+	Signatures map[common.Address]hexutil.Bytes `json:"signatures"`
+}
+
+// Hash returns the Safe transaction hash the owners need to sign.
+func (p *SafeProposal) Hash() common.Hash {
+	return p.Tx.SafeTxHash
+}
+
+// hasOwner reports whether the address is one of the owners of the proposal.
+func (p *SafeProposal) hasOwner(addr common.Address) bool {
+	for _, owner := range p.Owners {
+		if owner == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// Combined returns the signatures of the proposal concatenated in ascending
+// order of the owner addresses, as required by the Safe contract.
+func (p *SafeProposal) Combined() (hexutil.Bytes, error) {
+	if len(p.Signatures) < p.Threshold {
+		return nil, fmt.Errorf("%w: have %d, want %d", errThresholdNotMet, len(p.Signatures), p.Threshold)
+	}
+	owners := make([]common.Address, 0, len(p.Signatures))
+	for owner := range p.Signatures {
+		owners = append(owners, owner)
+	}
+	sort.Slice(owners, func(i, j int) bool {
+		return bytes.Compare(owners[i][:], owners[j][:]) < 0
+	})
+	var blob hexutil.Bytes
+	for _, owner := range owners {
+		blob = append(blob, p.Signatures[owner]...)
+	}
+	return blob, nil
+}
+
+type (
+	// SafeProposalRequest contains the info for the UI to approve a stage of a
+	// multisig proposal.
+	SafeProposalRequest struct {
+		Stage    string          `json:"stage"`
+		Proposal *SafeProposal   `json:"proposal"`
+		Owner    *common.Address `json:"owner,omitempty"`
+		Meta     Metadata        `json:"meta"`
+	}
+	SafeProposalResponse struct {
+		Approved bool `json:"approved"`
+	}
+)
+
+// SafeCosigner is a party able to sign Safe transactions on behalf of one or
+// more owners. The local SignerAPI (covering keystore and hardware wallets) and
+// remote clef instances both implement it.
+type SafeCosigner interface {
+	SignGnosisSafeTx(ctx context.Context, signerAddress common.MixedcaseAddress, gnosisTx GnosisSafeTx, methodSelector *string) (*GnosisSafeTx, error)
+}
+
+// remoteCosigner requests Safe signatures from a remote clef instance through
+// its external API.
+type remoteCosigner struct {
+	client *rpc.Client
+}
+
+// NewRemoteCosigner creates a cosigner forwarding signing requests to the clef
+// instance behind the given RPC client.
+func NewRemoteCosigner(client *rpc.Client) SafeCosigner {
+	return &remoteCosigner{client: client}
+}
+
+// SignGnosisSafeTx implements SafeCosigner, calling account_signGnosisSafeTx
+// on the remote clef.
+func (c *remoteCosigner) SignGnosisSafeTx(ctx context.Context, signerAddress common.MixedcaseAddress, gnosisTx GnosisSafeTx, methodSelector *string) (*GnosisSafeTx, error) {
+	var result GnosisSafeTx
+	if err := c.client.CallContext(ctx, &result, "account_signGnosisSafeTx", &signerAddress, &gnosisTx, methodSelector); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// MultisigAPI coordinates the signing of Safe transactions by multiple owners.
+// Pending proposals are persisted in a storage, partial signatures are collected
+// from the configured cosigners or imported from third parties and once the
+// threshold is met, they are combined into a single signature blob.
+type MultisigAPI struct {
+	ui        UIClientAPI
+	storage   storage.Storage
+	local     *SignerAPI                      // Signer for the locally available owners
+	cosigners map[common.Address]SafeCosigner // Remote signers of specific owners
+	lock      sync.Mutex                      // Protects the proposals in the storage
+}
+
+// NewMultisigAPI creates a new multisig coordinator. Owners with an explicitly
+// configured cosigner are signed by it, all others by the local signer if it
+// has the owner's account.
+func NewMultisigAPI(ui UIClientAPI, storage storage.Storage, local *SignerAPI, cosigners map[common.Address]SafeCosigner) *MultisigAPI {
+	if cosigners == nil {
+		cosigners = make(map[common.Address]SafeCosigner)
+	}
+	return &MultisigAPI{
+		ui:        ui,
+		storage:   storage,
+		local:     local,
+		cosigners: cosigners,
+	}
+}
+
+// Propose creates a new proposal for a Safe transaction, to be signed by at
+// least threshold of the given owners.
+//
+// Clef has no access to the chain, so the owners and the threshold are trusted
+// input of the proposer and are not checked against the Safe contract. Wrong ones
+// can't be abused to move funds, as the combined signatures are verified by the
+// Safe upon execution, but they result in a proposal that can't be executed.
+func (api *MultisigAPI) Propose(ctx context.Context, gnosisTx GnosisSafeTx, owners []common.MixedcaseAddress, threshold int) (*SafeProposal, error) {
+	if threshold < 1 || threshold > len(owners) {
+		return nil, fmt.Errorf("invalid threshold %d for %d owners", threshold, len(owners))
+	}
+	hash, _, err := TypedDataAndHash(gnosisTx.ToTypedData())
+	if err != nil {
+		return nil, err
+	}
+	// Only retain the fields of the transaction which are relevant for signing
+	gnosisTx.Signature = nil
+	gnosisTx.Sender = common.NewMixedcaseAddress(common.Address{})
+	gnosisTx.SafeTxHash = common.BytesToHash(hash)
+
+	proposal := &SafeProposal{
+		Tx:         gnosisTx,
+		Threshold:  threshold,
+		Signatures: make(map[common.Address]hexutil.Bytes),
+	}
+	for _, owner := range owners {
+		if proposal.hasOwner(owner.Address()) {
+			return nil, fmt.Errorf("duplicate owner %v", owner.Address())
+		}
+		proposal.Owners = append(proposal.Owners, owner.Address())
+	}
+	if err := api.approve(ctx, SafeStagePropose, proposal, nil); err != nil {
+		return nil, err
+	}
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	if _, err := api.load(proposal.Hash()); err == nil {
+		return nil, errProposalExists
+	}
+	api.store(proposal)
+	return proposal, nil
+}
+
+// Proposal returns a pending proposal.
+func (api *MultisigAPI) Proposal(ctx context.Context, hash common.Hash) (*SafeProposal, error) {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	return api.load(hash)
+}
+
+// Proposals returns all the pending proposals.
+func (api *MultisigAPI) Proposals(ctx context.Context) ([]*SafeProposal, error) {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	proposals := []*SafeProposal{}
+	for _, hash := range api.index() {
+		proposal, err := api.load(hash)
+		if err != nil {
+			return nil, err
+		}
+		proposals = append(proposals, proposal)
+	}
+	return proposals, nil
+}
+
+// Collect requests partial signatures from the cosigners of the owners who have
+// not yet signed the proposal, until the threshold is met. Failures of single
+// cosigners are logged and skipped, the updated proposal is returned.
+func (api *MultisigAPI) Collect(ctx context.Context, hash common.Hash, methodSelector *string) (*SafeProposal, error) {
+	proposal, err := api.Proposal(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	for _, owner := range proposal.Owners {
+		if len(proposal.Signatures) >= proposal.Threshold {
+			break
+		}
+		if _, ok := proposal.Signatures[owner]; ok {
+			continue
+		}
+		cosigner := api.cosigner(owner)
+		if cosigner == nil {
+			log.Debug("No cosigner for Safe owner", "owner", owner)
+			continue
+		}
+		owner := owner
+		if err := api.approve(ctx, SafeStageSign, proposal, &owner); err != nil {
+			log.Info("Safe signature request denied", "hash", hash, "owner", owner, "err", err)
+			continue
+		}
+		signed, err := cosigner.SignGnosisSafeTx(ctx, common.NewMixedcaseAddress(owner), proposal.Tx, methodSelector)
+		if err != nil {
+			log.Warn("Failed to collect Safe signature", "hash", hash, "owner", owner, "err", err)
+			continue
+		}
+		if proposal, err = api.addSignature(hash, owner, signed.Signature); err != nil {
+			log.Warn("Invalid Safe signature collected", "hash", hash, "owner", owner, "err", err)
+			if proposal, err = api.Proposal(ctx, hash); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return proposal, nil
+}
+
+// Confirm imports a partial signature of an owner created outside of clef,
+// e.g. by an offline hardware wallet or through the Safe relay service.
+func (api *MultisigAPI) Confirm(ctx context.Context, hash common.Hash, owner common.MixedcaseAddress, signature hexutil.Bytes) (*SafeProposal, error) {
+	proposal, err := api.Proposal(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	addr := owner.Address()
+	if err := verifySafeSignature(proposal, addr, signature); err != nil {
+		return nil, err
+	}
+	if err := api.approve(ctx, SafeStageConfirm, proposal, &addr); err != nil {
+		return nil, err
+	}
+	return api.addSignature(hash, addr, signature)
+}
+
+// Combine returns the signature blob of a proposal once enough owners signed
+// it, to be passed as the signatures of the Safe's execTransaction.
+func (api *MultisigAPI) Combine(ctx context.Context, hash common.Hash) (hexutil.Bytes, error) {
+	proposal, err := api.Proposal(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := proposal.Combined(); err != nil {
+		return nil, err
+	}
+	if err := api.approve(ctx, SafeStageCombine, proposal, nil); err != nil {
+		return nil, err
+	}
+	return proposal.Combined()
+}
+
+// Discard removes a pending proposal.
+func (api *MultisigAPI) Discard(ctx context.Context, hash common.Hash) error {
+	proposal, err := api.Proposal(ctx, hash)
+	if err != nil {
+		return err
+	}
+	if err := api.approve(ctx, SafeStageDiscard, proposal, nil); err != nil {
+		return err
+	}
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	api.storage.Del(safeProposalPrefix + hash.Hex())
+
+	var index []common.Hash
+	for _, have := range api.index() {
+		if have != hash {
+			index = append(index, have)
+		}
+	}
+	api.storeIndex(index)
+	return nil
+}
+
+// approve asks the UI to approve a stage of a proposal.
+func (api *MultisigAPI) approve(ctx context.Context, stage string, proposal *SafeProposal, owner *common.Address) error {
+	result, err := api.ui.ApproveSafeProposal(&SafeProposalRequest{
+		Stage:    stage,
+		Proposal: proposal,
+		Owner:    owner,
+		Meta:     MetadataFromContext(ctx),
+	})
+	if err != nil {
+		return err
+	}
+	if !result.Approved {
+		return ErrRequestDenied
+	}
+	return nil
+}
+
+// cosigner returns the signer of the given owner, if any.
+func (api *MultisigAPI) cosigner(owner common.Address) SafeCosigner {
+	if cosigner, ok := api.cosigners[owner]; ok {
+		return cosigner
+	}
+	if api.local == nil {
+		return nil
+	}
+	if _, err := api.local.am.Find(accounts.Account{Address: owner}); err != nil {
+		return nil
+	}
+	return api.local
+}
+
+// addSignature verifies and stores the partial signature of an owner, returning
+// the updated proposal.
+func (api *MultisigAPI) addSignature(hash common.Hash, owner common.Address, signature hexutil.Bytes) (*SafeProposal, error) {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	proposal, err := api.load(hash)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySafeSignature(proposal, owner, signature); err != nil {
+		return nil, err
+	}
+	proposal.Signatures[owner] = common.CopyBytes(signature)
+	api.store(proposal)
+	return proposal, nil
+}
+
+// load retrieves a proposal from the storage.
+func (api *MultisigAPI) load(hash common.Hash) (*SafeProposal, error) {
+	blob, err := api.storage.Get(safeProposalPrefix + hash.Hex())
+	if err != nil {
+		return nil, errUnknownProposal
+	}
+	proposal := new(SafeProposal)
+	if err := json.Unmarshal([]byte(blob), proposal); err != nil {
+		return nil, err
+	}
+	if proposal.Signatures == nil {
+		proposal.Signatures = make(map[common.Address]hexutil.Bytes)
+	}
+	return proposal, nil
+}
+
+// store persists a proposal, adding it to the index if it's new.
+func (api *MultisigAPI) store(proposal *SafeProposal) {
+	blob, err := json.Marshal(proposal)
+	if err != nil {
+		log.Error("Failed to encode Safe proposal", "hash", proposal.Hash(), "err", err)
+		return
+	}
+	api.storage.Put(safeProposalPrefix+proposal.Hash().Hex(), string(blob))
+
+	index := api.index()
+	for _, hash := range index {
+		if hash == proposal.Hash() {
+			return
+		}
+	}
+	api.storeIndex(append(index, proposal.Hash()))
+}
+
+// index returns the hashes of all the stored proposals.
+func (api *MultisigAPI) index() []common.Hash {
+	blob, err := api.storage.Get(safeProposalIndex)
+	if err != nil {
+		return nil
+	}
+	var index []common.Hash
+	if err := json.Unmarshal([]byte(blob), &index); err != nil {
+		log.Error("Corrupt Safe proposal index", "err", err)
+		return nil
+	}
+	return index
+}
+
+// storeIndex persists the hashes of all the stored proposals.
+func (api *MultisigAPI) storeIndex(index []common.Hash) {
+	if len(index) == 0 {
+		api.storage.Del(safeProposalIndex)
+		return
+	}
+	blob, _ := json.Marshal(index)
+	api.storage.Put(safeProposalIndex, string(blob))
+}
+
+// verifySafeSignature checks that a signature over the proposal's Safe hash was
+// made by the given owner. Both EIP-712 signatures (v = 27/28) and eth_sign
+// signatures of the hash (v = 31/32) are accepted, as the Safe does.
+func verifySafeSignature(proposal *SafeProposal, owner common.Address, signature hexutil.Bytes) error {
+	if !proposal.hasOwner(owner) {
+		return fmt.Errorf("%v is not an owner of the proposal", owner)
+	}
+	if len(signature) != crypto.SignatureLength {
+		return fmt.Errorf("%w: have %d, want %d", errInvalidSafeSigLen, len(signature), crypto.SignatureLength)
+	}
+	var (
+		sig    = common.CopyBytes(signature)
+		digest = proposal.Hash().Bytes()
+	)
+	switch sig[crypto.RecoveryIDOffset] {
+	case 27, 28:
+	case 31, 32:
+		digest = accounts.TextHash(digest)
+		sig[crypto.RecoveryIDOffset] -= 4
+	default:
+		return fmt.Errorf("unsupported signature type v = %d", sig[crypto.RecoveryIDOffset])
+	}
+	sig[crypto.RecoveryIDOffset] -= 27
+
+	pubkey, err := crypto.SigToPub(digest, sig)
+	if err != nil {
+		return err
+	}
+	if signer := crypto.PubkeyToAddress(*pubkey); signer != owner {
+		return fmt.Errorf("signature made by %v, not owner %v", signer, owner)
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core"
+	"github.com/ethereum/go-ethereum/signer/storage"
+)
+
+// stageUI approves all multisig stages except the denied ones, recording the
+// stages it was asked about.
+type stageUI struct {
+	*headlessUi
+	denied map[string]bool
+	stages []string
+}
+
+func (ui *stageUI) ApproveSafeProposal(request *core.SafeProposalRequest) (core.SafeProposalResponse, error) {
+	ui.stages = append(ui.stages, request.Stage)
+	return core.SafeProposalResponse{Approved: !ui.denied[request.Stage]}, nil
+}
+
+// keyCosigner signs Safe transactions with a private key, like a remote clef
+// holding the owner's account would.
+type keyCosigner struct {
+	key *ecdsa.PrivateKey
+}
+
+func (c *keyCosigner) SignGnosisSafeTx(ctx context.Context, signerAddress common.MixedcaseAddress, gnosisTx core.GnosisSafeTx, methodSelector *string) (*core.GnosisSafeTx, error) {
+	if signerAddress.Address() != crypto.PubkeyToAddress(c.key.PublicKey) {
+		return nil, errors.New("unknown account")
+	}
+	hash, _, err := core.TypedDataAndHash(gnosisTx.ToTypedData())
+	if err != nil {
+		return nil, err
+	}
+	sig, err := crypto.Sign(hash, c.key)
+	if err != nil {
+		return nil, err
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+	gnosisTx.Signature = sig
+	return &gnosisTx, nil
+}
+
+func newTestSafeTx() core.GnosisSafeTx {
+	var tx core.GnosisSafeTx
+	tx.Safe = common.NewMixedcaseAddress(common.HexToAddress("0x25a6c4BBd32B2424A9c99aEB0584Ad12045382B3"))
+	tx.To = common.NewMixedcaseAddress(common.HexToAddress("0xB372a646f7F05Cc1785018dBDA7EBc734a2A20E2"))
+	tx.Value = math.Decimal256(*big.NewInt(1))
+	tx.SafeTxGas.SetUint64(27845)
+	tx.Nonce.SetUint64(2)
+	return tx
+}
+
+// Tests that partial signatures are collected from cosigners and imported from
+// third parties until the threshold is met, and that they are combined in the
+// order required by the Safe.
+func TestMultisigThreshold(t *testing.T) {
+	var (
+		keys      = make([]*ecdsa.PrivateKey, 3)
+		owners    = make([]common.MixedcaseAddress, 3)
+		cosigners = make(map[common.Address]core.SafeCosigner)
+	)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		owners[i] = common.NewMixedcaseAddress(crypto.PubkeyToAddress(keys[i].PublicKey))
+	}
+	cosigners[owners[0].Address()] = &keyCosigner{keys[0]}
+
+	var (
+		ctx = context.Background()
+		db  = storage.NewEphemeralStorage()
+		ui  = &stageUI{headlessUi: &headlessUi{}}
+		api = core.NewMultisigAPI(ui, db, nil, cosigners)
+	)
+	proposal, err := api.Propose(ctx, newTestSafeTx(), owners, 2)
+	if err != nil {
+		t.Fatalf("failed to create proposal: %v", err)
+	}
+	if _, err := api.Propose(ctx, newTestSafeTx(), owners, 2); err == nil {
+		t.Fatalf("duplicate proposal accepted")
+	}
+	hash := proposal.Hash()
+	if _, err := api.Combine(ctx, hash); err == nil {
+		t.Fatalf("combined signatures below threshold")
+	}
+	// Collect the signature of the only owner with a cosigner
+	if proposal, err = api.Collect(ctx, hash, nil); err != nil {
+		t.Fatalf("failed to collect signatures: %v", err)
+	}
+	if len(proposal.Signatures) != 1 {
+		t.Fatalf("collected signature count mismatch: have %d, want 1", len(proposal.Signatures))
+	}
+	// Import an eth_sign signature of another owner, rejecting mismatching ones
+	sig, _ := crypto.Sign(accounts.TextHash(hash.Bytes()), keys[2])
+	sig[crypto.RecoveryIDOffset] += 31
+
+	if _, err := api.Confirm(ctx, hash, owners[1], sig); err == nil {
+		t.Fatalf("signature of wrong owner accepted")
+	}
+	if proposal, err = api.Confirm(ctx, hash, owners[2], sig); err != nil {
+		t.Fatalf("failed to confirm signature: %v", err)
+	}
+	// Proposals should be persisted, check from a fresh coordinator
+	api = core.NewMultisigAPI(ui, db, nil, nil)
+	if proposals, err := api.Proposals(ctx); err != nil || len(proposals) != 1 {
+		t.Fatalf("proposal not persisted: %v, %v", proposals, err)
+	}
+	blob, err := api.Combine(ctx, hash)
+	if err != nil {
+		t.Fatalf("failed to combine signatures: %v", err)
+	}
+	first, second := proposal.Signatures[owners[0].Address()], proposal.Signatures[owners[2].Address()]
+	if bytes.Compare(owners[0].Address().Bytes(), owners[2].Address().Bytes()) > 0 {
+		first, second = second, first
+	}
+	if want := hexutil.Bytes(append(append([]byte{}, first...), second...)); !bytes.Equal(blob, want) {
+		t.Fatalf("combined signature mismatch: have %x, want %x", blob, want)
+	}
+	if err := api.Discard(ctx, hash); err != nil {
+		t.Fatalf("failed to discard proposal: %v", err)
+	}
+	if _, err := api.Proposal(ctx, hash); err == nil {
+		t.Fatalf("discarded proposal retrievable")
+	}
+}
+
+// Tests that the UI can deny each stage of a proposal.
+func TestMultisigStageApproval(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	owner := common.NewMixedcaseAddress(crypto.PubkeyToAddress(key.PublicKey))
+
+	var (
+		ctx = context.Background()
+		ui  = &stageUI{headlessUi: &headlessUi{}, denied: map[string]bool{core.SafeStagePropose: true}}
+		api = core.NewMultisigAPI(ui, storage.NewEphemeralStorage(), nil, map[common.Address]core.SafeCosigner{
+			owner.Address(): &keyCosigner{key},
+		})
+	)
+	if _, err := api.Propose(ctx, newTestSafeTx(), []common.MixedcaseAddress{owner}, 1); err != core.ErrRequestDenied {
+		t.Fatalf("denied proposal error mismatch: have %v, want %v", err, core.ErrRequestDenied)
+	}
+	ui.denied = map[string]bool{core.SafeStageSign: true, core.SafeStageCombine: true}
+	proposal, err := api.Propose(ctx, newTestSafeTx(), []common.MixedcaseAddress{owner}, 1)
+	if err != nil {
+		t.Fatalf("failed to create proposal: %v", err)
+	}
+	if proposal, _ = api.Collect(ctx, proposal.Hash(), nil); len(proposal.Signatures) != 0 {
+		t.Fatalf("signature collected despite denial")
+	}
+	delete(ui.denied, core.SafeStageSign)
+	if proposal, _ = api.Collect(ctx, proposal.Hash(), nil); len(proposal.Signatures) != 1 {
+		t.Fatalf("signature not collected")
+	}
+	if _, err := api.Combine(ctx, proposal.Hash()); err != core.ErrRequestDenied {
+		t.Fatalf("denied combination error mismatch: have %v, want %v", err, core.ErrRequestDenied)
+	}
+	want := []string{"propose", "propose", "sign", "sign", "combine"}
+	if len(ui.stages) != len(want) {
+		t.Fatalf("approved stages mismatch: have %v, want %v", ui.stages, want)
+	}
+	for i := range want {
+		if ui.stages[i] != want[i] {
+			t.Fatalf("approved stages mismatch: have %v, want %v", ui.stages, want)
+		}
+	}
+}
+
+// Tests that multisig requests are recorded in the audit log.
+func TestMultisigAuditLog(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	owner := common.NewMixedcaseAddress(crypto.PubkeyToAddress(key.PublicKey))
+
+	logfile := filepath.Join(tmpDirName(t), "audit.log")
+	auditor, err := core.NewAuditLogger(logfile, nil)
+	if err != nil {
+		t.Fatalf("failed to create audit log: %v", err)
+	}
+	var (
+		ctx = context.Background()
+		ui  = &stageUI{headlessUi: &headlessUi{}}
+		api = auditor.Multisig(core.NewMultisigAPI(ui, storage.NewEphemeralStorage(), nil, map[common.Address]core.SafeCosigner{
+			owner.Address(): &keyCosigner{key},
+		}))
+	)
+	proposal, err := api.Propose(ctx, newTestSafeTx(), []common.MixedcaseAddress{owner}, 1)
+	if err != nil {
+		t.Fatalf("failed to create proposal: %v", err)
+	}
+	if _, err := api.Collect(ctx, proposal.Hash(), nil); err != nil {
+		t.Fatalf("failed to collect signatures: %v", err)
+	}
+	if _, err := api.Combine(ctx, proposal.Hash()); err != nil {
+		t.Fatalf("failed to combine signatures: %v", err)
+	}
+	blob, err := ioutil.ReadFile(logfile)
+	if err != nil {
+		t.Fatalf("failed to read audit log: %v", err)
+	}
+	for _, method := range []string{"SafePropose", "SafeCollect", "SafeCombine"} {
+		for _, kind := range []string{"request", "response"} {
+			if !strings.Contains(string(blob), "msg="+method+" api=signer type="+kind) {
+				t.Errorf("%s %s missing from audit log:\n%s", method, kind, blob)
+			}
+		}
+	}
+}
@@ -19,6 +19,7 @@ package core
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
@@ -82,16 +83,39 @@ type Type struct {
 }
 
 func (t *Type) isArray() bool {
-	return strings.HasSuffix(t.Type, "[]")
+	return strings.HasSuffix(t.Type, "]")
 }
 
-// typeName returns the canonical name of the type. If the type is 'Person[]', then
-// this method returns 'Person'
+// typeName returns the canonical name of the type. If the type is 'Person[]' or
+// 'Person[2][]', then this method returns 'Person'
 func (t *Type) typeName() string {
-	if strings.HasSuffix(t.Type, "[]") {
-		return strings.TrimSuffix(t.Type, "[]")
+	return baseType(t.Type)
+}
+
+// baseType strips all the array dimensions from a type.
+func baseType(typ string) string {
+	if i := strings.Index(typ, "["); i >= 0 {
+		return typ[:i]
+	}
+	return typ
+}
+
+// arrayElemType strips the outermost (last) dimension of an array type, returning
+// the type of its elements and the length of the array, or -1 if it's dynamic.
+func arrayElemType(typ string) (string, int, error) {
+	match := typedDataArrayDimRegexp.FindStringSubmatch(typ)
+	if match == nil {
+		return "", 0, fmt.Errorf("type '%s' is not an array", typ)
+	}
+	elem := strings.TrimSuffix(typ, match[0])
+	if match[1] == "" {
+		return elem, -1, nil
+	}
+	length, err := strconv.Atoi(match[1])
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid array length in type '%s'", typ)
 	}
-	return t.Type
+	return elem, length, nil
 }
 
 func (t *Type) isReferenceType() bool {
@@ -119,7 +143,11 @@ type TypedDataDomain struct {
 	Salt              string                `json:"salt"`
 }
 
-var typedDataReferenceTypeRegexp = regexp.MustCompile(`^[A-Z](\w*)(\[\])?$`)
+var (
+	typedDataReferenceTypeRegexp = regexp.MustCompile(`^[A-Z](\w*)(\[\d*\])*$`)
+	typedDataPrimitiveTypeRegexp = regexp.MustCompile(`^[a-z](\w*)(\[\d*\])*$`)
+	typedDataArrayDimRegexp      = regexp.MustCompile(`\[(\d*)\]$`)
+)
 
 // sign receives a request and produces a signature
 //
@@ -324,16 +352,17 @@ func (api *SignerAPI) SignTypedData(ctx context.Context, addr common.MixedcaseAd
 // - the signature preimage (hash)
 func (api *SignerAPI) signTypedData(ctx context.Context, addr common.MixedcaseAddress,
 	typedData TypedData, validationMessages *ValidationMessages) (hexutil.Bytes, hexutil.Bytes, error) {
-	domainSeparator, err := typedData.HashStruct("EIP712Domain", typedData.Domain.Map())
+	sighash, rawData, err := TypedDataAndHash(typedData)
 	if err != nil {
 		return nil, nil, err
 	}
-	typedDataHash, err := typedData.HashStruct(typedData.PrimaryType, typedData.Message)
-	if err != nil {
-		return nil, nil, err
+	// Signatures for other chains may be replayed there, make sure the user notices
+	if chainId := typedData.Domain.ChainId; chainId != nil && api.chainID != nil && (*big.Int)(chainId).Cmp(api.chainID) != 0 {
+		if validationMessages == nil {
+			validationMessages = new(ValidationMessages)
+		}
+		validationMessages.Warn(fmt.Sprintf("Domain chainId %v differs from the configured chain %v", (*big.Int)(chainId), api.chainID))
 	}
-	rawData := []byte(fmt.Sprintf("\x19\x01%s%s", string(domainSeparator), string(typedDataHash)))
-	sighash := crypto.Keccak256(rawData)
 	messages, err := typedData.Format()
 	if err != nil {
 		return nil, nil, err
@@ -355,6 +384,21 @@ func (api *SignerAPI) signTypedData(ctx context.Context, addr common.MixedcaseAd
 	return signature, sighash, nil
 }
 
+// TypedDataAndHash calculates the EIP-712 signing hash of the typed data, also
+// returning the raw preimage it was derived from.
+func TypedDataAndHash(typedData TypedData) ([]byte, []byte, error) {
+	domainSeparator, err := typedData.HashStruct("EIP712Domain", typedData.Domain.Map())
+	if err != nil {
+		return nil, nil, err
+	}
+	typedDataHash, err := typedData.HashStruct(typedData.PrimaryType, typedData.Message)
+	if err != nil {
+		return nil, nil, err
+	}
+	rawData := []byte(fmt.Sprintf("\x19\x01%s%s", string(domainSeparator), string(typedDataHash)))
+	return crypto.Keccak256(rawData), rawData, nil
+}
+
 // HashStruct generates a keccak256 hash of the encoding of the provided data
 func (typedData *TypedData) HashStruct(primaryType string, data TypedDataMessage) (hexutil.Bytes, error) {
 	encodedData, err := typedData.EncodeData(primaryType, data, 1)
@@ -375,6 +419,8 @@ func (typedData *TypedData) Dependencies(primaryType string, found []string) []s
 		return false
 	}
 
+	primaryType = baseType(primaryType) // Arrays depend on their element type
+
 	if includes(found, primaryType) {
 		return found
 	}
@@ -448,56 +494,63 @@ func (typedData *TypedData) EncodeData(primaryType string, data map[string]inter
 
 	// Add field contents. Structs and arrays have special handlers.
 	for _, field := range typedData.Types[primaryType] {
-		encType := field.Type
-		encValue := data[field.Name]
-		if encType[len(encType)-1:] == "]" {
-			arrayValue, ok := encValue.([]interface{})
-			if !ok {
-				return nil, dataMismatchError(encType, encValue)
-			}
+		encodedValue, err := typedData.encodeValue(field.Type, data[field.Name], depth)
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(encodedValue)
+	}
+	return buffer.Bytes(), nil
+}
 
-			arrayBuffer := bytes.Buffer{}
-			parsedType := strings.Split(encType, "[")[0]
-			for _, item := range arrayValue {
-				if typedData.Types[parsedType] != nil {
-					mapValue, ok := item.(map[string]interface{})
-					if !ok {
-						return nil, dataMismatchError(parsedType, item)
-					}
-					encodedData, err := typedData.EncodeData(parsedType, mapValue, depth+1)
-					if err != nil {
-						return nil, err
-					}
-					arrayBuffer.Write(encodedData)
-				} else {
-					bytesValue, err := typedData.EncodePrimitiveValue(parsedType, item, depth)
-					if err != nil {
-						return nil, err
-					}
-					arrayBuffer.Write(bytesValue)
-				}
-			}
+// encodeValue encodes a single member of a struct or array into 32 bytes. As per
+// EIP-712, structs are encoded as their hashStruct and arrays as the hash of the
+// concatenated encodings of their elements, recursively for nested arrays.
+func (typedData *TypedData) encodeValue(encType string, encValue interface{}, depth int) ([]byte, error) {
+	if strings.HasSuffix(encType, "]") {
+		return typedData.encodeArrayValue(encType, encValue, depth)
+	}
+	if typedData.Types[encType] != nil {
+		// Missing or null structs are encoded as zero, as in the reference (v4) implementation
+		if encValue == nil {
+			return make([]byte, 32), nil
+		}
+		mapValue, ok := encValue.(map[string]interface{})
+		if !ok {
+			return nil, dataMismatchError(encType, encValue)
+		}
+		encodedData, err := typedData.EncodeData(encType, mapValue, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.Keccak256(encodedData), nil
+	}
+	return typedData.EncodePrimitiveValue(encType, encValue, depth)
+}
 
-			buffer.Write(crypto.Keccak256(arrayBuffer.Bytes()))
-		} else if typedData.Types[field.Type] != nil {
-			mapValue, ok := encValue.(map[string]interface{})
-			if !ok {
-				return nil, dataMismatchError(encType, encValue)
-			}
-			encodedData, err := typedData.EncodeData(field.Type, mapValue, depth+1)
-			if err != nil {
-				return nil, err
-			}
-			buffer.Write(crypto.Keccak256(encodedData))
-		} else {
-			byteValue, err := typedData.EncodePrimitiveValue(encType, encValue, depth)
-			if err != nil {
-				return nil, err
-			}
-			buffer.Write(byteValue)
+// encodeArrayValue encodes a (possibly nested, fixed size or dynamic) array as the
+// hash of the concatenated encodings of its elements.
+func (typedData *TypedData) encodeArrayValue(encType string, encValue interface{}, depth int) ([]byte, error) {
+	arrayValue, ok := encValue.([]interface{})
+	if !ok {
+		return nil, dataMismatchError(encType, encValue)
+	}
+	elemType, length, err := arrayElemType(encType)
+	if err != nil {
+		return nil, err
+	}
+	if length >= 0 && len(arrayValue) != length {
+		return nil, fmt.Errorf("array length mismatch for type '%s': have %d, want %d", encType, len(arrayValue), length)
+	}
+	var arrayBuffer bytes.Buffer
+	for _, item := range arrayValue {
+		encodedItem, err := typedData.encodeValue(elemType, item, depth+1)
+		if err != nil {
+			return nil, err
 		}
+		arrayBuffer.Write(encodedItem)
 	}
-	return buffer.Bytes(), nil
+	return crypto.Keccak256(arrayBuffer.Bytes()), nil
 }
 
 // Attempt to parse bytes in different formats: byte array, hex string, hexutil.Bytes.
@@ -711,6 +764,48 @@ func (typedData *TypedData) validate() error {
 	if err := typedData.Domain.validate(); err != nil {
 		return err
 	}
+	return typedData.validateDomainType()
+}
+
+// domainFieldTypes are the types of the fields a domain may contain.
+var domainFieldTypes = map[string]string{
+	"name":              "string",
+	"version":           "string",
+	"chainId":           "uint256",
+	"verifyingContract": "address",
+	"salt":              "bytes32",
+}
+
+// validateDomainType makes sure the EIP712Domain type declares exactly the fields
+// present in the domain, with their standard types, so that no part of the domain
+// is silently left out of the signature.
+func (typedData *TypedData) validateDomainType() error {
+	domainType, ok := typedData.Types["EIP712Domain"]
+	if !ok {
+		return errors.New("EIP712Domain type is undefined")
+	}
+	var (
+		domain   = typedData.Domain.Map()
+		declared = make(map[string]bool)
+	)
+	for _, field := range domainType {
+		want, ok := domainFieldTypes[field.Name]
+		if !ok {
+			return fmt.Errorf("unknown domain field %q", field.Name)
+		}
+		if field.Type != want {
+			return fmt.Errorf("domain field %q has type %q, want %q", field.Name, field.Type, want)
+		}
+		if _, ok := domain[field.Name]; !ok {
+			return fmt.Errorf("domain field %q declared but missing", field.Name)
+		}
+		declared[field.Name] = true
+	}
+	for name := range domain {
+		if !declared[name] {
+			return fmt.Errorf("domain field %q not declared in EIP712Domain", name)
+		}
+	}
 	return nil
 }
 
@@ -755,51 +850,49 @@ func (typedData *TypedData) formatData(primaryType string, data map[string]inter
 
 	// Add field contents. Structs and arrays have special handlers.
 	for _, field := range typedData.Types[primaryType] {
-		encName := field.Name
-		encValue := data[encName]
-		item := &NameValueType{
-			Name: encName,
-			Typ:  field.Type,
+		value, err := typedData.formatValue(field.Type, data[field.Name])
+		if err != nil {
+			return nil, err
 		}
-		if field.isArray() {
-			arrayValue, _ := encValue.([]interface{})
-			parsedType := field.typeName()
-			for _, v := range arrayValue {
-				if typedData.Types[parsedType] != nil {
-					mapValue, _ := v.(map[string]interface{})
-					mapOutput, err := typedData.formatData(parsedType, mapValue)
-					if err != nil {
-						return nil, err
-					}
-					item.Value = mapOutput
-				} else {
-					primitiveOutput, err := formatPrimitiveValue(field.Type, encValue)
-					if err != nil {
-						return nil, err
-					}
-					item.Value = primitiveOutput
-				}
-			}
-		} else if typedData.Types[field.Type] != nil {
-			if mapValue, ok := encValue.(map[string]interface{}); ok {
-				mapOutput, err := typedData.formatData(field.Type, mapValue)
-				if err != nil {
-					return nil, err
-				}
-				item.Value = mapOutput
-			} else {
-				item.Value = "<nil>"
-			}
-		} else {
-			primitiveOutput, err := formatPrimitiveValue(field.Type, encValue)
+		output = append(output, &NameValueType{
+			Name:  field.Name,
+			Value: value,
+			Typ:   field.Type,
+		})
+	}
+	return output, nil
+}
+
+// formatValue renders a single value for display. Structs and arrays are rendered
+// as nested lists, array elements being named by their index.
+func (typedData *TypedData) formatValue(encType string, encValue interface{}) (interface{}, error) {
+	if strings.HasSuffix(encType, "]") {
+		elemType, _, err := arrayElemType(encType)
+		if err != nil {
+			return nil, err
+		}
+		arrayValue, _ := encValue.([]interface{})
+		items := make([]*NameValueType, 0, len(arrayValue))
+		for i, item := range arrayValue {
+			value, err := typedData.formatValue(elemType, item)
 			if err != nil {
 				return nil, err
 			}
-			item.Value = primitiveOutput
+			items = append(items, &NameValueType{
+				Name:  fmt.Sprintf("[%d]", i),
+				Value: value,
+				Typ:   elemType,
+			})
 		}
-		output = append(output, item)
+		return items, nil
 	}
-	return output, nil
+	if typedData.Types[encType] != nil {
+		if mapValue, ok := encValue.(map[string]interface{}); ok {
+			return typedData.formatData(encType, mapValue)
+		}
+		return "<nil>", nil
+	}
+	return formatPrimitiveValue(encType, encValue)
 }
 
 func formatPrimitiveValue(encType string, encValue interface{}) (string, error) {
@@ -895,115 +988,62 @@ func (t Types) validate() error {
 
 // Checks if the primitive value is valid
 func isPrimitiveTypeValid(primitiveType string) bool {
-	if primitiveType == "address" ||
-		primitiveType == "address[]" ||
-		primitiveType == "bool" ||
-		primitiveType == "bool[]" ||
-		primitiveType == "string" ||
-		primitiveType == "string[]" {
-		return true
+	if !typedDataPrimitiveTypeRegexp.MatchString(primitiveType) {
+		return false
 	}
-	if primitiveType == "bytes" ||
-		primitiveType == "bytes[]" ||
-		primitiveType == "bytes1" ||
-		primitiveType == "bytes1[]" ||
-		primitiveType == "bytes2" ||
-		primitiveType == "bytes2[]" ||
-		primitiveType == "bytes3" ||
-		primitiveType == "bytes3[]" ||
-		primitiveType == "bytes4" ||
-		primitiveType == "bytes4[]" ||
-		primitiveType == "bytes5" ||
-		primitiveType == "bytes5[]" ||
-		primitiveType == "bytes6" ||
-		primitiveType == "bytes6[]" ||
-		primitiveType == "bytes7" ||
-		primitiveType == "bytes7[]" ||
-		primitiveType == "bytes8" ||
-		primitiveType == "bytes8[]" ||
-		primitiveType == "bytes9" ||
-		primitiveType == "bytes9[]" ||
-		primitiveType == "bytes10" ||
-		primitiveType == "bytes10[]" ||
-		primitiveType == "bytes11" ||
-		primitiveType == "bytes11[]" ||
-		primitiveType == "bytes12" ||
-		primitiveType == "bytes12[]" ||
-		primitiveType == "bytes13" ||
-		primitiveType == "bytes13[]" ||
-		primitiveType == "bytes14" ||
-		primitiveType == "bytes14[]" ||
-		primitiveType == "bytes15" ||
-		primitiveType == "bytes15[]" ||
-		primitiveType == "bytes16" ||
-		primitiveType == "bytes16[]" ||
-		primitiveType == "bytes17" ||
-		primitiveType == "bytes17[]" ||
-		primitiveType == "bytes18" ||
-		primitiveType == "bytes18[]" ||
-		primitiveType == "bytes19" ||
-		primitiveType == "bytes19[]" ||
-		primitiveType == "bytes20" ||
-		primitiveType == "bytes20[]" ||
-		primitiveType == "bytes21" ||
-		primitiveType == "bytes21[]" ||
-		primitiveType == "bytes22" ||
-		primitiveType == "bytes22[]" ||
-		primitiveType == "bytes23" ||
-		primitiveType == "bytes23[]" ||
-		primitiveType == "bytes24" ||
-		primitiveType == "bytes24[]" ||
-		primitiveType == "bytes25" ||
-		primitiveType == "bytes25[]" ||
-		primitiveType == "bytes26" ||
-		primitiveType == "bytes26[]" ||
-		primitiveType == "bytes27" ||
-		primitiveType == "bytes27[]" ||
-		primitiveType == "bytes28" ||
-		primitiveType == "bytes28[]" ||
-		primitiveType == "bytes29" ||
-		primitiveType == "bytes29[]" ||
-		primitiveType == "bytes30" ||
-		primitiveType == "bytes30[]" ||
-		primitiveType == "bytes31" ||
-		primitiveType == "bytes31[]" ||
-		primitiveType == "bytes32" ||
-		primitiveType == "bytes32[]" {
+	base := baseType(primitiveType)
+	switch base {
+	case "address", "bool", "string", "bytes", "int", "uint":
 		return true
 	}
-	if primitiveType == "int" ||
-		primitiveType == "int[]" ||
-		primitiveType == "int8" ||
-		primitiveType == "int8[]" ||
-		primitiveType == "int16" ||
-		primitiveType == "int16[]" ||
-		primitiveType == "int32" ||
-		primitiveType == "int32[]" ||
-		primitiveType == "int64" ||
-		primitiveType == "int64[]" ||
-		primitiveType == "int128" ||
-		primitiveType == "int128[]" ||
-		primitiveType == "int256" ||
-		primitiveType == "int256[]" {
-		return true
+	var size string
+	switch {
+	case strings.HasPrefix(base, "bytes"):
+		size = strings.TrimPrefix(base, "bytes")
+		n, err := strconv.Atoi(size)
+		return err == nil && strconv.Itoa(n) == size && n >= 1 && n <= 32
+	case strings.HasPrefix(base, "uint"):
+		size = strings.TrimPrefix(base, "uint")
+	case strings.HasPrefix(base, "int"):
+		size = strings.TrimPrefix(base, "int")
+	default:
+		return false
 	}
-	if primitiveType == "uint" ||
-		primitiveType == "uint[]" ||
-		primitiveType == "uint8" ||
-		primitiveType == "uint8[]" ||
-		primitiveType == "uint16" ||
-		primitiveType == "uint16[]" ||
-		primitiveType == "uint32" ||
-		primitiveType == "uint32[]" ||
-		primitiveType == "uint64" ||
-		primitiveType == "uint64[]" ||
-		primitiveType == "uint128" ||
-		primitiveType == "uint128[]" ||
-		primitiveType == "uint256" ||
-		primitiveType == "uint256[]" {
-		return true
+	n, err := strconv.Atoi(size)
+	return err == nil && strconv.Itoa(n) == size && n >= 8 && n <= 256 && n%8 == 0
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Besides the hex or decimal strings
+// accepted by math.HexOrDecimal256, the chainId may also be a plain JSON number,
+// as sent by many dapps.
+func (domain *TypedDataDomain) UnmarshalJSON(input []byte) error {
+	type typedDataDomain TypedDataDomain
+	var dec struct {
+		typedDataDomain
+		ChainId json.RawMessage `json:"chainId"`
+	}
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	*domain = TypedDataDomain(dec.typedDataDomain)
+	domain.ChainId = nil
+
+	switch raw := dec.ChainId; {
+	case len(raw) == 0 || string(raw) == "null":
+	case raw[0] == '"':
+		domain.ChainId = new(math.HexOrDecimal256)
+		if err := json.Unmarshal(raw, domain.ChainId); err != nil {
+			return err
+		}
+	default:
+		// Only integral numbers, which can't be mistaken for hex
+		chainId, ok := new(big.Int).SetString(string(raw), 10)
+		if !ok || chainId.BitLen() > 256 {
+			return fmt.Errorf("invalid domain chainId %s", raw)
+		}
+		domain.ChainId = (*math.HexOrDecimal256)(chainId)
 	}
-	return false
+	return nil
 }
 
 // validate checks if the given domain is valid, i.e. contains at least
@@ -1012,6 +1052,17 @@ func (domain *TypedDataDomain) validate() error {
 	if domain.ChainId == nil && len(domain.Name) == 0 && len(domain.Version) == 0 && len(domain.VerifyingContract) == 0 && len(domain.Salt) == 0 {
 		return errors.New("domain is undefined")
 	}
+	if domain.ChainId != nil && (*big.Int)(domain.ChainId).Sign() < 0 {
+		return fmt.Errorf("invalid domain chainId %v", (*big.Int)(domain.ChainId))
+	}
+	if len(domain.VerifyingContract) > 0 && !common.IsHexAddress(domain.VerifyingContract) {
+		return fmt.Errorf("invalid domain verifyingContract %q", domain.VerifyingContract)
+	}
+	if len(domain.Salt) > 0 {
+		if salt, err := hexutil.Decode(domain.Salt); err != nil || len(salt) != 32 {
+			return fmt.Errorf("invalid domain salt %q", domain.Salt)
+		}
+	}
 
 	return nil
 }
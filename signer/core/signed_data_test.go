@@ -22,6 +22,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"path"
 	"strings"
 	"testing"
@@ -32,6 +33,7 @@ import (
 	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/signer/core"
+	"github.com/ethereum/go-ethereum/signer/storage"
 )
 
 var typesStandard = core.Types{
@@ -531,3 +533,239 @@ func TestGnosisCustomData(t *testing.T) {
 		t.Fatalf("Error, got %x, wanted %x", sighash, expSigHash)
 	}
 }
+
+// typedDataV4 is the reference EIP-712 v4 test vector, containing arrays of
+// structs and arrays within structs.
+const typedDataV4 = `{
+  "types": {
+    "EIP712Domain": [
+      {"name": "name", "type": "string"},
+      {"name": "version", "type": "string"},
+      {"name": "chainId", "type": "uint256"},
+      {"name": "verifyingContract", "type": "address"}
+    ],
+    "Person": [
+      {"name": "name", "type": "string"},
+      {"name": "wallets", "type": "address[]"}
+    ],
+    "Mail": [
+      {"name": "from", "type": "Person"},
+      {"name": "to", "type": "Person[]"},
+      {"name": "contents", "type": "string"}
+    ],
+    "Group": [
+      {"name": "name", "type": "string"},
+      {"name": "members", "type": "Person[]"}
+    ]
+  },
+  "domain": {
+    "name": "Ether Mail",
+    "version": "1",
+    "chainId": 1,
+    "verifyingContract": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"
+  },
+  "primaryType": "Mail",
+  "message": {
+    "from": {
+      "name": "Cow",
+      "wallets": [
+        "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826",
+        "0xDeaDbeefdEAdbeefdEadbEEFdeadbeEFdEaDbeeF"
+      ]
+    },
+    "to": [{
+      "name": "Bob",
+      "wallets": [
+        "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB",
+        "0xB0BdaBea57B0BDABeA57b0bdABEA57b0BDabEa57",
+        "0xB0B0b0b0b0b0B000000000000000000000000000"
+      ]
+    }],
+    "contents": "Hello, Bob!"
+  }
+}`
+
+// Tests that typed data is hashed identically to the EIP-712 v4 reference
+// implementation.
+func TestTypedDataV4(t *testing.T) {
+	var td core.TypedData
+	if err := json.Unmarshal([]byte(typedDataV4), &td); err != nil {
+		t.Fatalf("unmarshalling failed: %v", err)
+	}
+	if have, want := string(td.EncodeType("Mail")), "Mail(Person from,Person[] to,string contents)Person(string name,address[] wallets)"; have != want {
+		t.Errorf("encodeType mismatch: have %s, want %s", have, want)
+	}
+	if have, want := td.TypeHash("Mail").String(), "0x4bd8a9a2b93427bb184aca81e24beb30ffa3c747e2a33d4225ec08bf12e2e753"; have != want {
+		t.Errorf("typeHash mismatch: have %s, want %s", have, want)
+	}
+	mail, sighash, err := sign(td)
+	if err != nil {
+		t.Fatalf("failed to hash typed data: %v", err)
+	}
+	if have, want := hexutil.Encode(mail), "0xeb4221181ff3f1a83ea7313993ca9218496e424604ba9492bb4052c03d5c3df8"; have != want {
+		t.Errorf("message hashStruct mismatch: have %s, want %s", have, want)
+	}
+	if have, want := hexutil.Encode(sighash), "0xa85c2e2b118698e88db68a8105b794a8cc7cec074e89ef991cb4f5f533819cc2"; have != want {
+		t.Errorf("signing hash mismatch: have %s, want %s", have, want)
+	}
+}
+
+// Tests that nested dynamic and fixed size arrays are encoded as the hash of
+// the concatenated encodings of their elements, recursively.
+func TestTypedDataNestedArrays(t *testing.T) {
+	td := core.TypedData{
+		Types: core.Types{
+			"EIP712Domain": []core.Type{{Name: "name", Type: "string"}},
+			"Point":        []core.Type{{Name: "x", Type: "uint8"}},
+			"Shape": []core.Type{
+				{Name: "matrix", Type: "uint256[][]"},
+				{Name: "corners", Type: "Point[2]"},
+			},
+		},
+		PrimaryType: "Shape",
+		Domain:      core.TypedDataDomain{Name: "test"},
+		Message: core.TypedDataMessage{
+			"matrix":  []interface{}{[]interface{}{"1", "2"}, []interface{}{"3"}},
+			"corners": []interface{}{map[string]interface{}{"x": "1"}, map[string]interface{}{"x": "2"}},
+		},
+	}
+	word := func(n int64) []byte { return math.U256Bytes(big.NewInt(n)) }
+	cat := func(blobs ...[]byte) []byte { return bytes.Join(blobs, nil) }
+
+	var (
+		pointType = crypto.Keccak256([]byte("Point(uint8 x)"))
+		matrix    = crypto.Keccak256(cat(crypto.Keccak256(cat(word(1), word(2))), crypto.Keccak256(word(3))))
+		corners   = crypto.Keccak256(cat(crypto.Keccak256(cat(pointType, word(1))), crypto.Keccak256(cat(pointType, word(2)))))
+		want      = crypto.Keccak256(cat(crypto.Keccak256([]byte("Shape(uint256[][] matrix,Point[2] corners)Point(uint8 x)")), matrix, corners))
+	)
+	have, err := td.HashStruct("Shape", td.Message)
+	if err != nil {
+		t.Fatalf("failed to hash nested arrays: %v", err)
+	}
+	if !bytes.Equal(have, want) {
+		t.Errorf("hashStruct mismatch: have %x, want %x", have, want)
+	}
+	// Fixed size arrays must have the declared length
+	td.Message["corners"] = []interface{}{map[string]interface{}{"x": "1"}}
+	if _, err := td.HashStruct("Shape", td.Message); err == nil {
+		t.Errorf("fixed size array length mismatch accepted")
+	}
+	// Nested arrays should be rendered element by element
+	td.Message["corners"] = []interface{}{map[string]interface{}{"x": "1"}, map[string]interface{}{"x": "2"}}
+	formatted, err := td.Format()
+	if err != nil {
+		t.Fatalf("failed to format typed data: %v", err)
+	}
+	output := formatted[1].Pprint(0)
+	for _, want := range []string{"[1] [uint256[]]", "[0] [uint256]: \"3 (0x3)\"", "[1] [Point]", "x [uint8]: \"2 (0x2)\""} {
+		if !strings.Contains(output, want) {
+			t.Errorf("formatted output missing %q:\n%s", want, output)
+		}
+	}
+}
+
+// chainRecordingUI denies all data signing requests, recording them.
+type chainRecordingUI struct {
+	*headlessUi
+	requests []*core.SignDataRequest
+}
+
+func (ui *chainRecordingUI) ApproveSignData(request *core.SignDataRequest) (core.SignDataResponse, error) {
+	ui.requests = append(ui.requests, request)
+	return core.SignDataResponse{Approved: false}, nil
+}
+
+// Tests that the user is warned if the domain of typed data is for a different
+// chain than the configured one.
+func TestTypedDataChainIdWarning(t *testing.T) {
+	ui := &chainRecordingUI{headlessUi: &headlessUi{}}
+	am := core.StartClefAccountManager(tmpDirName(t), true, true, "")
+	api := core.NewSignerAPI(am, 1337, true, ui, nil, true, &storage.NoStorage{})
+
+	addr := common.NewMixedcaseAddress(common.HexToAddress("0x1"))
+	for _, chainId := range []int64{1337, 1} {
+		td := typedData
+		td.Domain.ChainId = math.NewHexOrDecimal256(chainId)
+		if _, err := api.SignTypedData(context.Background(), addr, td); err != core.ErrRequestDenied {
+			t.Fatalf("chain %d: unexpected error: %v", chainId, err)
+		}
+	}
+	if len(ui.requests[0].Callinfo) != 0 {
+		t.Errorf("warning raised for matching chain: %v", ui.requests[0].Callinfo)
+	}
+	if info := ui.requests[1].Callinfo; len(info) != 1 || info[0].Typ != core.WARN || !strings.Contains(info[0].Message, "chainId") {
+		t.Errorf("missing chain mismatch warning: %v", info)
+	}
+}
+
+// Tests that the domain must be valid and match its declared type exactly.
+func TestTypedDataDomainValidation(t *testing.T) {
+	tests := []struct {
+		types  []core.Type
+		domain core.TypedDataDomain
+	}{
+		// Fields present but not declared
+		{[]core.Type{{Name: "name", Type: "string"}}, core.TypedDataDomain{Name: "test", ChainId: math.NewHexOrDecimal256(1)}},
+		// Fields declared but not present
+		{[]core.Type{{Name: "name", Type: "string"}, {Name: "version", Type: "string"}}, core.TypedDataDomain{Name: "test"}},
+		// Wrongly typed fields
+		{[]core.Type{{Name: "chainId", Type: "uint64"}}, core.TypedDataDomain{ChainId: math.NewHexOrDecimal256(1)}},
+		// Unknown fields
+		{[]core.Type{{Name: "name", Type: "string"}, {Name: "owner", Type: "address"}}, core.TypedDataDomain{Name: "test"}},
+		// Invalid values
+		{[]core.Type{{Name: "verifyingContract", Type: "address"}}, core.TypedDataDomain{VerifyingContract: "0x1234"}},
+		{[]core.Type{{Name: "salt", Type: "bytes32"}}, core.TypedDataDomain{Salt: "0x1234"}},
+		{[]core.Type{{Name: "chainId", Type: "uint256"}}, core.TypedDataDomain{ChainId: math.NewHexOrDecimal256(-1)}},
+	}
+	for i, tt := range tests {
+		td := core.TypedData{
+			Types:       core.Types{"EIP712Domain": tt.types, "Empty": []core.Type{}},
+			PrimaryType: "Empty",
+			Domain:      tt.domain,
+			Message:     core.TypedDataMessage{},
+		}
+		if _, _, err := core.TypedDataAndHash(td); err == nil {
+			t.Errorf("test %d: invalid domain accepted", i)
+		}
+	}
+}
+
+// Tests that the domain chainId can be given both as string and as number.
+func TestTypedDataDomainChainIdJSON(t *testing.T) {
+	tests := []struct {
+		input string
+		want  *big.Int
+		ok    bool
+	}{
+		{`{"name": "test"}`, nil, true},
+		{`{"chainId": null}`, nil, true},
+		{`{"chainId": "0x12345678"}`, big.NewInt(0x12345678), true},
+		{`{"chainId": "12345678"}`, big.NewInt(12345678), true},
+		{`{"chainId": 12345678}`, big.NewInt(12345678), true},
+		{`{"chainId": 1.5}`, nil, false},
+		{`{"chainId": 1e3}`, nil, false},
+		{`{"chainId": true}`, nil, false},
+		{`{"chainId": "0xzz"}`, nil, false},
+	}
+	for i, tt := range tests {
+		var domain core.TypedDataDomain
+		err := json.Unmarshal([]byte(tt.input), &domain)
+		if (err == nil) != tt.ok {
+			t.Errorf("test %d: error mismatch: have %v, want ok %v", i, err, tt.ok)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if have := (*big.Int)(domain.ChainId); (have == nil) != (tt.want == nil) || have != nil && have.Cmp(tt.want) != 0 {
+			t.Errorf("test %d: chainId mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+	var domain core.TypedDataDomain
+	if err := json.Unmarshal([]byte(`{"name": "n", "version": "1", "verifyingContract": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC", "salt": "0x01"}`), &domain); err != nil {
+		t.Fatal(err)
+	}
+	if domain.Name != "n" || domain.Version != "1" || domain.VerifyingContract != "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC" || domain.Salt != "0x01" {
+		t.Errorf("other domain fields lost: %+v", domain)
+	}
+}
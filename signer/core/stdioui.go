@@ -84,6 +84,12 @@ func (ui *StdIOUI) ApproveNewAccount(request *NewAccountRequest) (NewAccountResp
 	return result, err
 }
 
+func (ui *StdIOUI) ApproveSafeProposal(request *SafeProposalRequest) (SafeProposalResponse, error) {
+	var result SafeProposalResponse
+	err := ui.dispatch("ui_approveSafeProposal", request, &result)
+	return result, err
+}
+
 func (ui *StdIOUI) ShowError(message string) {
 	err := ui.notify("ui_showError", &Message{message})
 	if err != nil {
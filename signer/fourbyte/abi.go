@@ -81,19 +81,23 @@ func verifySelector(selector string, calldata []byte) (*decodedCallData, error)
 // Note, although uppercase letters are not part of the ABI spec, this regexp
 // still accepts it as the general format is valid. It will be rejected later
 // by the type checker.
-var selectorRegexp = regexp.MustCompile(`^([^\)]+)\(([A-Za-z0-9,\[\]]*)\)`)
+var selectorRegexp = regexp.MustCompile(`^([^\(\)]+)\(([A-Za-z0-9,\[\]\(\)]*)\)`)
+
+// selectorArg is a tiny fake ABI argument for JSON marshalling.
+type selectorArg struct {
+	Name       string        `json:"name,omitempty"`
+	Type       string        `json:"type"`
+	Components []selectorArg `json:"components,omitempty"`
+}
 
 // parseSelector converts a method selector into an ABI JSON spec. The returned
 // data is a valid JSON string which can be consumed by the standard abi package.
 func parseSelector(unescapedSelector string) ([]byte, error) {
 	// Define a tiny fake ABI struct for JSON marshalling
-	type fakeArg struct {
-		Type string `json:"type"`
-	}
 	type fakeABI struct {
-		Name   string    `json:"name"`
-		Type   string    `json:"type"`
-		Inputs []fakeArg `json:"inputs"`
+		Name   string        `json:"name"`
+		Type   string        `json:"type"`
+		Inputs []selectorArg `json:"inputs"`
 	}
 	// Validate the unescapedSelector and extract it's components
 	groups := selectorRegexp.FindStringSubmatch(unescapedSelector)
@@ -104,15 +108,78 @@ func parseSelector(unescapedSelector string) ([]byte, error) {
 	args := groups[2]
 
 	// Reassemble the fake ABI and constuct the JSON
-	arguments := make([]fakeArg, 0)
-	if len(args) > 0 {
-		for _, arg := range strings.Split(args, ",") {
-			arguments = append(arguments, fakeArg{arg})
-		}
+	arguments, err := parseSelectorArgs(args)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector %q: %v", unescapedSelector, err)
 	}
 	return json.Marshal([]fakeABI{{name, "function", arguments}})
 }
 
+// parseSelectorArgs splits a comma separated argument list of a selector into
+// fake ABI arguments, recursively expanding tuples into their components.
+func parseSelectorArgs(args string) ([]selectorArg, error) {
+	arguments := make([]selectorArg, 0)
+	if len(args) == 0 {
+		return arguments, nil
+	}
+	var (
+		depth int
+		start int
+	)
+	for i := 0; i <= len(args); i++ {
+		if i < len(args) {
+			switch args[i] {
+			case '(':
+				depth++
+				continue
+			case ')':
+				if depth--; depth < 0 {
+					return nil, fmt.Errorf("unbalanced parentheses in %q", args)
+				}
+				continue
+			case ',':
+				if depth > 0 {
+					continue
+				}
+			default:
+				continue
+			}
+		}
+		if depth != 0 {
+			return nil, fmt.Errorf("unbalanced parentheses in %q", args)
+		}
+		arg, err := parseSelectorArg(args[start:i])
+		if err != nil {
+			return nil, err
+		}
+		arguments = append(arguments, arg)
+		start = i + 1
+	}
+	return arguments, nil
+}
+
+// parseSelectorArg converts a single selector argument into a fake ABI argument.
+// Tuples are written as their parenthesised component list, optionally followed
+// by array dimensions.
+func parseSelectorArg(arg string) (selectorArg, error) {
+	if !strings.HasPrefix(arg, "(") {
+		return selectorArg{Type: arg}, nil
+	}
+	end := strings.LastIndex(arg, ")")
+	components, err := parseSelectorArgs(arg[1:end])
+	if err != nil {
+		return selectorArg{}, err
+	}
+	if len(components) == 0 {
+		return selectorArg{}, fmt.Errorf("empty tuple in %q", arg)
+	}
+	// Tuple components need names to be representable in Go
+	for i := range components {
+		components[i].Name = fmt.Sprintf("field%d", i)
+	}
+	return selectorArg{Type: "tuple" + arg[end+1:], Components: components}, nil
+}
+
 // parseCallData matches the provided call data against the ABI definition and
 // returns a struct containing the actual go-typed values.
 func parseCallData(calldata []byte, unescapedAbidata string) (*decodedCallData, error) {
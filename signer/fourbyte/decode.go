@@ -0,0 +1,268 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package fourbyte
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	// maxDecodeDepth is the maximum nesting of calls decoded recursively, to
+	// avoid maliciously crafted payloads from blowing up the decoder.
+	maxDecodeDepth = 8
+
+	// multiSendSignature is the method of the Gnosis Safe MultiSend contract,
+	// which takes a list of calls packed (not ABI encoded) into a byte blob.
+	multiSendSignature = "multiSend(bytes)"
+)
+
+// errContractCreation is returned if a contract deployment is attempted to be
+// decoded as a method call.
+var errContractCreation = errors.New("contract creation has no method call to decode")
+
+// Decoding is a candidate interpretation of some call data according to one of
+// the method signatures matching its 4byte selector.
+type Decoding struct {
+	Selector  string          `json:"selector"`
+	Signature string          `json:"signature"`
+	Score     int             `json:"score"`
+	Inputs    []*DecodedValue `json:"inputs,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// DecodedValue is a single value decoded from call data. Arrays and tuples hold
+// their elements as a list of decoded values, byte blobs which are themselves
+// valid call data hold the (recursive) decodings of the nested calls.
+type DecodedValue struct {
+	Name     string        `json:"name,omitempty"`
+	Type     string        `json:"type"`
+	Value    interface{}   `json:"value"`
+	Calls    []*Decoding   `json:"calls,omitempty"`
+	Unpacked *DecodedValue `json:"unpacked,omitempty"`
+}
+
+// Selectors returns all the method signatures known for the given 4byte ID, in
+// both the embedded and custom datasets. They are ranked by preference: embedded
+// signatures before custom ones, each in the order they were added in.
+func (db *Database) Selectors(id []byte) []string {
+	if len(id) < 4 {
+		return nil
+	}
+	var (
+		sig       = hex.EncodeToString(id[:4])
+		selectors []string
+		seen      = make(map[string]bool)
+	)
+	for _, dataset := range []map[string]signatures{db.embedded, db.custom} {
+		for _, selector := range dataset[sig] {
+			if !seen[selector] {
+				selectors = append(selectors, selector)
+				seen[selector] = true
+			}
+		}
+	}
+	return selectors
+}
+
+// DecodeTransaction decodes the call data of a transaction into all possible
+// interpretations, ranked by how well they match.
+func (db *Database) DecodeTransaction(tx *types.Transaction) ([]*Decoding, error) {
+	if tx.To() == nil {
+		return nil, errContractCreation
+	}
+	return db.DecodeCallData(tx.Data())
+}
+
+// DecodeCallData resolves all the candidate method signatures for the given call
+// data and tries to decode it with each of them. Byte arguments which are valid
+// call data themselves (e.g. multisig execTransaction, multicall) are decoded
+// recursively.
+//
+// The returned decodings are ranked by score: every successful decoding scores
+// one point, plus the score of the best decoding of each nested call. Ties are
+// ranked in the order of Selectors. Failed candidates are included with a zero
+// score and the reason of the failure.
+func (db *Database) DecodeCallData(data []byte) ([]*Decoding, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("invalid call data, incomplete method signature (%d bytes < 4)", len(data))
+	}
+	selectors := db.Selectors(data[:4])
+	if len(selectors) == 0 {
+		return nil, fmt.Errorf("signature %x not found", data[:4])
+	}
+	return db.decode(data, selectors, 0), nil
+}
+
+// decode interprets the call data with all the given candidate signatures and
+// returns the decodings ranked by score.
+func (db *Database) decode(data []byte, selectors []string, depth int) []*Decoding {
+	decodings := make([]*Decoding, 0, len(selectors))
+	for _, selector := range selectors {
+		decodings = append(decodings, db.decodeWith(data, selector, depth))
+	}
+	sort.SliceStable(decodings, func(i, j int) bool {
+		return decodings[i].Score > decodings[j].Score
+	})
+	return decodings
+}
+
+// decodeWith interprets the call data according to a single method signature.
+func (db *Database) decodeWith(data []byte, selector string, depth int) *Decoding {
+	decoding := &Decoding{
+		Selector:  hexutil.Encode(data[:4]),
+		Signature: selector,
+	}
+	call, err := verifySelector(selector, data)
+	if err != nil {
+		decoding.Error = err.Error()
+		return decoding
+	}
+	decoding.Score = 1
+	for _, arg := range call.inputs {
+		value := db.decodeValue(arg.soltype.Type, reflect.ValueOf(arg.value), depth)
+		decoding.Inputs = append(decoding.Inputs, value)
+		decoding.Score += value.score()
+	}
+	// The MultiSend payload is a packed list of calls, decode them one by one
+	if call.signature == multiSendSignature {
+		if unpacked, err := db.decodeMultiSend(decoding.Inputs[0].Value.(hexutil.Bytes), depth); err == nil {
+			decoding.Inputs[0].Unpacked = unpacked
+			decoding.Score += unpacked.score()
+		}
+	}
+	return decoding
+}
+
+// decodeNested tries to interpret a byte blob as the call data of a nested call,
+// returning only the successful decodings.
+func (db *Database) decodeNested(data []byte, depth int) []*Decoding {
+	if depth >= maxDecodeDepth || len(data) < 4 {
+		return nil
+	}
+	var decodings []*Decoding
+	for _, decoding := range db.decode(data, db.Selectors(data[:4]), depth+1) {
+		if decoding.Error == "" {
+			decodings = append(decodings, decoding)
+		}
+	}
+	return decodings
+}
+
+// decodeValue converts a value unpacked by the abi package into its printable
+// representation, recursively decoding any byte blobs that are valid call data.
+func (db *Database) decodeValue(kind abi.Type, val reflect.Value, depth int) *DecodedValue {
+	decoded := &DecodedValue{Type: kind.String()}
+
+	switch kind.T {
+	case abi.TupleTy:
+		fields := make([]*DecodedValue, len(kind.TupleElems))
+		for i, elem := range kind.TupleElems {
+			fields[i] = db.decodeValue(*elem, val.Field(i), depth)
+		}
+		decoded.Value = fields
+
+	case abi.SliceTy, abi.ArrayTy:
+		items := make([]*DecodedValue, val.Len())
+		for i := range items {
+			items[i] = db.decodeValue(*kind.Elem, val.Index(i), depth)
+		}
+		decoded.Value = items
+
+	case abi.BytesTy:
+		blob := hexutil.Bytes(val.Bytes())
+		decoded.Value = blob
+		decoded.Calls = db.decodeNested(blob, depth)
+
+	case abi.FixedBytesTy, abi.FunctionTy:
+		blob := make(hexutil.Bytes, val.Len())
+		reflect.Copy(reflect.ValueOf(blob), val)
+		decoded.Value = blob
+
+	case abi.IntTy, abi.UintTy:
+		// Integers are rendered as decimal strings to retain precision in JSON
+		decoded.Value = fmt.Sprint(val.Interface())
+
+	case abi.AddressTy:
+		decoded.Value = val.Interface().(common.Address).Hex()
+
+	default:
+		decoded.Value = val.Interface()
+	}
+	return decoded
+}
+
+// score returns the sum of the scores of the best decodings of all the calls
+// nested within a value.
+func (v *DecodedValue) score() int {
+	var score int
+	if len(v.Calls) > 0 {
+		score += v.Calls[0].Score
+	}
+	if items, ok := v.Value.([]*DecodedValue); ok {
+		for _, item := range items {
+			score += item.score()
+		}
+	}
+	if v.Unpacked != nil {
+		score += v.Unpacked.score()
+	}
+	return score
+}
+
+// decodeMultiSend unpacks the transaction list of a MultiSend call. Each entry
+// is packed as operation (uint8), to (address), value (uint256), data length
+// (uint256) and data (bytes).
+func (db *Database) decodeMultiSend(blob []byte, depth int) (*DecodedValue, error) {
+	const header = 1 + common.AddressLength + 32 + 32
+
+	var txs []*DecodedValue
+	for len(blob) > 0 {
+		if len(blob) < header {
+			return nil, fmt.Errorf("truncated multisend entry header (%d bytes < %d)", len(blob), header)
+		}
+		size := new(big.Int).SetBytes(blob[header-32 : header])
+		if !size.IsUint64() || size.Uint64() > uint64(len(blob)-header) {
+			return nil, fmt.Errorf("truncated multisend entry data (%v bytes > %d)", size, len(blob)-header)
+		}
+		var (
+			to    = common.BytesToAddress(blob[1 : 1+common.AddressLength])
+			value = new(big.Int).SetBytes(blob[1+common.AddressLength : header-32])
+			data  = hexutil.Bytes(common.CopyBytes(blob[header : header+int(size.Uint64())]))
+		)
+		txs = append(txs, &DecodedValue{
+			Type: "(uint8,address,uint256,bytes)",
+			Value: []*DecodedValue{
+				{Name: "operation", Type: "uint8", Value: fmt.Sprint(blob[0])},
+				{Name: "to", Type: "address", Value: to.Hex()},
+				{Name: "value", Type: "uint256", Value: value.String()},
+				{Name: "data", Type: "bytes", Value: data, Calls: db.decodeNested(data, depth)},
+			},
+		})
+		blob = blob[header+int(size.Uint64()):]
+	}
+	return &DecodedValue{Type: "(uint8,address,uint256,bytes)[]", Value: txs}, nil
+}
@@ -0,0 +1,198 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package fourbyte
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const decodeTestABI = `[
+	{"type":"function","name":"transfer","inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"}]},
+	{"type":"function","name":"execTransaction","inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"},{"name":"data","type":"bytes"},{"name":"operation","type":"uint8"},{"name":"safeTxGas","type":"uint256"},{"name":"baseGas","type":"uint256"},{"name":"gasPrice","type":"uint256"},{"name":"gasToken","type":"address"},{"name":"refundReceiver","type":"address"},{"name":"signatures","type":"bytes"}]},
+	{"type":"function","name":"aggregate","inputs":[{"name":"calls","type":"tuple[]","components":[{"name":"target","type":"address"},{"name":"callData","type":"bytes"}]}]},
+	{"type":"function","name":"multiSend","inputs":[{"name":"transactions","type":"bytes"}]}
+]`
+
+// newDecodeTestDatabase creates a 4byte database containing the test methods.
+func newDecodeTestDatabase(t *testing.T) (*Database, abi.ABI) {
+	parsed, err := abi.JSON(strings.NewReader(decodeTestABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := newEmpty()
+	for _, method := range parsed.Methods {
+		db.embedded[hex.EncodeToString(method.ID)] = signatures{method.Sig}
+	}
+	return db, parsed
+}
+
+func mustPack(t *testing.T, parsed abi.ABI, method string, args ...interface{}) []byte {
+	data, err := parsed.Pack(method, args...)
+	if err != nil {
+		t.Fatalf("failed to pack %s: %v", method, err)
+	}
+	return data
+}
+
+// Tests that candidate signatures are ranked by how well they decode the data.
+func TestDecodeCallDataRanking(t *testing.T) {
+	db, parsed := newDecodeTestDatabase(t)
+
+	transfer := mustPack(t, parsed, "transfer", common.HexToAddress("0xdeadbeef"), big.NewInt(42))
+	db.custom[hex.EncodeToString(transfer[:4])] = signatures{"transfer(address,uint256,uint256)", "transfer(bytes)"}
+
+	decodings, err := db.DecodeCallData(transfer)
+	if err != nil {
+		t.Fatalf("failed to decode call data: %v", err)
+	}
+	if len(decodings) != 3 {
+		t.Fatalf("decoding count mismatch: have %d, want 3", len(decodings))
+	}
+	if have, want := decodings[0].Signature, "transfer(address,uint256)"; have != want {
+		t.Errorf("best decoding mismatch: have %s, want %s", have, want)
+	}
+	if decodings[0].Score != 1 || decodings[0].Error != "" {
+		t.Errorf("best decoding score mismatch: have %d (%s), want 1", decodings[0].Score, decodings[0].Error)
+	}
+	if have, want := decodings[0].Inputs[1].Value, "42"; have != want {
+		t.Errorf("decoded value mismatch: have %v, want %v", have, want)
+	}
+	for _, decoding := range decodings[1:] {
+		if decoding.Score != 0 || decoding.Error == "" {
+			t.Errorf("failed decoding not reported: score %d, error %q", decoding.Score, decoding.Error)
+		}
+	}
+	if _, err := db.DecodeCallData(common.Hex2Bytes("deadbeef")); err == nil {
+		t.Errorf("unknown signature decoded")
+	}
+}
+
+// Tests that calls nested in multisig transactions, multicalls and multisends
+// are decoded recursively.
+func TestDecodeCallDataNested(t *testing.T) {
+	db, parsed := newDecodeTestDatabase(t)
+
+	var (
+		token    = common.HexToAddress("0xcafebabe")
+		transfer = mustPack(t, parsed, "transfer", common.HexToAddress("0xdeadbeef"), big.NewInt(42))
+	)
+	type call struct {
+		Target   common.Address
+		CallData []byte
+	}
+	aggregate := mustPack(t, parsed, "aggregate", []call{{token, transfer}, {token, []byte{0x01}}})
+
+	multisend := append(packMultiSend(0, token, big.NewInt(0), transfer), packMultiSend(1, token, big.NewInt(1), aggregate)...)
+	multisend = mustPack(t, parsed, "multiSend", multisend)
+
+	exec := mustPack(t, parsed, "execTransaction", token, big.NewInt(0), multisend, uint8(1),
+		big.NewInt(0), big.NewInt(0), big.NewInt(0), common.Address{}, common.Address{}, []byte{})
+
+	decodings, err := db.DecodeCallData(exec)
+	if err != nil {
+		t.Fatalf("failed to decode call data: %v", err)
+	}
+	// execTransaction -> multiSend -> [transfer, aggregate -> [transfer]]
+	if have, want := decodings[0].Score, 5; have != want {
+		t.Fatalf("score mismatch: have %d, want %d", have, want)
+	}
+	nested := decodings[0].Inputs[2].Calls
+	if len(nested) != 1 || nested[0].Signature != "multiSend(bytes)" {
+		t.Fatalf("nested multisend not decoded: %v", nested)
+	}
+	txs := nested[0].Inputs[0].Unpacked.Value.([]*DecodedValue)
+	if len(txs) != 2 {
+		t.Fatalf("multisend transaction count mismatch: have %d, want 2", len(txs))
+	}
+	data := txs[1].Value.([]*DecodedValue)[3]
+	if len(data.Calls) != 1 || data.Calls[0].Signature != "aggregate((address,bytes)[])" {
+		t.Fatalf("nested multicall not decoded: %v", data.Calls)
+	}
+	calls := data.Calls[0].Inputs[0].Value.([]*DecodedValue)
+	first := calls[0].Value.([]*DecodedValue)
+	if have, want := first[0].Value, token.Hex(); have != want {
+		t.Errorf("multicall target mismatch: have %v, want %v", have, want)
+	}
+	if len(first[1].Calls) != 1 || first[1].Calls[0].Signature != "transfer(address,uint256)" {
+		t.Errorf("multicall payload not decoded: %v", first[1].Calls)
+	}
+	if second := calls[1].Value.([]*DecodedValue); len(second[1].Calls) != 0 {
+		t.Errorf("invalid multicall payload decoded: %v", second[1].Calls)
+	}
+	if have, want := first[1].Value.(hexutil.Bytes), transfer; !bytes.Equal(have, want) {
+		t.Errorf("multicall payload mismatch: have %v, want %v", have, want)
+	}
+}
+
+// Tests that tuple selectors can be converted into ABI specs.
+func TestParseTupleSelector(t *testing.T) {
+	tests := []struct {
+		selector string
+		valid    bool
+	}{
+		{"aggregate((address,bytes)[])", true},
+		{"f((uint256,(bool,string)[2])[],bytes32)", true},
+		{"f(uint256,(address,bytes)", false},
+		{"f((address,bytes)))", false},
+		{"f(())", false},
+	}
+	for i, tt := range tests {
+		spec, err := parseSelector(tt.selector)
+		if err != nil {
+			if tt.valid {
+				t.Errorf("test %d: failed to parse selector %s: %v", i, tt.selector, err)
+			}
+			continue
+		}
+		parsed, err := abi.JSON(strings.NewReader(string(spec)))
+		if err != nil {
+			if tt.valid {
+				t.Errorf("test %d: failed to parse ABI of %s: %v", i, tt.selector, err)
+			}
+			continue
+		}
+		if !tt.valid {
+			t.Errorf("test %d: invalid selector %s accepted", i, tt.selector)
+			continue
+		}
+		for _, method := range parsed.Methods {
+			if method.Sig != tt.selector {
+				t.Errorf("test %d: signature mismatch: have %s, want %s", i, method.Sig, tt.selector)
+			}
+			if id := crypto.Keccak256([]byte(tt.selector))[:4]; !bytes.Equal(method.ID, id) {
+				t.Errorf("test %d: selector mismatch: have %x, want %x", i, method.ID, id)
+			}
+		}
+	}
+}
+
+// packMultiSend packs a single transaction of a MultiSend payload.
+func packMultiSend(op uint8, to common.Address, value *big.Int, data []byte) []byte {
+	blob := append([]byte{op}, to.Bytes()...)
+	blob = append(blob, common.LeftPadBytes(value.Bytes(), 32)...)
+	blob = append(blob, common.LeftPadBytes(big.NewInt(int64(len(data))).Bytes(), 32)...)
+	return append(blob, data...)
+}
@@ -29,19 +29,44 @@ import (
 	"os"
 )
 
+// signatures is the list of method signatures sharing a 4byte ID, in order of
+// preference. It is encoded as a plain string if there's only one, keeping the
+// datasets compatible with the single signature format.
+type signatures []string
+
+// UnmarshalJSON implements json.Unmarshaler, accepting either a single signature
+// or a list of them.
+func (s *signatures) UnmarshalJSON(input []byte) error {
+	var single string
+	if err := json.Unmarshal(input, &single); err == nil {
+		*s = signatures{single}
+		return nil
+	}
+	return json.Unmarshal(input, (*[]string)(s))
+}
+
+// MarshalJSON implements json.Marshaler, encoding a lone signature as a string.
+func (s signatures) MarshalJSON() ([]byte, error) {
+	if len(s) == 1 {
+		return json.Marshal(s[0])
+	}
+	return json.Marshal([]string(s))
+}
+
 // Database is a 4byte database with the possibility of maintaining an immutable
 // set (embedded) into the process and a mutable set (loaded and written to file).
+// Colliding method signatures are all retained for each 4byte ID.
 type Database struct {
-	embedded   map[string]string
-	custom     map[string]string
+	embedded   map[string]signatures
+	custom     map[string]signatures
 	customPath string
 }
 
 // newEmpty exists for testing purposes.
 func newEmpty() *Database {
 	return &Database{
-		embedded: make(map[string]string),
-		custom:   make(map[string]string),
+		embedded: make(map[string]signatures),
+		custom:   make(map[string]signatures),
 	}
 }
 
@@ -74,7 +99,7 @@ func NewFromFile(path string) (*Database, error) {
 // file) as well as a custom database. The latter will be used to write new
 // values into if they are submitted via the API.
 func NewWithFile(path string) (*Database, error) {
-	db := &Database{make(map[string]string), make(map[string]string), path}
+	db := &Database{make(map[string]signatures), make(map[string]signatures), path}
 	db.customPath = path
 
 	blob, err := Asset("4byte.json")
@@ -96,12 +121,13 @@ func NewWithFile(path string) (*Database, error) {
 	return db, nil
 }
 
-// Size returns the number of 4byte entries in the embedded and custom datasets.
+// Size returns the number of 4byte IDs in the embedded and custom datasets.
 func (db *Database) Size() (int, int) {
 	return len(db.embedded), len(db.custom)
 }
 
-// Selector checks the given 4byte ID against the known ABI methods.
+// Selector checks the given 4byte ID against the known ABI methods, returning
+// the preferred one if several share the ID. Use Selectors to retrieve all.
 //
 // This method does not validate the match, it's assumed the caller will do.
 func (db *Database) Selector(id []byte) (string, error) {
@@ -109,17 +135,18 @@ func (db *Database) Selector(id []byte) (string, error) {
 		return "", fmt.Errorf("expected 4-byte id, got %d", len(id))
 	}
 	sig := hex.EncodeToString(id[:4])
-	if selector, exists := db.embedded[sig]; exists {
-		return selector, nil
+	if selectors := db.embedded[sig]; len(selectors) > 0 {
+		return selectors[0], nil
 	}
-	if selector, exists := db.custom[sig]; exists {
-		return selector, nil
+	if selectors := db.custom[sig]; len(selectors) > 0 {
+		return selectors[0], nil
 	}
 	return "", fmt.Errorf("signature %v not found", sig)
 }
 
-// AddSelector inserts a new 4byte entry into the database. If custom database
-// saving is enabled, the new dataset is also persisted to disk.
+// AddSelector inserts a new 4byte entry into the database. If the 4byte ID is
+// already known with other signatures, the new one is added after them. If custom
+// database saving is enabled, the new dataset is also persisted to disk.
 //
 // Node, this method does _not_ validate the correctness of the data. It assumes
 // the caller has already done so.
@@ -128,11 +155,14 @@ func (db *Database) AddSelector(selector string, data []byte) error {
 	if len(data) < 4 {
 		return nil
 	}
-	if _, err := db.Selector(data[:4]); err == nil {
-		return nil
+	for _, known := range db.Selectors(data[:4]) {
+		if known == selector {
+			return nil
+		}
 	}
 	// Inject the custom selector into the database and persist if needed
-	db.custom[hex.EncodeToString(data[:4])] = selector
+	sig := hex.EncodeToString(data[:4])
+	db.custom[sig] = append(db.custom[sig], selector)
 	if db.customPath == "" {
 		return nil
 	}
@@ -19,6 +19,9 @@ package fourbyte
 import (
 	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -32,24 +35,26 @@ func TestEmbeddedDatabase(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	for id, selector := range db.embedded {
-		abistring, err := parseSelector(selector)
-		if err != nil {
-			t.Errorf("Failed to convert selector to ABI: %v", err)
-			continue
-		}
-		abistruct, err := abi.JSON(strings.NewReader(string(abistring)))
-		if err != nil {
-			t.Errorf("Failed to parse ABI: %v", err)
-			continue
-		}
-		m, err := abistruct.MethodById(common.Hex2Bytes(id))
-		if err != nil {
-			t.Errorf("Failed to get method by id (%s): %v", id, err)
-			continue
-		}
-		if m.Sig != selector {
-			t.Errorf("Selector mismatch: have %v, want %v", m.Sig, selector)
+	for id, selectors := range db.embedded {
+		for _, selector := range selectors {
+			abistring, err := parseSelector(selector)
+			if err != nil {
+				t.Errorf("Failed to convert selector to ABI: %v", err)
+				continue
+			}
+			abistruct, err := abi.JSON(strings.NewReader(string(abistring)))
+			if err != nil {
+				t.Errorf("Failed to parse ABI: %v", err)
+				continue
+			}
+			m, err := abistruct.MethodById(common.Hex2Bytes(id))
+			if err != nil {
+				t.Errorf("Failed to get method by id (%s): %v", id, err)
+				continue
+			}
+			if m.Sig != selector {
+				t.Errorf("Selector mismatch: have %v, want %v", m.Sig, selector)
+			}
 		}
 	}
 }
@@ -67,7 +72,7 @@ func TestCustomDatabase(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	db.embedded = make(map[string]string)
+	db.embedded = make(map[string]signatures)
 
 	// Ensure the database is empty, insert and verify
 	calldata := common.Hex2Bytes("a52c101edeadbeef")
@@ -89,3 +94,45 @@ func TestCustomDatabase(t *testing.T) {
 		t.Fatalf("Failed to find a match for persisted abi signature: %v", err)
 	}
 }
+
+// Tests that all the signatures sharing a 4byte ID are retained and persisted.
+func TestCollidingSelectors(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "signer-4byte-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+	filename := filepath.Join(tmpdir, "4byte_custom.json")
+
+	// Start out with a single signature in the old format
+	if err := ioutil.WriteFile(filename, []byte(`{"a9059cbb":"transfer(address,uint256)"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	db, err := NewWithFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.embedded = make(map[string]signatures)
+
+	id := common.Hex2Bytes("a9059cbb")
+	for _, selector := range []string{"many_msg_babbage(bytes1)", "transfer(address,uint256)", "func_2093253501(bytes)"} {
+		if err := db.AddSelector(selector, id); err != nil {
+			t.Fatalf("Failed to add selector: %v", err)
+		}
+	}
+	want := []string{"transfer(address,uint256)", "many_msg_babbage(bytes1)", "func_2093253501(bytes)"}
+	if have := db.Selectors(id); !reflect.DeepEqual(have, want) {
+		t.Fatalf("Selectors mismatch: have %v, want %v", have, want)
+	}
+	if have, _ := db.Selector(id); have != want[0] {
+		t.Fatalf("Preferred selector mismatch: have %v, want %v", have, want[0])
+	}
+	// Check that all of them were persisted to disk
+	db2, err := NewFromFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to create new abidb: %v", err)
+	}
+	if have := db2.Selectors(id); !reflect.DeepEqual(have, want) {
+		t.Fatalf("Persisted selectors mismatch: have %v, want %v", have, want)
+	}
+}
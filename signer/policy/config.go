@@ -0,0 +1,282 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package policy implements declarative, auditable approval rules for clef as
+// an alternative to the javascript rule engine.
+package policy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/naoina/toml"
+)
+
+// Action is the outcome of a policy decision.
+type Action string
+
+const (
+	ActionApprove Action = "approve" // Request is approved without user interaction
+	ActionReject  Action = "reject"  // Request is rejected without user interaction
+	ActionManual  Action = "manual"  // Request is passed on to the user
+)
+
+// Config is the declarative policy, as loaded from a TOML file.
+type Config struct {
+	Default Action // Action for requests not decided by any rule, manual if unset
+	Listing Action // Action for account listing requests, Default if unset
+	Rules   []Rule // Transaction rules, evaluated in order
+}
+
+// Rule is a set of conditions a transaction needs to satisfy for the rule to
+// apply. The first applicable rule decides the transaction. Unset conditions
+// match any transaction.
+type Rule struct {
+	Name   string // Unique name of the rule, referenced in the audit log
+	Action Action // Action to take if the rule applies, approve if unset
+
+	From    []common.Address // Allowed signing accounts
+	To      []common.Address // Allowed recipients and contracts
+	Methods []string         // Allowed method signatures or 4byte selectors
+
+	MaxValue    *big.Int // Maximum value of a single transaction (wei)
+	MaxGas      uint64   // Maximum gas limit of a single transaction
+	MaxGasPrice *big.Int // Maximum gas price of a single transaction (wei)
+	DailyLimit  *big.Int // Maximum value spent per account and day (wei)
+
+	Days     []string // Allowed weekdays, e.g. ["mon", "tue"]
+	Hours    string   // Allowed time of day, e.g. "09:00-17:30"
+	Timezone string   // Timezone of the time window and daily limit, UTC if unset
+
+	AllowWarnings bool // Whether to apply even if the validator raised warnings
+}
+
+// tomlSettings ensures that TOML keys use the same names as Go struct fields
+// and that typos are reported instead of silently ignored.
+var tomlSettings = toml.Config{
+	NormFieldName: func(rt reflect.Type, key string) string {
+		return key
+	},
+	FieldToKey: func(rt reflect.Type, field string) string {
+		return field
+	},
+	MissingField: func(rt reflect.Type, field string) error {
+		return fmt.Errorf("field '%s' is not defined in %s", field, rt.String())
+	},
+}
+
+// Parse decodes a TOML policy and validates it.
+func Parse(data []byte) (*Policy, error) {
+	var config Config
+	if err := tomlSettings.NewDecoder(bytes.NewReader(data)).Decode(&config); err != nil {
+		return nil, err
+	}
+	return New(&config)
+}
+
+// Policy is a validated and compiled policy configuration.
+type Policy struct {
+	defaults Action
+	listing  Action
+	rules    []*rule
+}
+
+// rule is a validated policy rule with all the conditions preprocessed.
+type rule struct {
+	Rule
+
+	from    map[common.Address]bool
+	to      map[common.Address]bool
+	methods map[[4]byte]string
+
+	days     map[time.Weekday]bool
+	start    int // Start of the time window in minutes of the day
+	end      int // End of the time window in minutes of the day
+	location *time.Location
+}
+
+// weekdays maps the accepted day names to their weekday.
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// New validates a policy configuration and compiles it for evaluation.
+func New(config *Config) (*Policy, error) {
+	p := &Policy{
+		defaults: config.Default,
+		listing:  config.Listing,
+	}
+	if p.defaults == "" {
+		p.defaults = ActionManual
+	}
+	if p.listing == "" {
+		p.listing = p.defaults
+	}
+	if err := validateAction(p.defaults); err != nil {
+		return nil, fmt.Errorf("default: %v", err)
+	}
+	if err := validateAction(p.listing); err != nil {
+		return nil, fmt.Errorf("listing: %v", err)
+	}
+	names := make(map[string]bool)
+	for i, cfg := range config.Rules {
+		r, err := compileRule(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d (%s): %v", i, cfg.Name, err)
+		}
+		if names[r.Name] {
+			return nil, fmt.Errorf("rule %d: duplicate name %q", i, r.Name)
+		}
+		names[r.Name] = true
+		p.rules = append(p.rules, r)
+	}
+	return p, nil
+}
+
+// validateAction checks that the action is one of the known ones.
+func validateAction(action Action) error {
+	switch action {
+	case ActionApprove, ActionReject, ActionManual:
+		return nil
+	}
+	return fmt.Errorf("unknown action %q", action)
+}
+
+// compileRule validates a rule and preprocesses its conditions.
+func compileRule(cfg Rule) (*rule, error) {
+	if cfg.Name == "" {
+		return nil, errors.New("missing name")
+	}
+	if cfg.Action == "" {
+		cfg.Action = ActionApprove
+	}
+	if err := validateAction(cfg.Action); err != nil {
+		return nil, err
+	}
+	r := &rule{Rule: cfg, location: time.UTC}
+	if len(cfg.From) > 0 {
+		r.from = make(map[common.Address]bool)
+		for _, addr := range cfg.From {
+			r.from[addr] = true
+		}
+	}
+	if len(cfg.To) > 0 {
+		r.to = make(map[common.Address]bool)
+		for _, addr := range cfg.To {
+			r.to[addr] = true
+		}
+	}
+	if len(cfg.Methods) > 0 {
+		r.methods = make(map[[4]byte]string)
+		for _, method := range cfg.Methods {
+			selector, err := parseSelector(method)
+			if err != nil {
+				return nil, err
+			}
+			r.methods[selector] = method
+		}
+	}
+	for _, limit := range []*big.Int{cfg.MaxValue, cfg.MaxGasPrice, cfg.DailyLimit} {
+		if limit != nil && limit.Sign() < 0 {
+			return nil, errors.New("negative limit")
+		}
+	}
+	if len(cfg.Days) > 0 {
+		r.days = make(map[time.Weekday]bool)
+		for _, day := range cfg.Days {
+			weekday, ok := weekdays[strings.ToLower(day)]
+			if !ok {
+				return nil, fmt.Errorf("invalid day %q", day)
+			}
+			r.days[weekday] = true
+		}
+	}
+	if cfg.Hours != "" {
+		var err error
+		if r.start, r.end, err = parseHours(cfg.Hours); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Timezone != "" {
+		loc, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return nil, err
+		}
+		r.location = loc
+	}
+	return r, nil
+}
+
+// parseSelector converts a method signature or hex 4byte selector into the
+// selector it stands for.
+func parseSelector(method string) ([4]byte, error) {
+	var selector [4]byte
+	if strings.HasPrefix(method, "0x") {
+		blob, err := hexutil.Decode(method)
+		if err != nil || len(blob) != 4 {
+			return selector, fmt.Errorf("invalid method selector %q", method)
+		}
+		copy(selector[:], blob)
+		return selector, nil
+	}
+	if !strings.HasSuffix(method, ")") || strings.Contains(method, " ") {
+		return selector, fmt.Errorf("invalid method signature %q", method)
+	}
+	copy(selector[:], crypto.Keccak256([]byte(method))[:4])
+	return selector, nil
+}
+
+// parseHours parses a time window in the form "hh:mm-hh:mm" into minutes of
+// the day. Windows wrapping around midnight are allowed.
+func parseHours(hours string) (int, int, error) {
+	parts := strings.Split(hours, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid time window %q", hours)
+	}
+	var bounds [2]int
+	for i, part := range parts {
+		t, err := time.Parse("15:04", strings.TrimSpace(part))
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid time window %q: %v", hours, err)
+		}
+		bounds[i] = t.Hour()*60 + t.Minute()
+	}
+	if bounds[0] == bounds[1] {
+		return 0, 0, fmt.Errorf("empty time window %q", hours)
+	}
+	return bounds[0], bounds[1], nil
+}
+
+// inWindow reports whether the time falls within the rule's time window.
+func (r *rule) inWindow(now time.Time) bool {
+	if r.Hours == "" {
+		return true
+	}
+	minute := now.Hour()*60 + now.Minute()
+	if r.start < r.end {
+		return r.start <= minute && minute < r.end
+	}
+	return minute >= r.start || minute < r.end
+}
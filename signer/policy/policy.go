@@ -0,0 +1,341 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/signer/core"
+	"github.com/ethereum/go-ethereum/signer/storage"
+)
+
+// dailySpend is the value spent by an account under a rule on a given day, as
+// persisted in the storage.
+type dailySpend struct {
+	Day   string `json:"day"`
+	Spent string `json:"spent"`
+}
+
+// policyUI provides an implementation of UIClientAPI that decides requests based
+// on a declarative policy, passing undecided requests on to the next UI.
+type policyUI struct {
+	next    core.UIClientAPI     // The next handler, for manual processing
+	storage storage.Storage      // Storage tracking the daily spending
+	policy  *Policy              // The policy to evaluate requests against
+	auditor core.DecisionAuditor // Audit log to explain decisions in, if any
+
+	now  func() time.Time // Clock, overridable for testing
+	lock sync.Mutex       // Serializes decisions updating the daily spending
+}
+
+// NewPolicyEvaluator creates a UI deciding requests according to the policy and
+// forwarding the ones needing manual approval to next.
+func NewPolicyEvaluator(next core.UIClientAPI, storage storage.Storage, policy *Policy) (*policyUI, error) {
+	return &policyUI{
+		next:    next,
+		storage: storage,
+		policy:  policy,
+		now:     time.Now,
+	}, nil
+}
+
+// SetAuditor sets the audit log to record the explanation of all decisions in.
+func (p *policyUI) SetAuditor(auditor core.DecisionAuditor) {
+	p.auditor = auditor
+}
+
+// audit records a decision in the audit log, or the regular log if no audit
+// log is configured.
+func (p *policyUI) audit(decision *core.Decision) {
+	if p.auditor != nil {
+		p.auditor.AuditDecision(decision)
+		return
+	}
+	log.Info("Policy decision", "request", decision.Request, "action", decision.Action,
+		"rule", decision.Rule, "reasons", strings.Join(decision.Reasons, "; "))
+}
+
+func (p *policyUI) RegisterUIServer(api *core.UIServerAPI) {
+	p.next.RegisterUIServer(api)
+}
+
+func (p *policyUI) ApproveTx(request *core.SignTxRequest) (core.SignTxResponse, error) {
+	// Only hold the lock while deciding, manual approvals may take arbitrarily
+	// long and must not hold up the decisions on other requests
+	p.lock.Lock()
+	decision := p.evaluateTx(request)
+	p.lock.Unlock()
+
+	p.audit(decision)
+
+	switch Action(decision.Action) {
+	case ActionApprove:
+		return core.SignTxResponse{Transaction: request.Transaction, Approved: true}, nil
+	case ActionReject:
+		return core.SignTxResponse{Approved: false}, nil
+	}
+	resp, err := p.next.ApproveTx(request)
+	if err == nil && resp.Approved {
+		p.lock.Lock()
+		p.addManualSpend(request, resp.Transaction)
+		p.lock.Unlock()
+	}
+	return resp, err
+}
+
+// evaluateTx finds the first rule applicable to a transaction request, tracking
+// the spending if it's approved by a rule with a daily limit.
+func (p *policyUI) evaluateTx(request *core.SignTxRequest) *core.Decision {
+	decision := &core.Decision{
+		Request: "ApproveTx",
+		Action:  string(p.policy.defaults),
+		Meta:    request.Meta,
+	}
+	now := p.now()
+	for _, r := range p.policy.rules {
+		reason := p.mismatch(r, request, now)
+		if reason == "" {
+			reason = p.exceeded(r, request, now)
+		}
+		if reason != "" {
+			decision.Reasons = append(decision.Reasons, fmt.Sprintf("rule %q skipped: %s", r.Name, reason))
+			continue
+		}
+		decision.Action = string(r.Action)
+		decision.Rule = r.Name
+		decision.Reasons = append(decision.Reasons, fmt.Sprintf("rule %q applies: %s", r.Name, r.Action))
+
+		if r.Action == ActionApprove && r.DailyLimit != nil {
+			p.addSpend(r, request, now)
+		}
+		return decision
+	}
+	decision.Reasons = append(decision.Reasons, fmt.Sprintf("no rule applies, default: %s", p.policy.defaults))
+	return decision
+}
+
+// mismatch returns the reason why a rule does not apply to a transaction, or an
+// empty string if it does.
+func (p *policyUI) mismatch(r *rule, request *core.SignTxRequest, now time.Time) string {
+	tx := request.Transaction
+
+	if r.from != nil && !r.from[tx.From.Address()] {
+		return fmt.Sprintf("sender %v not allowed", tx.From.Address().Hex())
+	}
+	if r.to != nil {
+		if tx.To == nil {
+			return "contract creation not allowed"
+		}
+		if !r.to[tx.To.Address()] {
+			return fmt.Sprintf("recipient %v not allowed", tx.To.Address().Hex())
+		}
+	}
+	if r.methods != nil {
+		var data []byte
+		if tx.Data != nil {
+			data = *tx.Data
+		} else if tx.Input != nil {
+			data = *tx.Input
+		}
+		if len(data) < 4 {
+			return "method call required"
+		}
+		var selector [4]byte
+		copy(selector[:], data[:4])
+		if _, ok := r.methods[selector]; !ok {
+			return fmt.Sprintf("method %#x not allowed", selector)
+		}
+	}
+	value := tx.Value.ToInt()
+	if r.MaxValue != nil && value.Cmp(r.MaxValue) > 0 {
+		return fmt.Sprintf("value %v above cap %v", value, r.MaxValue)
+	}
+	if r.MaxGas != 0 && uint64(tx.Gas) > r.MaxGas {
+		return fmt.Sprintf("gas %d above cap %d", tx.Gas, r.MaxGas)
+	}
+	if r.MaxGasPrice != nil && tx.GasPrice.ToInt().Cmp(r.MaxGasPrice) > 0 {
+		return fmt.Sprintf("gas price %v above cap %v", tx.GasPrice.ToInt(), r.MaxGasPrice)
+	}
+	if !r.AllowWarnings {
+		for _, info := range request.Callinfo {
+			if info.Typ == core.WARN || info.Typ == core.CRIT {
+				return fmt.Sprintf("validation %s: %s", strings.ToLower(info.Typ), info.Message)
+			}
+		}
+	}
+	local := now.In(r.location)
+	if r.days != nil && !r.days[local.Weekday()] {
+		return fmt.Sprintf("%v outside of allowed days", local.Weekday())
+	}
+	if !r.inWindow(local) {
+		return fmt.Sprintf("%s outside of time window %s", local.Format("15:04"), r.Hours)
+	}
+	return ""
+}
+
+// exceeded returns the reason why a transaction would exceed the daily limit of
+// an approving rule, or an empty string if it's within the limit.
+func (p *policyUI) exceeded(r *rule, request *core.SignTxRequest, now time.Time) string {
+	if r.DailyLimit == nil || r.Action != ActionApprove {
+		return ""
+	}
+	var (
+		value = request.Transaction.Value.ToInt()
+		spent = p.spent(r, request.Transaction.From.Address(), now.In(r.location))
+	)
+	if total := new(big.Int).Add(spent, value); total.Cmp(r.DailyLimit) > 0 {
+		return fmt.Sprintf("daily limit %v exceeded, spent %v + value %v", r.DailyLimit, spent, value)
+	}
+	return ""
+}
+
+// spendKey returns the storage key tracking an account's spending under a rule.
+func spendKey(r *rule, from common.Address) string {
+	return fmt.Sprintf("policy-spend-%s-%s", r.Name, from.Hex())
+}
+
+// spent returns the value an account spent today under a rule.
+func (p *policyUI) spent(r *rule, from common.Address, now time.Time) *big.Int {
+	blob, err := p.storage.Get(spendKey(r, from))
+	if err != nil {
+		return new(big.Int)
+	}
+	var spend dailySpend
+	if err := json.Unmarshal([]byte(blob), &spend); err != nil {
+		log.Warn("Corrupt policy spending record", "rule", r.Name, "from", from, "err", err)
+		return new(big.Int)
+	}
+	if spend.Day != now.Format("2006-01-02") {
+		return new(big.Int)
+	}
+	spent, ok := new(big.Int).SetString(spend.Spent, 10)
+	if !ok {
+		return new(big.Int)
+	}
+	return spent
+}
+
+// addSpend adds the value of an approved transaction to the daily spending of
+// its sender. Approved transactions are counted even if signing them fails
+// later, erring on the safe side.
+func (p *policyUI) addSpend(r *rule, request *core.SignTxRequest, now time.Time) {
+	var (
+		from  = request.Transaction.From.Address()
+		local = now.In(r.location)
+		spent = new(big.Int).Add(p.spent(r, from, local), request.Transaction.Value.ToInt())
+	)
+	blob, _ := json.Marshal(&dailySpend{Day: local.Format("2006-01-02"), Spent: spent.String()})
+	p.storage.Put(spendKey(r, from), string(blob))
+}
+
+// addManualSpend adds the value of a manually approved transaction to the daily
+// spending of the limited rules covering it, so that manual and automatic
+// approvals combined can't exceed the limits.
+func (p *policyUI) addManualSpend(request *core.SignTxRequest, tx core.SendTxArgs) {
+	approved := *request
+	approved.Transaction = tx
+
+	now := p.now()
+	for _, r := range p.policy.rules {
+		if r.Action == ActionApprove && r.DailyLimit != nil && p.mismatch(r, &approved, now) == "" {
+			p.addSpend(r, &approved, now)
+		}
+	}
+}
+
+func (p *policyUI) ApproveListing(request *core.ListRequest) (core.ListResponse, error) {
+	p.audit(&core.Decision{
+		Request: "ApproveListing",
+		Action:  string(p.policy.listing),
+		Reasons: []string{fmt.Sprintf("listing policy: %s", p.policy.listing)},
+		Meta:    request.Meta,
+	})
+	switch p.policy.listing {
+	case ActionApprove:
+		return core.ListResponse{Accounts: request.Accounts}, nil
+	case ActionReject:
+		return core.ListResponse{}, nil
+	}
+	return p.next.ApproveListing(request)
+}
+
+// undecided handles the requests the policy has no rules for, rejecting them
+// or forwarding them for manual approval depending on the default action.
+// Requests are never approved by default, as the policy knows nothing of them.
+func (p *policyUI) undecided(request string, meta core.Metadata) bool {
+	action := p.policy.defaults
+	if action == ActionApprove {
+		action = ActionManual
+	}
+	p.audit(&core.Decision{
+		Request: request,
+		Action:  string(action),
+		Reasons: []string{fmt.Sprintf("no rules for request type, default: %s", action)},
+		Meta:    meta,
+	})
+	return action == ActionReject
+}
+
+func (p *policyUI) ApproveSignData(request *core.SignDataRequest) (core.SignDataResponse, error) {
+	if p.undecided("ApproveSignData", request.Meta) {
+		return core.SignDataResponse{Approved: false}, nil
+	}
+	return p.next.ApproveSignData(request)
+}
+
+func (p *policyUI) ApproveSafeProposal(request *core.SafeProposalRequest) (core.SafeProposalResponse, error) {
+	if p.undecided("ApproveSafeProposal", request.Meta) {
+		return core.SafeProposalResponse{Approved: false}, nil
+	}
+	return p.next.ApproveSafeProposal(request)
+}
+
+func (p *policyUI) ApproveNewAccount(request *core.NewAccountRequest) (core.NewAccountResponse, error) {
+	// This cannot be handled by policies, requires setting a password
+	return p.next.ApproveNewAccount(request)
+}
+
+// OnInputRequired not handled by policies
+func (p *policyUI) OnInputRequired(info core.UserInputRequest) (core.UserInputResponse, error) {
+	return p.next.OnInputRequired(info)
+}
+
+func (p *policyUI) ShowError(message string) {
+	log.Error(message)
+	p.next.ShowError(message)
+}
+
+func (p *policyUI) ShowInfo(message string) {
+	log.Info(message)
+	p.next.ShowInfo(message)
+}
+
+func (p *policyUI) OnSignerStartup(info core.StartupInfo) {
+	p.next.OnSignerStartup(info)
+}
+
+func (p *policyUI) OnApprovedTx(tx ethapi.SignTransactionResult) {
+	p.next.OnApprovedTx(tx)
+}
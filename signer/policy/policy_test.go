@@ -0,0 +1,292 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package policy
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/signer/core"
+	"github.com/ethereum/go-ethereum/signer/storage"
+)
+
+const testPolicy = `
+Default = "reject"
+Listing = "approve"
+
+[[Rules]]
+Name = "blocked"
+Action = "reject"
+To = ["0x000000000000000000000000000000000000dead"]
+
+[[Rules]]
+Name = "token"
+From = ["0x0000000000000000000000000000000000001000"]
+To = ["0x000000000000000000000000000000000000beef"]
+Methods = ["transfer(address,uint256)", "0x095ea7b3"]
+MaxValue = 0
+MaxGas = 100000
+
+[[Rules]]
+Name = "payments"
+From = ["0x0000000000000000000000000000000000001000"]
+MaxValue = 5000000000000000000
+MaxGasPrice = 100000000000
+DailyLimit = 12000000000000000000
+Days = ["mon", "tue", "wed", "thu", "fri"]
+Hours = "09:00-17:30"
+`
+
+var (
+	testSender = common.HexToAddress("0x1000")
+	testToken  = common.HexToAddress("0xbeef")
+	testPayee  = common.HexToAddress("0xcafe")
+)
+
+// manualUI records the requests forwarded to it for manual approval.
+type manualUI struct {
+	core.UIClientAPI
+	calls []string
+}
+
+func (ui *manualUI) ApproveTx(request *core.SignTxRequest) (core.SignTxResponse, error) {
+	ui.calls = append(ui.calls, "ApproveTx")
+	return core.SignTxResponse{Approved: false}, nil
+}
+
+func (ui *manualUI) ApproveSignData(request *core.SignDataRequest) (core.SignDataResponse, error) {
+	ui.calls = append(ui.calls, "ApproveSignData")
+	return core.SignDataResponse{Approved: true}, nil
+}
+
+func (ui *manualUI) OnApprovedTx(tx ethapi.SignTransactionResult) {}
+
+// testAuditor collects the audited decisions.
+type testAuditor struct {
+	decisions []*core.Decision
+}
+
+func (a *testAuditor) AuditDecision(d *core.Decision) {
+	a.decisions = append(a.decisions, d)
+}
+
+func newTestEvaluator(t *testing.T, policy string, now time.Time) (*policyUI, *manualUI, *testAuditor) {
+	p, err := Parse([]byte(policy))
+	if err != nil {
+		t.Fatalf("failed to parse policy: %v", err)
+	}
+	next, auditor := new(manualUI), new(testAuditor)
+	ui, _ := NewPolicyEvaluator(next, storage.NewEphemeralStorage(), p)
+	ui.SetAuditor(auditor)
+	ui.now = func() time.Time { return now }
+	return ui, next, auditor
+}
+
+func txRequest(to common.Address, value *big.Int, gas uint64, data []byte) *core.SignTxRequest {
+	mto := common.NewMixedcaseAddress(to)
+	req := &core.SignTxRequest{
+		Transaction: core.SendTxArgs{
+			From:     common.NewMixedcaseAddress(testSender),
+			To:       &mto,
+			Gas:      hexutil.Uint64(gas),
+			GasPrice: hexutil.Big(*big.NewInt(1000000000)),
+			Value:    hexutil.Big(*value),
+		},
+	}
+	if data != nil {
+		blob := hexutil.Bytes(data)
+		req.Transaction.Data = &blob
+	}
+	return req
+}
+
+func ether(n int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(n), big.NewInt(1000000000000000000))
+}
+
+// Tests that transactions are decided by the first applicable rule, with the
+// reasons for skipping the others explained in the audit log.
+func TestPolicyTransactions(t *testing.T) {
+	// Wednesday, during office hours
+	now := time.Date(2021, 6, 2, 10, 0, 0, 0, time.UTC)
+	transfer := append(crypto.Keccak256([]byte("transfer(address,uint256)"))[:4], make([]byte, 64)...)
+
+	tests := []struct {
+		req      *core.SignTxRequest
+		approved bool
+		action   Action
+		rule     string
+		reason   string
+	}{
+		{txRequest(common.HexToAddress("0xdead"), big.NewInt(1), 21000, nil), false, ActionReject, "blocked", ""},
+		{txRequest(testToken, big.NewInt(0), 60000, transfer), true, ActionApprove, "token", ""},
+		{txRequest(testToken, big.NewInt(0), 60000, common.Hex2Bytes("095ea7b3")), true, ActionApprove, "token", ""},
+		{txRequest(testToken, big.NewInt(0), 200000, transfer), true, ActionApprove, "payments", "gas 200000 above cap 100000"},
+		{txRequest(testToken, big.NewInt(0), 60000, common.Hex2Bytes("deadbeef")), true, ActionApprove, "payments", "method 0xdeadbeef not allowed"},
+		{txRequest(testPayee, ether(6), 21000, nil), false, ActionReject, "", "value 6000000000000000000 above cap 5000000000000000000"},
+	}
+	for i, tt := range tests {
+		ui, _, auditor := newTestEvaluator(t, testPolicy, now)
+		resp, err := ui.ApproveTx(tt.req)
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		if resp.Approved != tt.approved {
+			t.Errorf("test %d: approval mismatch: have %v, want %v", i, resp.Approved, tt.approved)
+		}
+		if len(auditor.decisions) != 1 {
+			t.Fatalf("test %d: audited decision count mismatch: have %d, want 1", i, len(auditor.decisions))
+		}
+		decision := auditor.decisions[0]
+		if decision.Action != string(tt.action) || decision.Rule != tt.rule {
+			t.Errorf("test %d: decision mismatch: have %s/%q, want %s/%q", i, decision.Action, decision.Rule, tt.action, tt.rule)
+		}
+		if reasons := strings.Join(decision.Reasons, "; "); !strings.Contains(reasons, tt.reason) {
+			t.Errorf("test %d: reasons %q missing %q", i, reasons, tt.reason)
+		}
+	}
+}
+
+// Tests that the daily spending is tracked across requests and reset on the
+// next day, and that time windows are enforced.
+func TestPolicyLimits(t *testing.T) {
+	now := time.Date(2021, 6, 2, 10, 0, 0, 0, time.UTC)
+	ui, _, auditor := newTestEvaluator(t, testPolicy, now)
+
+	for i, want := range []bool{true, true, false} {
+		resp, _ := ui.ApproveTx(txRequest(testPayee, ether(5), 21000, nil))
+		if resp.Approved != want {
+			t.Fatalf("payment %d: approval mismatch: have %v, want %v", i, resp.Approved, want)
+		}
+	}
+	if reasons := strings.Join(auditor.decisions[2].Reasons, "; "); !strings.Contains(reasons, "daily limit") {
+		t.Errorf("daily limit not explained: %q", reasons)
+	}
+	// Next day the limit resets, but only within the time window
+	ui.now = func() time.Time { return now.Add(24 * time.Hour) }
+	if resp, _ := ui.ApproveTx(txRequest(testPayee, ether(5), 21000, nil)); !resp.Approved {
+		t.Fatalf("payment not approved after daily reset")
+	}
+	ui.now = func() time.Time { return now.Add(24*time.Hour + 8*time.Hour) }
+	if resp, _ := ui.ApproveTx(txRequest(testPayee, ether(1), 21000, nil)); resp.Approved {
+		t.Fatalf("payment approved outside of time window")
+	}
+	ui.now = func() time.Time { return now.Add(3 * 24 * time.Hour) }
+	if resp, _ := ui.ApproveTx(txRequest(testPayee, ether(1), 21000, nil)); resp.Approved {
+		t.Fatalf("payment approved on weekend")
+	}
+}
+
+// blockingUI holds manual approvals until released, approving them then.
+type blockingUI struct {
+	core.UIClientAPI
+	started chan struct{}
+	release chan struct{}
+}
+
+func (ui *blockingUI) ApproveTx(request *core.SignTxRequest) (core.SignTxResponse, error) {
+	ui.started <- struct{}{}
+	<-ui.release
+	return core.SignTxResponse{Transaction: request.Transaction, Approved: true}, nil
+}
+
+// Tests that pending manual approvals don't hold up the automatic decisions, and
+// that manually approved transactions count towards the daily limits.
+func TestPolicyManualConcurrency(t *testing.T) {
+	now := time.Date(2021, 6, 2, 10, 0, 0, 0, time.UTC)
+	ui, _, _ := newTestEvaluator(t, strings.Replace(testPolicy, `Default = "reject"`, `Default = "manual"`, 1), now)
+
+	next := &blockingUI{started: make(chan struct{}), release: make(chan struct{})}
+	ui.next = next
+
+	// Use up most of the daily limit, forwarding the next payment for approval
+	for i := 0; i < 2; i++ {
+		if resp, _ := ui.ApproveTx(txRequest(testPayee, ether(5), 21000, nil)); !resp.Approved {
+			t.Fatalf("payment %d not approved", i)
+		}
+	}
+	manual := make(chan core.SignTxResponse)
+	go func() {
+		resp, _ := ui.ApproveTx(txRequest(testPayee, ether(5), 21000, nil))
+		manual <- resp
+	}()
+	<-next.started
+
+	// While the manual approval is pending, the policy must still decide
+	decided := make(chan core.SignTxResponse)
+	go func() {
+		resp, _ := ui.ApproveTx(txRequest(testPayee, ether(1), 21000, nil))
+		decided <- resp
+	}()
+	select {
+	case resp := <-decided:
+		if !resp.Approved {
+			t.Fatalf("payment within the limit not approved")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("automatic decision blocked by pending manual approval")
+	}
+	close(next.release)
+	if resp := <-manual; !resp.Approved {
+		t.Fatalf("manual approval not returned")
+	}
+	// The manually approved payment must be counted in the daily spending
+	r := ui.policy.rules[2]
+	if spent := ui.spent(r, testSender, now); spent.Cmp(ether(16)) != 0 {
+		t.Errorf("daily spending mismatch: have %v, want %v", spent, ether(16))
+	}
+}
+
+// Tests that requests without rules are never approved by default.
+func TestPolicyDefaults(t *testing.T) {
+	ui, next, auditor := newTestEvaluator(t, `Default = "approve"`, time.Now())
+
+	if resp, _ := ui.ApproveTx(txRequest(testPayee, ether(1), 21000, nil)); !resp.Approved {
+		t.Errorf("default transaction approval not applied")
+	}
+	if resp, _ := ui.ApproveSignData(&core.SignDataRequest{}); !resp.Approved || len(next.calls) != 1 {
+		t.Errorf("data signing not forwarded for manual approval")
+	}
+	if have := auditor.decisions[1].Action; have != string(ActionManual) {
+		t.Errorf("data signing decision mismatch: have %s, want %s", have, ActionManual)
+	}
+}
+
+// Tests that invalid policies are rejected.
+func TestPolicyValidation(t *testing.T) {
+	tests := []string{
+		`Default = "maybe"`,
+		`Unknown = true`,
+		"[[Rules]]\nAction = \"approve\"",
+		"[[Rules]]\nName = \"a\"\n[[Rules]]\nName = \"a\"",
+		"[[Rules]]\nName = \"a\"\nMethods = [\"transfer\"]",
+		"[[Rules]]\nName = \"a\"\nDays = [\"someday\"]",
+		"[[Rules]]\nName = \"a\"\nHours = \"9-17\"",
+		"[[Rules]]\nName = \"a\"\nMaxValue = -1",
+	}
+	for i, policy := range tests {
+		if _, err := Parse([]byte(policy)); err == nil {
+			t.Errorf("test %d: invalid policy accepted: %s", i, policy)
+		}
+	}
+}
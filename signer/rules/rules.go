@@ -207,6 +207,19 @@ func (r *rulesetUI) ApproveNewAccount(request *core.NewAccountRequest) (core.New
 	return r.next.ApproveNewAccount(request)
 }
 
+func (r *rulesetUI) ApproveSafeProposal(request *core.SafeProposalRequest) (core.SafeProposalResponse, error) {
+	jsonreq, err := json.Marshal(request)
+	approved, err := r.checkApproval("ApproveSafeProposal", jsonreq, err)
+	if err != nil {
+		log.Info("Rule-based approval error, going to manual", "error", err)
+		return r.next.ApproveSafeProposal(request)
+	}
+	if approved {
+		return core.SafeProposalResponse{Approved: true}, nil
+	}
+	return core.SafeProposalResponse{Approved: false}, err
+}
+
 func (r *rulesetUI) ShowError(message string) {
 	log.Error(message)
 	r.next.ShowError(message)
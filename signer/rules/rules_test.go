@@ -99,6 +99,10 @@ func (alwaysDenyUI) ApproveNewAccount(request *core.NewAccountRequest) (core.New
 	return core.NewAccountResponse{Approved: false}, nil
 }
 
+func (alwaysDenyUI) ApproveSafeProposal(request *core.SafeProposalRequest) (core.SafeProposalResponse, error) {
+	return core.SafeProposalResponse{Approved: false}, nil
+}
+
 func (alwaysDenyUI) ShowError(message string) {
 	panic("implement me")
 }
@@ -150,6 +154,51 @@ func TestListRequest(t *testing.T) {
 	}
 }
 
+func TestSafeProposalRequest(t *testing.T) {
+	js := `
+	function ApproveSafeProposal(r){
+		if (r.stage == "combine" && Object.keys(r.proposal.signatures).length < r.proposal.threshold){
+			return "Reject"
+		}
+		if (r.stage == "propose" || r.stage == "sign" || r.stage == "combine"){
+			return "Approve"
+		}
+		return "Reject"
+	}`
+	r, err := initRuleEngine(js)
+	if err != nil {
+		t.Errorf("Couldn't create evaluator %v", err)
+		return
+	}
+	proposal := &core.SafeProposal{
+		Owners:     []common.Address{common.HexToAddress("0x1")},
+		Threshold:  1,
+		Signatures: map[common.Address]hexutil.Bytes{},
+	}
+	for i, tt := range []struct {
+		stage    string
+		signed   bool
+		approved bool
+	}{
+		{core.SafeStagePropose, false, true},
+		{core.SafeStageSign, false, true},
+		{core.SafeStageConfirm, false, false},
+		{core.SafeStageCombine, false, false},
+		{core.SafeStageCombine, true, true},
+	} {
+		if tt.signed {
+			proposal.Signatures[proposal.Owners[0]] = make(hexutil.Bytes, 65)
+		}
+		resp, err := r.ApproveSafeProposal(&core.SafeProposalRequest{Stage: tt.stage, Proposal: proposal})
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+		}
+		if resp.Approved != tt.approved {
+			t.Errorf("test %d: stage %s approval mismatch: have %v, want %v", i, tt.stage, resp.Approved, tt.approved)
+		}
+	}
+}
+
 func TestSignTxRequest(t *testing.T) {
 
 	js := `
@@ -227,6 +276,11 @@ func (d *dummyUI) ApproveNewAccount(request *core.NewAccountRequest) (core.NewAc
 	return core.NewAccountResponse{}, core.ErrRequestDenied
 }
 
+func (d *dummyUI) ApproveSafeProposal(request *core.SafeProposalRequest) (core.SafeProposalResponse, error) {
+	d.calls = append(d.calls, "ApproveSafeProposal")
+	return core.SafeProposalResponse{}, core.ErrRequestDenied
+}
+
 func (d *dummyUI) ShowError(message string) {
 	d.calls = append(d.calls, "ShowError")
 }
@@ -531,6 +585,11 @@ func (d *dontCallMe) ApproveNewAccount(request *core.NewAccountRequest) (core.Ne
 	return core.NewAccountResponse{}, core.ErrRequestDenied
 }
 
+func (d *dontCallMe) ApproveSafeProposal(request *core.SafeProposalRequest) (core.SafeProposalResponse, error) {
+	d.t.Fatalf("Did not expect next-handler to be called")
+	return core.SafeProposalResponse{}, core.ErrRequestDenied
+}
+
 func (d *dontCallMe) ShowError(message string) {
 	d.t.Fatalf("Did not expect next-handler to be called")
 }
@@ -61,27 +61,51 @@ func TestState(t *testing.T) {
 		legacyStateTestDir,
 	} {
 		st.walk(t, dir, func(t *testing.T, name string, test *StateTest) {
-			for _, subtest := range test.Subtests() {
-				subtest := subtest
-				key := fmt.Sprintf("%s/%d", subtest.Fork, subtest.Index)
-				name := name + "/" + key
-
-				t.Run(key+"/trie", func(t *testing.T) {
-					withTrace(t, test.gasLimit(subtest), func(vmconfig vm.Config) error {
-						_, _, err := test.Run(subtest, vmconfig, false)
-						return st.checkFailure(t, name+"/trie", err)
-					})
-				})
-				t.Run(key+"/snap", func(t *testing.T) {
-					withTrace(t, test.gasLimit(subtest), func(vmconfig vm.Config) error {
-						snaps, statedb, err := test.Run(subtest, vmconfig, true)
-						if _, err := snaps.Journal(statedb.IntermediateRoot(false)); err != nil {
-							return err
-						}
-						return st.checkFailure(t, name+"/snap", err)
-					})
-				})
-			}
+			execStateTest(t, st, name, test)
+		})
+	}
+}
+
+// Tests the experimental EVM object format, which the tests of the submodule
+// don't cover.
+func TestEOFState(t *testing.T) {
+	t.Parallel()
+
+	st := new(testMatcher)
+	st.walk(t, eofStateTestDir, func(t *testing.T, name string, test *StateTest) {
+		execStateTest(t, st, name, test)
+	})
+}
+
+// execStateTest runs all subtests of a state test, on the trie, with the
+// snapshotter and with the interpreter executing code by basic blocks.
+func execStateTest(t *testing.T, st *testMatcher, name string, test *StateTest) {
+	for _, subtest := range test.Subtests() {
+		subtest := subtest
+		key := fmt.Sprintf("%s/%d", subtest.Fork, subtest.Index)
+		name := name + "/" + key
+
+		t.Run(key+"/trie", func(t *testing.T) {
+			withTrace(t, test.gasLimit(subtest), func(vmconfig vm.Config) error {
+				_, _, err := test.Run(subtest, vmconfig, false)
+				return st.checkFailure(t, name+"/trie", err)
+			})
+		})
+		t.Run(key+"/snap", func(t *testing.T) {
+			withTrace(t, test.gasLimit(subtest), func(vmconfig vm.Config) error {
+				snaps, statedb, err := test.Run(subtest, vmconfig, true)
+				if _, err := snaps.Journal(statedb.IntermediateRoot(false)); err != nil {
+					return err
+				}
+				return st.checkFailure(t, name+"/snap", err)
+			})
+		})
+		t.Run(key+"/blocks", func(t *testing.T) {
+			withTrace(t, test.gasLimit(subtest), func(vmconfig vm.Config) error {
+				vmconfig.BlockAnalysis = true
+				_, _, err := test.Run(subtest, vmconfig, false)
+				return st.checkFailure(t, name+"/blocks", err)
+			})
 		})
 	}
 }
@@ -62,6 +62,9 @@ type stJSON struct {
 	Tx   stTransaction            `json:"transaction"`
 	Out  hexutil.Bytes            `json:"out"`
 	Post map[string][]stPostState `json:"post"`
+
+	// Custom precompiles of private networks, activated on top of the fork
+	Precompiles map[common.Address]*params.PrecompileConfig `json:"precompiles,omitempty"`
 }
 
 type stPostState struct {
@@ -174,6 +177,14 @@ func (t *StateTest) RunNoVerify(subtest StateSubtest, vmconfig vm.Config, snapsh
 		return nil, nil, common.Hash{}, UnsupportedForkError{subtest.Fork}
 	}
 	vmconfig.ExtraEips = eips
+	if len(t.json.Precompiles) > 0 {
+		cpy := *config
+		cpy.Precompiles = t.json.Precompiles
+		if err := vm.CheckPrecompiles(&cpy); err != nil {
+			return nil, nil, common.Hash{}, err
+		}
+		config = &cpy
+	}
 	block := t.genesis(config).ToBlock(nil)
 	snaps, statedb := MakePreState(rawdb.NewMemoryDatabase(), t.json.Pre, snapshotter)
 